@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yourusername/did-char/pkg/did"
+	"github.com/yourusername/did-char/pkg/keys"
+	"github.com/yourusername/did-char/pkg/signing"
+)
+
+// runCreate implements `did-char create`
+func runCreate(args []string) error {
+	fs := newFlagSet("create", "create [options]")
+	var services stringList
+	fs.Var(&services, "service", "Service endpoint JSON (or path to a JSON file); repeatable")
+	algorithm := fs.String("algorithm", string(signing.AlgES256), "Key algorithm: ES256, ES256K, EdDSA, BLS or BIP340")
+	keyFile := fs.String("key-file", "", "Custom path for key file (default: derived from DID)")
+	verbose := fs.Bool("verbose", false, "Show detailed operation information")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 0); err != nil {
+		return err
+	}
+
+	req := &did.CreateDIDRequest{
+		Algorithm:   signing.SignatureAlgorithm(*algorithm),
+		KeyFilePath: *keyFile,
+	}
+	for _, arg := range services {
+		svc, err := parseServiceArg(arg)
+		if err != nil {
+			return withCode(exitValidation, err)
+		}
+		req.Services = append(req.Services, svc)
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+	req.Batcher = e.newBatcher()
+
+	verbosef(*verbose, "Generating %s update and recovery keys...\n", req.Algorithm)
+	verbosef(*verbose, "Submitting CREATE operation to CHAR node %s:%d...\n", e.cfg.CHAR.RPCHost, e.cfg.CHAR.RPCPort)
+
+	result, err := did.CreateDID(req, e.cfg, e.store, e.charClient)
+	if err != nil {
+		return withCode(exitRPC, err)
+	}
+
+	fmt.Printf("Created DID: %s\n", result.DID)
+	fmt.Printf("Long-form DID: %s\n", result.LongFormDID)
+	fmt.Printf("Ballot: %d\n", result.BallotNumber)
+	fmt.Printf("Keys saved to: %s\n", keyFileLocation(*keyFile, result.DID, e.cfg.DataDir.KeysDir))
+
+	if *verbose {
+		fmt.Printf("Update commitment: %s\n", result.KeyFile.NextUpdateCommitment)
+		fmt.Printf("Recovery commitment: %s\n", result.KeyFile.NextRecoveryCommitment)
+		fmt.Println("Document:")
+		if err := printJSON(result.Document); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseServiceArg parses a service endpoint given either as inline JSON or as a path to a JSON file
+func parseServiceArg(arg string) (did.Service, error) {
+	data := []byte(arg)
+	if !strings.HasPrefix(strings.TrimSpace(arg), "{") {
+		fileData, err := os.ReadFile(arg)
+		if err != nil {
+			return did.Service{}, fmt.Errorf("failed to read service file: %w", err)
+		}
+		data = fileData
+	}
+
+	var svc did.Service
+	if err := json.Unmarshal(data, &svc); err != nil {
+		return did.Service{}, fmt.Errorf("invalid service JSON: %w", err)
+	}
+	if svc.Type == "" || svc.ServiceEndpoint == "" {
+		return did.Service{}, fmt.Errorf("service requires type and serviceEndpoint")
+	}
+	svc.ID = fragmentID(svc.ID)
+
+	return svc, nil
+}
+
+// fragmentID normalizes a document-relative ID to the "#id" form used in DID documents
+func fragmentID(id string) string {
+	if id == "" || strings.HasPrefix(id, "#") {
+		return id
+	}
+	return "#" + id
+}
+
+// keyFileLocation returns where the key file for a DID lives
+func keyFileLocation(override, didStr, keysDir string) string {
+	if override != "" {
+		return override
+	}
+	return keys.GetKeyFilePath(didStr, keysDir)
+}
+
+// printJSON prints a value as indented JSON to stdout
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal output: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
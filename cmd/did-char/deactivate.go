@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yourusername/did-char/pkg/did"
+)
+
+// runDeactivate implements `did-char deactivate <did>`
+func runDeactivate(args []string) error {
+	fs := newFlagSet("deactivate", "deactivate <did> [options]")
+	keyFile := fs.String("key-file", "", "Override key file path (default: derived from DID)")
+	confirm := fs.Bool("confirm", false, "Skip confirmation prompt")
+	verbose := fs.Bool("verbose", false, "Show detailed operation information")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 1); err != nil {
+		return err
+	}
+
+	req := &did.DeactivateDIDRequest{
+		DID:         positional[0],
+		KeyFilePath: *keyFile,
+	}
+	if _, err := did.ParseDID(req.DID); err != nil {
+		return withCode(exitValidation, err)
+	}
+
+	if !*confirm {
+		fmt.Println("WARNING: This will permanently deactivate the DID. This cannot be undone.")
+		fmt.Print("Are you sure? (yes/no): ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(answer)) != "yes" {
+			fmt.Println("Aborted")
+			return nil
+		}
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+	req.Batcher = e.newBatcher()
+
+	verbosef(*verbose, "Deactivating %s with recovery key\n", req.DID)
+
+	if err := did.DeactivateDID(req, e.cfg, e.store, e.charClient); err != nil {
+		return withCode(exitRPC, err)
+	}
+
+	record, err := e.store.GetDID(req.DID)
+	if err != nil {
+		return withCode(exitDatabase, fmt.Errorf("failed to load DID: %w", err))
+	}
+
+	fmt.Println("DID deactivated permanently")
+	if record != nil {
+		fmt.Printf("Ballot: %d\n", record.LastOperationBallot)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yourusername/did-char/pkg/did"
+)
+
+// runDereference implements `did-char dereference <did-url>`
+func runDereference(args []string) error {
+	fs := newFlagSet("dereference", "dereference <did-url> [options]")
+	doSync := fs.Bool("sync", false, "Force sync from CHAR before dereferencing")
+	format := fs.String("format", "json", "Output format: json (the selected resource) or w3c (DID URL Dereferencing Result)")
+	verbose := fs.Bool("verbose", false, "Show sync progress")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 1); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "w3c" {
+		return withCode(exitValidation, fmt.Errorf("unsupported format: %s", *format))
+	}
+
+	didURL := positional[0]
+	if _, err := did.ParseDIDURL(didURL); err != nil {
+		return withCode(exitValidation, err)
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	if *doSync {
+		if err := syncToTip(e, *verbose); err != nil {
+			return err
+		}
+	}
+
+	result, err := did.NewResolver(e.store).Dereference(didURL)
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+	if meta := result.DereferencingMetadata; meta.Error != "" {
+		return fmt.Errorf("%s: %s", meta.Error, meta.ErrorMessage)
+	}
+	if *format == "w3c" {
+		return printJSON(result)
+	}
+	if endpoint, ok := result.ContentStream.(string); ok {
+		fmt.Println(endpoint)
+		return nil
+	}
+	return printJSON(result.ContentStream)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yourusername/did-char/pkg/did"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// runHistory implements `did-char history <did>`
+func runHistory(args []string) error {
+	fs := newFlagSet("history", "history <did> [options]")
+	format := fs.String("format", "table", "Output format: json or table")
+	limit := fs.Int("limit", 0, "Show only last N operations (0 = all)")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 1); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "table" {
+		return withCode(exitValidation, fmt.Errorf("unsupported format: %s", *format))
+	}
+
+	didStr := positional[0]
+	if _, err := did.ParseDID(didStr); err != nil {
+		return withCode(exitValidation, err)
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	ops, err := e.store.GetOperations(didStr)
+	if err != nil {
+		return withCode(exitDatabase, fmt.Errorf("failed to load operations: %w", err))
+	}
+	if len(ops) == 0 {
+		return fmt.Errorf("no operations found for %s", didStr)
+	}
+	if *limit > 0 && len(ops) > *limit {
+		ops = ops[len(ops)-*limit:]
+	}
+
+	if *format == "json" {
+		return printJSON(historyEntries(ops))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "Ballot\t Operation\t Timestamp\t Changes")
+	for _, op := range ops {
+		fmt.Fprintf(w, "%d\t %s\t %s\t %s\n",
+			op.BallotNumber,
+			strings.ToUpper(op.OperationType),
+			op.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			describeOperation(op),
+		)
+	}
+	return w.Flush()
+}
+
+// describeOperation summarizes the document changes made by an operation
+func describeOperation(op *storage.OperationRecord) string {
+	switch op.OperationType {
+	case did.OperationTypeCreate:
+		return "Initial creation"
+	case did.OperationTypeDeactivate:
+		return "Deactivated"
+	case did.OperationTypeRecover:
+		return "Recovered with new document"
+	case did.OperationTypeUpdate:
+		var updateOp did.UpdateOperation
+		if err := json.Unmarshal([]byte(op.OperationData), &updateOp); err != nil || updateOp.Delta == nil {
+			return "Updated"
+		}
+		return describePatches(updateOp.Delta.Patches)
+	default:
+		return ""
+	}
+}
+
+// describePatches renders patches as a short human-readable summary
+func describePatches(patches []did.Patch) string {
+	var changes []string
+	for _, patch := range patches {
+		switch patch.Action {
+		case did.PatchActionAddPublicKeys:
+			for _, pk := range patch.PublicKeys {
+				changes = append(changes, "Added "+strings.TrimPrefix(pk.ID, "#"))
+			}
+		case did.PatchActionRemovePublicKeys:
+			for _, id := range patch.PublicKeyIDs {
+				changes = append(changes, "Removed "+strings.TrimPrefix(id, "#"))
+			}
+		case did.PatchActionAddServices:
+			for _, svc := range patch.Services {
+				changes = append(changes, "Added service "+strings.TrimPrefix(svc.ID, "#"))
+			}
+		case did.PatchActionRemoveServices:
+			for _, id := range patch.ServiceIDs {
+				changes = append(changes, "Removed service "+strings.TrimPrefix(id, "#"))
+			}
+		}
+	}
+	if len(changes) == 0 {
+		return "Key rotation only"
+	}
+	return strings.Join(changes, ", ")
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/yourusername/did-char/pkg/cas"
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/config"
+	"github.com/yourusername/did-char/pkg/did"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// version is the CLI version, overridable at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// Exit codes (see CLI.md)
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitConfig     = 2
+	exitRPC        = 3
+	exitDatabase   = 4
+	exitKeyFile    = 5
+	exitValidation = 6
+)
+
+// command describes a CLI subcommand
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"create":           {"Create a new DID", runCreate},
+	"update":           {"Update an existing DID document", runUpdate},
+	"deactivate":       {"Permanently deactivate a DID", runDeactivate},
+	"recover":          {"Replace a DID document and rotate its keys", runRecover},
+	"resolve":          {"Resolve a DID to its current state", runResolve},
+	"history":          {"Show operation history for a DID", runHistory},
+	"dereference":      {"Dereference a DID URL to a key, service or service endpoint", runDereference},
+	"rejected":         {"List decided operations that failed validation", runRejected},
+	"sync":             {"Sync DID operations from CHAR", runSync},
+	"node":             {"Continuously follow CHAR and apply new operations", runNode},
+	"serve":            {"Serve DID resolution over HTTP (Universal Resolver driver)", runServe},
+	"status":           {"Show CLI and database status", runStatus},
+	"generate-key":     {"Generate a random JWK key", runGenerateKey},
+	"generate-service": {"Generate a random service endpoint", runGenerateService},
+}
+
+// exitCodeError carries a process exit code alongside an error
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+func (e *exitCodeError) Unwrap() error { return e.err }
+
+// withCode wraps err so main exits with the given code
+func withCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitCodeError{code: code, err: err}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run dispatches to a subcommand and returns the process exit code
+func run(args []string) int {
+	global := flag.NewFlagSet("did-char", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+	global.StringVar(&configPath, "config", defaultConfigPath(), "Path to config file")
+	showVersion := global.Bool("version", false, "Show version information")
+
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage(os.Stdout)
+			return exitOK
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printUsage(os.Stderr)
+		return exitValidation
+	}
+
+	if *showVersion {
+		fmt.Printf("did-char %s\n", version)
+		return exitOK
+	}
+
+	rest := global.Args()
+	if len(rest) == 0 {
+		printUsage(os.Stderr)
+		return exitValidation
+	}
+
+	name := rest[0]
+	if name == "help" {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+	if name == "version" {
+		fmt.Printf("did-char %s\n", version)
+		return exitOK
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown command %q\n", name)
+		printUsage(os.Stderr)
+		return exitValidation
+	}
+
+	if err := cmd.run(rest[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var codeErr *exitCodeError
+		if errors.As(err, &codeErr) {
+			return codeErr.code
+		}
+		return exitError
+	}
+
+	return exitOK
+}
+
+// printUsage prints the top-level usage
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: did-char [--config <path>] <command> [options]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-18s %s\n", name, commands[name].summary)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'did-char <command> --help' for command options.")
+}
+
+// configPath is the config file path shared by all commands
+var configPath string
+
+// defaultConfigPath returns ./config.yaml if it exists
+func defaultConfigPath() string {
+	if _, err := os.Stat("config.yaml"); err == nil {
+		return "config.yaml"
+	}
+	return ""
+}
+
+// newFlagSet creates a flag set for a subcommand with the global options registered
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&configPath, "config", configPath, "Path to config file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: did-char %s\n\nOptions:\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags that may appear before, between or after positional arguments
+// and returns the positional arguments in order
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, withCode(exitValidation, err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// requireArgs checks the number of positional arguments
+func requireArgs(fs *flag.FlagSet, args []string, n int) error {
+	if len(args) != n {
+		fs.Usage()
+		return withCode(exitValidation, fmt.Errorf("expected %d argument(s), got %d", n, len(args)))
+	}
+	return nil
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// env bundles the configuration, store and CHAR client used by commands
+type env struct {
+	cfg        *config.Config
+	store      *storage.Store
+	charClient *char.Client
+	content    *cas.FileStore // Local content store for anchored batches
+}
+
+// openEnv loads configuration and opens the database
+func openEnv() (*env, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, withCode(exitConfig, fmt.Errorf("failed to load config: %w", err))
+	}
+
+	content, err := cas.NewFileStore(cfg.Content.Dir)
+	if err != nil {
+		return nil, withCode(exitConfig, err)
+	}
+
+	store, err := storage.NewStore(cfg.Database.Path)
+	if err != nil {
+		return nil, withCode(exitDatabase, fmt.Errorf("failed to open database: %w", err))
+	}
+
+	return &env{
+		cfg:        cfg,
+		store:      store,
+		charClient: char.NewClient(&cfg.CHAR),
+		content:    content,
+	}, nil
+}
+
+// newProcessor creates a processor enforcing the configured payload limits that fetches
+// anchored batches from the local content store, then from the configured HTTP content store
+func (e *env) newProcessor() *did.Processor {
+	processor := did.NewProcessor(e.store, e.charClient, e.cfg.CHAR.AppPreimage)
+	processor.SetLimits(did.PayloadLimits(e.cfg))
+	processor.SetContentFetcher(e.contentFetcher())
+	return processor
+}
+
+// newBatcher creates a batcher that fetches other writers' anchored batches like newProcessor
+func (e *env) newBatcher() *did.Batcher {
+	batcher := did.NewBatcher(e.cfg, e.store, e.charClient)
+	batcher.SetContentFetcher(e.contentFetcher())
+	return batcher
+}
+
+// contentFetcher returns where anchored batches are fetched from: the local content
+// store, then the configured fetch URL
+func (e *env) contentFetcher() cas.Fetcher {
+	fetchers := cas.Chain{e.content}
+	if e.cfg.Content.FetchURL != "" {
+		fetchers = append(fetchers, cas.NewHTTPFetcher(e.cfg.Content.FetchURL, nil))
+	}
+	return fetchers
+}
+
+// Close releases the database connection
+func (e *env) Close() error {
+	return e.store.Close()
+}
+
+// verbosef prints only when verbose output is enabled
+func verbosef(verbose bool, format string, args ...interface{}) {
+	if verbose {
+		fmt.Printf(format, args...)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yourusername/did-char/pkg/did"
+)
+
+func TestParseArgsInterspersed(t *testing.T) {
+	fs := newFlagSet("test", "test")
+	var services stringList
+	fs.Var(&services, "add-service", "")
+	verbose := fs.Bool("verbose", false, "")
+
+	positional, err := parseArgs(fs, []string{"did:char:abc", "--add-service", "a.json", "--verbose", "--add-service", "b.json"})
+	if err != nil {
+		t.Fatalf("parseArgs failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(positional, []string{"did:char:abc"}) {
+		t.Errorf("positional = %v, want [did:char:abc]", positional)
+	}
+	if !reflect.DeepEqual([]string(services), []string{"a.json", "b.json"}) {
+		t.Errorf("services = %v, want [a.json b.json]", services)
+	}
+	if !*verbose {
+		t.Error("verbose should be set")
+	}
+}
+
+func TestParseArgsUnknownFlag(t *testing.T) {
+	fs := newFlagSet("test", "test")
+	fs.SetOutput(io.Discard)
+
+	_, err := parseArgs(fs, []string{"--nope"})
+	if err == nil {
+		t.Fatal("expected error for unknown flag")
+	}
+
+	codeErr, ok := err.(*exitCodeError)
+	if !ok || codeErr.code != exitValidation {
+		t.Errorf("expected validation exit code, got %v", err)
+	}
+}
+
+func TestParseServiceArgInline(t *testing.T) {
+	svc, err := parseServiceArg(`{"id":"domain","type":"LinkedDomains","serviceEndpoint":"https://example.com"}`)
+	if err != nil {
+		t.Fatalf("parseServiceArg failed: %v", err)
+	}
+
+	want := did.Service{ID: "#domain", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}
+	if svc != want {
+		t.Errorf("service = %+v, want %+v", svc, want)
+	}
+}
+
+func TestParseServiceArgFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "service.json")
+	if err := os.WriteFile(path, []byte(`{"id":"#hub","type":"IdentityHub","serviceEndpoint":"https://hub.example.com"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := parseServiceArg(path)
+	if err != nil {
+		t.Fatalf("parseServiceArg failed: %v", err)
+	}
+	if svc.ID != "#hub" || svc.Type != "IdentityHub" {
+		t.Errorf("unexpected service: %+v", svc)
+	}
+}
+
+func TestParseServiceArgInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+	}{
+		{"bad json", `{"id":`},
+		{"missing endpoint", `{"id":"x","type":"LinkedDomains"}`},
+		{"missing file", filepath.Join(t.TempDir(), "missing.json")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseServiceArg(tt.arg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestFragmentID(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"key-2", "#key-2"},
+		{"#key-2", "#key-2"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := fragmentID(tt.input); got != tt.expected {
+			t.Errorf("fragmentID(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestDescribePatches(t *testing.T) {
+	patches := []did.Patch{
+		{Action: did.PatchActionAddPublicKeys, PublicKeys: []did.PublicKey{{ID: "#key-2"}}},
+		{Action: did.PatchActionRemoveServices, ServiceIDs: []string{"#domain"}},
+	}
+
+	got := describePatches(patches)
+	want := "Added key-2, Removed service domain"
+	if got != want {
+		t.Errorf("describePatches = %q, want %q", got, want)
+	}
+
+	if got := describePatches(nil); got != "Key rotation only" {
+		t.Errorf("describePatches(nil) = %q", got)
+	}
+}
+
+func TestGenerateKeyPurpose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.jwk")
+	if err := runGenerateKey([]string{"--output", path, "--id", "agree", "--purpose", "keyagreement"}); err != nil {
+		t.Fatalf("generate-key failed: %v", err)
+	}
+
+	pk, err := loadPublicKeyFile(path, 2)
+	if err != nil {
+		t.Fatalf("loadPublicKeyFile failed: %v", err)
+	}
+	if pk.ID != "#agree" || !reflect.DeepEqual(pk.Purposes, []string{did.PurposeKeyAgreement}) {
+		t.Errorf("unexpected public key: %+v", pk)
+	}
+	if pk.PublicKeyJwk.D != "" {
+		t.Error("public key entry should not contain D")
+	}
+
+	if err := runGenerateKey([]string{"--output", path, "--purpose", "signing"}); err == nil {
+		t.Error("expected error for unknown purpose")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/did"
+)
+
+// runNode implements `did-char node`
+func runNode(args []string) error {
+	fs := newFlagSet("node", "node [options]")
+	minIdle := fs.Duration("min-idle", 500*time.Millisecond, "Poll interval once caught up with the tip")
+	maxIdle := fs.Duration("max-idle", 5*time.Second, "Maximum poll interval while no ballots are decided")
+	verbose := fs.Bool("verbose", false, "Report every sync pass")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 0); err != nil {
+		return err
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	processor := e.newProcessor()
+	start, err := processor.NextUnsyncedBallot()
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+	fmt.Printf("Following CHAR from ballot %d (Ctrl+C to stop)...\n", start)
+
+	err = processor.Follow(ctx, did.FollowOptions{
+		MinIdle: *minIdle,
+		MaxIdle: *maxIdle,
+		OnSync: func(start int, result *did.SyncResult) {
+			if result.Applied > 0 || result.Unresolvable > 0 || *verbose {
+				fmt.Printf("Synced ballots %d-%d: %d applied, %d rejected, %d unresolvable\n",
+					start, result.Next-1, result.Applied, result.Rejected, result.Unresolvable)
+			}
+		},
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return withCode(exitDatabase, err)
+	}
+
+	next, err := processor.NextUnsyncedBallot()
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+	fmt.Printf("Stopped; next ballot to sync is %d\n", next)
+
+	return nil
+}
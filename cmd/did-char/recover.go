@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yourusername/did-char/pkg/did"
+)
+
+// runRecover implements `did-char recover <did>`
+func runRecover(args []string) error {
+	fs := newFlagSet("recover", "recover <did> [options]")
+	document := fs.String("document", "", "Replacement document JSON file with publicKey and service entries (default: a single new key)")
+	keyFile := fs.String("key-file", "", "Override key file path (default: derived from DID)")
+	confirm := fs.Bool("confirm", false, "Skip confirmation prompt")
+	verbose := fs.Bool("verbose", false, "Show detailed operation information")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 1); err != nil {
+		return err
+	}
+
+	req := &did.RecoverDIDRequest{
+		DID:         positional[0],
+		KeyFilePath: *keyFile,
+	}
+	if _, err := did.ParseDID(req.DID); err != nil {
+		return withCode(exitValidation, err)
+	}
+
+	if *document != "" {
+		doc, err := loadRecoveryDocument(*document)
+		if err != nil {
+			return withCode(exitValidation, err)
+		}
+		req.Document = doc
+	}
+
+	if !*confirm {
+		fmt.Println("WARNING: This will replace the entire DID document and rotate both the update and recovery keys.")
+		fmt.Print("Are you sure? (yes/no): ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(answer)) != "yes" {
+			fmt.Println("Aborted")
+			return nil
+		}
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+	req.Batcher = e.newBatcher()
+
+	verbosef(*verbose, "Recovering %s with recovery key\n", req.DID)
+
+	result, err := did.RecoverDID(req, e.cfg, e.store, e.charClient)
+	if err != nil {
+		return withCode(exitRPC, err)
+	}
+
+	fmt.Println("DID recovered")
+	fmt.Printf("Ballot: %d\n", result.BallotNumber)
+	fmt.Printf("Keys saved to: %s\n", keyFileLocation(*keyFile, req.DID, e.cfg.DataDir.KeysDir))
+
+	if *verbose {
+		fmt.Printf("Update commitment: %s\n", result.KeyFile.NextUpdateCommitment)
+		fmt.Printf("Recovery commitment: %s\n", result.KeyFile.NextRecoveryCommitment)
+		fmt.Println("Document:")
+		if err := printJSON(result.Document); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadRecoveryDocument reads a replacement DID document from a JSON file
+func loadRecoveryDocument(path string) (*did.Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document file: %w", err)
+	}
+
+	var doc did.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid document JSON in %s: %w", path, err)
+	}
+	for i := range doc.PublicKeys {
+		doc.PublicKeys[i].ID = fragmentID(doc.PublicKeys[i].ID)
+	}
+	for i := range doc.Services {
+		doc.Services[i].ID = fragmentID(doc.Services[i].ID)
+	}
+
+	return &doc, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/did"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// defaultRejectedLimit is how many rejected operations `rejected` lists by default
+const defaultRejectedLimit = 20
+
+// rejectedEntry is the JSON form of a rejected operation
+type rejectedEntry struct {
+	Ballot    int       `json:"ballot"`
+	Index     int       `json:"index"` // Position of the operation in the ballot's payload
+	DID       string    `json:"did,omitempty"`
+	Operation string    `json:"operation,omitempty"`
+	Reason    string    `json:"reason"`
+	Payload   string    `json:"payload"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// runRejected implements `did-char rejected`
+func runRejected(args []string) error {
+	fs := newFlagSet("rejected", "rejected [options]")
+	didFilter := fs.String("did", "", "Only show rejected operations targeting this DID")
+	limit := fs.Int("limit", defaultRejectedLimit, "Show only the last N rejected operations (0 = all)")
+	format := fs.String("format", "table", "Output format: json or table")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 0); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "table" {
+		return withCode(exitValidation, fmt.Errorf("unsupported format: %s", *format))
+	}
+	if *didFilter != "" {
+		if _, err := did.ParseDID(*didFilter); err != nil {
+			return withCode(exitValidation, err)
+		}
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	records, err := e.store.GetRejectedOperations(*didFilter, *limit)
+	if err != nil {
+		return withCode(exitDatabase, fmt.Errorf("failed to load rejected operations: %w", err))
+	}
+
+	if *format == "json" {
+		return printJSON(rejectedEntries(records))
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No rejected operations")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "Ballot\t Index\t Operation\t DID\t Reason")
+	for _, r := range records {
+		fmt.Fprintf(w, "%d\t %d\t %s\t %s\t %s\n",
+			r.BallotNumber,
+			r.OperationIndex,
+			orDash(r.OperationType),
+			orDash(r.DID),
+			r.Reason,
+		)
+	}
+	return w.Flush()
+}
+
+// rejectedEntries converts rejected operation records to their JSON form
+func rejectedEntries(records []*storage.RejectedOperationRecord) []rejectedEntry {
+	entries := make([]rejectedEntry, 0, len(records))
+	for _, r := range records {
+		entries = append(entries, rejectedEntry{
+			Ballot:    r.BallotNumber,
+			Index:     r.OperationIndex,
+			DID:       r.DID,
+			Operation: r.OperationType,
+			Reason:    r.Reason,
+			Payload:   r.Payload,
+			Timestamp: r.CreatedAt,
+		})
+	}
+	return entries
+}
+
+// orDash returns s, or "-" if s is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/did"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// historyEntry is one operation in resolve/history JSON output
+type historyEntry struct {
+	Ballot    int    `json:"ballot"`
+	Operation string `json:"operation"`
+	Timestamp string `json:"timestamp"`
+}
+
+// resolveOutput is the resolve --history JSON output
+type resolveOutput struct {
+	DID       string          `json:"did"`
+	Status    string          `json:"status"`
+	VersionID int             `json:"versionId"`
+	Document  json.RawMessage `json:"document"`
+	History   []historyEntry  `json:"history"`
+}
+
+// runResolve implements `did-char resolve <did>`
+func runResolve(args []string) error {
+	fs := newFlagSet("resolve", "resolve <did> [options]")
+	doSync := fs.Bool("sync", false, "Force sync from CHAR before resolving")
+	withHistory := fs.Bool("history", false, "Include operation history")
+	format := fs.String("format", "json", "Output format: json (stored document), w3c (DID Resolution Result) or table")
+	versionID := fs.Int("version-id", -1, "Resolve the version produced by the operation on this ballot")
+	versionTime := fs.String("version-time", "", "Resolve the version current at this time (RFC3339)")
+	verbose := fs.Bool("verbose", false, "Show sync progress")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 1); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "w3c" && *format != "table" {
+		return withCode(exitValidation, fmt.Errorf("unsupported format: %s", *format))
+	}
+
+	didStr := positional[0]
+	if _, err := did.ParseDID(didStr); err != nil {
+		return withCode(exitValidation, err)
+	}
+
+	var opts did.ResolveOptions
+	if *versionID >= 0 {
+		opts.VersionID = versionID
+	}
+	if *versionTime != "" {
+		t, err := time.Parse(time.RFC3339, *versionTime)
+		if err != nil {
+			return withCode(exitValidation, fmt.Errorf("invalid --version-time: %w", err))
+		}
+		opts.VersionTime = t
+	}
+	if opts.VersionID != nil && !opts.VersionTime.IsZero() {
+		return withCode(exitValidation, fmt.Errorf("--version-id and --version-time cannot be combined"))
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	if *doSync {
+		if err := syncToTip(e, *verbose); err != nil {
+			return err
+		}
+	}
+
+	resolver := did.NewResolver(e.store)
+
+	if *format == "w3c" {
+		result, err := resolver.ResolveResult(didStr, opts)
+		if err != nil {
+			return withCode(exitDatabase, err)
+		}
+		if result.DIDResolutionMetadata.Error != "" {
+			return fmt.Errorf("%s: %s", result.DIDResolutionMetadata.Error, result.DIDResolutionMetadata.ErrorMessage)
+		}
+		return printJSON(result)
+	}
+
+	res, err := resolver.Resolve(didStr, opts)
+	if err != nil {
+		return err
+	}
+
+	var ops []*storage.OperationRecord
+	if *withHistory || *format == "table" {
+		all, err := e.store.GetOperations(res.DID)
+		if err != nil {
+			return withCode(exitDatabase, fmt.Errorf("failed to load operations: %w", err))
+		}
+		// History as it stood at the resolved version
+		for _, op := range all {
+			if op.BallotNumber <= res.VersionID {
+				ops = append(ops, op)
+			}
+		}
+	}
+
+	if *format == "table" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "DID:\t%s\n", res.DID)
+		fmt.Fprintf(w, "Status:\t%s\n", res.Status)
+		fmt.Fprintf(w, "Created at ballot:\t%d\n", res.CreatedAtBallot)
+		fmt.Fprintf(w, "Last operation ballot:\t%d\n", res.VersionID)
+		if res.NextVersionID != nil {
+			fmt.Fprintf(w, "Next operation ballot:\t%d\n", *res.NextVersionID)
+		}
+		fmt.Fprintf(w, "Operations:\t%d\n", len(ops))
+		w.Flush()
+		fmt.Println("Document:")
+		return printJSON(res.Document)
+	}
+
+	if !*withHistory {
+		return printJSON(res.Document)
+	}
+
+	return printJSON(&resolveOutput{
+		DID:       res.DID,
+		Status:    res.Status,
+		VersionID: res.VersionID,
+		Document:  res.Document,
+		History:   historyEntries(ops),
+	})
+}
+
+// historyEntries converts operation records to history output entries
+func historyEntries(ops []*storage.OperationRecord) []historyEntry {
+	entries := make([]historyEntry, 0, len(ops))
+	for _, op := range ops {
+		entries = append(entries, historyEntry{
+			Ballot:    op.BallotNumber,
+			Operation: op.OperationType,
+			Timestamp: op.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return entries
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/did"
+	"github.com/yourusername/did-char/pkg/server"
+)
+
+// runServe implements `did-char serve`
+func runServe(args []string) error {
+	fs := newFlagSet("serve", "serve [options]")
+	listen := fs.String("listen", "127.0.0.1:8080", "Address to listen on (\":8080\" for all interfaces)")
+	follow := fs.Bool("follow", true, "Follow CHAR and apply new operations while serving")
+	enableRegistrar := fs.Bool("registrar", false, "Also serve the Universal Registrar create/update/recover/deactivate endpoints")
+	serveContent := fs.Bool("content", true, "Also serve anchored batches from the local content store under /cas/")
+	minIdle := fs.Duration("min-idle", 500*time.Millisecond, "Poll interval once caught up with the tip")
+	maxIdle := fs.Duration("max-idle", 5*time.Second, "Maximum poll interval while no ballots are decided")
+	verbose := fs.Bool("verbose", false, "Report every sync pass")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 0); err != nil {
+		return err
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var registrar *server.Registrar
+	if *enableRegistrar {
+		registrar = server.NewRegistrar(e.cfg, e.store, e.charClient)
+		registrar.SetContentFetcher(e.contentFetcher())
+		if e.cfg.Registrar.Token == "" {
+			fmt.Println("No registrar token configured: serving client-managed secret mode only")
+		}
+	}
+
+	handler := server.New(did.NewResolver(e.store), registrar)
+	if *serveContent {
+		handler.ServeContent(e.content)
+	}
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 2)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- withCode(exitValidation, fmt.Errorf("failed to serve: %w", err))
+		}
+	}()
+	fmt.Printf("Serving DID resolution on %s (Ctrl+C to stop)...\n", *listen)
+
+	if *follow {
+		processor := e.newProcessor()
+		go func() {
+			err := processor.Follow(ctx, did.FollowOptions{
+				MinIdle: *minIdle,
+				MaxIdle: *maxIdle,
+				OnSync: func(start int, result *did.SyncResult) {
+					if result.Applied > 0 || result.Unresolvable > 0 || *verbose {
+						fmt.Printf("Synced ballots %d-%d: %d applied, %d rejected, %d unresolvable\n",
+							start, result.Next-1, result.Applied, result.Rejected, result.Unresolvable)
+					}
+				},
+			})
+			if err != nil && !errors.Is(err, context.Canceled) {
+				errCh <- withCode(exitDatabase, err)
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+		err = fmt.Errorf("failed to shut down server: %w", shutdownErr)
+	}
+	if err == nil {
+		fmt.Println("Stopped")
+	}
+	return err
+}
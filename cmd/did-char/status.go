@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// recentActivityLimit is how many recent operations `status` lists
+const recentActivityLimit = 5
+
+// runStatus implements `did-char status`
+func runStatus(args []string) error {
+	fs := newFlagSet("status", "status")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 0); err != nil {
+		return err
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	total, err := e.store.GetDIDCount("")
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+	active, err := e.store.GetDIDCount("active")
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+	deactivated, err := e.store.GetDIDCount("deactivated")
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+	opCount, err := e.store.GetOperationCount()
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+	rejectedCount, err := e.store.GetRejectedOperationCount()
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+	lastSynced, err := e.store.GetSyncState("last_synced_ballot")
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+	if lastSynced == "" {
+		lastSynced = "never"
+	}
+	recent, err := e.store.GetRecentOperations(recentActivityLimit)
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+
+	fmt.Println("Configuration:")
+	fmt.Printf("  CHAR Node: %s:%d\n", e.cfg.CHAR.RPCHost, e.cfg.CHAR.RPCPort)
+	fmt.Printf("  App Domain: %s\n", e.cfg.CHAR.AppDomain)
+	fmt.Printf("  Database: %s\n", e.cfg.Database.Path)
+	fmt.Printf("  Keys Dir: %s\n", e.cfg.DataDir.KeysDir)
+	fmt.Println()
+
+	fmt.Println("Database Status:")
+	fmt.Printf("  Total DIDs: %d\n", total)
+	fmt.Printf("  Active DIDs: %d\n", active)
+	fmt.Printf("  Deactivated DIDs: %d\n", deactivated)
+	fmt.Printf("  Last Synced Ballot: %s\n", lastSynced)
+	fmt.Printf("  Total Operations: %d\n", opCount)
+	fmt.Printf("  Rejected Operations: %d\n", rejectedCount)
+
+	if len(recent) > 0 {
+		fmt.Println()
+		fmt.Println("Recent Activity:")
+		for _, op := range recent {
+			fmt.Printf("  Ballot %d: %s %s (%s)\n",
+				op.BallotNumber, strings.ToUpper(op.OperationType), op.DID, timeAgo(op.CreatedAt))
+		}
+	}
+
+	return nil
+}
+
+// timeAgo renders a timestamp relative to now
+func timeAgo(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%d mins ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d hours ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d days ago", int(d.Hours()/24))
+	}
+}
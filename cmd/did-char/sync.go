@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourusername/did-char/pkg/did"
+)
+
+// runSync implements `did-char sync`
+func runSync(args []string) error {
+	fs := newFlagSet("sync", "sync [options]")
+	from := fs.Int("from", -1, "Start syncing from specific ballot number, at most the next unsynced one (default: after last synced ballot)")
+	to := fs.Int("to", -1, "Stop syncing at specific ballot number (inclusive)")
+	count := fs.Int("count", 0, "Maximum number of ballots to sync (default: sync to the current tip)")
+	verbose := fs.Bool("verbose", false, "Show the outcome of each ballot")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 0); err != nil {
+		return err
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+
+	processor := e.newProcessor()
+	next, err := processor.NextUnsyncedBallot()
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+
+	start := *from
+	if start < 0 {
+		start = next
+	} else if start > next {
+		// Applying later ballots first would make this node's state differ from everyone else's
+		return withCode(exitValidation, fmt.Errorf("--from %d would skip ballots %d to %d, which are not synced yet", start, next, start-1))
+	}
+
+	n := *count
+	if *to >= 0 {
+		if *to < start {
+			return withCode(exitValidation, fmt.Errorf("--to %d is before start ballot %d", *to, start))
+		}
+		n = *to - start + 1
+	}
+
+	return syncBallots(processor, start, n, *verbose)
+}
+
+// syncToTip syncs the ballots decided since the last sync, for commands with --sync
+func syncToTip(e *env, verbose bool) error {
+	processor := e.newProcessor()
+	start, err := processor.NextUnsyncedBallot()
+	if err != nil {
+		return withCode(exitDatabase, err)
+	}
+	return syncBallots(processor, start, 0, verbose)
+}
+
+// syncBallots processes up to count decided ballots starting at start (count <= 0 syncs to the tip)
+// and reports progress
+func syncBallots(processor *did.Processor, start, count int, verbose bool) error {
+	if count > 0 {
+		fmt.Printf("Syncing from ballot %d to %d...\n", start, start+count-1)
+	} else {
+		fmt.Printf("Syncing from ballot %d to tip...\n", start)
+	}
+
+	var result *did.SyncResult
+	var err error
+	if verbose {
+		result, err = syncEachBallot(processor, start, count)
+	} else {
+		result, err = processor.SyncFromBallot(context.Background(), start, count)
+	}
+	if err != nil {
+		return withCode(exitRPC, err)
+	}
+
+	fmt.Printf("Processed %d ballots: %d with operations applied, %d rejected\n", result.Ballots, result.Applied, result.Rejected)
+	if result.Blocked {
+		fmt.Printf("Stopped at ballot %d: its anchored batch is not available yet; sync again once it is\n", result.Next)
+		return nil
+	}
+	if result.AtTip {
+		fmt.Printf("Synced to tip: ballot %d is not decided yet\n", result.Next)
+	} else {
+		fmt.Printf("Synced through ballot %d\n", result.Next-1)
+	}
+
+	return nil
+}
+
+// syncEachBallot syncs like SyncFromBallot one ballot at a time, printing the outcome of each
+func syncEachBallot(processor *did.Processor, start, count int) (*did.SyncResult, error) {
+	total := &did.SyncResult{Next: start}
+	for count <= 0 || total.Ballots < count {
+		step, err := processor.SyncFromBallot(context.Background(), total.Next, 1)
+		if err != nil {
+			return total, err
+		}
+		total.Applied += step.Applied
+		total.Rejected += step.Rejected
+		total.Unresolvable += step.Unresolvable
+		total.AtTip, total.Blocked = step.AtTip, step.Blocked
+		if step.Ballots == 0 {
+			break
+		}
+
+		switch {
+		case step.Applied > 0:
+			fmt.Printf("Ballot %d: applied\n", total.Next)
+		case step.Rejected > 0:
+			fmt.Printf("Ballot %d: rejected\n", total.Next)
+		default:
+			fmt.Printf("Ballot %d: no DID operations\n", total.Next)
+		}
+		total.Ballots++
+		total.Next = step.Next
+	}
+	return total, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/yourusername/did-char/pkg/did"
+	"github.com/yourusername/did-char/pkg/keys"
+)
+
+// runUpdate implements `did-char update <did>`
+func runUpdate(args []string) error {
+	fs := newFlagSet("update", "update <did> [options]")
+	var addKeys, removeKeys, addServices, removeServices stringList
+	fs.Var(&addKeys, "add-public-key", "Add a public key from a JWK file; repeatable")
+	fs.Var(&removeKeys, "remove-public-key", "Remove a public key by ID; repeatable")
+	fs.Var(&addServices, "add-service", "Add a service endpoint JSON (or path to a JSON file); repeatable")
+	fs.Var(&removeServices, "remove-service", "Remove a service by ID; repeatable")
+	keyFile := fs.String("key-file", "", "Override key file path (default: derived from DID)")
+	verbose := fs.Bool("verbose", false, "Show detailed operation information")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := requireArgs(fs, positional, 1); err != nil {
+		return err
+	}
+
+	req := &did.UpdateDIDRequest{
+		DID:         positional[0],
+		KeyFilePath: *keyFile,
+	}
+	if _, err := did.ParseDID(req.DID); err != nil {
+		return withCode(exitValidation, err)
+	}
+
+	for i, path := range addKeys {
+		pk, err := loadPublicKeyFile(path, i+2)
+		if err != nil {
+			return withCode(exitValidation, err)
+		}
+		req.AddPublicKeys = append(req.AddPublicKeys, pk)
+	}
+	for _, id := range removeKeys {
+		req.RemovePublicKeys = append(req.RemovePublicKeys, fragmentID(id))
+	}
+	for _, arg := range addServices {
+		svc, err := parseServiceArg(arg)
+		if err != nil {
+			return withCode(exitValidation, err)
+		}
+		req.AddServices = append(req.AddServices, svc)
+	}
+	for _, id := range removeServices {
+		req.RemoveServices = append(req.RemoveServices, fragmentID(id))
+	}
+
+	if len(req.AddPublicKeys)+len(req.RemovePublicKeys)+len(req.AddServices)+len(req.RemoveServices) == 0 {
+		fs.Usage()
+		return withCode(exitValidation, fmt.Errorf("no changes requested"))
+	}
+
+	e, err := openEnv()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+	req.Batcher = e.newBatcher()
+
+	verbosef(*verbose, "Updating %s\n", req.DID)
+	for _, pk := range req.AddPublicKeys {
+		verbosef(*verbose, "  + public key %s (%s)\n", pk.ID, pk.Type)
+	}
+	for _, id := range req.RemovePublicKeys {
+		verbosef(*verbose, "  - public key %s\n", id)
+	}
+	for _, svc := range req.AddServices {
+		verbosef(*verbose, "  + service %s (%s) -> %s\n", svc.ID, svc.Type, svc.ServiceEndpoint)
+	}
+	for _, id := range req.RemoveServices {
+		verbosef(*verbose, "  - service %s\n", id)
+	}
+
+	if err := did.UpdateDID(req, e.cfg, e.store, e.charClient); err != nil {
+		return withCode(exitRPC, err)
+	}
+
+	record, err := e.store.GetDID(req.DID)
+	if err != nil {
+		return withCode(exitDatabase, fmt.Errorf("failed to load DID: %w", err))
+	}
+
+	fmt.Printf("Updated DID: %s\n", req.DID)
+	if record != nil {
+		fmt.Printf("Ballot: %d\n", record.LastOperationBallot)
+	}
+
+	return nil
+}
+
+// loadPublicKeyFile reads a JWK file and builds a public key entry for the document
+// Keys without an ID are numbered after the initial key-1; the purposes written by
+// generate-key, if any, are kept
+func loadPublicKeyFile(path string, index int) (did.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return did.PublicKey{}, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var file struct {
+		keys.JWK
+		Purposes []string `json:"purposes"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return did.PublicKey{}, fmt.Errorf("invalid JWK in %s: %w", path, err)
+	}
+
+	id := file.ID
+	if id == "" {
+		id = fmt.Sprintf("key-%d", index)
+	}
+
+	pk, err := did.NewPublicKeyFromJWK(fragmentID(id), &file.JWK)
+	if err != nil {
+		return did.PublicKey{}, err
+	}
+	pk.Purposes = file.Purposes
+	return pk, nil
+}
@@ -6,11 +6,11 @@ toolchain go1.24.3
 
 require (
 	github.com/cloudflare/circl v1.5.0
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.4.1
 	github.com/go-jose/go-jose/v4 v4.0.4
+	github.com/klauspost/compress v1.18.0
 	github.com/mattn/go-sqlite3 v1.14.32
+	golang.org/x/crypto v0.25.0
 )
 
-require (
-	golang.org/x/crypto v0.25.0 // indirect
-	golang.org/x/sys v0.22.0 // indirect
-)
+require golang.org/x/sys v0.22.0 // indirect
@@ -0,0 +1,278 @@
+// Package canonical serializes JSON with the RFC 8785 JSON Canonicalization Scheme (JCS)
+//
+// Every did:char hash over JSON (DID suffixes, key reveals and commitments, delta
+// hashes) is computed over JCS output, so clients in any language that implement
+// RFC 8785 compute the same hashes.
+package canonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Scheme is how a value is serialized before it is hashed
+type Scheme int
+
+const (
+	// Legacy is encoding/json output of the Go value: member order follows the
+	// struct fields. It is only used to check operations anchored in payloads
+	// that predate JCS.
+	Legacy Scheme = iota
+
+	// JCS is RFC 8785 canonical JSON
+	JCS
+)
+
+// String returns the scheme name
+func (s Scheme) String() string {
+	switch s {
+	case Legacy:
+		return "legacy"
+	case JCS:
+		return "JCS"
+	default:
+		return fmt.Sprintf("Scheme(%d)", int(s))
+	}
+}
+
+// Marshal serializes v with the scheme
+func (s Scheme) Marshal(v interface{}) ([]byte, error) {
+	if s == Legacy {
+		return json.Marshal(v)
+	}
+	return Marshal(v)
+}
+
+// Transform returns the JSON text data as it is hashed under the scheme
+//
+// Under Legacy the hash is over the bytes as they are, since writers hashed the
+// very encoding/json output they sent.
+func (s Scheme) Transform(data []byte) ([]byte, error) {
+	if s == Legacy {
+		return data, nil
+	}
+	return Transform(data)
+}
+
+// Marshal returns the JCS serialization of v
+//
+// v is first marshaled with encoding/json, so struct tags apply as usual; the
+// result is then canonicalized.
+func Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Transform(data)
+}
+
+// Transform returns the JCS serialization of the JSON text data
+//
+// Objects with duplicate member names, invalid UTF-8 and numbers that are not
+// finite IEEE 754 doubles are rejected, as RFC 8785 requires I-JSON input.
+func Transform(data []byte) ([]byte, error) {
+	if !utf8.Valid(data) {
+		return nil, errors.New("canonical: invalid UTF-8")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := transformValue(dec, &buf); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("canonical: trailing data after JSON value")
+	}
+	return buf.Bytes(), nil
+}
+
+// transformValue reads one JSON value from dec and writes its canonical form
+func transformValue(dec *json.Decoder, buf *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("canonical: %w", err)
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			return transformObject(dec, buf)
+		case '[':
+			return transformArray(dec, buf)
+		default:
+			return fmt.Errorf("canonical: unexpected %s", v)
+		}
+	case string:
+		writeString(buf, v)
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return fmt.Errorf("canonical: number %s: %w", v, err)
+		}
+		s, err := FormatNumber(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case bool:
+		if v {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case nil:
+		buf.WriteString("null")
+	default:
+		return fmt.Errorf("canonical: unexpected token %v", tok)
+	}
+	return nil
+}
+
+// transformObject writes an object whose opening brace has been read, members sorted by name
+func transformObject(dec *json.Decoder, buf *bytes.Buffer) error {
+	type member struct {
+		name  string
+		key   []uint16 // name as UTF-16 code units, the sort key
+		value []byte
+	}
+
+	var members []member
+	seen := make(map[string]bool)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("canonical: %w", err)
+		}
+		name, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("canonical: unexpected object key %v", tok)
+		}
+		if seen[name] {
+			return fmt.Errorf("canonical: duplicate member %q", name)
+		}
+		seen[name] = true
+
+		var value bytes.Buffer
+		if err := transformValue(dec, &value); err != nil {
+			return err
+		}
+		members = append(members, member{name: name, key: utf16.Encode([]rune(name)), value: value.Bytes()})
+	}
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("canonical: %w", err)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return lessUTF16(members[i].key, members[j].key)
+	})
+
+	buf.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeString(buf, m.name)
+		buf.WriteByte(':')
+		buf.Write(m.value)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// transformArray writes an array whose opening bracket has been read, keeping element order
+func transformArray(dec *json.Decoder, buf *bytes.Buffer) error {
+	buf.WriteByte('[')
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := transformValue(dec, buf); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("canonical: %w", err)
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// lessUTF16 compares strings by their UTF-16 code units, as RFC 8785 sorts member names
+func lessUTF16(a, b []uint16) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// writeString writes s as a JSON string, escaping only what ECMAScript JSON.stringify escapes
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// FormatNumber serializes a number as ECMAScript Number.prototype.toString does (RFC 8785 section 3.2.2.3)
+func FormatNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("canonical: %v is not a valid JSON number", f)
+	}
+	if f == 0 {
+		return "0", nil // Including -0
+	}
+
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+
+	format := byte('e')
+	if f >= 1e-6 && f < 1e21 {
+		format = 'f'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+
+	// Go writes at least two exponent digits ("1e+07"); ECMAScript writes "1e+7"
+	if i := strings.IndexByte(s, 'e'); i > 0 && s[i+2] == '0' {
+		s = s[:i+2] + s[i+3:]
+	}
+	return sign + s, nil
+}
@@ -0,0 +1,136 @@
+package canonical
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// TestVectors checks the shared test vectors, which other implementations can use too
+func TestVectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/vectors.json")
+	if err != nil {
+		t.Fatalf("failed to read vectors: %v", err)
+	}
+	var vectors []struct {
+		Name   string `json:"name"`
+		Input  string `json:"input"`
+		Output string `json:"output"`
+	}
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("failed to parse vectors: %v", err)
+	}
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			got, err := Transform([]byte(v.Input))
+			if err != nil {
+				t.Fatalf("Transform failed: %v", err)
+			}
+			if string(got) != v.Output {
+				t.Errorf("Transform =\n%s\nwant\n%s", got, v.Output)
+			}
+		})
+	}
+}
+
+// TestFormatNumber checks the IEEE 754 samples of RFC 8785 appendix B
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		bits string
+		want string
+	}{
+		{"0000000000000000", "0"},
+		{"8000000000000000", "0"},
+		{"0000000000000001", "5e-324"},
+		{"8000000000000001", "-5e-324"},
+		{"7fefffffffffffff", "1.7976931348623157e+308"},
+		{"ffefffffffffffff", "-1.7976931348623157e+308"},
+		{"4340000000000000", "9007199254740992"},
+		{"c340000000000000", "-9007199254740992"},
+		{"4430000000000000", "295147905179352830000"},
+		{"44b52d02c7e14af5", "9.999999999999997e+22"},
+		{"44b52d02c7e14af6", "1e+23"},
+		{"44b52d02c7e14af7", "1.0000000000000001e+23"},
+		{"444b1ae4d6e2ef4e", "999999999999999700000"},
+		{"444b1ae4d6e2ef4f", "999999999999999900000"},
+		{"444b1ae4d6e2ef50", "1e+21"},
+		{"3eb0c6f7a0b5ed8c", "9.999999999999997e-7"},
+		{"3eb0c6f7a0b5ed8d", "0.000001"},
+		{"41b3de4355555553", "333333333.3333332"},
+		{"41b3de4355555554", "333333333.33333325"},
+		{"41b3de4355555555", "333333333.3333333"},
+		{"41b3de4355555556", "333333333.3333334"},
+		{"41b3de4355555557", "333333333.33333343"},
+		{"becbf647612f3696", "-0.0000033333333333333333"},
+		{"43143ff3c1cb0959", "1424953923781206.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bits, func(t *testing.T) {
+			bits, _ := strconv.ParseUint(tt.bits, 16, 64)
+			got, err := FormatNumber(math.Float64frombits(bits))
+			if err != nil {
+				t.Fatalf("FormatNumber failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FormatNumber = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := FormatNumber(f); err == nil {
+			t.Errorf("expected error for %v", f)
+		}
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"duplicate member", `{"a":1,"a":2}`},
+		{"trailing data", `{} {}`},
+		{"unterminated", `{"a":[1,2}`},
+		{"number out of range", `1e400`},
+		{"invalid UTF-8", "\"\xff\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Transform([]byte(tt.input)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestMarshalIgnoresFieldOrder(t *testing.T) {
+	type ab struct {
+		A string `json:"a"`
+		B string `json:"b"`
+	}
+	type ba struct {
+		B string `json:"b"`
+		A string `json:"a"`
+	}
+
+	first, err := Marshal(ab{A: "1", B: "2"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	second, _ := Marshal(ba{A: "1", B: "2"})
+	if string(first) != string(second) || string(first) != `{"a":"1","b":"2"}` {
+		t.Errorf("Marshal = %s and %s", first, second)
+	}
+
+	legacy, _ := Legacy.Marshal(ba{A: "1", B: "2"})
+	if string(legacy) != `{"b":"2","a":"1"}` {
+		t.Errorf("Legacy.Marshal = %s", legacy)
+	}
+}
@@ -0,0 +1,63 @@
+// Package cas stores operation batches by content hash, so an anchor vote only has to carry the hash
+package cas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yourusername/did-char/pkg/crypto"
+)
+
+// ErrNotFound is returned when content is not available from a fetcher
+var ErrNotFound = errors.New("content not found")
+
+// Fetcher retrieves content by hash
+//
+// Implementations must only return content whose hash matches; see Verify.
+type Fetcher interface {
+	Get(ctx context.Context, hash string) ([]byte, error)
+}
+
+// Store is a Fetcher that can also store content
+type Store interface {
+	Fetcher
+	Put(ctx context.Context, data []byte) (string, error)
+}
+
+// Hash returns the content hash of data (base64url SHA-256)
+func Hash(data []byte) string {
+	return crypto.HashToBase64URL(data)
+}
+
+// Verify checks that data has the given content hash
+func Verify(hash string, data []byte) error {
+	if actual := Hash(data); actual != hash {
+		return fmt.Errorf("content hash mismatch: want %s, got %s", hash, actual)
+	}
+	return nil
+}
+
+// Chain tries each fetcher in order, returning the first content found
+type Chain []Fetcher
+
+// Get implements Fetcher
+//
+// ErrNotFound is returned only if every fetcher reports it; otherwise the last
+// other error is returned.
+func (c Chain) Get(ctx context.Context, hash string) ([]byte, error) {
+	err := ErrNotFound
+	for _, f := range c {
+		data, fetchErr := f.Get(ctx, hash)
+		if fetchErr == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !errors.Is(fetchErr, ErrNotFound) {
+			err = fetchErr
+		}
+	}
+	return nil, err
+}
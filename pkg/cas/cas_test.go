@@ -0,0 +1,118 @@
+package cas
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "cas"))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+
+	data := []byte("anchored batch")
+	hash, err := store.Put(ctx, data)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if hash != Hash(data) {
+		t.Errorf("hash = %s, want %s", hash, Hash(data))
+	}
+	if again, err := store.Put(ctx, data); err != nil || again != hash {
+		t.Errorf("second Put = %s, %v", again, err)
+	}
+
+	got, err := store.Get(ctx, hash)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("Get = %q, want %q", got, data)
+	}
+
+	if _, err := store.Get(ctx, Hash([]byte("other"))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing content: err = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Get(ctx, "../etc/passwd"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("invalid hash: err = %v", err)
+	}
+}
+
+func TestFileStoreDetectsCorruption(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+
+	hash, _ := store.Put(ctx, []byte("original"))
+	if err := os.WriteFile(filepath.Join(dir, hash), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get(ctx, hash); err == nil {
+		t.Error("expected corrupted content to be refused")
+	}
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	ctx := context.Background()
+	store, _ := NewFileStore(t.TempDir())
+	hash, _ := store.Put(ctx, []byte("served batch"))
+
+	srv := httptest.NewServer(http.StripPrefix("/cas", NewHandler(store)))
+	defer srv.Close()
+
+	fetcher := NewHTTPFetcher(srv.URL+"/cas/", nil)
+	got, err := fetcher.Get(ctx, hash)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(got) != "served batch" {
+		t.Errorf("Get = %q", got)
+	}
+
+	if _, err := fetcher.Get(ctx, Hash([]byte("other"))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing content: err = %v, want ErrNotFound", err)
+	}
+
+	resp, err := http.Post(srv.URL+"/cas/"+hash, "application/octet-stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHTTPFetcherRejectsWrongContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not what was asked for"))
+	}))
+	defer srv.Close()
+
+	if _, err := NewHTTPFetcher(srv.URL, nil).Get(context.Background(), Hash([]byte("wanted"))); err == nil {
+		t.Error("expected content with the wrong hash to be refused")
+	}
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	first, _ := NewFileStore(t.TempDir())
+	second, _ := NewFileStore(t.TempDir())
+	hash, _ := second.Put(ctx, []byte("only in second"))
+
+	chain := Chain{first, second}
+	if got, err := chain.Get(ctx, hash); err != nil || string(got) != "only in second" {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+	if _, err := chain.Get(ctx, Hash([]byte("nowhere"))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing content: err = %v, want ErrNotFound", err)
+	}
+}
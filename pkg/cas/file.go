@@ -0,0 +1,86 @@
+package cas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStore keeps content in a local directory, one file per hash
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore opens a content directory, creating it if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create content directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put stores data and returns its hash. Storing the same content again is a no-op.
+func (s *FileStore) Put(ctx context.Context, data []byte) (string, error) {
+	hash := Hash(data)
+	path := s.path(hash)
+
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	// Write to a temporary file first so readers never see partial content
+	tmp, err := os.CreateTemp(s.dir, ".put-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create content file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write content file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write content file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to store content: %w", err)
+	}
+
+	return hash, nil
+}
+
+// Get returns the content with the given hash, or ErrNotFound
+func (s *FileStore) Get(ctx context.Context, hash string) ([]byte, error) {
+	if !validHash(hash) {
+		return nil, fmt.Errorf("invalid content hash %q", hash)
+	}
+
+	data, err := os.ReadFile(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	if err := Verify(hash, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// path returns the file holding content with the given hash
+func (s *FileStore) path(hash string) string {
+	return filepath.Join(s.dir, hash)
+}
+
+// validHash reports whether hash is a plausible base64url content hash that is safe to use as a file name
+func validHash(hash string) bool {
+	if hash == "" || len(hash) > 128 {
+		return false
+	}
+	return strings.Trim(hash, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_") == ""
+}
@@ -0,0 +1,104 @@
+package cas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxContentSize caps how much an HTTPFetcher reads for one hash
+const maxContentSize = 64 << 20
+
+// HTTPFetcher fetches content from an HTTP content store at BaseURL/<hash>
+//
+// It is read-only: content is published by whoever anchored it, for example by
+// serving a FileStore with NewHandler.
+type HTTPFetcher struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPFetcher creates a fetcher for baseURL. A nil client uses http.DefaultClient.
+func NewHTTPFetcher(baseURL string, client *http.Client) *HTTPFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPFetcher{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+	}
+}
+
+// Get implements Fetcher
+func (f *HTTPFetcher) Get(ctx context.Context, hash string) ([]byte, error) {
+	if !validHash(hash) {
+		return nil, fmt.Errorf("invalid content hash %q", hash)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.baseURL+"/"+hash, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch content: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch content: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxContentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	if len(data) > maxContentSize {
+		return nil, fmt.Errorf("content exceeds %d bytes", maxContentSize)
+	}
+	if err := Verify(hash, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// NewHandler serves content from f at /<hash>, for HTTPFetchers on other nodes
+//
+// Mount it with http.StripPrefix when serving under a path prefix.
+func NewHandler(f Fetcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		hash := strings.TrimPrefix(r.URL.Path, "/")
+		if !validHash(hash) {
+			http.Error(w, "invalid content hash", http.StatusBadRequest)
+			return
+		}
+
+		data, err := f.Get(r.Context(), hash)
+		if errors.Is(err, ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to read content", http.StatusInternalServerError)
+			return
+		}
+
+		// Content never changes for a given hash
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Write(data)
+	})
+}
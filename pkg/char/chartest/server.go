@@ -0,0 +1,507 @@
+// Package chartest provides an in-process fake CHAR node for tests and offline development.
+//
+// The fake speaks the same JSON-RPC over HTTP as a real node for the two methods
+// did-char uses (addbambookv and getreferendumdecisionroll). Votes submitted with
+// addbambookv are queued for the currently open ballot; each time a ballot is
+// decided the first queued vote for a domain wins and the rest are dropped, just
+// like losing votes on a real referendum.
+package chartest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/config"
+)
+
+// Options configures a fake CHAR node
+type Options struct {
+	// Tick is how often a ballot is decided. Zero disables automatic ticking;
+	// ballots are then decided only by Advance or DecideOnSubmit.
+	Tick time.Duration
+
+	// StartBallot is the first ballot number that will be decided. Earlier ballots
+	// are reported as decided and empty, like history on a node that was already running.
+	StartBallot int
+
+	// DecideOnSubmit decides the open ballot immediately after every accepted vote
+	DecideOnSubmit bool
+
+	// Leaders is the set of leaders reported in decision rolls. When LeaderEvery
+	// is non-zero the leader rotates through this list every LeaderEvery ballots.
+	Leaders     []string
+	LeaderEvery int
+
+	// RPCUser and RPCPassword, when set, are required as HTTP basic auth
+	RPCUser     string
+	RPCPassword string
+}
+
+// ballot is a decided ballot
+type ballot struct {
+	leader string
+	mine   bool
+	data   map[string]string // domain hex -> decided data hex
+}
+
+// rpcFault is an injected RPC error
+type rpcFault struct {
+	method  string
+	code    int
+	message string
+}
+
+// Server is a fake CHAR node
+type Server struct {
+	// URL is the base URL of the server, e.g. http://127.0.0.1:12345/
+	URL string
+
+	opts Options
+	http *httptest.Server
+
+	mu          sync.Mutex
+	next        int                 // next ballot to be decided (the open ballot)
+	decided     map[int]*ballot     // decided ballots by number
+	pending     map[string][]string // domain hex -> queued votes for the open ballot
+	leader      string
+	leaderMine  bool
+	emptyNext   int        // number of upcoming ballots forced empty
+	rpcFaults   []rpcFault // injected RPC errors, consumed in order
+	httpFaults  []int      // injected HTTP status codes, consumed in order
+	submissions int
+	calls       map[string]int // RPC method -> number of requests received
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewServer starts a fake CHAR node. Call Close when finished.
+func NewServer(opts Options) *Server {
+	s := &Server{
+		opts:    opts,
+		next:    opts.StartBallot,
+		decided: make(map[int]*ballot),
+		pending: make(map[string][]string),
+		calls:   make(map[string]int),
+		leader:  "fake-leader",
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if len(opts.Leaders) > 0 {
+		s.leader = opts.Leaders[0]
+	}
+
+	s.http = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.http.URL + "/"
+
+	if opts.Tick > 0 {
+		go s.tickLoop()
+	} else {
+		close(s.done)
+	}
+
+	return s
+}
+
+// Close stops ticking and shuts down the HTTP server
+func (s *Server) Close() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	s.http.Close()
+}
+
+// CHARConfig returns a client configuration pointing at this server
+func (s *Server) CHARConfig() config.CHARConfig {
+	host, portStr, _ := net.SplitHostPort(s.http.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	cfg := config.DefaultConfig().CHAR
+	cfg.RPCHost = host
+	cfg.RPCPort = port
+	cfg.RPCUser = s.opts.RPCUser
+	cfg.RPCPassword = s.opts.RPCPassword
+	return cfg
+}
+
+// Client returns a CHAR client connected to this server
+func (s *Server) Client() *char.Client {
+	cfg := s.CHARConfig()
+	return char.NewClient(&cfg)
+}
+
+// tickLoop decides a ballot every tick until Close
+func (s *Server) tickLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.Tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Advance()
+		}
+	}
+}
+
+// Advance decides the open ballot now and returns its number
+func (s *Server) Advance() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.decideLocked()
+}
+
+// decideLocked decides the open ballot. Callers must hold s.mu.
+func (s *Server) decideLocked() int {
+	number := s.next
+
+	if s.opts.LeaderEvery > 0 && len(s.opts.Leaders) > 0 {
+		s.leader = s.opts.Leaders[(number/s.opts.LeaderEvery)%len(s.opts.Leaders)]
+	}
+
+	b := &ballot{
+		leader: s.leader,
+		mine:   s.leaderMine,
+		data:   make(map[string]string),
+	}
+
+	if s.emptyNext > 0 {
+		s.emptyNext--
+	} else {
+		for domainHex, votes := range s.pending {
+			if len(votes) > 0 {
+				b.data[domainHex] = votes[0]
+			}
+		}
+	}
+
+	// Losing votes and votes cast into an empty ballot are dropped
+	s.pending = make(map[string][]string)
+	s.decided[number] = b
+	s.next++
+
+	return number
+}
+
+// NextBallot returns the number of the open (not yet decided) ballot
+func (s *Server) NextBallot() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
+
+// Submissions returns how many addbambookv votes the server has accepted
+func (s *Server) Submissions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.submissions
+}
+
+// Calls returns how many requests for an RPC method the server has received
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// SetLeader changes the leader reported for subsequently decided ballots
+func (s *Server) SetLeader(leader string, mine bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leader = leader
+	s.leaderMine = mine
+}
+
+// EmptyBallots forces the next n decided ballots to be empty, dropping any votes cast into them
+func (s *Server) EmptyBallots(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emptyNext += n
+}
+
+// InjectVote queues a vote from another writer for the open ballot.
+// Injected votes are queued like any other, so one injected before a
+// client submission wins the ballot.
+func (s *Server) InjectVote(appPreimage, dataHex string, slotize bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slotize {
+		dataHex = WrapInSlotFormat(dataHex)
+	}
+	domainHex := hex.EncodeToString([]byte(appPreimage))
+	s.pending[domainHex] = append(s.pending[domainHex], dataHex)
+}
+
+// InjectRPCError makes the next count calls to method fail with a JSON-RPC error.
+// An empty method matches any method.
+func (s *Server) InjectRPCError(method string, count, code int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.rpcFaults = append(s.rpcFaults, rpcFault{method: method, code: code, message: message})
+	}
+}
+
+// InjectHTTPError makes the next count requests fail with the given HTTP status
+func (s *Server) InjectHTTPError(count, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.httpFaults = append(s.httpFaults, status)
+	}
+}
+
+// DecidedData returns the decided data hex for a domain on a ballot
+func (s *Server) DecidedData(appPreimage string, ballotNumber int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.decided[ballotNumber]
+	if !ok {
+		return "", false
+	}
+	return b.data[hex.EncodeToString([]byte(appPreimage))], true
+}
+
+// handle serves a JSON-RPC request
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if s.opts.RPCUser != "" || s.opts.RPCPassword != "" {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != s.opts.RPCUser || pass != s.opts.RPCPassword {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	if len(s.httpFaults) > 0 {
+		status := s.httpFaults[0]
+		s.httpFaults = s.httpFaults[1:]
+		s.mu.Unlock()
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	s.mu.Unlock()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ID     string            `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeRPC(w, req.ID, nil, &char.RPCError{Code: -32700, Message: "parse error"})
+		return
+	}
+
+	s.mu.Lock()
+	s.calls[req.Method]++
+	s.mu.Unlock()
+
+	if fault := s.takeFault(req.Method); fault != nil {
+		writeRPC(w, req.ID, nil, &char.RPCError{Code: fault.code, Message: fault.message})
+		return
+	}
+
+	var result interface{}
+	var rpcErr *char.RPCError
+	switch req.Method {
+	case "addbambookv":
+		result, rpcErr = s.addBambooKV(req.Params)
+	case "getreferendumdecisionroll":
+		result, rpcErr = s.getReferendumDecisionRoll(req.Params)
+	default:
+		rpcErr = &char.RPCError{Code: -32601, Message: "Method not found"}
+	}
+
+	writeRPC(w, req.ID, result, rpcErr)
+}
+
+// takeFault pops the next injected RPC error matching method, if any
+func (s *Server) takeFault(method string) *rpcFault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.rpcFaults {
+		if f.method == "" || f.method == method {
+			s.rpcFaults = append(s.rpcFaults[:i], s.rpcFaults[i+1:]...)
+			return &f
+		}
+	}
+	return nil
+}
+
+// addBambooKV handles addbambookv [[{domainHex: dataHex}], slotize]
+func (s *Server) addBambooKV(params []json.RawMessage) (interface{}, *char.RPCError) {
+	if len(params) < 1 {
+		return nil, &char.RPCError{Code: -8, Message: "missing bamboo kv array"}
+	}
+
+	var kvs []map[string]string
+	if err := json.Unmarshal(params[0], &kvs); err != nil {
+		return nil, &char.RPCError{Code: -8, Message: "invalid bamboo kv array"}
+	}
+
+	slotize := false
+	if len(params) > 1 {
+		if err := json.Unmarshal(params[1], &slotize); err != nil {
+			return nil, &char.RPCError{Code: -8, Message: "invalid slotize flag"}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := char.AddBambooKVResponse{}
+	for _, kv := range kvs {
+		for domainHex, dataHex := range kv {
+			if _, err := hex.DecodeString(dataHex); err != nil {
+				response[domainHex] = false
+				continue
+			}
+			if slotize {
+				dataHex = WrapInSlotFormat(dataHex)
+			}
+			s.pending[domainHex] = append(s.pending[domainHex], dataHex)
+			s.submissions++
+			response[domainHex] = true
+		}
+	}
+
+	if s.opts.DecideOnSubmit {
+		s.decideLocked()
+	}
+
+	return response, nil
+}
+
+// getReferendumDecisionRoll handles getreferendumdecisionroll [domainHex, ballot, verbosity]
+func (s *Server) getReferendumDecisionRoll(params []json.RawMessage) (interface{}, *char.RPCError) {
+	if len(params) < 2 {
+		return nil, &char.RPCError{Code: -8, Message: "expected domain and ballot number"}
+	}
+
+	var domainHex string
+	var ballotNumber, verbosity int
+	if err := json.Unmarshal(params[0], &domainHex); err != nil {
+		return nil, &char.RPCError{Code: -8, Message: "invalid domain"}
+	}
+	domain, err := hex.DecodeString(domainHex)
+	if err != nil {
+		return nil, &char.RPCError{Code: -8, Message: "domain must be hex"}
+	}
+	if err := json.Unmarshal(params[1], &ballotNumber); err != nil {
+		return nil, &char.RPCError{Code: -8, Message: "invalid ballot number"}
+	}
+	if len(params) > 2 {
+		if err := json.Unmarshal(params[2], &verbosity); err != nil {
+			return nil, &char.RPCError{Code: -8, Message: "invalid verbosity"}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	domainHash := sha256.Sum256(domain)
+	response := &char.DecisionRollResponse{
+		DomainHash:   hex.EncodeToString(domainHash[:]),
+		BallotNumber: ballotNumber,
+		Leader:       s.leader,
+		LeaderIsMine: s.leaderMine,
+	}
+
+	b, ok := s.decided[ballotNumber]
+	if !ok && ballotNumber >= 0 && ballotNumber < s.opts.StartBallot {
+		b, ok = &ballot{leader: s.leader, data: map[string]string{}}, true
+	}
+	if !ok {
+		return response, nil
+	}
+
+	response.Found = true
+	response.Leader = b.leader
+	response.LeaderIsMine = b.mine
+
+	if verbosity >= 1 {
+		data := b.data[domainHex]
+		dataBytes, _ := hex.DecodeString(data)
+		dataHash := sha256.Sum256(dataBytes)
+		rollHash := sha256.Sum256(append([]byte(strconv.Itoa(ballotNumber)), dataHash[:]...))
+		response.DecisionRoll = &char.DecisionRoll{
+			RollHash:     hex.EncodeToString(rollHash[:]),
+			DataHash:     hex.EncodeToString(dataHash[:]),
+			EnvelopeHash: hex.EncodeToString(rollHash[:]),
+			Serialized:   data,
+			Data:         data,
+		}
+	}
+
+	return response, nil
+}
+
+// writeRPC writes a JSON-RPC response
+func writeRPC(w http.ResponseWriter, id string, result interface{}, rpcErr *char.RPCError) {
+	resp := struct {
+		Result interface{}    `json:"result"`
+		Error  *char.RPCError `json:"error"`
+		ID     string         `json:"id"`
+	}{result, rpcErr, id}
+
+	w.Header().Set("Content-Type", "application/json")
+	if rpcErr != nil {
+		// bitcoind-style nodes answer RPC errors with HTTP 500
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// WrapInSlotFormat wraps data in the CHAR slot format a node applies when slotize is set
+// Format: [0x00][0x00][CompactSize length][data]
+func WrapInSlotFormat(dataHex string) string {
+	data, err := hex.DecodeString(dataHex)
+	if err != nil {
+		panic(fmt.Sprintf("chartest: invalid hex data: %v", err))
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(0x00)
+	buf.WriteByte(0x00)
+
+	n := uint64(len(data))
+	switch {
+	case n < 0xfd:
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(0xfd)
+		binary.Write(buf, binary.LittleEndian, uint16(n))
+	case n <= 0xffffffff:
+		buf.WriteByte(0xfe)
+		binary.Write(buf, binary.LittleEndian, uint32(n))
+	default:
+		buf.WriteByte(0xff)
+		binary.Write(buf, binary.LittleEndian, n)
+	}
+
+	buf.Write(data)
+	return hex.EncodeToString(buf.Bytes())
+}
@@ -0,0 +1,191 @@
+package chartest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/char"
+)
+
+const testDomain = "did-char-domain"
+
+var ctx = context.Background()
+
+func TestAddBambooKVAndDecide(t *testing.T) {
+	srv := NewServer(Options{StartBallot: 10})
+	defer srv.Close()
+	client := srv.Client()
+
+	resp, err := client.AddBambooKV(ctx, testDomain, "0102", true)
+	if err != nil {
+		t.Fatalf("AddBambooKV failed: %v", err)
+	}
+	if !resp["6469642d636861722d646f6d61696e"] {
+		t.Fatalf("vote not accepted: %v", resp)
+	}
+
+	// Not decided yet
+	roll, err := client.GetReferendumDecisionRoll(ctx, testDomain, 10, 1)
+	if err != nil {
+		t.Fatalf("GetReferendumDecisionRoll failed: %v", err)
+	}
+	if roll.Found {
+		t.Fatal("ballot 10 should not be decided yet")
+	}
+
+	if got := srv.Advance(); got != 10 {
+		t.Fatalf("Advance decided ballot %d, want 10", got)
+	}
+
+	roll, err = client.GetReferendumDecisionRoll(ctx, testDomain, 10, 1)
+	if err != nil {
+		t.Fatalf("GetReferendumDecisionRoll failed: %v", err)
+	}
+	if !roll.Found || roll.DecisionRoll == nil {
+		t.Fatal("ballot 10 should be decided with a decision roll")
+	}
+	// Slot format: 0000 + length 02 + data
+	if roll.DecisionRoll.Data != "0000020102" {
+		t.Errorf("data = %s, want slot-wrapped 0000020102", roll.DecisionRoll.Data)
+	}
+}
+
+func TestFirstVoteWins(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	client := srv.Client()
+
+	srv.InjectVote(testDomain, "aaaa", true)
+	if _, err := client.AddBambooKV(ctx, testDomain, "bbbb", true); err != nil {
+		t.Fatalf("AddBambooKV failed: %v", err)
+	}
+	srv.Advance()
+
+	data, ok := srv.DecidedData(testDomain, 0)
+	if !ok {
+		t.Fatal("ballot 0 should be decided")
+	}
+	if !strings.HasSuffix(data, "aaaa") {
+		t.Errorf("winner = %s, want injected vote", data)
+	}
+
+	// Losing vote is dropped, not carried over
+	srv.Advance()
+	if data, _ := srv.DecidedData(testDomain, 1); data != "" {
+		t.Errorf("ballot 1 data = %s, want empty", data)
+	}
+}
+
+func TestEmptyBallots(t *testing.T) {
+	srv := NewServer(Options{DecideOnSubmit: true})
+	defer srv.Close()
+	client := srv.Client()
+
+	srv.EmptyBallots(1)
+	if _, err := client.AddBambooKV(ctx, testDomain, "0102", true); err != nil {
+		t.Fatalf("AddBambooKV failed: %v", err)
+	}
+
+	roll, err := client.GetReferendumDecisionRoll(ctx, testDomain, 0, 1)
+	if err != nil {
+		t.Fatalf("GetReferendumDecisionRoll failed: %v", err)
+	}
+	if !roll.Found || roll.DecisionRoll.Data != "" {
+		t.Errorf("expected empty decided ballot, got %+v", roll.DecisionRoll)
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+
+	// Disable client retries so every injected fault is observed
+	cfg := srv.CHARConfig()
+	cfg.MaxRetries = 0
+	client := char.NewClient(&cfg)
+
+	srv.InjectRPCError("getreferendumdecisionroll", 1, -28, "Loading block index...")
+	if _, err := client.GetReferendumDecisionRoll(ctx, testDomain, 0, 0); err == nil || !strings.Contains(err.Error(), "-28") {
+		t.Errorf("expected injected RPC error, got %v", err)
+	}
+
+	srv.InjectHTTPError(1, 503)
+	if _, err := client.GetReferendumDecisionRoll(ctx, testDomain, 0, 0); err == nil {
+		t.Error("expected injected HTTP error")
+	}
+
+	if _, err := client.GetReferendumDecisionRoll(ctx, testDomain, 0, 0); err != nil {
+		t.Errorf("faults should be consumed, got %v", err)
+	}
+}
+
+func TestLeaderRotation(t *testing.T) {
+	srv := NewServer(Options{Leaders: []string{"a", "b"}, LeaderEvery: 2})
+	defer srv.Close()
+	client := srv.Client()
+
+	for i := 0; i < 4; i++ {
+		srv.Advance()
+	}
+
+	want := []string{"a", "a", "b", "b"}
+	for i, leader := range want {
+		roll, err := client.GetReferendumDecisionRoll(ctx, testDomain, i, 0)
+		if err != nil {
+			t.Fatalf("GetReferendumDecisionRoll failed: %v", err)
+		}
+		if roll.Leader != leader {
+			t.Errorf("ballot %d leader = %s, want %s", i, roll.Leader, leader)
+		}
+	}
+
+}
+
+func TestSetLeader(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	client := srv.Client()
+
+	srv.Advance()
+	srv.SetLeader("me", true)
+	srv.Advance()
+
+	before, _ := client.GetReferendumDecisionRoll(ctx, testDomain, 0, 0)
+	after, _ := client.GetReferendumDecisionRoll(ctx, testDomain, 1, 0)
+	if before.Leader == "me" || before.LeaderIsMine {
+		t.Errorf("ballot 0 should keep the original leader, got %s", before.Leader)
+	}
+	if after.Leader != "me" || !after.LeaderIsMine {
+		t.Errorf("ballot 1 leader = %s (mine=%v), want me (mine=true)", after.Leader, after.LeaderIsMine)
+	}
+}
+
+func TestTick(t *testing.T) {
+	srv := NewServer(Options{Tick: 5 * time.Millisecond})
+	defer srv.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.NextBallot() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("ticker did not decide ballots")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	srv := NewServer(Options{RPCUser: "char", RPCPassword: "secret"})
+	defer srv.Close()
+
+	if _, err := srv.Client().GetReferendumDecisionRoll(ctx, testDomain, 0, 0); err != nil {
+		t.Errorf("authorized call failed: %v", err)
+	}
+
+	cfg := srv.CHARConfig()
+	cfg.RPCPassword = "wrong"
+	if _, err := char.NewClient(&cfg).GetReferendumDecisionRoll(ctx, testDomain, 0, 0); err == nil {
+		t.Error("expected unauthorized call to fail")
+	}
+}
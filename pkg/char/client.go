@@ -2,29 +2,52 @@ package char
 
 import (
 	"bytes"
-	"encoding/binary"
-	"encoding/hex"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/yourusername/did-char/pkg/config"
 )
 
-var (
-	SLOT_GAP_LIMIT = 5000 // Number of slots to check ahead for empty ballot (ballots every 20s, so 5000 = ~27 hours)
-)
-
 // Client wraps bitcoin-cli for CHAR RPC calls
 type Client struct {
-	cfg *config.CHARConfig
+	cfg        *config.CHARConfig
+	httpClient *http.Client
+}
+
+// sharedTransport is reused by all clients so connections to the node are kept alive across calls
+var sharedTransport = newTransport()
+
+// newTransport builds an HTTP transport tuned for many small RPC calls to one host
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = 64
+	t.MaxIdleConnsPerHost = 16
+	t.IdleConnTimeout = 90 * time.Second
+	return t
 }
 
 // NewClient creates a new CHAR RPC client
 func NewClient(cfg *config.CHARConfig) *Client {
-	return &Client{cfg: cfg}
+	timeout := time.Duration(cfg.RequestTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &Client{
+		cfg: cfg,
+		httpClient: &http.Client{
+			Transport: sharedTransport,
+			Timeout:   timeout,
+		},
+	}
 }
 
 // RPCRequest represents a JSON-RPC request
@@ -42,14 +65,17 @@ type RPCResponse struct {
 	ID     string          `json:"id"`
 }
 
-// RPCError represents a JSON-RPC error
-type RPCError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+// idempotentMethods are the RPC methods that change nothing on the node, so any
+// transient failure can be retried
+var idempotentMethods = map[string]bool{
+	"getreferendumdecisionroll": true,
 }
 
-// rpcCall executes a JSON-RPC HTTP request
-func (c *Client) rpcCall(method string, params ...interface{}) ([]byte, error) {
+// rpcCall executes a JSON-RPC HTTP request, retrying transient failures with jittered exponential backoff
+//
+// Other methods, such as addbambookv, are retried only when the node certainly did not
+// act on the request: a timeout after the node accepted a vote must not submit it twice.
+func (c *Client) rpcCall(ctx context.Context, method string, params ...interface{}) ([]byte, error) {
 	// Build JSON-RPC request
 	req := RPCRequest{
 		JSONRPC: "1.0",
@@ -63,9 +89,41 @@ func (c *Client) rpcCall(method string, params ...interface{}) ([]byte, error) {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	for attempt := 0; ; attempt++ {
+		result, err := c.doRPC(ctx, method, reqBody)
+		if err == nil {
+			return result, nil
+		}
+
+		retryable := IsRetryable(err)
+		if !idempotentMethods[method] {
+			retryable = notProcessed(err)
+		}
+		if attempt >= c.cfg.MaxRetries || !retryable || ctx.Err() != nil {
+			return nil, err
+		}
+
+		if err := sleepContext(ctx, c.backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// notProcessed reports whether err shows the node never acted on the request:
+// it could not be reached, or it refused the call while warming up
+func notProcessed(err error) bool {
+	if IsRPCErrorCode(err, RPCErrInWarmup) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// doRPC performs a single JSON-RPC HTTP round trip
+func (c *Client) doRPC(ctx context.Context, method string, reqBody []byte) ([]byte, error) {
 	// Create HTTP request
 	url := fmt.Sprintf("http://%s:%d/", c.cfg.RPCHost, c.cfg.RPCPort)
-	httpReq, err := http.NewRequest("POST", url, bytes.NewReader(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -74,35 +132,77 @@ func (c *Client) rpcCall(method string, params ...interface{}) ([]byte, error) {
 	httpReq.SetBasicAuth(c.cfg.RPCUser, c.cfg.RPCPassword)
 
 	// Execute request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(httpReq)
+	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Read response body
+	// Read response body (fully, so the connection can be reused)
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	// Parse JSON-RPC response
+	// bitcoind-style nodes report RPC errors with HTTP 500 and a JSON body
 	var rpcResp RPCResponse
 	if err := json.Unmarshal(body, &rpcResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	// Check for RPC error
 	if rpcResp.Error != nil {
-		return nil, fmt.Errorf("RPC error code %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		rpcResp.Error.Method = method
+		return nil, rpcResp.Error
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	return rpcResp.Result, nil
 }
 
+// backoff returns the jittered delay before retry number attempt+1
+func (c *Client) backoff(attempt int) time.Duration {
+	base := time.Duration(c.cfg.RetryBaseMS) * time.Millisecond
+	if base <= 0 {
+		base = 200 * time.Millisecond
+	}
+	maxDelay := time.Duration(c.cfg.RetryMaxMS) * time.Millisecond
+	if maxDelay <= 0 {
+		maxDelay = 5 * time.Second
+	}
+
+	delay := base << uint(attempt)
+	if delay > maxDelay || delay <= 0 {
+		delay = maxDelay
+	}
+
+	// Equal jitter: half fixed, half random
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// sleepContext sleeps for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // AddBambooKV submits a key-value pair via addbambookv
-func (c *Client) AddBambooKV(appPreimage, dataHex string, slotize bool) (AddBambooKVResponse, error) {
+func (c *Client) AddBambooKV(ctx context.Context, appPreimage, dataHex string, slotize bool) (AddBambooKVResponse, error) {
 	// Convert appPreimage to hex
 	appPreimageHex := stringToHex(appPreimage)
 
@@ -111,7 +211,7 @@ func (c *Client) AddBambooKV(appPreimage, dataHex string, slotize bool) (AddBamb
 		{appPreimageHex: dataHex},
 	}
 
-	result, err := c.rpcCall("addbambookv", bambooKV, slotize)
+	result, err := c.rpcCall(ctx, "addbambookv", bambooKV, slotize)
 	if err != nil {
 		return nil, fmt.Errorf("addbambookv failed: %w", err)
 	}
@@ -125,11 +225,11 @@ func (c *Client) AddBambooKV(appPreimage, dataHex string, slotize bool) (AddBamb
 }
 
 // GetReferendumDecisionRoll queries a ballot's decision roll
-func (c *Client) GetReferendumDecisionRoll(domain string, ballotNumber, verbosity int) (*DecisionRollResponse, error) {
+func (c *Client) GetReferendumDecisionRoll(ctx context.Context, domain string, ballotNumber, verbosity int) (*DecisionRollResponse, error) {
 	// Convert domain to hex
 	domainHex := stringToHex(domain)
 
-	result, err := c.rpcCall("getreferendumdecisionroll", domainHex, ballotNumber, verbosity)
+	result, err := c.rpcCall(ctx, "getreferendumdecisionroll", domainHex, ballotNumber, verbosity)
 	if err != nil {
 		return nil, fmt.Errorf("getreferendumdecisionroll failed: %w", err)
 	}
@@ -147,146 +247,91 @@ func stringToHex(s string) string {
 	return fmt.Sprintf("%x", s)
 }
 
-// GetNextAvailableBallot finds the next empty ballot by searching forward
-func (c *Client) GetNextAvailableBallot(domain string, startFrom int) (int, error) {
-	// Search forward from startFrom, looking for an empty ballot
-	// Check up to 500 ballots ahead
-	for i := 0; i < SLOT_GAP_LIMIT; i++ {
-		ballotNum := startFrom + i
-		roll, err := c.GetReferendumDecisionRoll(domain, ballotNum, 0)
+// GetNextAvailableBallot finds the ballot currently open for votes
+//
+// startFrom is a hint, usually the last synced ballot or a wall-clock prediction;
+// the search is logarithmic in its distance from the tip (see FindTip).
+func (c *Client) GetNextAvailableBallot(ctx context.Context, domain string, startFrom int) (int, error) {
+	return c.FindTip(ctx, domain, startFrom)
+}
+
+// PollForConfirmation polls until a ballot is confirmed (found: true) and has data
+func (c *Client) PollForConfirmation(ctx context.Context, domain string, ballotNumber int, maxAttempts int, interval time.Duration) (*DecisionRollResponse, error) {
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		roll, err := c.GetReferendumDecisionRoll(ctx, domain, ballotNumber, 1)
 		if err != nil {
-			return 0, fmt.Errorf("failed to query ballot %d: %w", ballotNum, err)
+			return nil, fmt.Errorf("poll attempt %d failed: %w", attempt, err)
 		}
 
-		if !roll.Found {
-			// Ballot doesn't exist yet - use this number (it will exist soon)
-			// Ballots are created every 20 seconds
-			return ballotNum, nil
+		if roll.Found {
+			// Check if decision roll has data
+			if roll.DecisionRoll != nil && roll.DecisionRoll.Data != "" {
+				return roll, nil
+			}
+			// Ballot found but no data yet - keep polling
 		}
-	}
 
-	return 0, fmt.Errorf("no empty ballot found in range %d to %d", startFrom, startFrom+SLOT_GAP_LIMIT)
-}
-
-// wrapInSlotFormat wraps data in CHAR slot format
-// Format: [0x00][0x00][CompactSize length][data]
-func wrapInSlotFormat(dataHex string) string {
-	data, err := hex.DecodeString(dataHex)
-	if err != nil {
-		return ""
-	}
-
-	buf := new(bytes.Buffer)
-
-	// Slot format prefix: 0000
-	buf.WriteByte(0x00)
-	buf.WriteByte(0x00)
-
-	// Data length as CompactSize
-	writeCompactSize(buf, uint64(len(data)))
-
-	// Data
-	buf.Write(data)
-
-	return hex.EncodeToString(buf.Bytes())
-}
-
-// encodeReferendumVote encodes a referendum vote
-// Format: [0x00][varint ballot_number][compact_size payload_len][payload]
-func encodeReferendumVote(ballotNumber int, payloadHex string) string {
-	payload, err := hex.DecodeString(payloadHex)
-	if err != nil {
-		// If decode fails, return empty - will cause submission to fail
-		return ""
+		if attempt < maxAttempts {
+			if err := sleepContext(ctx, interval); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	buf := new(bytes.Buffer)
-
-	// Leaf type = 0x00 for REFERENDUM_VOTE
-	buf.WriteByte(0x00)
-
-	// Ballot number as CompactSize (Bitcoin uses CompactSize for varints)
-	writeCompactSize(buf, uint64(ballotNumber))
-
-	// Payload length as CompactSize
-	writeCompactSize(buf, uint64(len(payload)))
-
-	// Payload
-	buf.Write(payload)
-
-	return hex.EncodeToString(buf.Bytes())
+	return nil, fmt.Errorf("timeout: ballot %d not confirmed with data after %d attempts", ballotNumber, maxAttempts)
 }
 
-// writeVarint writes a Bitcoin-style varint
-// For values < 253: single byte
-// For values <= 0xFFFF: 0xFD followed by 2 bytes (little endian)
-// For values <= 0xFFFFFFFF: 0xFE followed by 4 bytes (little endian)
-// For larger values: 0xFF followed by 8 bytes (little endian)
-func writeVarint(buf *bytes.Buffer, n uint64) {
-	if n < 0xFD {
-		buf.WriteByte(byte(n))
-	} else if n <= 0xFFFF {
-		buf.WriteByte(0xFD)
-		binary.Write(buf, binary.LittleEndian, uint16(n))
-	} else if n <= 0xFFFFFFFF {
-		buf.WriteByte(0xFE)
-		binary.Write(buf, binary.LittleEndian, uint32(n))
-	} else {
-		buf.WriteByte(0xFF)
-		binary.Write(buf, binary.LittleEndian, n)
+// WaitForBallot polls until a ballot is decided (found: true), whether or not it carries data
+//
+// Polling stops after MaxAttempts polls or TimeoutSeconds, whichever comes first.
+// A zero value for either limit leaves it unbounded; IntervalMS defaults to 100ms.
+func (c *Client) WaitForBallot(ctx context.Context, domain string, ballotNumber int, pollingCfg config.PollingConfig) (*DecisionRollResponse, error) {
+	interval := time.Duration(pollingCfg.IntervalMS) * time.Millisecond
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
 	}
-}
 
-// writeCompactSize writes a Bitcoin-style CompactSize
-func writeCompactSize(buf *bytes.Buffer, n uint64) {
-	if n < 0xfd {
-		buf.WriteByte(byte(n))
-	} else if n <= 0xffff {
-		buf.WriteByte(0xfd)
-		binary.Write(buf, binary.LittleEndian, uint16(n))
-	} else if n <= 0xffffffff {
-		buf.WriteByte(0xfe)
-		binary.Write(buf, binary.LittleEndian, uint32(n))
-	} else {
-		buf.WriteByte(0xff)
-		binary.Write(buf, binary.LittleEndian, n)
+	var deadline time.Time
+	if pollingCfg.TimeoutSeconds > 0 {
+		deadline = time.Now().Add(time.Duration(pollingCfg.TimeoutSeconds) * time.Second)
 	}
-}
 
-// PollForConfirmation polls until a ballot is confirmed (found: true) and has data
-func (c *Client) PollForConfirmation(domain string, ballotNumber int, maxAttempts int, interval time.Duration) (*DecisionRollResponse, error) {
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		roll, err := c.GetReferendumDecisionRoll(domain, ballotNumber, 1)
+	for attempt := 1; ; attempt++ {
+		roll, err := c.GetReferendumDecisionRoll(ctx, domain, ballotNumber, 1)
 		if err != nil {
 			return nil, fmt.Errorf("poll attempt %d failed: %w", attempt, err)
 		}
 
 		if roll.Found {
-			// Check if decision roll has data
-			if roll.DecisionRoll != nil && roll.DecisionRoll.Data != "" {
-				return roll, nil
-			}
-			// Ballot found but no data yet - keep polling
+			return roll, nil
 		}
 
-		if attempt < maxAttempts {
-			time.Sleep(interval)
+		if pollingCfg.MaxAttempts > 0 && attempt >= pollingCfg.MaxAttempts {
+			return nil, fmt.Errorf("%w: ballot %d not decided after %d attempts", ErrConfirmationTimeout, ballotNumber, attempt)
+		}
+		if !deadline.IsZero() && time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("%w: ballot %d not decided within %ds", ErrConfirmationTimeout, ballotNumber, pollingCfg.TimeoutSeconds)
 		}
-	}
 
-	return nil, fmt.Errorf("timeout: ballot %d not confirmed with data after %d attempts", ballotNumber, maxAttempts)
+		if err := sleepContext(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
 }
 
-// SubmitAndWaitForConfirmation submits a DID operation to CHAR and polls until confirmed
+// SubmitAndWaitForConfirmation submits a DID operation to CHAR and polls until the target ballot is decided
 //
 // The dataHex parameter is the complete encoded DID operation payload (not just raw DID data).
 // It includes: version, operation type, DID suffix, and operation-specific data.
 //
 // With slotize=true, CHAR handles referendum vote encoding and slot wrapping automatically.
-func (c *Client) SubmitAndWaitForConfirmation(appPreimage, dataHex string, ballotNumber int, pollingCfg config.PollingConfig) error {
+//
+// Once ballotNumber is decided, its data (with wrappers stripped) is compared with dataHex.
+// If another payload won the ballot, or it was decided empty, a *BallotLostError is returned.
+func (c *Client) SubmitAndWaitForConfirmation(ctx context.Context, appPreimage, dataHex string, ballotNumber int, pollingCfg config.PollingConfig) error {
 	// Submit the encoded DID operation with slotize=true
 	// CHAR will handle referendum vote encoding and slot wrapping
-	response, err := c.AddBambooKV(appPreimage, dataHex, true)
+	response, err := c.AddBambooKV(ctx, appPreimage, dataHex, true)
 	if err != nil {
 		return fmt.Errorf("failed to submit vote: %w", err)
 	}
@@ -298,5 +343,27 @@ func (c *Client) SubmitAndWaitForConfirmation(appPreimage, dataHex string, ballo
 		return fmt.Errorf("vote submission failed for app preimage %s (hex: %s)", appPreimage, appPreimageHex)
 	}
 
+	roll, err := c.WaitForBallot(ctx, appPreimage, ballotNumber, pollingCfg)
+	if err != nil {
+		return err
+	}
+
+	// Verify our payload is what the ballot decided
+	decided := ""
+	if roll.DecisionRoll != nil && roll.DecisionRoll.Data != "" {
+		stripped, err := StripWrappers(roll.DecisionRoll.Data)
+		if err != nil {
+			// A malformed wrapper cannot be our payload
+			stripped = roll.DecisionRoll.Data
+		}
+		decided = stripped
+	}
+	if !strings.EqualFold(decided, dataHex) {
+		return &BallotLostError{
+			BallotNumber: ballotNumber,
+			DecidedData:  decided,
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,202 @@
+package char_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+	"github.com/yourusername/did-char/pkg/config"
+)
+
+const testDomain = "did-char-domain"
+
+var ctx = context.Background()
+
+var fastPolling = config.PollingConfig{MaxAttempts: 200, IntervalMS: 5, TimeoutSeconds: 5}
+
+func TestSubmitAndWaitForConfirmationIncluded(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{Tick: 20 * time.Millisecond})
+	defer srv.Close()
+	client := srv.Client()
+
+	ballot, err := client.GetNextAvailableBallot(ctx, testDomain, 0)
+	if err != nil {
+		t.Fatalf("GetNextAvailableBallot failed: %v", err)
+	}
+
+	if err := client.SubmitAndWaitForConfirmation(ctx, testDomain, "01020304aabb", ballot, fastPolling); err != nil {
+		t.Fatalf("SubmitAndWaitForConfirmation failed: %v", err)
+	}
+}
+
+func TestSubmitAndWaitForConfirmationLostToOtherWriter(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{DecideOnSubmit: true})
+	defer srv.Close()
+	client := srv.Client()
+
+	srv.InjectVote(testDomain, "ffffffffff", true)
+
+	err := client.SubmitAndWaitForConfirmation(ctx, testDomain, "01020304aabb", 0, fastPolling)
+	var lost *char.BallotLostError
+	if !errors.As(err, &lost) {
+		t.Fatalf("expected BallotLostError, got %v", err)
+	}
+	if lost.BallotNumber != 0 || lost.DecidedData != "ffffffffff" {
+		t.Errorf("unexpected error details: %+v", lost)
+	}
+}
+
+func TestSubmitAndWaitForConfirmationEmptyBallot(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{DecideOnSubmit: true})
+	defer srv.Close()
+	client := srv.Client()
+
+	srv.EmptyBallots(1)
+
+	err := client.SubmitAndWaitForConfirmation(ctx, testDomain, "01020304aabb", 0, fastPolling)
+	var lost *char.BallotLostError
+	if !errors.As(err, &lost) {
+		t.Fatalf("expected BallotLostError, got %v", err)
+	}
+	if lost.DecidedData != "" {
+		t.Errorf("expected empty decided data, got %s", lost.DecidedData)
+	}
+}
+
+func TestWaitForBallotMaxAttempts(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+
+	_, err := srv.Client().WaitForBallot(ctx, testDomain, 0, config.PollingConfig{MaxAttempts: 3, IntervalMS: 1})
+	if !errors.Is(err, char.ErrConfirmationTimeout) {
+		t.Fatalf("expected ErrConfirmationTimeout, got %v", err)
+	}
+}
+
+func TestWaitForBallotTimeout(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := srv.Client().WaitForBallot(ctx, testDomain, 0, config.PollingConfig{IntervalMS: 50, TimeoutSeconds: 1})
+	if !errors.Is(err, char.ErrConfirmationTimeout) {
+		t.Fatalf("expected ErrConfirmationTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timeout not honored, took %v", elapsed)
+	}
+}
+
+// fastRetryClient returns a client for srv with quick backoff so retry tests stay fast
+func fastRetryClient(srv *chartest.Server, maxRetries int) *char.Client {
+	cfg := srv.CHARConfig()
+	cfg.MaxRetries = maxRetries
+	cfg.RetryBaseMS = 1
+	cfg.RetryMaxMS = 5
+	return char.NewClient(&cfg)
+}
+
+func TestRetriesTransientHTTPErrors(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+	client := fastRetryClient(srv, 3)
+
+	srv.InjectHTTPError(2, 503)
+	if _, err := client.GetReferendumDecisionRoll(ctx, testDomain, 0, 0); err != nil {
+		t.Fatalf("expected retries to succeed, got %v", err)
+	}
+}
+
+func TestRetriesWarmupErrors(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+	client := fastRetryClient(srv, 3)
+
+	srv.InjectRPCError("getreferendumdecisionroll", 3, char.RPCErrInWarmup, "Loading block index...")
+	if _, err := client.GetReferendumDecisionRoll(ctx, testDomain, 0, 0); err != nil {
+		t.Fatalf("expected retries to succeed, got %v", err)
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+	client := fastRetryClient(srv, 2)
+
+	srv.InjectHTTPError(5, 502)
+	_, err := client.GetReferendumDecisionRoll(ctx, testDomain, 0, 0)
+
+	var httpErr *char.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 502 {
+		t.Fatalf("expected HTTPError 502, got %v", err)
+	}
+}
+
+func TestNoRetryOfAmbiguousSubmit(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+	client := fastRetryClient(srv, 3)
+
+	// The node may have accepted the vote before failing, so resubmitting could vote twice
+	srv.InjectHTTPError(1, 503)
+	_, err := client.AddBambooKV(ctx, testDomain, "0102", true)
+
+	var httpErr *char.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 503 {
+		t.Fatalf("expected HTTPError 503 without retry, got %v", err)
+	}
+}
+
+func TestRetriesSubmitDuringWarmup(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+	client := fastRetryClient(srv, 3)
+
+	// A warming-up node refuses the call, so it is safe to repeat
+	srv.InjectRPCError("addbambookv", 2, char.RPCErrInWarmup, "Loading block index...")
+	if _, err := client.AddBambooKV(ctx, testDomain, "0102", true); err != nil {
+		t.Fatalf("expected retries to succeed, got %v", err)
+	}
+	if calls := srv.Calls("addbambookv"); calls != 3 {
+		t.Errorf("addbambookv calls = %d, want 3", calls)
+	}
+}
+
+func TestNoRetryOnPermanentRPCError(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+	client := fastRetryClient(srv, 3)
+
+	srv.InjectRPCError("", 1, char.RPCErrInvalidParameter, "bad ballot")
+	_, err := client.GetReferendumDecisionRoll(ctx, testDomain, 0, 0)
+
+	var rpcErr *char.RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("expected RPCError, got %v", err)
+	}
+	if rpcErr.Code != char.RPCErrInvalidParameter || rpcErr.Method != "getreferendumdecisionroll" {
+		t.Errorf("unexpected RPC error: %+v", rpcErr)
+	}
+	if !char.IsRPCErrorCode(err, char.RPCErrInvalidParameter) {
+		t.Error("IsRPCErrorCode should match")
+	}
+	if char.IsRetryable(err) {
+		t.Error("invalid parameter errors should not be retryable")
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err := srv.Client().WaitForBallot(cancelled, testDomain, 0, config.PollingConfig{IntervalMS: 10})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
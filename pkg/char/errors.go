@@ -0,0 +1,94 @@
+package char
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrConfirmationTimeout is returned when a ballot is not decided within the polling limits
+var ErrConfirmationTimeout = errors.New("timed out waiting for ballot decision")
+
+// BallotLostError is returned when the target ballot was decided without our payload,
+// either because another writer's vote won or because the ballot was decided empty
+type BallotLostError struct {
+	BallotNumber int
+	DecidedData  string // Decided payload hex after stripping wrappers (empty for an empty ballot)
+}
+
+func (e *BallotLostError) Error() string {
+	if e.DecidedData == "" {
+		return fmt.Sprintf("ballot %d was decided empty; submitted payload was not included", e.BallotNumber)
+	}
+	return fmt.Sprintf("ballot %d was won by another payload; submitted payload was not included", e.BallotNumber)
+}
+
+// JSON-RPC error codes returned by CHAR (bitcoind) nodes
+const (
+	RPCErrMisc             = -1     // General application error
+	RPCErrInvalidParameter = -8     // Invalid, missing or duplicate parameter
+	RPCErrInWarmup         = -28    // Node is still starting up (loading blocks, verifying, ...)
+	RPCErrInvalidRequest   = -32600 // Malformed JSON-RPC request
+	RPCErrMethodNotFound   = -32601 // Method not found or not enabled on the node
+	RPCErrInvalidParams    = -32602 // Invalid method parameters
+	RPCErrInternal         = -32603 // Internal node error
+	RPCErrParse            = -32700 // Request body could not be parsed
+)
+
+// RPCError represents a JSON-RPC error
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Method  string `json:"-"` // RPC method that failed, filled in by the client
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("RPC error code %d: %s", e.Code, e.Message)
+}
+
+// HTTPError is returned when the node answers with a non-200 status and no JSON-RPC body
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP status %d: %s", e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// IsRPCErrorCode reports whether err is (or wraps) an RPC error with the given code
+func IsRPCErrorCode(err error, code int) bool {
+	var rpcErr *RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == code
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying:
+// network errors, 5xx/429 HTTP responses and RPC warmup errors.
+// Context cancellation is never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == RPCErrInWarmup
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500 || httpErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,126 @@
+package char
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrMalformedWrapper is returned when decided data starts with a CHAR wrapper that is
+// truncated or declares more payload than it carries. Decided data comes from any
+// participant, so it is never trusted to be well formed.
+var ErrMalformedWrapper = errors.New("malformed CHAR wrapper")
+
+// StripWrappers removes CHAR slot wrapper or referendum vote wrapper from payload hex
+//
+// Data that is not hex, is too short to carry a wrapper or does not start with one is
+// returned unchanged. A wrapper that does not fit the data is an ErrMalformedWrapper.
+func StripWrappers(hexData string) (string, error) {
+	data, err := hex.DecodeString(hexData)
+	if err != nil || len(data) < 3 {
+		return hexData, nil
+	}
+
+	// Check if it starts with CHAR slot wrapper: 0000
+	if data[0] == 0x00 && data[1] == 0x00 {
+		// CHAR slot format: [0x00][0x00][CompactSize length][payload]
+		return stripSlotWrapper(hexData)
+	}
+
+	// Check if it starts with referendum vote wrapper: 0x00 (leaf type)
+	if data[0] == 0x00 {
+		// Referendum vote format: [0x00][varint ballot][compact_size length][payload]
+		return stripReferendumVoteWrapper(hexData)
+	}
+
+	// No recognized wrapper, return as-is
+	return hexData, nil
+}
+
+// stripSlotWrapper removes CHAR slot format wrapper
+func stripSlotWrapper(hexData string) (string, error) {
+	data, _ := hex.DecodeString(hexData)
+	if len(data) < 3 {
+		return hexData, nil
+	}
+
+	// Parse CompactSize at position 2
+	offset, err := skipCompactSizePayload(data, 2)
+	if err != nil {
+		return "", fmt.Errorf("slot wrapper: %w", err)
+	}
+	return hexData[offset*2:], nil
+}
+
+// stripReferendumVoteWrapper removes referendum vote wrapper
+func stripReferendumVoteWrapper(hexData string) (string, error) {
+	data, _ := hex.DecodeString(hexData)
+	if len(data) < 3 {
+		return hexData, nil
+	}
+
+	offset := 1 // Skip leaf type byte
+
+	// Skip Go varint ballot number (we need to read it to know its length)
+	for {
+		if offset >= len(data) {
+			return "", fmt.Errorf("referendum vote: %w: truncated ballot number", ErrMalformedWrapper)
+		}
+		last := data[offset] < 0x80
+		offset++
+		if last {
+			break
+		}
+	}
+
+	// Skip CompactSize payload length
+	offset, err := skipCompactSizePayload(data, offset)
+	if err != nil {
+		return "", fmt.Errorf("referendum vote: %w", err)
+	}
+
+	// Return payload after all wrappers
+	return hexData[offset*2:], nil
+}
+
+// skipCompactSizePayload reads the CompactSize payload length at data[offset] and
+// returns the offset of the payload, checking the length and payload fit in data
+func skipCompactSizePayload(data []byte, offset int) (int, error) {
+	if offset >= len(data) {
+		return 0, fmt.Errorf("%w: missing payload length", ErrMalformedWrapper)
+	}
+
+	// Width of the little-endian length after the prefix byte
+	width := 0
+	switch data[offset] {
+	case 0xfd:
+		width = 2
+	case 0xfe:
+		width = 4
+	case 0xff:
+		width = 8
+	}
+
+	start := offset + 1 + width
+	if start > len(data) {
+		return 0, fmt.Errorf("%w: truncated payload length", ErrMalformedWrapper)
+	}
+
+	var length uint64
+	switch width {
+	case 0:
+		length = uint64(data[offset])
+	case 2:
+		length = uint64(binary.LittleEndian.Uint16(data[offset+1:]))
+	case 4:
+		length = uint64(binary.LittleEndian.Uint32(data[offset+1:]))
+	case 8:
+		length = binary.LittleEndian.Uint64(data[offset+1:])
+	}
+	if length > uint64(len(data)-start) {
+		return 0, fmt.Errorf("%w: payload length %d exceeds %d bytes of data", ErrMalformedWrapper, length, len(data)-start)
+	}
+
+	return start, nil
+}
@@ -0,0 +1,115 @@
+package char
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStripWrappers(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "no wrapper - returns unchanged",
+			input:    "0101036162630a7b7d",
+			expected: "0101036162630a7b7d",
+		},
+		{
+			name:     "too short - returns unchanged",
+			input:    "00",
+			expected: "00",
+		},
+		{
+			name:     "invalid hex - returns unchanged",
+			input:    "not-hex!",
+			expected: "not-hex!",
+		},
+		{
+			name:     "CHAR slot wrapper short length",
+			input:    "000005" + "0101036162630a7b7d", // 0000 + length(5) + payload
+			expected: "0101036162630a7b7d",
+		},
+		{
+			name:     "CHAR slot wrapper 0xfd length",
+			input:    "0000fd0300" + "616263",
+			expected: "616263",
+		},
+		{
+			name:     "referendum vote wrapper",
+			input:    "00" + "8001" + "03" + "616263", // leaf + varint ballot 128 + length(3) + payload
+			expected: "616263",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := StripWrappers(tt.input)
+			if err != nil {
+				t.Fatalf("StripWrappers(%q) failed: %v", tt.input, err)
+			}
+			if result != tt.expected {
+				t.Errorf("StripWrappers(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStripSlotWrapper(t *testing.T) {
+	// Test with different CompactSize lengths
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "1-byte length",
+			input:    "000003616263", // 0000 + 03 + "abc"
+			expected: "616263",
+		},
+		{
+			name:     "too short",
+			input:    "0000",
+			expected: "0000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := stripSlotWrapper(tt.input)
+			if err != nil {
+				t.Fatalf("stripSlotWrapper(%q) failed: %v", tt.input, err)
+			}
+			if result != tt.expected {
+				t.Errorf("stripSlotWrapper(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStripWrappersMalformed(t *testing.T) {
+	// Decided data comes from any participant; none of these may panic
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "truncated 0xfd length", input: "0000fd01"},
+		{name: "truncated 0xfe length", input: "0000fe0102"},
+		{name: "truncated 0xff length", input: "0000ff01020304"},
+		{name: "length past end of data", input: "000005616263"},
+		{name: "0xff length past end of data", input: "0000ff0200000000000000" + "61"},
+		{name: "referendum vote truncated 0xff length", input: "0001ff"},
+		{name: "referendum vote truncated ballot number", input: "008080"},
+		{name: "referendum vote missing length", input: "008001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := StripWrappers(tt.input)
+			if !errors.Is(err, ErrMalformedWrapper) {
+				t.Errorf("StripWrappers(%q) = %q, %v; want ErrMalformedWrapper", tt.input, result, err)
+			}
+		})
+	}
+}
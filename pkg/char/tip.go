@@ -0,0 +1,118 @@
+package char
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultBallotInterval is how often CHAR decides a ballot
+const DefaultBallotInterval = 20 * time.Second
+
+// maxTipProbes bounds the number of RPC calls FindTip makes before giving up
+const maxTipProbes = 128
+
+// TipEstimate is a previously observed tip: the first undecided ballot at a point in time
+type TipEstimate struct {
+	Ballot     int
+	ObservedAt time.Time
+}
+
+// Predict extrapolates the tip at now, assuming one ballot is decided every interval
+func (t TipEstimate) Predict(now time.Time, interval time.Duration) int {
+	if interval <= 0 {
+		interval = DefaultBallotInterval
+	}
+
+	elapsed := now.Sub(t.ObservedAt)
+	if elapsed <= 0 {
+		return t.Ballot
+	}
+	return t.Ballot + int(elapsed/interval)
+}
+
+// FindTip returns the first undecided ballot (the one currently open for votes)
+//
+// Ballots are decided in order, so "found" is true for every ballot below the tip
+// and false from the tip onwards. Starting at hint, FindTip gallops up or down in
+// doubling steps until it brackets the tip, then binary searches the bracket.
+// A hint within k ballots of the tip costs about 2*log2(k) calls.
+func (c *Client) FindTip(ctx context.Context, domain string, hint int) (int, error) {
+	if hint < 0 {
+		hint = 0
+	}
+
+	probes := 0
+	decided := func(ballot int) (bool, error) {
+		probes++
+		if probes > maxTipProbes {
+			return false, fmt.Errorf("tip not found after %d probes starting at ballot %d", maxTipProbes, hint)
+		}
+		roll, err := c.GetReferendumDecisionRoll(ctx, domain, ballot, 0)
+		if err != nil {
+			return false, fmt.Errorf("failed to query ballot %d: %w", ballot, err)
+		}
+		return roll.Found, nil
+	}
+
+	found, err := decided(hint)
+	if err != nil {
+		return 0, err
+	}
+
+	// lo is known decided and hi known undecided; lo == -1 means nothing below hi is decided
+	var lo, hi int
+	if found {
+		// Hint is behind the tip: gallop forward
+		lo = hint
+		for step := 1; ; step *= 2 {
+			next := lo + step
+			found, err := decided(next)
+			if err != nil {
+				return 0, err
+			}
+			if !found {
+				hi = next
+				break
+			}
+			lo = next
+		}
+	} else {
+		// Hint is at or past the tip: gallop backward
+		hi = hint
+		lo = -1
+		for step := 1; hi > 0; step *= 2 {
+			next := hi - step
+			if next < 0 {
+				next = 0
+			}
+			found, err := decided(next)
+			if err != nil {
+				return 0, err
+			}
+			if found {
+				lo = next
+				break
+			}
+			hi = next
+		}
+		if lo < 0 {
+			return hi, nil
+		}
+	}
+
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		found, err := decided(mid)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	return hi, nil
+}
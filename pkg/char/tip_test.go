@@ -0,0 +1,104 @@
+package char_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+)
+
+func TestFindTip(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+	for i := 0; i < 5000; i++ {
+		srv.Advance()
+	}
+
+	tests := []struct {
+		name      string
+		hint      int
+		maxProbes int
+	}{
+		{"from genesis", 0, 26},
+		{"behind tip", 4990, 10},
+		{"at tip", 5000, 2},
+		{"just past tip", 5001, 4},
+		{"far past tip", 1000000, 42},
+		{"negative hint", -5, 26},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := srv.Calls("getreferendumdecisionroll")
+
+			tip, err := srv.Client().FindTip(ctx, testDomain, tt.hint)
+			if err != nil {
+				t.Fatalf("FindTip failed: %v", err)
+			}
+			if tip != 5000 {
+				t.Errorf("tip = %d, want 5000", tip)
+			}
+
+			if probes := srv.Calls("getreferendumdecisionroll") - before; probes > tt.maxProbes {
+				t.Errorf("used %d probes, want at most %d", probes, tt.maxProbes)
+			}
+		})
+	}
+}
+
+func TestFindTipNothingDecided(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{})
+	defer srv.Close()
+
+	for _, hint := range []int{0, 1, 37} {
+		tip, err := srv.Client().FindTip(ctx, testDomain, hint)
+		if err != nil {
+			t.Fatalf("FindTip(%d) failed: %v", hint, err)
+		}
+		if tip != 0 {
+			t.Errorf("FindTip(%d) = %d, want 0", hint, tip)
+		}
+	}
+}
+
+func TestFindTipStartBallot(t *testing.T) {
+	srv := chartest.NewServer(chartest.Options{StartBallot: 12})
+	defer srv.Close()
+	srv.Advance()
+
+	tip, err := srv.Client().GetNextAvailableBallot(ctx, testDomain, 0)
+	if err != nil {
+		t.Fatalf("GetNextAvailableBallot failed: %v", err)
+	}
+	if tip != 13 {
+		t.Errorf("tip = %d, want 13", tip)
+	}
+}
+
+func TestTipEstimatePredict(t *testing.T) {
+	observed := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	est := char.TipEstimate{Ballot: 100, ObservedAt: observed}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		interval time.Duration
+		want     int
+	}{
+		{"same instant", observed, char.DefaultBallotInterval, 100},
+		{"clock went backwards", observed.Add(-time.Hour), char.DefaultBallotInterval, 100},
+		{"partial interval", observed.Add(19 * time.Second), char.DefaultBallotInterval, 100},
+		{"one hour", observed.Add(time.Hour), char.DefaultBallotInterval, 280},
+		{"default interval", observed.Add(time.Minute), 0, 103},
+		{"custom interval", observed.Add(time.Minute), 10 * time.Second, 106},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := est.Predict(tt.now, tt.interval); got != tt.want {
+				t.Errorf("Predict = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,10 +8,13 @@ import (
 
 // Config holds all configuration for the did-char CLI
 type Config struct {
-	CHAR     CHARConfig     `yaml:"char"`
-	Database DatabaseConfig `yaml:"database"`
-	DataDir  DataDirConfig  `yaml:"data_dir"`
-	Polling  PollingConfig  `yaml:"polling"`
+	CHAR      CHARConfig      `yaml:"char"`
+	Database  DatabaseConfig  `yaml:"database"`
+	DataDir   DataDirConfig   `yaml:"data_dir"`
+	Polling   PollingConfig   `yaml:"polling"`
+	Content   ContentConfig   `yaml:"content"`
+	Payload   PayloadConfig   `yaml:"payload"`
+	Registrar RegistrarConfig `yaml:"registrar"`
 }
 
 // CHARConfig contains CHAR node connection settings
@@ -23,6 +26,15 @@ type CHARConfig struct {
 	Network     string `yaml:"network"`
 	AppDomain   string `yaml:"app_domain"`
 	AppPreimage string `yaml:"app_preimage"`
+
+	// RPC transport behavior
+	RequestTimeoutSeconds int `yaml:"request_timeout_seconds"` // Per-request HTTP timeout
+	MaxRetries            int `yaml:"max_retries"`             // Retries for transient failures (0 = no retries); submits only when the node did not act
+	RetryBaseMS           int `yaml:"retry_base_ms"`           // Initial backoff delay
+	RetryMaxMS            int `yaml:"retry_max_ms"`            // Backoff delay cap
+
+	// BallotIntervalSeconds is how often the node decides a ballot, used to predict the tip from the wall clock
+	BallotIntervalSeconds int `yaml:"ballot_interval_seconds"`
 }
 
 // DatabaseConfig contains database settings
@@ -44,6 +56,27 @@ type PollingConfig struct {
 	TimeoutSeconds int `yaml:"timeout_seconds"`
 }
 
+// ContentConfig contains settings for the content store holding anchored batches
+type ContentConfig struct {
+	Dir      string `yaml:"dir"`       // Local content store directory
+	FetchURL string `yaml:"fetch_url"` // HTTP content store to fetch batches missing locally (optional)
+}
+
+// PayloadConfig contains payload encoding settings and the size limits enforced when decoding
+type PayloadConfig struct {
+	Compression      string `yaml:"compression"`        // Compression of submitted batches: none, deflate or zstd
+	MaxSuffixSize    int    `yaml:"max_suffix_size"`    // Bytes in a DID suffix
+	MaxOperationSize int    `yaml:"max_operation_size"` // Bytes of operation JSON
+	MaxPayloadSize   int    `yaml:"max_payload_size"`   // Bytes of a payload or batch, after decompression
+}
+
+// RegistrarConfig contains settings for the HTTP registrar of `did-char serve`
+type RegistrarConfig struct {
+	// Token is the bearer token every registrar request must carry. Internal secret
+	// mode signs with the keys in the keys directory, so it is only served with a token.
+	Token string `yaml:"token"`
+}
+
 // DefaultConfig returns default configuration
 func DefaultConfig() *Config {
 	homeDir, _ := os.UserHomeDir()
@@ -58,6 +91,13 @@ func DefaultConfig() *Config {
 			Network:     "regtest",
 			AppDomain:   "did-char-domain",
 			AppPreimage: "did-char-domain", // Plain text, will be hex-encoded by client
+
+			RequestTimeoutSeconds: 30,
+			MaxRetries:            3,
+			RetryBaseMS:           200,
+			RetryMaxMS:            5000,
+
+			BallotIntervalSeconds: 20,
 		},
 		Database: DatabaseConfig{
 			Path: filepath.Join(dataDir, "did-char.db"),
@@ -68,9 +108,18 @@ func DefaultConfig() *Config {
 			DBPath:  filepath.Join(dataDir, "did-char.db"),
 		},
 		Polling: PollingConfig{
-			MaxAttempts:    300,  // Poll for up to 30 seconds
-			IntervalMS:     100,  // Check every 100ms
-			TimeoutSeconds: 10,
+			MaxAttempts:    600, // Poll for up to 60 seconds
+			IntervalMS:     100, // Check every 100ms
+			TimeoutSeconds: 60,  // Ballots are decided every 20s, allow a few to pass
+		},
+		Content: ContentConfig{
+			Dir: filepath.Join(dataDir, "cas"),
+		},
+		Payload: PayloadConfig{
+			Compression:      "none",
+			MaxSuffixSize:    256,       // Suffixes are 46 characters
+			MaxOperationSize: 256 << 10, // 256 KiB
+			MaxPayloadSize:   16 << 20,  // 16 MiB
 		},
 	}
 }
@@ -104,8 +153,21 @@ func LoadConfig(cfgFile string) (*Config, error) {
 	if val := os.Getenv("CHAR_APP_DOMAIN"); val != "" {
 		cfg.CHAR.AppDomain = val
 	}
+	if val := os.Getenv("CAS_DIR"); val != "" {
+		cfg.Content.Dir = val
+	}
+	if val := os.Getenv("CAS_FETCH_URL"); val != "" {
+		cfg.Content.FetchURL = val
+	}
+	if val := os.Getenv("PAYLOAD_COMPRESSION"); val != "" {
+		cfg.Payload.Compression = val
+	}
+	if val := os.Getenv("REGISTRAR_TOKEN"); val != "" {
+		cfg.Registrar.Token = val
+	}
 	if val := os.Getenv("DB_PATH"); val != "" {
 		cfg.Database.Path = val
+		cfg.DataDir.DBPath = val
 	}
 
 	// Ensure data directories exist
@@ -0,0 +1,33 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestBase58Encode(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string // hex encoded
+		expected string
+	}{
+		{"empty", "", ""},
+		{"single zero", "00", "1"},
+		{"leading zeros", "00000000287fb4cd", "1111233QC4"},
+		{"hello world", hex.EncodeToString([]byte("Hello World!")), "2NEpo7TZRRrLZSi2U"},
+		{"two leading zeros", "0000287fb4cd", "11233QC4"},
+		{"ed25519 multikey", "ed01" + "3b6a27bcceb6a42d62a3a8d02a6f0d73653215771de243a63ac048a18b59da29", "6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tt.input)
+			if err != nil {
+				t.Fatalf("bad test input: %v", err)
+			}
+			if got := Base58Encode(data); got != tt.expected {
+				t.Errorf("Base58Encode(%s) = %s, want %s", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
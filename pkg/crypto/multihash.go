@@ -0,0 +1,142 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// HashAlgorithm is a hash function, identified by its multicodec code
+type HashAlgorithm uint64
+
+const (
+	SHA2_256   HashAlgorithm = 0x12
+	SHA3_256   HashAlgorithm = 0x16
+	Blake2b256 HashAlgorithm = 0xb220
+)
+
+// DefaultHashAlgorithm is the algorithm new suffixes, reveals, commitments and delta hashes use
+const DefaultHashAlgorithm = SHA2_256
+
+// String returns the multicodec name of the algorithm
+func (a HashAlgorithm) String() string {
+	switch a {
+	case SHA2_256:
+		return "sha2-256"
+	case SHA3_256:
+		return "sha3-256"
+	case Blake2b256:
+		return "blake2b-256"
+	default:
+		return fmt.Sprintf("HashAlgorithm(0x%x)", uint64(a))
+	}
+}
+
+// Size returns the digest size of the algorithm in bytes, or 0 if it is not supported
+func (a HashAlgorithm) Size() int {
+	switch a {
+	case SHA2_256, SHA3_256, Blake2b256:
+		return 32
+	default:
+		return 0
+	}
+}
+
+// Sum returns the digest of data
+func (a HashAlgorithm) Sum(data []byte) ([]byte, error) {
+	var digest [32]byte
+	switch a {
+	case SHA2_256:
+		digest = sha256.Sum256(data)
+	case SHA3_256:
+		digest = sha3.Sum256(data)
+	case Blake2b256:
+		digest = blake2b.Sum256(data)
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %s", a)
+	}
+	return digest[:], nil
+}
+
+// EncodeMultihash prefixes digest with the varint code of alg and the varint digest length
+func EncodeMultihash(alg HashAlgorithm, digest []byte) []byte {
+	mh := binary.AppendUvarint(nil, uint64(alg))
+	mh = binary.AppendUvarint(mh, uint64(len(digest)))
+	return append(mh, digest...)
+}
+
+// DecodeMultihash splits a multihash into its algorithm and digest
+//
+// The algorithm must be supported and the digest exactly its size.
+func DecodeMultihash(mh []byte) (HashAlgorithm, []byte, error) {
+	code, n := binary.Uvarint(mh)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("invalid multihash code")
+	}
+	alg := HashAlgorithm(code)
+	if alg.Size() == 0 {
+		return 0, nil, fmt.Errorf("unsupported hash algorithm %s", alg)
+	}
+
+	length, m := binary.Uvarint(mh[n:])
+	if m <= 0 {
+		return 0, nil, fmt.Errorf("invalid multihash length")
+	}
+	digest := mh[n+m:]
+	if length != uint64(alg.Size()) || len(digest) != alg.Size() {
+		return 0, nil, fmt.Errorf("%s multihash digest is not %d bytes", alg, alg.Size())
+	}
+	return alg, digest, nil
+}
+
+// HashToMultihash hashes data with the default algorithm and returns the base64url multihash
+func HashToMultihash(data []byte) string {
+	mh, _ := MultihashToBase64URL(DefaultHashAlgorithm, data)
+	return mh
+}
+
+// MultihashToBase64URL hashes data with alg and returns the base64url multihash
+func MultihashToBase64URL(alg HashAlgorithm, data []byte) (string, error) {
+	digest, err := alg.Sum(data)
+	if err != nil {
+		return "", err
+	}
+	return Base64URLEncode(EncodeMultihash(alg, digest)), nil
+}
+
+// DecodeHash decodes a base64url hash to its algorithm and digest
+//
+// A base64url multihash names its algorithm. A bare 32-byte digest, as written
+// before hashes were multihashes, is SHA-256; it can never be mistaken for a
+// multihash, whose prefix leaves room for a 30-byte digest at most.
+func DecodeHash(encoded string) (HashAlgorithm, []byte, error) {
+	data, err := Base64URLDecode(encoded)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid hash encoding: %w", err)
+	}
+	if alg, digest, err := DecodeMultihash(data); err == nil {
+		return alg, digest, nil
+	}
+	if len(data) == sha256.Size {
+		return SHA2_256, data, nil
+	}
+	return 0, nil, fmt.Errorf("hash is neither a supported multihash nor a SHA-256 digest")
+}
+
+// HashMatches reports whether encoded, as accepted by DecodeHash, is the hash of data
+// under the algorithm it names
+func HashMatches(encoded string, data []byte) bool {
+	alg, digest, err := DecodeHash(encoded)
+	if err != nil {
+		return false
+	}
+	actual, err := alg.Sum(data)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(actual, digest) == 1
+}
@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestMultihash(t *testing.T) {
+	tests := []struct {
+		alg      HashAlgorithm
+		input    string
+		expected string // hex encoded multihash
+	}{
+		{SHA2_256, "hello world", "1220b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"},
+		{SHA3_256, "", "1620a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a"},
+		{Blake2b256, "", "a0e402200e5751c026e543b2e8ab2eb06099daa1d1e5df47778f7787faab45cdf12fe3a8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg.String(), func(t *testing.T) {
+			digest, err := tt.alg.Sum([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Sum failed: %v", err)
+			}
+			mh := EncodeMultihash(tt.alg, digest)
+			if got := hex.EncodeToString(mh); got != tt.expected {
+				t.Errorf("multihash = %s, want %s", got, tt.expected)
+			}
+
+			alg, decoded, err := DecodeMultihash(mh)
+			if err != nil {
+				t.Fatalf("DecodeMultihash failed: %v", err)
+			}
+			if alg != tt.alg || hex.EncodeToString(decoded) != hex.EncodeToString(digest) {
+				t.Errorf("DecodeMultihash = %s %x", alg, decoded)
+			}
+
+			encoded, _ := MultihashToBase64URL(tt.alg, []byte(tt.input))
+			if !HashMatches(encoded, []byte(tt.input)) {
+				t.Error("hash should match its input")
+			}
+			if HashMatches(encoded, []byte(tt.input+"!")) {
+				t.Error("hash should not match other input")
+			}
+		})
+	}
+}
+
+func TestHashToMultihashIsSidetreeEncoded(t *testing.T) {
+	// Sidetree's SHA-256 multihashes all start with "Ei"
+	if got := HashToMultihash([]byte("hello world")); !strings.HasPrefix(got, "Ei") || len(got) != 46 {
+		t.Errorf("HashToMultihash = %s", got)
+	}
+}
+
+func TestDecodeMultihashErrors(t *testing.T) {
+	digest := strings.Repeat("00", 32)
+	tests := []struct {
+		name string
+		mh   string // hex encoded
+	}{
+		{"empty", ""},
+		{"unsupported algorithm", "1320" + digest},
+		{"short digest", "121f" + digest[2:]},
+		{"long digest", "1220" + digest + "00"},
+		{"length disagrees with digest", "1221" + digest},
+		{"truncated length", "12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mh, _ := hex.DecodeString(tt.mh)
+			if _, _, err := DecodeMultihash(mh); err == nil {
+				t.Errorf("DecodeMultihash(%s) should fail", tt.mh)
+			}
+		})
+	}
+}
+
+func TestDecodeHashLegacy(t *testing.T) {
+	data := []byte("anchored before multihashes")
+	legacy := HashToBase64URL(data)
+
+	alg, digest, err := DecodeHash(legacy)
+	if err != nil {
+		t.Fatalf("DecodeHash failed: %v", err)
+	}
+	if alg != SHA2_256 || Base64URLEncode(digest) != legacy {
+		t.Errorf("DecodeHash = %s %x", alg, digest)
+	}
+	if !HashMatches(legacy, data) {
+		t.Error("bare SHA-256 hash should match its input")
+	}
+
+	if _, _, err := DecodeHash(Base64URLEncode([]byte("too short"))); err == nil {
+		t.Error("expected a hash that is neither multihash nor SHA-256 to be refused")
+	}
+	if HashMatches("not base64!", data) {
+		t.Error("invalid encoding should not match")
+	}
+}
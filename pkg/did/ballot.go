@@ -0,0 +1,108 @@
+package did
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/config"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// Keys used in the sync_state table
+const (
+	syncStateLastSynced    = "last_synced_ballot"
+	syncStateTipBallot     = "tip_ballot"
+	syncStateTipObservedAt = "tip_observed_at"
+)
+
+// findNextBallot locates the ballot a new operation should be submitted to
+//
+// The search starts from the cached tip extrapolated along the wall clock, or the
+// last synced ballot if that is further ahead. The discovered tip is cached in
+// sync_state so the next writer starts close to it.
+func findNextBallot(ctx context.Context, cfg *config.Config, store *storage.Store, charClient *char.Client) (int, error) {
+	hint, err := syncStateInt(store, syncStateLastSynced)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	cached, err := loadTipEstimate(store)
+	if err != nil {
+		return 0, err
+	}
+	if cached != nil {
+		interval := time.Duration(cfg.CHAR.BallotIntervalSeconds) * time.Second
+		if predicted := cached.Predict(now, interval); predicted > hint {
+			hint = predicted
+		}
+	}
+
+	ballot, err := charClient.GetNextAvailableBallot(ctx, cfg.CHAR.AppPreimage, hint)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := saveTipEstimate(store, char.TipEstimate{Ballot: ballot, ObservedAt: now}); err != nil {
+		return 0, err
+	}
+
+	return ballot, nil
+}
+
+// loadTipEstimate reads the cached tip from sync_state, or nil if none has been recorded
+func loadTipEstimate(store *storage.Store) (*char.TipEstimate, error) {
+	ballotStr, err := store.GetSyncState(syncStateTipBallot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync state: %w", err)
+	}
+	observedStr, err := store.GetSyncState(syncStateTipObservedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync state: %w", err)
+	}
+	if ballotStr == "" || observedStr == "" {
+		return nil, nil
+	}
+
+	ballot, err := strconv.Atoi(ballotStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cached tip ballot in sync state: %w", err)
+	}
+	observedAt, err := time.Parse(time.RFC3339, observedStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cached tip time in sync state: %w", err)
+	}
+
+	return &char.TipEstimate{Ballot: ballot, ObservedAt: observedAt}, nil
+}
+
+// saveTipEstimate caches an observed tip in sync_state
+func saveTipEstimate(store *storage.Store, tip char.TipEstimate) error {
+	if err := store.SetSyncState(syncStateTipBallot, strconv.Itoa(tip.Ballot)); err != nil {
+		return fmt.Errorf("failed to save tip ballot: %w", err)
+	}
+	if err := store.SetSyncState(syncStateTipObservedAt, tip.ObservedAt.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to save tip time: %w", err)
+	}
+	return nil
+}
+
+// syncStateInt reads an integer sync_state value, returning 0 if it is unset
+func syncStateInt(store *storage.Store, key string) (int, error) {
+	value, err := store.GetSyncState(key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sync state: %w", err)
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s in sync state: %w", key, err)
+	}
+	return n, nil
+}
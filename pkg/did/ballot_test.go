@@ -0,0 +1,63 @@
+package did
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+)
+
+func TestFindNextBallotCachesTip(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{StartBallot: 3000})
+
+	ballot, err := findNextBallot(context.Background(), env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("findNextBallot failed: %v", err)
+	}
+	if ballot != 3000 {
+		t.Errorf("ballot = %d, want 3000", ballot)
+	}
+
+	cached, err := loadTipEstimate(env.store)
+	if err != nil {
+		t.Fatalf("loadTipEstimate failed: %v", err)
+	}
+	if cached == nil || cached.Ballot != 3000 {
+		t.Fatalf("cached tip = %+v, want ballot 3000", cached)
+	}
+
+	// A warm cache finds the tip in a couple of calls
+	env.srv.Advance()
+	before := env.srv.Calls("getreferendumdecisionroll")
+	ballot, err = findNextBallot(context.Background(), env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("findNextBallot failed: %v", err)
+	}
+	if ballot != 3001 {
+		t.Errorf("ballot = %d, want 3001", ballot)
+	}
+	if probes := env.srv.Calls("getreferendumdecisionroll") - before; probes > 3 {
+		t.Errorf("warm cache used %d probes, want at most 3", probes)
+	}
+}
+
+func TestFindNextBallotStalledNode(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{StartBallot: 50})
+
+	// The wall clock predicts ~1000 ballots since the cached observation,
+	// but the node stalled; the search must walk back to the real tip.
+	stale := char.TipEstimate{Ballot: 50, ObservedAt: time.Now().Add(-1000 * char.DefaultBallotInterval)}
+	if err := saveTipEstimate(env.store, stale); err != nil {
+		t.Fatalf("saveTipEstimate failed: %v", err)
+	}
+
+	ballot, err := findNextBallot(context.Background(), env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("findNextBallot failed: %v", err)
+	}
+	if ballot != 50 {
+		t.Errorf("ballot = %d, want 50", ballot)
+	}
+}
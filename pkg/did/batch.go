@@ -0,0 +1,386 @@
+package did
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/cas"
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/config"
+	"github.com/yourusername/did-char/pkg/encoding"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// Batcher collects signed local operations and anchors them together in one CHAR vote
+//
+// A batch may hold at most one operation per DID, since the processor rejects any
+// further operation on a DID within the same ballot. A Batcher is safe for concurrent
+// use, and a long-lived one lets concurrent writers share submissions: operations
+// queued while a batch is being submitted go out together in the next one.
+type Batcher struct {
+	cfg        *config.Config
+	store      *storage.Store
+	charClient *char.Client
+
+	submitMu sync.Mutex // Held for a whole submission, so batches go out one at a time
+
+	mu      sync.Mutex
+	content cas.Store   // Set to anchor batches by content hash
+	fetcher cas.Fetcher // Where other writers' anchored batches are fetched from while catching up
+	ops     []encoding.Operation
+	waiters []chan submitOutcome // Parallel to ops; nil for operations queued with Add
+	dids    map[string]bool      // Suffixes with an operation in ops
+}
+
+// maxWriteCatchUp bounds how many unsynced ballots a write processes before its own
+//
+// A write applies every ballot before its own first; a database further behind, such
+// as a new one, is refused before anything is anchored, rather than replaying the
+// chain while the caller waits. `did-char sync` or a follower catches it up.
+const maxWriteCatchUp = 1000
+
+// submitOutcome is what a submission reports to a waiting SubmitOne caller
+type submitOutcome struct {
+	ballot int
+	op     BatchOperationResult
+	err    error
+}
+
+// BatchResult reports where a batch was anchored and what happened to each operation
+type BatchResult struct {
+	BallotNumber int
+	Operations   []BatchOperationResult // In the order the operations were added
+}
+
+// BatchOperationResult is the outcome of one operation of a batch
+type BatchOperationResult struct {
+	DID     string
+	Type    string // OperationTypeCreate, OperationTypeUpdate, ...
+	Applied bool
+	Reason  string // Why the operation was rejected, if it was not applied
+}
+
+// NewBatcher creates an empty batcher
+func NewBatcher(cfg *config.Config, store *storage.Store, charClient *char.Client) *Batcher {
+	return &Batcher{
+		cfg:        cfg,
+		store:      store,
+		charClient: charClient,
+		dids:       make(map[string]bool),
+	}
+}
+
+// batcherFor returns b, or a new batcher for a batch of one if b is nil
+func batcherFor(b *Batcher, cfg *config.Config, store *storage.Store, charClient *char.Client) *Batcher {
+	if b != nil {
+		return b
+	}
+	return NewBatcher(cfg, store, charClient)
+}
+
+// SetContentStore switches the batcher to anchoring by content hash
+//
+// The encoded batch is put in content and the vote carries only its hash and
+// operation count, so batches are no longer limited by the size of a vote. Readers
+// need the content too, through a content fetcher on their Processor.
+func (b *Batcher) SetContentStore(content cas.Store) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.content = content
+}
+
+// SetContentFetcher sets where batches anchored by other writers are fetched from
+//
+// Submit catches up with every ballot before its own, so it needs the same content
+// as the node's Processor.
+func (b *Batcher) SetContentFetcher(f cas.Fetcher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fetcher = f
+}
+
+// Add queues an operation on the DID with the given suffix
+func (b *Batcher) Add(opType encoding.OperationType, suffix string, op interface{}) error {
+	return b.enqueue(opType, suffix, op, nil)
+}
+
+// AddPending queues a signed update, recover or deactivate operation
+func (b *Batcher) AddPending(p *PendingOperation) error {
+	opType, suffix, err := pendingOperation(p)
+	if err != nil {
+		return err
+	}
+	return b.Add(opType, suffix, p.op)
+}
+
+// SubmitOne queues an operation and returns its ballot once a submission carrying it
+// has been decided and the operation applied
+//
+// If another submission is in flight the operation waits for it and goes out in the
+// next one, together with anything else queued meanwhile.
+func (b *Batcher) SubmitOne(ctx context.Context, opType encoding.OperationType, suffix string, op interface{}) (int, error) {
+	done := make(chan submitOutcome, 1)
+	if err := b.enqueue(opType, suffix, op, done); err != nil {
+		return 0, err
+	}
+
+	// Submissions report to their waiters before releasing submitMu, so once it is
+	// held the operation has either gone out already or is still queued
+	var out submitOutcome
+	b.submitMu.Lock()
+	select {
+	case out = <-done:
+	default:
+		b.submitLocked(ctx)
+		out = <-done
+	}
+	b.submitMu.Unlock()
+
+	if out.err != nil {
+		return 0, out.err
+	}
+	if !out.op.Applied {
+		return 0, fmt.Errorf("operation on ballot %d was not applied: %s", out.ballot, out.op.Reason)
+	}
+	return out.ballot, nil
+}
+
+// SubmitPending is SubmitOne for a signed update, recover or deactivate operation
+func (b *Batcher) SubmitPending(ctx context.Context, p *PendingOperation) (int, error) {
+	opType, suffix, err := pendingOperation(p)
+	if err != nil {
+		return 0, err
+	}
+	return b.SubmitOne(ctx, opType, suffix, p.op)
+}
+
+// enqueue queues an operation, with done to report its outcome to if not nil
+func (b *Batcher) enqueue(opType encoding.OperationType, suffix string, op interface{}, done chan submitOutcome) error {
+	encoded, err := encoding.NewOperation(opType, suffix, op)
+	if err != nil {
+		return err
+	}
+
+	if err := PayloadLimits(b.cfg).CheckOperation(encoded); err != nil {
+		return fmt.Errorf("operation on %s is too large: %w", FormatDID(suffix), err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.dids[suffix] {
+		return fmt.Errorf("batch already has an operation for %s", FormatDID(suffix))
+	}
+	b.dids[suffix] = true
+	b.ops = append(b.ops, encoded)
+	b.waiters = append(b.waiters, done)
+	return nil
+}
+
+// pendingOperation returns the payload type and DID suffix of a signed pending operation
+func pendingOperation(p *PendingOperation) (encoding.OperationType, string, error) {
+	if !p.signed {
+		return 0, "", fmt.Errorf("%s operation has not been signed", p.Type)
+	}
+
+	opType, err := payloadOperationType(p.Type)
+	if err != nil {
+		return 0, "", err
+	}
+	suffix, err := ParseDID(p.DID)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to parse DID: %w", err)
+	}
+	return opType, suffix, nil
+}
+
+// Len returns the number of queued operations
+func (b *Batcher) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.ops)
+}
+
+// Submit anchors the queued operations in one vote and applies the decided ballot
+//
+// Every ballot before the batch's own is processed first, in order, so the batch is
+// applied against the same state as on every other node. The queue is emptied
+// whether or not the submission succeeds. Operations are reported individually,
+// since some may be rejected while others are applied.
+func (b *Batcher) Submit(ctx context.Context) (*BatchResult, error) {
+	b.submitMu.Lock()
+	defer b.submitMu.Unlock()
+	return b.submitLocked(ctx)
+}
+
+// submitLocked implements Submit with submitMu held, reporting to any waiters
+func (b *Batcher) submitLocked(ctx context.Context) (*BatchResult, error) {
+	b.mu.Lock()
+	ops, waiters := b.ops, b.waiters
+	content, fetcher := b.content, b.fetcher
+	b.ops, b.waiters = nil, nil
+	b.dids = make(map[string]bool)
+	b.mu.Unlock()
+
+	result, err := b.submit(ctx, ops, content, fetcher)
+	for i, done := range waiters {
+		if done == nil {
+			continue
+		}
+		if err != nil {
+			done <- submitOutcome{err: err}
+			continue
+		}
+		done <- submitOutcome{ballot: result.BallotNumber, op: result.Operations[i]}
+	}
+	return result, err
+}
+
+// submit anchors ops in one vote, catches up to its ballot and reports what was applied
+func (b *Batcher) submit(ctx context.Context, ops []encoding.Operation, content cas.Store, fetcher cas.Fetcher) (*BatchResult, error) {
+	compression, err := encoding.ParseCompression(b.cfg.Payload.Compression)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("batch has no operations")
+	}
+
+	// Encode payload
+	payloadHex, err := encodeBatch(ctx, ops, compression, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	// Find the ballot currently open for votes
+	probedAt := time.Now()
+	ballotNumber, err := findNextBallot(ctx, b.cfg, b.store, b.charClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find available ballot: %w", err)
+	}
+
+	// Our ballot was still open, so it is decided after probedAt
+	processor := NewProcessor(b.store, b.charClient, b.cfg.CHAR.AppPreimage)
+	processor.undecided.observe(ballotNumber, probedAt)
+	next, err := processor.NextUnsyncedBallot()
+	if err != nil {
+		return nil, err
+	}
+	if behind := ballotNumber - next; behind > maxWriteCatchUp {
+		return nil, fmt.Errorf("database is %d ballots behind CHAR (next unsynced ballot %d, open ballot %d); run sync first", behind, next, ballotNumber)
+	}
+
+	// Submit to CHAR and wait for confirmation
+	if err := b.charClient.SubmitAndWaitForConfirmation(
+		ctx,
+		b.cfg.CHAR.AppPreimage,
+		payloadHex,
+		ballotNumber,
+		b.cfg.Polling,
+	); err != nil {
+		return nil, fmt.Errorf("failed to submit and confirm: %w", err)
+	}
+
+	// Now process the ballots up to ours to write to SQLite
+	processor.SetLimits(PayloadLimits(b.cfg))
+	var fetchers cas.Chain
+	if content != nil {
+		fetchers = append(fetchers, content)
+	}
+	if fetcher != nil {
+		fetchers = append(fetchers, fetcher)
+	}
+	if len(fetchers) > 0 {
+		processor.SetContentFetcher(fetchers)
+	}
+	if err := processor.SyncThrough(ctx, ballotNumber); err != nil {
+		return nil, fmt.Errorf("failed to process ballot: %w", err)
+	}
+
+	return batchResult(b.store, ballotNumber, ops)
+}
+
+// encodeBatch encodes ops as a batch payload, or stores them in content and encodes an anchor
+//
+// Batches are always encoded as canonical batches, since operations built by this
+// package are hashed over JCS.
+func encodeBatch(ctx context.Context, ops []encoding.Operation, compression encoding.Compression, content cas.Store) (string, error) {
+	data, err := encoding.EncodeCanonicalBatch(ops, compression)
+	if err != nil {
+		return "", err
+	}
+	if content == nil {
+		return hex.EncodeToString(data), nil
+	}
+
+	hash, err := content.Put(ctx, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to store batch: %w", err)
+	}
+	return encoding.EncodeAnchorPayload(encoding.Anchor{ContentHash: hash, OperationCount: len(ops)})
+}
+
+// batchResult looks up what processing a ballot recorded for each operation of its batch
+func batchResult(store *storage.Store, ballotNumber int, ops []encoding.Operation) (*BatchResult, error) {
+	applied, err := store.GetOperationsAtBallot(ballotNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load operations for ballot %d: %w", ballotNumber, err)
+	}
+	rejected, err := store.GetRejectedOperationsAtBallot(ballotNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rejected operations for ballot %d: %w", ballotNumber, err)
+	}
+
+	isApplied := make(map[int]bool)
+	for _, op := range applied {
+		isApplied[op.OperationIndex] = true
+	}
+	reasons := make(map[int]string)
+	for _, r := range rejected {
+		reasons[r.OperationIndex] = r.Reason
+	}
+
+	result := &BatchResult{BallotNumber: ballotNumber}
+	for i, op := range ops {
+		opResult := BatchOperationResult{
+			DID:     FormatDID(op.DIDSuffix),
+			Type:    operationTypeName(op.Type),
+			Applied: isApplied[i],
+			Reason:  reasons[i],
+		}
+		if !opResult.Applied && opResult.Reason == "" {
+			opResult.Reason = "not recorded on ballot"
+		}
+		result.Operations = append(result.Operations, opResult)
+	}
+
+	return result, nil
+}
+
+// PayloadLimits returns the payload size limits set in cfg
+func PayloadLimits(cfg *config.Config) encoding.Limits {
+	return encoding.Limits{
+		MaxSuffixSize:    cfg.Payload.MaxSuffixSize,
+		MaxOperationSize: cfg.Payload.MaxOperationSize,
+		MaxPayloadSize:   cfg.Payload.MaxPayloadSize,
+	}
+}
+
+// payloadOperationType returns the payload operation type for a stored operation name
+func payloadOperationType(name string) (encoding.OperationType, error) {
+	switch name {
+	case OperationTypeCreate:
+		return encoding.OperationTypeCreate, nil
+	case OperationTypeUpdate:
+		return encoding.OperationTypeUpdate, nil
+	case OperationTypeRecover:
+		return encoding.OperationTypeRecover, nil
+	case OperationTypeDeactivate:
+		return encoding.OperationTypeDeactivate, nil
+	default:
+		return 0, fmt.Errorf("unknown operation type %q", name)
+	}
+}
@@ -0,0 +1,525 @@
+package did
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/did-char/pkg/cas"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+	"github.com/yourusername/did-char/pkg/encoding"
+)
+
+func TestIntegrationBatchSharesBallot(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	batch := NewBatcher(env.cfg, env.store, env.client)
+
+	if _, err := batch.Submit(context.Background()); err == nil {
+		t.Fatal("expected empty batch to be refused")
+	}
+
+	var dids []string
+	for i := 0; i < 3; i++ {
+		op, did := newTestCreateOperation(t)
+		suffix, _ := ParseDID(did)
+		if err := batch.Add(encoding.OperationTypeCreate, suffix, op); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if i == 0 {
+			if err := batch.Add(encoding.OperationTypeCreate, suffix, op); err == nil {
+				t.Fatal("expected second operation for the same DID to be refused")
+			}
+		}
+		dids = append(dids, did)
+	}
+	if batch.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", batch.Len())
+	}
+
+	result, err := batch.Submit(context.Background())
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if batch.Len() != 0 {
+		t.Error("Submit did not empty the batch")
+	}
+	if env.srv.Submissions() != 1 {
+		t.Errorf("submissions = %d, want 1", env.srv.Submissions())
+	}
+
+	for i, op := range result.Operations {
+		if op.DID != dids[i] || op.Type != OperationTypeCreate || !op.Applied {
+			t.Errorf("operation %d = %+v", i, op)
+		}
+		record, _ := loadDocument(t, env.store, dids[i])
+		if record.CreatedAtBallot != result.BallotNumber {
+			t.Errorf("%s created at ballot %d, want %d", dids[i], record.CreatedAtBallot, result.BallotNumber)
+		}
+	}
+
+	ops, _ := env.store.GetOperationsAtBallot(result.BallotNumber)
+	for i, op := range ops {
+		if op.OperationIndex != i || op.DID != dids[i] {
+			t.Errorf("stored operation %d = %+v", i, op)
+		}
+	}
+}
+
+func TestIntegrationBatchAppliesInOrder(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{})
+	domain := env.cfg.CHAR.AppPreimage
+
+	first, firstDID := newTestCreateOperation(t)
+	firstSuffix, _ := ParseDID(firstDID)
+	second, secondDID := newTestCreateOperation(t)
+	secondSuffix, _ := ParseDID(secondDID)
+
+	var ops []encoding.Operation
+	for _, tt := range []struct {
+		suffix string
+		op     *CreateOperation
+	}{
+		{firstSuffix, first},
+		{secondSuffix, &CreateOperation{Type: "create"}},
+		{firstSuffix, first},
+		{secondSuffix, second},
+	} {
+		op, err := encoding.NewOperation(encoding.OperationTypeCreate, tt.suffix, tt.op)
+		if err != nil {
+			t.Fatalf("NewOperation failed: %v", err)
+		}
+		ops = append(ops, op)
+	}
+	payload, err := encoding.EncodeCanonicalPayload(ops, encoding.CompressionNone)
+	if err != nil {
+		t.Fatalf("EncodeCanonicalPayload failed: %v", err)
+	}
+
+	// Another writer's batch
+	ballot := env.srv.NextBallot()
+	env.srv.InjectVote(domain, payload, true)
+	env.srv.Advance()
+
+	for _, path := range []string{"a.db", "b.db"} {
+		store := newTestStore(t, filepath.Join(t.TempDir(), path))
+		processor := NewProcessor(store, env.client, domain)
+
+		result, err := processor.SyncFromBallot(context.Background(), 0, 0)
+		if err != nil {
+			t.Fatalf("SyncFromBallot failed: %v", err)
+		}
+		if result.Applied != 1 || result.Rejected != 0 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+
+		applied, _ := store.GetOperationsAtBallot(ballot)
+		if len(applied) != 1 || applied[0].DID != firstDID || applied[0].OperationIndex != 0 {
+			t.Errorf("applied operations = %+v", applied)
+		}
+
+		// Once an operation on a DID is rejected, the DID has had its one operation in the batch
+		rejected, _ := store.GetRejectedOperationsAtBallot(ballot)
+		wants := []struct {
+			index  int
+			reason string
+		}{
+			{1, "create operation has no initial document"},
+			{2, "duplicate operation for DID in batch"},
+			{3, "duplicate operation for DID in batch"},
+		}
+		if len(rejected) != len(wants) {
+			t.Fatalf("got %d rejected operations, want %d", len(rejected), len(wants))
+		}
+		for i, want := range wants {
+			if rejected[i].OperationIndex != want.index || !strings.HasPrefix(rejected[i].Reason, want.reason) {
+				t.Errorf("rejected[%d] = index %d reason %q, want index %d reason %q",
+					i, rejected[i].OperationIndex, rejected[i].Reason, want.index, want.reason)
+			}
+		}
+		if exists, _ := store.DIDExists(secondDID); exists {
+			t.Error("second DID should not exist")
+		}
+	}
+}
+
+func TestIntegrationBatchCatchesUpInOrder(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	domain := env.cfg.CHAR.AppPreimage
+
+	// Another writer's create, decided before our batch and not synced yet
+	theirs, theirDID := newTestCreateOperation(t)
+	theirSuffix, _ := ParseDID(theirDID)
+	encoded, err := encoding.NewOperation(encoding.OperationTypeCreate, theirSuffix, theirs)
+	if err != nil {
+		t.Fatalf("NewOperation failed: %v", err)
+	}
+	payload, err := encoding.EncodeCanonicalPayload([]encoding.Operation{encoded}, encoding.CompressionNone)
+	if err != nil {
+		t.Fatalf("EncodeCanonicalPayload failed: %v", err)
+	}
+	theirBallot := env.srv.NextBallot()
+	env.srv.InjectVote(domain, payload, true)
+	env.srv.Advance()
+
+	ours, ourDID := newTestCreateOperation(t)
+	ourSuffix, _ := ParseDID(ourDID)
+	ballot, err := NewBatcher(env.cfg, env.store, env.client).SubmitOne(context.Background(), encoding.OperationTypeCreate, ourSuffix, ours)
+	if err != nil {
+		t.Fatalf("SubmitOne failed: %v", err)
+	}
+	if ballot <= theirBallot {
+		t.Fatalf("our ballot %d is not after theirs %d", ballot, theirBallot)
+	}
+
+	record, _ := loadDocument(t, env.store, theirDID)
+	if record.CreatedAtBallot != theirBallot {
+		t.Errorf("earlier ballot applied at %d, want %d", record.CreatedAtBallot, theirBallot)
+	}
+	if lastSynced, _ := syncStateInt(env.store, syncStateLastSynced); lastSynced != ballot {
+		t.Errorf("last synced = %d, want %d", lastSynced, ballot)
+	}
+}
+
+func TestIntegrationBatchRefusesLongCatchUp(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true, StartBallot: maxWriteCatchUp + 10})
+
+	// A new database would replay the whole chain before the write could finish
+	op, did := newTestCreateOperation(t)
+	suffix, _ := ParseDID(did)
+	batch := NewBatcher(env.cfg, env.store, env.client)
+	_, err := batch.SubmitOne(context.Background(), encoding.OperationTypeCreate, suffix, op)
+	if err == nil || !strings.Contains(err.Error(), "run sync first") {
+		t.Fatalf("SubmitOne error = %v, want a request to sync first", err)
+	}
+	if votes := env.srv.Calls("addbambookv"); votes != 0 {
+		t.Errorf("%d votes submitted by a refused write", votes)
+	}
+
+	// Once synced close to the tip, the write goes through
+	if err := env.store.SetSyncState(syncStateLastSynced, fmt.Sprintf("%d", maxWriteCatchUp)); err != nil {
+		t.Fatalf("SetSyncState failed: %v", err)
+	}
+	if _, err := batch.SubmitOne(context.Background(), encoding.OperationTypeCreate, suffix, op); err != nil {
+		t.Fatalf("SubmitOne failed: %v", err)
+	}
+}
+
+func TestIntegrationBatcherSharedByConcurrentWriters(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	batch := NewBatcher(env.cfg, env.store, env.client)
+
+	const writers = 4
+	type outcome struct {
+		did    string
+		ballot int
+		err    error
+	}
+	results := make(chan outcome, writers)
+	for i := 0; i < writers; i++ {
+		op, did := newTestCreateOperation(t)
+		suffix, _ := ParseDID(did)
+		go func() {
+			ballot, err := batch.SubmitOne(context.Background(), encoding.OperationTypeCreate, suffix, op)
+			results <- outcome{did, ballot, err}
+		}()
+	}
+
+	for i := 0; i < writers; i++ {
+		out := <-results
+		if out.err != nil {
+			t.Errorf("SubmitOne for %s failed: %v", out.did, out.err)
+			continue
+		}
+		record, _ := loadDocument(t, env.store, out.did)
+		if record.CreatedAtBallot != out.ballot {
+			t.Errorf("%s created at ballot %d, reported %d", out.did, record.CreatedAtBallot, out.ballot)
+		}
+	}
+	if n := env.srv.Submissions(); n < 1 || n > writers {
+		t.Errorf("submissions = %d, want between 1 and %d", n, writers)
+	}
+	if batch.Len() != 0 {
+		t.Errorf("Len = %d after every writer returned", batch.Len())
+	}
+}
+
+func TestIntegrationAnchoredBatch(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	content, err := cas.NewFileStore(filepath.Join(t.TempDir(), "cas"))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+
+	batch := NewBatcher(env.cfg, env.store, env.client)
+	batch.SetContentStore(content)
+	var dids []string
+	for i := 0; i < 2; i++ {
+		op, did := newTestCreateOperation(t)
+		suffix, _ := ParseDID(did)
+		if err := batch.Add(encoding.OperationTypeCreate, suffix, op); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		dids = append(dids, did)
+	}
+
+	result, err := batch.Submit(ctx)
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	for i, op := range result.Operations {
+		if !op.Applied {
+			t.Errorf("operation %d not applied: %s", i, op.Reason)
+		}
+	}
+
+	// A replica without the content records the batch, stops there and applies it once it can fetch it
+	replica := newTestStore(t, filepath.Join(t.TempDir(), "replica.db"))
+	processor := NewProcessor(replica, env.client, env.cfg.CHAR.AppPreimage)
+	sync, err := processor.SyncFromBallot(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if sync.Unresolvable != 1 || sync.Applied != 0 || !sync.Blocked || sync.Next != result.BallotNumber {
+		t.Errorf("unexpected sync result: %+v", sync)
+	}
+	if exists, _ := replica.UnresolvableBatchExistsAtBallot(result.BallotNumber); !exists {
+		t.Fatal("batch not recorded as unresolvable")
+	}
+	if next, _ := processor.NextUnsyncedBallot(); next != result.BallotNumber {
+		t.Errorf("cursor moved past the unresolvable ballot: next = %d, want %d", next, result.BallotNumber)
+	}
+
+	srv := httptest.NewServer(http.StripPrefix("/cas", cas.NewHandler(content)))
+	defer srv.Close()
+	processor.SetContentFetcher(cas.NewHTTPFetcher(srv.URL+"/cas", nil))
+
+	sync, err = processor.SyncFromBallot(ctx, sync.Next, 0)
+	if err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if sync.Applied != 1 || sync.Blocked {
+		t.Errorf("unexpected sync result after the content arrived: %+v", sync)
+	}
+	for _, did := range dids {
+		record, _ := loadDocument(t, replica, did)
+		if record.CreatedAtBallot != result.BallotNumber {
+			t.Errorf("%s created at ballot %d, want %d", did, record.CreatedAtBallot, result.BallotNumber)
+		}
+	}
+	if batches, _ := replica.GetUnresolvableBatches(); len(batches) != 0 {
+		t.Errorf("unresolvable batches left: %+v", batches)
+	}
+}
+
+// A later operation on a DID must not be applied before an earlier batch that could
+// not be fetched yet, or the replica would end up in a different state than the writer
+func TestIntegrationUnresolvableBatchHoldsLaterOperations(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	content, err := cas.NewFileStore(filepath.Join(t.TempDir(), "cas"))
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+
+	// Create through an anchored batch only this writer has the content of, then send
+	// the same create again inline, which the writer rejects as the DID already exists
+	op, did := newTestCreateOperation(t)
+	suffix, _ := ParseDID(did)
+	anchored := NewBatcher(env.cfg, env.store, env.client)
+	anchored.SetContentStore(content)
+	inline := NewBatcher(env.cfg, env.store, env.client)
+	var ballots []int
+	for _, batch := range []*Batcher{anchored, inline} {
+		if err := batch.Add(encoding.OperationTypeCreate, suffix, op); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		result, err := batch.Submit(ctx)
+		if err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+		ballots = append(ballots, result.BallotNumber)
+	}
+	want, _ := loadDocument(t, env.store, did)
+	if want.CreatedAtBallot != ballots[0] {
+		t.Fatalf("writer created %s at ballot %d, want %d", did, want.CreatedAtBallot, ballots[0])
+	}
+
+	replica := newTestStore(t, filepath.Join(t.TempDir(), "replica.db"))
+	processor := NewProcessor(replica, env.client, env.cfg.CHAR.AppPreimage)
+	sync, err := processor.SyncFromBallot(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if !sync.Blocked || sync.Next != ballots[0] {
+		t.Fatalf("sync did not stop at the unresolvable ballot: %+v", sync)
+	}
+	if exists, _ := replica.DIDExists(did); exists {
+		t.Error("the later create was applied before the unresolvable batch")
+	}
+
+	// The retry happens after the later create was decided, and both apply in ballot order
+	srv := httptest.NewServer(http.StripPrefix("/cas", cas.NewHandler(content)))
+	defer srv.Close()
+	processor.SetContentFetcher(cas.NewHTTPFetcher(srv.URL+"/cas", nil))
+
+	sync, err = processor.SyncFromBallot(ctx, sync.Next, 0)
+	if err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if sync.Applied != 1 || sync.Rejected != 1 || !sync.AtTip {
+		t.Errorf("unexpected sync result after the content arrived: %+v", sync)
+	}
+	got, _ := loadDocument(t, replica, did)
+	if got.CreatedAtBallot != want.CreatedAtBallot || got.LastOperationBallot != want.LastOperationBallot {
+		t.Errorf("replica record %+v differs from the writer's %+v", got, want)
+	}
+}
+
+func TestIntegrationAnchoredBatchMismatch(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t, chartest.Options{})
+	domain := env.cfg.CHAR.AppPreimage
+	content, _ := cas.NewFileStore(t.TempDir())
+
+	op, did := newTestCreateOperation(t)
+	suffix, _ := ParseDID(did)
+	encoded, _ := encoding.NewOperation(encoding.OperationTypeCreate, suffix, op)
+	data, _ := encoding.EncodeCanonicalBatch([]encoding.Operation{encoded}, encoding.CompressionNone)
+	hash, _ := content.Put(ctx, data)
+
+	// An anchor claiming more operations than its batch holds can never be applied
+	payload, _ := encoding.EncodeAnchorPayload(encoding.Anchor{ContentHash: hash, OperationCount: 2})
+	ballot := env.srv.NextBallot()
+	env.srv.InjectVote(domain, payload, true)
+	env.srv.Advance()
+
+	processor := NewProcessor(env.store, env.client, domain)
+	processor.SetContentFetcher(content)
+	outcome, err := processor.ProcessBallot(ctx, ballot)
+	if err != nil {
+		t.Fatalf("ProcessBallot failed: %v", err)
+	}
+	if outcome != BallotRejected {
+		t.Errorf("outcome = %v, want %v", outcome, BallotRejected)
+	}
+	rejected, _ := env.store.GetRejectedOperationsAtBallot(ballot)
+	if len(rejected) != 1 || !strings.Contains(rejected[0].Reason, "anchor claims 2") {
+		t.Errorf("rejected = %+v", rejected)
+	}
+	if exists, _ := env.store.DIDExists(did); exists {
+		t.Error("DID from a mismatched anchor should not exist")
+	}
+}
+
+func TestIntegrationCompressedBatch(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	env.cfg.Payload.Compression = "deflate"
+
+	batch := NewBatcher(env.cfg, env.store, env.client)
+	op, did := newTestCreateOperation(t)
+	suffix, _ := ParseDID(did)
+	if err := batch.Add(encoding.OperationTypeCreate, suffix, op); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	result, err := batch.Submit(context.Background())
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if !result.Operations[0].Applied {
+		t.Fatalf("operation not applied: %s", result.Operations[0].Reason)
+	}
+
+	roll, _ := env.client.GetReferendumDecisionRoll(context.Background(), env.cfg.CHAR.AppPreimage, result.BallotNumber, 1)
+	payload, _, _ := NewProcessor(env.store, env.client, env.cfg.CHAR.AppPreimage).decidedPayload(roll)
+	if version, _ := encoding.PayloadVersionOf(payload); version != encoding.PayloadVersionCanonical {
+		t.Errorf("payload version = %d, want %d", version, encoding.PayloadVersionCanonical)
+	}
+	if payload[2:4] != "01" {
+		t.Errorf("compression = %s, want deflate", payload[2:4])
+	}
+}
+
+func TestIntegrationPayloadLimits(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{})
+	domain := env.cfg.CHAR.AppPreimage
+	env.cfg.Payload.MaxOperationSize = 512
+
+	op, did := newTestCreateOperation(t)
+	suffix, _ := ParseDID(did)
+	batch := NewBatcher(env.cfg, env.store, env.client)
+	if err := batch.Add(encoding.OperationTypeCreate, suffix, op); err == nil {
+		t.Fatal("expected operation over the configured limit to be refused")
+	}
+
+	// Another writer's oversized operation is rejected by the processor
+	payload, _ := encoding.EncodePayload(encoding.OperationTypeCreate, suffix, op)
+	ballot := env.srv.NextBallot()
+	env.srv.InjectVote(domain, payload, true)
+	env.srv.Advance()
+
+	processor := NewProcessor(env.store, env.client, domain)
+	processor.SetLimits(PayloadLimits(env.cfg))
+	outcome, err := processor.ProcessBallot(context.Background(), ballot)
+	if err != nil {
+		t.Fatalf("ProcessBallot failed: %v", err)
+	}
+	if outcome != BallotRejected {
+		t.Errorf("outcome = %v, want %v", outcome, BallotRejected)
+	}
+	rejected, _ := env.store.GetRejectedOperationsAtBallot(ballot)
+	if len(rejected) != 1 || !strings.HasPrefix(rejected[0].Reason, "payload exceeds size limits: JSON of") {
+		t.Errorf("rejected = %+v", rejected)
+	}
+}
+
+func TestIntegrationWrappedPayloadLimits(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{})
+	domain := env.cfg.CHAR.AppPreimage
+	env.cfg.Payload.MaxPayloadSize = 16
+
+	// Anchors are not decoded through the limits, so the stripped payload is checked as a whole
+	anchor, err := encoding.EncodeAnchorPayload(encoding.Anchor{OperationCount: 1, ContentHash: strings.Repeat("h", 43)})
+	if err != nil {
+		t.Fatalf("EncodeAnchorPayload failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		slotize bool
+		reason  string
+	}{
+		{"anchor over the payload limit", anchor, true, "payload exceeds size limits: payload of"},
+		{"wrapper longer than its data", "0000ff0200000000000000" + "61", false, "failed to decode payload: slot wrapper: malformed CHAR wrapper"},
+	}
+
+	processor := NewProcessor(env.store, env.client, domain)
+	processor.SetLimits(PayloadLimits(env.cfg))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ballot := env.srv.NextBallot()
+			env.srv.InjectVote(domain, tt.data, tt.slotize)
+			env.srv.Advance()
+
+			outcome, err := processor.ProcessBallot(context.Background(), ballot)
+			if err != nil {
+				t.Fatalf("ProcessBallot failed: %v", err)
+			}
+			if outcome != BallotRejected {
+				t.Errorf("outcome = %v, want %v", outcome, BallotRejected)
+			}
+			rejected, _ := env.store.GetRejectedOperationsAtBallot(ballot)
+			if len(rejected) != 1 || !strings.HasPrefix(rejected[0].Reason, tt.reason) {
+				t.Errorf("rejected = %+v", rejected)
+			}
+			if exists, _ := env.store.UnresolvableBatchExistsAtBallot(ballot); exists {
+				t.Error("rejected payload recorded as unresolvable")
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/yourusername/did-char/pkg/canonical"
 	"github.com/yourusername/did-char/pkg/crypto"
 	"github.com/yourusername/did-char/pkg/keys"
 )
@@ -13,89 +14,134 @@ import (
 // Returns (commitment, revealValue, error)
 func GenerateCommitment(key *ecdsa.PrivateKey) (string, string, error) {
 	// Convert key to JWK (without private part for reveal)
-	jwk := keys.PublicKeyToJWK(&key.PublicKey, "")
-
-	// Canonicalize JWK to JSON
-	jwkBytes, err := json.Marshal(jwk)
-	if err != nil {
-		return "", "", err
-	}
-
-	// Step 1: Hash once to get reveal value
-	revealValue := crypto.HashToBase64URL(jwkBytes)
-
-	// Step 2: Hash again to get commitment
-	revealBytes, _ := crypto.Base64URLDecode(revealValue)
-	commitment := crypto.HashToBase64URL(revealBytes)
-
-	return commitment, revealValue, nil
+	return GenerateCommitmentFromJWK(keys.PublicKeyToJWK(&key.PublicKey, ""))
 }
 
 // VerifyReveal verifies that a reveal value matches an expected commitment
+//
+// The commitment is the hash of the reveal's digest, under the algorithm the
+// commitment names; bare SHA-256 reveals and commitments from before multihashes
+// are accepted.
 func VerifyReveal(revealValue, expectedCommitment string) bool {
-	revealBytes, err := crypto.Base64URLDecode(revealValue)
+	_, digest, err := crypto.DecodeHash(revealValue)
 	if err != nil {
 		return false
 	}
-
-	actualCommitment := crypto.HashToBase64URL(revealBytes)
-	return actualCommitment == expectedCommitment
+	return crypto.HashMatches(expectedCommitment, digest)
 }
 
 // VerifyKeyMatchesReveal verifies that a JWK hashes to the expected reveal value
 // This ensures the key in the signed data matches the commitment chain
+//
+// Keys committed to before reveals were hashed over JCS are accepted too.
 func VerifyKeyMatchesReveal(jwk *keys.JWK, revealValue string) error {
-	// Create public-only JWK for hashing (same as what was committed)
-	pubJWK := &keys.JWK{
-		ID:  jwk.ID,
-		Kty: jwk.Kty,
-		Crv: jwk.Crv,
-		Alg: jwk.Alg,
-		X:   jwk.X,
-		Y:   jwk.Y,
-		// D is intentionally omitted
-	}
-
-	// Serialize to JSON
-	jwkBytes, err := json.Marshal(pubJWK)
+	jwkJSON, err := json.Marshal(jwk)
 	if err != nil {
 		return fmt.Errorf("failed to marshal JWK: %w", err)
 	}
+	return verifyKeyMatchesReveal(jwkJSON, revealValue, canonical.JCS)
+}
+
+// verifyKeyMatchesReveal verifies that a JWK, as received, hashes to the expected
+// reveal value under scheme
+//
+// Under JCS the legacy hash is accepted as well: the commitment being revealed may
+// have been made by an operation anchored before JCS, and either way the reveal
+// is a hash of the same public key.
+func verifyKeyMatchesReveal(jwkJSON json.RawMessage, revealValue string, scheme canonical.Scheme) error {
+	schemes := []canonical.Scheme{scheme}
+	if scheme == canonical.JCS {
+		schemes = append(schemes, canonical.Legacy)
+	}
 
-	// Hash and compare to reveal value
-	computedReveal := crypto.HashToBase64URL(jwkBytes)
-	if computedReveal != revealValue {
-		return fmt.Errorf("key hash mismatch: computed %s, expected %s", computedReveal, revealValue)
+	for _, s := range schemes {
+		jwkBytes, err := publicJWKJSON(jwkJSON, s)
+		if err != nil {
+			return err
+		}
+		if crypto.HashMatches(revealValue, jwkBytes) {
+			return nil
+		}
 	}
 
-	return nil
+	jwkBytes, err := publicJWKJSON(jwkJSON, scheme)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("key hash mismatch: computed %s, expected %s", crypto.HashToMultihash(jwkBytes), revealValue)
 }
 
 // GenerateCommitmentFromJWK generates a commitment from a JWK
 // Returns (commitment, revealValue, error)
 func GenerateCommitmentFromJWK(jwk *keys.JWK) (string, string, error) {
-	// Create public-only JWK
-	pubJWK := &keys.JWK{
-		ID:  jwk.ID,
-		Kty: jwk.Kty,
-		Crv: jwk.Crv,
-		Alg: jwk.Alg,
-		X:   jwk.X,
-		Y:   jwk.Y,
+	// Step 1: Hash the public key once to get reveal value
+	revealValue, err := computeReveal(jwk, canonical.JCS)
+	if err != nil {
+		return "", "", err
 	}
 
-	// Serialize to JSON
-	jwkBytes, err := json.Marshal(pubJWK)
+	// Step 2: Hash the reveal's digest again to get commitment
+	alg, digest, err := crypto.DecodeHash(revealValue)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to marshal JWK: %w", err)
+		return "", "", err
+	}
+	commitment, err := crypto.MultihashToBase64URL(alg, digest)
+	if err != nil {
+		return "", "", err
 	}
 
-	// Step 1: Hash once to get reveal value
-	revealValue := crypto.HashToBase64URL(jwkBytes)
+	return commitment, revealValue, nil
+}
 
-	// Step 2: Hash again to get commitment
-	revealBytes, _ := crypto.Base64URLDecode(revealValue)
-	commitment := crypto.HashToBase64URL(revealBytes)
+// computeReveal hashes the public part of a JWK, serialized with scheme, to its multihash reveal value
+func computeReveal(jwk *keys.JWK, scheme canonical.Scheme) (string, error) {
+	jwkJSON, err := json.Marshal(jwk)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JWK: %w", err)
+	}
+	jwkBytes, err := publicJWKJSON(jwkJSON, scheme)
+	if err != nil {
+		return "", err
+	}
+	return crypto.HashToMultihash(jwkBytes), nil
+}
 
-	return commitment, revealValue, nil
+// publicJWKJSON returns the JWK jwkJSON without its private key, serialized with scheme
+//
+// D is never part of the hash. Under JCS every other member is kept as received, so
+// a key with members this node does not know about hashes as its writer hashed it.
+func publicJWKJSON(jwkJSON json.RawMessage, scheme canonical.Scheme) ([]byte, error) {
+	if scheme == canonical.Legacy {
+		// Legacy writers hashed the encoding/json output of the Go value
+		var jwk keys.JWK
+		if err := json.Unmarshal(jwkJSON, &jwk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JWK: %w", err)
+		}
+		return json.Marshal(getPublicJWK(&jwk))
+	}
+
+	// Transform rejects duplicate members, which decoding below would silently merge
+	if _, err := canonical.Transform(jwkJSON); err != nil {
+		return nil, fmt.Errorf("invalid JWK: %w", err)
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(jwkJSON, &members); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JWK: %w", err)
+	}
+	delete(members, "d")
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JWK: %w", err)
+	}
+	return canonical.Transform(data)
+}
+
+// computeDeltaHash hashes a delta, serialized with scheme, as signed in update and recover operations
+func computeDeltaHash(delta interface{}, scheme canonical.Scheme) (string, error) {
+	deltaBytes, err := scheme.Marshal(delta)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal delta: %w", err)
+	}
+	return crypto.HashToMultihash(deltaBytes), nil
 }
@@ -1,14 +1,17 @@
 package did
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/yourusername/did-char/pkg/canonical"
+	"github.com/yourusername/did-char/pkg/crypto"
 	"github.com/yourusername/did-char/pkg/keys"
 )
 
 func TestGenerateCommitment(t *testing.T) {
 	// Generate an ECDSA key
-	privateKey, err := keys.GenerateSecp256k1Key()
+	privateKey, err := keys.GenerateP256Key()
 	if err != nil {
 		t.Fatalf("failed to generate key: %v", err)
 	}
@@ -26,12 +29,12 @@ func TestGenerateCommitment(t *testing.T) {
 		t.Error("revealValue is empty")
 	}
 
-	// Both should be 43 chars (SHA256 = 32 bytes = 43 base64url chars)
-	if len(commitment) != 43 {
-		t.Errorf("commitment length = %d, want 43", len(commitment))
+	// Both should be 46 chars (SHA-256 multihash = 34 bytes = 46 base64url chars)
+	if len(commitment) != 46 {
+		t.Errorf("commitment length = %d, want 46", len(commitment))
 	}
-	if len(revealValue) != 43 {
-		t.Errorf("revealValue length = %d, want 43", len(revealValue))
+	if len(revealValue) != 46 {
+		t.Errorf("revealValue length = %d, want 46", len(revealValue))
 	}
 
 	// Commitment != revealValue (they're different hashes)
@@ -50,8 +53,8 @@ func TestGenerateCommitment(t *testing.T) {
 }
 
 func TestGenerateCommitmentDifferentKeys(t *testing.T) {
-	key1, _ := keys.GenerateSecp256k1Key()
-	key2, _ := keys.GenerateSecp256k1Key()
+	key1, _ := keys.GenerateP256Key()
+	key2, _ := keys.GenerateP256Key()
 
 	commitment1, reveal1, _ := GenerateCommitment(key1)
 	commitment2, reveal2, _ := GenerateCommitment(key2)
@@ -65,7 +68,7 @@ func TestGenerateCommitmentDifferentKeys(t *testing.T) {
 }
 
 func TestVerifyReveal(t *testing.T) {
-	privateKey, _ := keys.GenerateSecp256k1Key()
+	privateKey, _ := keys.GenerateP256Key()
 	commitment, revealValue, _ := GenerateCommitment(privateKey)
 
 	// Valid reveal
@@ -91,7 +94,7 @@ func TestVerifyReveal(t *testing.T) {
 
 func TestVerifyKeyMatchesReveal(t *testing.T) {
 	// Test with EC key - use GenerateCommitmentFromJWK for consistency
-	ecKey, _ := keys.GenerateSecp256k1Key()
+	ecKey, _ := keys.GenerateP256Key()
 	ecJWK := keys.PrivateKeyToJWK(ecKey, "test")
 	_, ecReveal, _ := GenerateCommitmentFromJWK(ecJWK)
 
@@ -108,7 +111,7 @@ func TestVerifyKeyMatchesReveal(t *testing.T) {
 	}
 
 	// Different key
-	ecKey2, _ := keys.GenerateSecp256k1Key()
+	ecKey2, _ := keys.GenerateP256Key()
 	ecJWK2 := keys.PrivateKeyToJWK(ecKey2, "test")
 	err = VerifyKeyMatchesReveal(ecJWK2, ecReveal)
 	if err == nil {
@@ -160,7 +163,7 @@ func TestGenerateCommitmentFromJWK(t *testing.T) {
 		{
 			name: "EC P-256",
 			generateFn: func() (*keys.JWK, error) {
-				key, err := keys.GenerateSecp256k1Key()
+				key, err := keys.GenerateP256Key()
 				if err != nil {
 					return nil, err
 				}
@@ -202,11 +205,11 @@ func TestGenerateCommitmentFromJWK(t *testing.T) {
 			}
 
 			// Both should be valid base64url hashes
-			if len(commitment) != 43 {
-				t.Errorf("commitment length = %d, want 43", len(commitment))
+			if len(commitment) != 46 {
+				t.Errorf("commitment length = %d, want 46", len(commitment))
 			}
-			if len(revealValue) != 43 {
-				t.Errorf("revealValue length = %d, want 43", len(revealValue))
+			if len(revealValue) != 46 {
+				t.Errorf("revealValue length = %d, want 46", len(revealValue))
 			}
 
 			// Reveal should verify against commitment
@@ -226,7 +229,7 @@ func TestCommitmentChainIntegrity(t *testing.T) {
 	// This test verifies the complete commitment chain:
 	// key -> hash(key) = reveal -> hash(reveal) = commitment
 
-	key, _ := keys.GenerateSecp256k1Key()
+	key, _ := keys.GenerateP256Key()
 	jwk := keys.PrivateKeyToJWK(key, "test")
 
 	commitment, revealValue, _ := GenerateCommitmentFromJWK(jwk)
@@ -247,7 +250,7 @@ func TestCommitmentChainIntegrity(t *testing.T) {
 
 func TestPublicOnlyJWKCommitment(t *testing.T) {
 	// Commitment should work the same for public-only JWK
-	key, _ := keys.GenerateSecp256k1Key()
+	key, _ := keys.GenerateP256Key()
 	privateJWK := keys.PrivateKeyToJWK(key, "test")
 	publicJWK := keys.PublicKeyToJWK(&key.PublicKey, "test")
 
@@ -259,3 +262,51 @@ func TestPublicOnlyJWKCommitment(t *testing.T) {
 		t.Error("private and public JWK should produce same reveal")
 	}
 }
+
+// TestCommitmentVector checks a reveal and commitment against values computed
+// independently over the RFC 8785 serialization
+// {"crv":"Ed25519","id":"key-1","kty":"OKP","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+func TestCommitmentVector(t *testing.T) {
+	jwk := &keys.JWK{ID: "key-1", Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo", D: "private"}
+
+	commitment, revealValue, err := GenerateCommitmentFromJWK(jwk)
+	if err != nil {
+		t.Fatalf("GenerateCommitmentFromJWK failed: %v", err)
+	}
+	if revealValue != "EiCKVKiTAssKW3qveA7E6LNbpTvdH5PYkB0LFNnDLxuAiw" {
+		t.Errorf("reveal = %s", revealValue)
+	}
+	if commitment != "EiB3_pWSQS8HGyj94jfODqtYXzA0_CO9DpbbNIWmb75sbg" {
+		t.Errorf("commitment = %s", commitment)
+	}
+}
+
+func TestRevealCoversUnknownJWKMembers(t *testing.T) {
+	key, _ := keys.GenerateP256Key()
+	jwk := keys.PrivateKeyToJWK(key, "")
+	_, plainReveal, _ := GenerateCommitmentFromJWK(jwk)
+
+	// The key as signed, with a member this node does not know about and its private part
+	var members map[string]interface{}
+	data, _ := json.Marshal(jwk)
+	if err := json.Unmarshal(data, &members); err != nil {
+		t.Fatalf("failed to unmarshal JWK: %v", err)
+	}
+	members["use"] = "sig"
+	signedJWK, _ := json.Marshal(members)
+
+	delete(members, "d")
+	publicJSON, _ := json.Marshal(members)
+	canonicalJWK, err := canonical.Transform(publicJSON)
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+	reveal := crypto.HashToMultihash(canonicalJWK)
+
+	if err := verifyKeyMatchesReveal(signedJWK, reveal, canonical.JCS); err != nil {
+		t.Errorf("key with an extra member should match its reveal: %v", err)
+	}
+	if err := verifyKeyMatchesReveal(signedJWK, plainReveal, canonical.JCS); err == nil {
+		t.Error("the extra member must be part of the reveal")
+	}
+}
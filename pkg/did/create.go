@@ -1,13 +1,12 @@
 package did
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"strconv"
 
+	"github.com/yourusername/did-char/pkg/canonical"
 	"github.com/yourusername/did-char/pkg/char"
 	"github.com/yourusername/did-char/pkg/config"
-	"github.com/yourusername/did-char/pkg/crypto"
 	"github.com/yourusername/did-char/pkg/encoding"
 	"github.com/yourusername/did-char/pkg/keys"
 	"github.com/yourusername/did-char/pkg/signing"
@@ -16,13 +15,16 @@ import (
 
 // CreateDIDRequest contains parameters for creating a DID
 type CreateDIDRequest struct {
-	Services  []Service
-	Algorithm signing.SignatureAlgorithm // ES256, EdDSA, or BLS (default: ES256)
+	Services    []Service
+	Algorithm   signing.SignatureAlgorithm // ES256, ES256K, EdDSA, BLS, or BIP340 (default: ES256)
+	KeyFilePath string                     // Optional explicit key file path (default: derived from DID in keys dir)
+	Batcher     *Batcher                   // Optional shared batcher to submit through (default: a batch of one)
 }
 
 // CreateDIDResult contains the result of creating a DID
 type CreateDIDResult struct {
 	DID          string
+	LongFormDID  string // Resolvable before the create operation is anchored
 	KeyFile      *keys.KeyFile
 	Document     *Document
 	BallotNumber int
@@ -85,69 +87,23 @@ func CreateDID(
 	}
 
 	// Create operation
-	createOp := &CreateOperation{
-		Type:               "create",
-		InitialDocument:    doc,
-		UpdateCommitment:   updateCommitment,
-		RecoveryCommitment: recoveryCommitment,
-	}
-
-	// Generate DID suffix from initial state
-	suffix, err := GenerateDIDSuffix(createOp)
+	createOp, err := NewCreateOperation(doc, updateCommitment, recoveryCommitment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate DID suffix: %w", err)
+		return nil, err
 	}
-
-	did := FormatDID(suffix)
-	doc.ID = did
-	createOp.InitialDocument.ID = did
-
-	// Update public key controller
-	if len(doc.PublicKeys) > 0 {
-		doc.PublicKeys[0].Controller = did
-	}
-
-	// Get next available ballot number from CHAR
-	// Use last synced ballot as starting point (not last operation ballot)
-	lastSyncedStr, err := store.GetSyncState("last_synced_ballot")
+	did := doc.ID
+	suffix, err := ParseDID(did)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get sync state: %w", err)
-	}
-
-	startBallot := 0
-	if lastSyncedStr != "" {
-		startBallot, err = strconv.Atoi(lastSyncedStr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid last synced ballot in sync state: %w", err)
-		}
+		return nil, fmt.Errorf("failed to parse DID: %w", err)
 	}
-
-	// Search for next empty ballot starting from last synced
-	ballotNumber, err := charClient.GetNextAvailableBallot(cfg.CHAR.AppPreimage, startBallot)
+	longFormDID, err := LongFormDID(createOp)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find available ballot: %w", err)
+		return nil, err
 	}
 
-	// Encode payload
-	payloadHex, err := encoding.EncodePayload(encoding.OperationTypeCreate, suffix, createOp)
+	ballotNumber, err := batcherFor(req.Batcher, cfg, store, charClient).SubmitOne(context.Background(), encoding.OperationTypeCreate, suffix, createOp)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode payload: %w", err)
-	}
-
-	// Submit to CHAR and wait for confirmation
-	if err := charClient.SubmitAndWaitForConfirmation(
-		cfg.CHAR.AppPreimage,
-		payloadHex,
-		ballotNumber,
-		cfg.Polling,
-	); err != nil {
-		return nil, fmt.Errorf("failed to submit and confirm: %w", err)
-	}
-
-	// Now process the ballot to write to SQLite
-	processor := NewProcessor(store, charClient, cfg.CHAR.AppPreimage)
-	if err := processor.ProcessBallot(ballotNumber); err != nil {
-		return nil, fmt.Errorf("failed to process ballot: %w", err)
+		return nil, err
 	}
 
 	// Create key file
@@ -162,28 +118,77 @@ func CreateDID(
 	}
 
 	// Save key file
-	if err := keys.SaveKeyFile(keyFile, cfg.DataDir.KeysDir); err != nil {
+	if err := keys.SaveKeyFileToPath(keyFile, keyFilePath(req.KeyFilePath, did, cfg.DataDir.KeysDir)); err != nil {
 		return nil, fmt.Errorf("failed to save key file: %w", err)
 	}
 
 	return &CreateDIDResult{
 		DID:          did,
+		LongFormDID:  longFormDID,
 		KeyFile:      keyFile,
 		Document:     doc,
 		BallotNumber: ballotNumber,
 	}, nil
 }
 
+// NewCreateOperation builds the create operation for an initial document
+//
+// The DID is derived from the operation, so doc must not have an ID yet. On return
+// doc.ID is the new DID, which also becomes the controller of keys that have none.
+func NewCreateOperation(doc *Document, updateCommitment, recoveryCommitment string) (*CreateOperation, error) {
+	if doc.ID != "" {
+		return nil, fmt.Errorf("initial document must not have an ID")
+	}
+
+	createOp := &CreateOperation{
+		Type:               OperationTypeCreate,
+		InitialDocument:    doc,
+		UpdateCommitment:   updateCommitment,
+		RecoveryCommitment: recoveryCommitment,
+	}
+
+	// Generate DID suffix from initial state
+	suffix, err := ComputeCreateSuffix(createOp, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate DID suffix: %w", err)
+	}
+
+	did := FormatDID(suffix)
+	doc.ID = did
+	for i := range doc.PublicKeys {
+		if doc.PublicKeys[i].Controller == "" {
+			doc.PublicKeys[i].Controller = did
+		}
+	}
+
+	return createOp, nil
+}
+
+// keyFilePath returns the explicit key file path if set, otherwise the default path for the DID
+func keyFilePath(override, did, keysDir string) string {
+	if override != "" {
+		return override
+	}
+	return keys.GetKeyFilePath(did, keysDir)
+}
+
 // generateKeyForAlgorithm generates a key pair for the specified algorithm
 func generateKeyForAlgorithm(algorithm signing.SignatureAlgorithm, keyID string) (*keys.JWK, error) {
 	switch algorithm {
 	case signing.AlgES256:
-		key, err := keys.GenerateSecp256k1Key()
+		key, err := keys.GenerateP256Key()
 		if err != nil {
 			return nil, err
 		}
 		return keys.PrivateKeyToJWK(key, keyID), nil
 
+	case signing.AlgES256K:
+		key, err := keys.GenerateSecp256k1Key()
+		if err != nil {
+			return nil, err
+		}
+		return keys.Secp256k1PrivateKeyToJWK(key, keyID), nil
+
 	case signing.AlgEdDSA:
 		key, err := keys.GenerateEd25519Key()
 		if err != nil {
@@ -198,6 +203,13 @@ func generateKeyForAlgorithm(algorithm signing.SignatureAlgorithm, keyID string)
 		}
 		return keys.BLSPrivateKeyToJWK(key, keyID), nil
 
+	case signing.AlgSchnorr:
+		key, err := keys.GenerateSecp256k1Key()
+		if err != nil {
+			return nil, err
+		}
+		return keys.SchnorrPrivateKeyToJWK(key, keyID), nil
+
 	default:
 		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
 	}
@@ -206,17 +218,34 @@ func generateKeyForAlgorithm(algorithm signing.SignatureAlgorithm, keyID string)
 // getVerificationKeyType returns the appropriate verification key type for the algorithm
 func getVerificationKeyType(algorithm signing.SignatureAlgorithm) string {
 	switch algorithm {
-	case signing.AlgES256:
+	case signing.AlgES256K:
 		return "EcdsaSecp256k1VerificationKey2019"
 	case signing.AlgEdDSA:
 		return "Ed25519VerificationKey2020"
 	case signing.AlgBLS:
 		return "Bls12381G1Key2020"
+	case signing.AlgSchnorr:
+		return "SchnorrSecp256k1VerificationKey2019"
 	default:
 		return "JsonWebKey2020"
 	}
 }
 
+// NewPublicKeyFromJWK builds a document public key entry from a JWK
+// The verification key type is derived from the JWK's key type and curve
+func NewPublicKeyFromJWK(id string, jwk *keys.JWK) (PublicKey, error) {
+	algorithm, err := signing.DetectAlgorithm(keys.JWKToMap(jwk))
+	if err != nil {
+		return PublicKey{}, err
+	}
+
+	return PublicKey{
+		ID:           id,
+		Type:         getVerificationKeyType(algorithm),
+		PublicKeyJwk: getPublicJWK(jwk),
+	}, nil
+}
+
 // GenerateNextCommitmentForJWK generates a new key of the same type and its commitment
 // This is a helper for operations that need to rotate keys
 func GenerateNextCommitmentForJWK(currentKey *keys.JWK) (*keys.JWK, string, string, error) {
@@ -226,9 +255,10 @@ func GenerateNextCommitmentForJWK(currentKey *keys.JWK) (*keys.JWK, string, stri
 	}
 
 	// Compute reveal value
-	pubJWK := getPublicJWK(newKey)
-	pubJWKJSON, _ := json.Marshal(pubJWK)
-	revealValue := crypto.HashToBase64URL(pubJWKJSON)
+	revealValue, err := computeReveal(newKey, canonical.JCS)
+	if err != nil {
+		return nil, "", "", err
+	}
 
 	return newKey, commitment, revealValue, nil
 }
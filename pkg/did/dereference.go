@@ -0,0 +1,128 @@
+package did
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// MediaTypeURIList is the content type of a dereferenced service endpoint URL
+const MediaTypeURIList = "text/uri-list"
+
+// DereferencingResult is a W3C DID URL Dereferencing Result
+//
+// ContentStream is a *DIDDocument, a *VerificationMethod, a *DIDCoreService or,
+// for service endpoint selection, the endpoint URL as a string.
+type DereferencingResult struct {
+	Context               string             `json:"@context"`
+	ContentStream         interface{}        `json:"contentStream"`
+	DereferencingMetadata ResolutionMetadata `json:"dereferencingMetadata"`
+	ContentMetadata       *DocumentMetadata  `json:"contentMetadata,omitempty"`
+}
+
+// NewDereferencingErrorResult returns a dereferencing result reporting an error
+func NewDereferencingErrorResult(code, message string) *DereferencingResult {
+	return &DereferencingResult{
+		Context: ContextDIDResolution,
+		DereferencingMetadata: ResolutionMetadata{
+			Error:        code,
+			ErrorMessage: message,
+		},
+	}
+}
+
+// Dereference dereferences a DID URL following the DID Resolution algorithm
+//
+// The DID is resolved at the version selected by the versionId or versionTime
+// parameters. A service parameter selects a service and returns its endpoint URL,
+// with relativeRef resolved against it per RFC 3986 and any fragment appended.
+// Otherwise a fragment selects a verification method or service from the document,
+// and a bare DID dereferences to the document itself. did:char defines no DID URL
+// paths. As with ResolveResult, the returned error is reserved for internal failures.
+func (r *Resolver) Dereference(didURL string) (*DereferencingResult, error) {
+	u, err := ParseDIDURL(didURL)
+	if err != nil {
+		return NewDereferencingErrorResult(ResolutionErrorInvalidDIDURL, err.Error()), nil
+	}
+
+	res, err := r.ResolveResult(u.DID, u.ResolveOptions())
+	if err != nil {
+		return nil, err
+	}
+	if res.DIDResolutionMetadata.Error != "" {
+		return NewDereferencingErrorResult(res.DIDResolutionMetadata.Error, res.DIDResolutionMetadata.ErrorMessage), nil
+	}
+	if u.Path != "" {
+		return NewDereferencingErrorResult(ResolutionErrorNotFound, "did:char does not define DID URL paths"), nil
+	}
+
+	doc, meta := res.DIDDocument, res.DIDDocumentMetadata
+	if u.Query.Has(ParamService) {
+		endpoint, err := serviceEndpointURL(doc, u)
+		if err != nil {
+			return NewDereferencingErrorResult(ResolutionErrorNotFound, err.Error()), nil
+		}
+		return dereferenced(endpoint, MediaTypeURIList, meta), nil
+	}
+
+	if u.Fragment == "" {
+		return dereferenced(doc, MediaTypeDIDLDJSON, meta), nil
+	}
+	id := u.DID + "#" + u.Fragment
+	for i := range doc.VerificationMethod {
+		if doc.VerificationMethod[i].ID == id {
+			return dereferenced(&doc.VerificationMethod[i], MediaTypeDIDLDJSON, meta), nil
+		}
+	}
+	for i := range doc.Service {
+		if doc.Service[i].ID == id {
+			return dereferenced(&doc.Service[i], MediaTypeDIDLDJSON, meta), nil
+		}
+	}
+	return NewDereferencingErrorResult(ResolutionErrorNotFound, fmt.Sprintf("no verification method or service with id %s", id)), nil
+}
+
+// dereferenced returns a successful dereferencing result
+func dereferenced(content interface{}, contentType string, meta DocumentMetadata) *DereferencingResult {
+	return &DereferencingResult{
+		Context:               ContextDIDResolution,
+		ContentStream:         content,
+		DereferencingMetadata: ResolutionMetadata{ContentType: contentType},
+		ContentMetadata:       &meta,
+	}
+}
+
+// serviceEndpointURL selects the service named by u's service parameter and
+// constructs its endpoint URL per the DID Core service endpoint construction
+func serviceEndpointURL(doc *DIDDocument, u *DIDURL) (string, error) {
+	name := u.Query.Get(ParamService)
+	id := u.DID + "#" + name
+	var svc *DIDCoreService
+	for i := range doc.Service {
+		if doc.Service[i].ID == id {
+			svc = &doc.Service[i]
+			break
+		}
+	}
+	if svc == nil {
+		return "", fmt.Errorf("no service with id %s", id)
+	}
+
+	endpoint, err := url.Parse(svc.ServiceEndpoint)
+	if err != nil || !endpoint.IsAbs() {
+		return "", fmt.Errorf("service %s does not have a URL endpoint", id)
+	}
+	if rel := u.Query.Get(ParamRelativeRef); rel != "" {
+		ref, err := url.Parse(rel)
+		if err != nil {
+			return "", fmt.Errorf("invalid relativeRef: %w", err)
+		}
+		endpoint = endpoint.ResolveReference(ref)
+	}
+
+	out := endpoint.String()
+	if u.Fragment != "" && !strings.Contains(out, "#") {
+		out += "#" + u.Fragment
+	}
+	return out, nil
+}
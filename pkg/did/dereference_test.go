@@ -0,0 +1,145 @@
+package did
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/yourusername/did-char/pkg/char/chartest"
+)
+
+func TestIntegrationDereference(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	created, err := CreateDID(&CreateDIDRequest{
+		Services: []Service{
+			{ID: "#files", Type: "LinkedDomains", ServiceEndpoint: "https://files.example.com/store/"},
+			{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: "https://hub.example.com/messages/8377464"},
+			{ID: "#relative", Type: "Other", ServiceEndpoint: "not-a-url"},
+		},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	err = UpdateDID(&UpdateDIDRequest{DID: created.DID, RemoveServices: []string{"#hub"}}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("UpdateDID failed: %v", err)
+	}
+	d := created.DID
+	createdAt := strconv.Itoa(created.BallotNumber)
+
+	resolver := NewResolver(env.store)
+
+	tests := []struct {
+		name        string
+		didURL      string
+		contentType string
+		check       func(t *testing.T, content interface{})
+		errCode     string
+	}{
+		{
+			name:        "document",
+			didURL:      d,
+			contentType: MediaTypeDIDLDJSON,
+			check: func(t *testing.T, content interface{}) {
+				if doc, ok := content.(*DIDDocument); !ok || doc.ID != d {
+					t.Errorf("unexpected content %#v", content)
+				}
+			},
+		},
+		{
+			name:        "verification method",
+			didURL:      d + "#key-1",
+			contentType: MediaTypeDIDLDJSON,
+			check: func(t *testing.T, content interface{}) {
+				if vm, ok := content.(*VerificationMethod); !ok || vm.ID != d+"#key-1" || vm.Controller != d {
+					t.Errorf("unexpected content %#v", content)
+				}
+			},
+		},
+		{
+			name:        "service by fragment",
+			didURL:      d + "#files",
+			contentType: MediaTypeDIDLDJSON,
+			check: func(t *testing.T, content interface{}) {
+				if svc, ok := content.(*DIDCoreService); !ok || svc.Type != "LinkedDomains" {
+					t.Errorf("unexpected content %#v", content)
+				}
+			},
+		},
+		{
+			name:        "service endpoint",
+			didURL:      d + "?service=files",
+			contentType: MediaTypeURIList,
+			check:       wantURL("https://files.example.com/store/"),
+		},
+		{
+			name:        "relative path",
+			didURL:      d + "?service=files&relativeRef=a%2Fb.txt",
+			contentType: MediaTypeURIList,
+			check:       wantURL("https://files.example.com/store/a/b.txt"),
+		},
+		{
+			name:        "absolute path with query and fragment",
+			didURL:      d + "?service=files&relativeRef=%2Fsome%2Fpath%3Fq%3D1%23frag",
+			contentType: MediaTypeURIList,
+			check:       wantURL("https://files.example.com/some/path?q=1#frag"),
+		},
+		{
+			name:        "fragment appended to endpoint",
+			didURL:      d + "?service=files&relativeRef=a.txt#section-2",
+			contentType: MediaTypeURIList,
+			check:       wantURL("https://files.example.com/store/a.txt#section-2"),
+		},
+		{
+			name:        "historical service",
+			didURL:      d + "?service=hub&versionId=" + createdAt,
+			contentType: MediaTypeURIList,
+			check:       wantURL("https://hub.example.com/messages/8377464"),
+		},
+		{name: "removed service", didURL: d + "?service=hub", errCode: ResolutionErrorNotFound},
+		{name: "unknown fragment", didURL: d + "#key-9", errCode: ResolutionErrorNotFound},
+		{name: "non-URL endpoint", didURL: d + "?service=relative", errCode: ResolutionErrorNotFound},
+		{name: "path", didURL: d + "/files", errCode: ResolutionErrorNotFound},
+		{name: "unknown DID", didURL: testRenderDID + "#key-1", errCode: ResolutionErrorNotFound},
+		{name: "unknown version", didURL: d + "?versionId=99999#key-1", errCode: ResolutionErrorNotFound},
+		{name: "malformed", didURL: d + "#", errCode: ResolutionErrorInvalidDIDURL},
+		{name: "other method", didURL: "did:example:abc#key-1", errCode: ResolutionErrorInvalidDIDURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := resolver.Dereference(tt.didURL)
+			if err != nil {
+				t.Fatalf("Dereference failed: %v", err)
+			}
+			if res.Context != ContextDIDResolution {
+				t.Errorf("@context = %q", res.Context)
+			}
+			meta := res.DereferencingMetadata
+			if tt.errCode != "" {
+				if meta.Error != tt.errCode || res.ContentStream != nil || res.ContentMetadata != nil {
+					t.Errorf("expected %s error result, got %+v", tt.errCode, res)
+				}
+				return
+			}
+			if meta.Error != "" {
+				t.Fatalf("unexpected error %s: %s", meta.Error, meta.ErrorMessage)
+			}
+			if meta.ContentType != tt.contentType {
+				t.Errorf("contentType = %q, want %q", meta.ContentType, tt.contentType)
+			}
+			if res.ContentMetadata == nil || res.ContentMetadata.CanonicalID != d {
+				t.Errorf("unexpected content metadata: %+v", res.ContentMetadata)
+			}
+			tt.check(t, res.ContentStream)
+		})
+	}
+}
+
+func wantURL(want string) func(t *testing.T, content interface{}) {
+	return func(t *testing.T, content interface{}) {
+		if got, ok := content.(string); !ok || got != want {
+			t.Errorf("content = %#v, want %q", content, want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package did
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/did-char/pkg/crypto"
+	"github.com/yourusername/did-char/pkg/keys"
+)
+
+// JSON-LD contexts used by rendered DID documents
+const (
+	ContextDIDCore  = "https://www.w3.org/ns/did/v1"
+	ContextJWS2020  = "https://w3id.org/security/suites/jws-2020/v1"
+	ContextMultikey = "https://w3id.org/security/multikey/v1"
+)
+
+// Verification method types used by rendered DID documents
+const (
+	VerificationTypeJWK      = "JsonWebKey2020"
+	VerificationTypeMultikey = "Multikey"
+)
+
+// Multicodec prefixes (unsigned varints) for Multikey public keys
+var (
+	multicodecEd25519Pub   = []byte{0xed, 0x01}
+	multicodecBLSG1Pub     = []byte{0xea, 0x01}
+	multicodecSecp256k1Pub = []byte{0xe7, 0x01}
+)
+
+// DIDDocument is a DID document as defined by W3C DID Core
+//
+// It is rendered from the stored Document, which keeps the original Sidetree-style
+// layout so existing databases and operations remain valid.
+type DIDDocument struct {
+	Context              []string             `json:"@context"`
+	ID                   string               `json:"id"`
+	VerificationMethod   []VerificationMethod `json:"verificationMethod,omitempty"`
+	Authentication       []string             `json:"authentication,omitempty"`
+	AssertionMethod      []string             `json:"assertionMethod,omitempty"`
+	KeyAgreement         []string             `json:"keyAgreement,omitempty"`
+	CapabilityDelegation []string             `json:"capabilityDelegation,omitempty"`
+	Service              []DIDCoreService     `json:"service,omitempty"`
+}
+
+// VerificationMethod is a DID Core verification method
+type VerificationMethod struct {
+	ID                 string    `json:"id"`
+	Type               string    `json:"type"`
+	Controller         string    `json:"controller"`
+	PublicKeyJwk       *keys.JWK `json:"publicKeyJwk,omitempty"`
+	PublicKeyMultibase string    `json:"publicKeyMultibase,omitempty"`
+}
+
+// DIDCoreService is a DID Core service with an absolute ID
+type DIDCoreService struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"`
+}
+
+// RenderDocument converts a stored document to a DID Core document for did
+//
+// Relative IDs become absolute, Ed25519, BLS12-381 and BIP-340 keys are expressed as
+// Multikey and all other keys as JsonWebKey2020. Keys are listed under the relationships their
+// purposes name; keys stored without purposes are listed under assertionMethod.
+// authentication keeps the stored list, followed by keys with that purpose.
+// A deactivated DID renders with no keys or services.
+func RenderDocument(did string, doc *Document, deactivated bool) (*DIDDocument, error) {
+	out := &DIDDocument{
+		Context: []string{ContextDIDCore},
+		ID:      did,
+	}
+	if deactivated || doc == nil {
+		return out, nil
+	}
+
+	var usesJWK, usesMultikey bool
+	for _, pk := range doc.PublicKeys {
+		vm, err := renderVerificationMethod(did, pk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render key %s: %w", pk.ID, err)
+		}
+		switch vm.Type {
+		case VerificationTypeJWK:
+			usesJWK = true
+		case VerificationTypeMultikey:
+			usesMultikey = true
+		}
+		out.VerificationMethod = append(out.VerificationMethod, vm)
+	}
+	for _, ref := range doc.Authentication {
+		out.Authentication = append(out.Authentication, absoluteID(did, ref))
+	}
+	for _, pk := range doc.PublicKeys {
+		id := absoluteID(did, pk.ID)
+		if len(pk.Purposes) == 0 {
+			out.AssertionMethod = append(out.AssertionMethod, id)
+			continue
+		}
+		for _, purpose := range pk.Purposes {
+			switch purpose {
+			case PurposeAuthentication:
+				out.Authentication = appendUnique(out.Authentication, id)
+			case PurposeAssertionMethod:
+				out.AssertionMethod = appendUnique(out.AssertionMethod, id)
+			case PurposeKeyAgreement:
+				out.KeyAgreement = appendUnique(out.KeyAgreement, id)
+			case PurposeCapabilityDelegation:
+				out.CapabilityDelegation = appendUnique(out.CapabilityDelegation, id)
+			}
+		}
+	}
+	for _, svc := range doc.Services {
+		out.Service = append(out.Service, DIDCoreService{
+			ID:              absoluteID(did, svc.ID),
+			Type:            svc.Type,
+			ServiceEndpoint: svc.ServiceEndpoint,
+		})
+	}
+
+	if usesJWK {
+		out.Context = append(out.Context, ContextJWS2020)
+	}
+	if usesMultikey {
+		out.Context = append(out.Context, ContextMultikey)
+	}
+
+	return out, nil
+}
+
+// renderVerificationMethod converts a stored public key to a verification method
+func renderVerificationMethod(did string, pk PublicKey) (VerificationMethod, error) {
+	vm := VerificationMethod{
+		ID:         absoluteID(did, pk.ID),
+		Controller: pk.Controller,
+	}
+	if vm.Controller == "" {
+		vm.Controller = did
+	}
+	if pk.PublicKeyJwk == nil {
+		return vm, fmt.Errorf("missing publicKeyJwk")
+	}
+
+	var prefix []byte
+	xOnly := false
+	switch {
+	case pk.PublicKeyJwk.Kty == "OKP" && pk.PublicKeyJwk.Crv == "Ed25519":
+		prefix = multicodecEd25519Pub
+	case pk.PublicKeyJwk.Kty == "OKP" && pk.PublicKeyJwk.Crv == "BLS12-381-G1":
+		prefix = multicodecBLSG1Pub
+	case pk.PublicKeyJwk.Kty == "OKP" && pk.PublicKeyJwk.Crv == "secp256k1":
+		// A BIP-340 key has no JOSE key type, so it is never a JsonWebKey2020
+		prefix = multicodecSecp256k1Pub
+		xOnly = true
+	}
+
+	if prefix == nil {
+		jwk := getPublicJWK(pk.PublicKeyJwk)
+		jwk.ID = "" // Not a JWK member; the method ID identifies the key
+		vm.Type = VerificationTypeJWK
+		vm.PublicKeyJwk = jwk
+		return vm, nil
+	}
+
+	raw, err := crypto.Base64URLDecode(pk.PublicKeyJwk.X)
+	if err != nil {
+		return vm, fmt.Errorf("invalid public key encoding: %w", err)
+	}
+	if xOnly {
+		// BIP-340 keys are the point with even y, written as a compressed point
+		if len(raw) != 32 {
+			return vm, fmt.Errorf("invalid x-only public key size: %d", len(raw))
+		}
+		raw = append([]byte{0x02}, raw...)
+	}
+	vm.Type = VerificationTypeMultikey
+	vm.PublicKeyMultibase = "z" + crypto.Base58Encode(append(append([]byte{}, prefix...), raw...))
+	return vm, nil
+}
+
+// appendUnique appends id to ids unless it is already there
+func appendUnique(ids []string, id string) []string {
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
+
+// absoluteID turns a document-relative ID ("#key-1" or "key-1") into a DID URL
+func absoluteID(did, id string) string {
+	if strings.HasPrefix(id, "did:") {
+		return id
+	}
+	return did + "#" + strings.TrimPrefix(id, "#")
+}
@@ -0,0 +1,227 @@
+package did
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/did-char/pkg/crypto"
+	"github.com/yourusername/did-char/pkg/keys"
+)
+
+const testRenderDID = "did:char:EiDahaOGH-liLLdDtTxEAdc8i-cfCz-WUcQdRJheMVNn3A"
+
+func TestRenderDocument(t *testing.T) {
+	ecKey, err := keys.GenerateP256Key()
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	// Public key from the did:key test vectors, with a known Multikey encoding
+	edPub := ed25519.PublicKey(mustHex(t, "3b6a27bcceb6a42d62a3a8d02a6f0d73653215771de243a63ac048a18b59da29"))
+
+	doc := NewDocument(testRenderDID)
+	doc.AddPublicKey(PublicKey{ID: "#key-1", Type: "EcdsaSecp256k1VerificationKey2019", PublicKeyJwk: keys.PrivateKeyToJWK(ecKey, "key-1")})
+	doc.AddPublicKey(PublicKey{ID: "key-2", Type: "Ed25519VerificationKey2020", Controller: "did:char:other", PublicKeyJwk: keys.Ed25519PublicKeyToJWK(edPub, "key-2")})
+	doc.AddAuthentication("#key-1")
+	doc.AddService(Service{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: "https://hub.example.com"})
+
+	out, err := RenderDocument(testRenderDID, doc, false)
+	if err != nil {
+		t.Fatalf("RenderDocument failed: %v", err)
+	}
+
+	wantContext := []string{ContextDIDCore, ContextJWS2020, ContextMultikey}
+	if strings.Join(out.Context, " ") != strings.Join(wantContext, " ") {
+		t.Errorf("@context = %v, want %v", out.Context, wantContext)
+	}
+	if len(out.VerificationMethod) != 2 {
+		t.Fatalf("expected 2 verification methods, got %d", len(out.VerificationMethod))
+	}
+
+	ec := out.VerificationMethod[0]
+	if ec.ID != testRenderDID+"#key-1" || ec.Type != VerificationTypeJWK || ec.Controller != testRenderDID {
+		t.Errorf("unexpected EC method: %+v", ec)
+	}
+	if ec.PublicKeyJwk == nil || ec.PublicKeyJwk.D != "" || ec.PublicKeyJwk.ID != "" {
+		t.Errorf("EC method JWK should be public and have no id: %+v", ec.PublicKeyJwk)
+	}
+
+	ed := out.VerificationMethod[1]
+	if ed.ID != testRenderDID+"#key-2" || ed.Type != VerificationTypeMultikey || ed.Controller != "did:char:other" {
+		t.Errorf("unexpected Ed25519 method: %+v", ed)
+	}
+	if ed.PublicKeyMultibase != "z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp" || ed.PublicKeyJwk != nil {
+		t.Errorf("unexpected Ed25519 key encoding: %+v", ed)
+	}
+
+	if len(out.Authentication) != 1 || out.Authentication[0] != testRenderDID+"#key-1" {
+		t.Errorf("authentication = %v", out.Authentication)
+	}
+	if len(out.AssertionMethod) != 2 {
+		t.Errorf("assertionMethod = %v", out.AssertionMethod)
+	}
+	if len(out.Service) != 1 || out.Service[0].ID != testRenderDID+"#hub" {
+		t.Errorf("service = %+v", out.Service)
+	}
+
+	// The obsolete Sidetree property must not appear
+	data, err := json.Marshal(out)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), `"publicKey"`) {
+		t.Errorf("rendered document contains publicKey: %s", data)
+	}
+}
+
+func TestRenderDocumentPurposes(t *testing.T) {
+	ecKey, err := keys.GenerateP256Key()
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	jwk := keys.PublicKeyToJWK(&ecKey.PublicKey, "")
+
+	doc := NewDocument(testRenderDID)
+	doc.AddPublicKey(PublicKey{ID: "#key-1", PublicKeyJwk: jwk})
+	doc.AddPublicKey(PublicKey{ID: "#auth", PublicKeyJwk: jwk, Purposes: []string{PurposeAuthentication, PurposeAssertionMethod}})
+	doc.AddPublicKey(PublicKey{ID: "#agree", PublicKeyJwk: jwk, Purposes: []string{PurposeKeyAgreement}})
+	doc.AddPublicKey(PublicKey{ID: "#delegate", PublicKeyJwk: jwk, Purposes: []string{PurposeCapabilityDelegation}})
+	doc.AddAuthentication("#key-1")
+	doc.AddAuthentication("#auth")
+
+	out, err := RenderDocument(testRenderDID, doc, false)
+	if err != nil {
+		t.Fatalf("RenderDocument failed: %v", err)
+	}
+
+	abs := func(ids ...string) []string {
+		for i, id := range ids {
+			ids[i] = testRenderDID + id
+		}
+		return ids
+	}
+	if !reflect.DeepEqual(out.Authentication, abs("#key-1", "#auth")) {
+		t.Errorf("authentication = %v", out.Authentication)
+	}
+	if !reflect.DeepEqual(out.AssertionMethod, abs("#key-1", "#auth")) {
+		t.Errorf("assertionMethod = %v", out.AssertionMethod)
+	}
+	if !reflect.DeepEqual(out.KeyAgreement, abs("#agree")) {
+		t.Errorf("keyAgreement = %v", out.KeyAgreement)
+	}
+	if !reflect.DeepEqual(out.CapabilityDelegation, abs("#delegate")) {
+		t.Errorf("capabilityDelegation = %v", out.CapabilityDelegation)
+	}
+}
+
+func TestRenderDocumentBLS(t *testing.T) {
+	key, err := keys.GenerateBLSKey()
+	if err != nil {
+		t.Fatalf("failed to generate BLS key: %v", err)
+	}
+	doc := NewDocument(testRenderDID)
+	doc.AddPublicKey(PublicKey{ID: "#key-1", PublicKeyJwk: keys.BLSPublicKeyToJWK(key.PublicKey(), "key-1")})
+
+	out, err := RenderDocument(testRenderDID, doc, false)
+	if err != nil {
+		t.Fatalf("RenderDocument failed: %v", err)
+	}
+	vm := out.VerificationMethod[0]
+	if vm.Type != VerificationTypeMultikey || !strings.HasPrefix(vm.PublicKeyMultibase, "z3tE") {
+		t.Errorf("unexpected BLS method: %+v", vm)
+	}
+	if len(out.Context) != 2 || out.Context[1] != ContextMultikey {
+		t.Errorf("@context = %v", out.Context)
+	}
+}
+
+func TestRenderDocumentSchnorr(t *testing.T) {
+	key, err := keys.GenerateSecp256k1Key()
+	if err != nil {
+		t.Fatalf("failed to generate secp256k1 key: %v", err)
+	}
+	doc := NewDocument(testRenderDID)
+	doc.AddPublicKey(PublicKey{ID: "#key-1", Type: "SchnorrSecp256k1VerificationKey2019", PublicKeyJwk: keys.SchnorrPublicKeyToJWK(key.PubKey(), "key-1")})
+
+	out, err := RenderDocument(testRenderDID, doc, false)
+	if err != nil {
+		t.Fatalf("RenderDocument failed: %v", err)
+	}
+
+	// secp256k1-pub multicodec, then the x-only key as a compressed point with even y
+	x := key.PubKey().SerializeCompressed()[1:]
+	want := "z" + crypto.Base58Encode(append([]byte{0xe7, 0x01, 0x02}, x...))
+	vm := out.VerificationMethod[0]
+	if vm.Type != VerificationTypeMultikey || vm.PublicKeyJwk != nil || vm.PublicKeyMultibase != want {
+		t.Errorf("unexpected Schnorr method: %+v, want publicKeyMultibase %s", vm, want)
+	}
+	if !strings.HasPrefix(vm.PublicKeyMultibase, "zQ3s") {
+		t.Errorf("publicKeyMultibase %s lacks the secp256k1-pub prefix", vm.PublicKeyMultibase)
+	}
+	if len(out.Context) != 2 || out.Context[1] != ContextMultikey {
+		t.Errorf("@context = %v", out.Context)
+	}
+}
+
+func TestRenderDocumentDeactivated(t *testing.T) {
+	doc := NewDocument(testRenderDID)
+	doc.AddService(Service{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: "https://hub.example.com"})
+
+	out, err := RenderDocument(testRenderDID, doc, true)
+	if err != nil {
+		t.Fatalf("RenderDocument failed: %v", err)
+	}
+	if out.ID != testRenderDID || len(out.VerificationMethod) != 0 || len(out.Service) != 0 {
+		t.Errorf("deactivated document should only carry its ID: %+v", out)
+	}
+}
+
+func TestRenderDocumentInvalidKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  PublicKey
+	}{
+		{"missing JWK", PublicKey{ID: "#key-1"}},
+		{"bad encoding", PublicKey{ID: "#key-1", PublicKeyJwk: &keys.JWK{Kty: "OKP", Crv: "Ed25519", X: "!!"}}},
+		{"short x-only key", PublicKey{ID: "#key-1", PublicKeyJwk: &keys.JWK{Kty: "OKP", Crv: "secp256k1", X: "AAAA"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := NewDocument(testRenderDID)
+			doc.AddPublicKey(tt.key)
+			if _, err := RenderDocument(testRenderDID, doc, false); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestAbsoluteID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"#key-1", testRenderDID + "#key-1"},
+		{"key-1", testRenderDID + "#key-1"},
+		{testRenderDID + "#key-1", testRenderDID + "#key-1"},
+	}
+
+	for _, tt := range tests {
+		if got := absoluteID(testRenderDID, tt.id); got != tt.want {
+			t.Errorf("absoluteID(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("bad hex: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,161 @@
+package did
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DID parameters defined by DID Core that may appear at most once in a DID URL query
+const (
+	ParamService     = "service"
+	ParamRelativeRef = "relativeRef"
+	ParamVersionID   = "versionId"
+	ParamVersionTime = "versionTime"
+	ParamHashLink    = "hl"
+)
+
+// DIDURL is a parsed DID URL: a DID with an optional path, query and fragment
+type DIDURL struct {
+	DID      string     // The bare DID
+	Path     string     // Including the leading "/", or empty
+	Query    url.Values // DID parameters and any other query parameters
+	Fragment string     // Without the leading "#", still percent-encoded
+}
+
+// ParseDIDURL parses and validates a did:char DID URL
+func ParseDIDURL(s string) (*DIDURL, error) {
+	rest, fragment, hasFragment := strings.Cut(s, "#")
+	rest, rawQuery, hasQuery := strings.Cut(rest, "?")
+	didStr, path := rest, ""
+	if i := strings.Index(rest, "/"); i >= 0 {
+		didStr, path = rest[:i], rest[i:]
+	}
+
+	if _, err := ParseDID(didStr); err != nil {
+		return nil, err
+	}
+	if !validURLPart(path, "/") {
+		return nil, fmt.Errorf("invalid DID URL path: %s", path)
+	}
+	if hasFragment && (fragment == "" || !validURLPart(fragment, "/?")) {
+		return nil, fmt.Errorf("invalid DID URL fragment: %s", fragment)
+	}
+	if hasQuery && (rawQuery == "" || !validURLPart(rawQuery, "/?")) {
+		return nil, fmt.Errorf("invalid DID URL query: %s", rawQuery)
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DID URL query: %w", err)
+	}
+	u := &DIDURL{DID: didStr, Path: path, Query: query, Fragment: fragment}
+	if err := u.validateParams(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// validateParams checks the DID parameters DID Core defines
+func (u *DIDURL) validateParams() error {
+	for _, name := range []string{ParamService, ParamRelativeRef, ParamVersionID, ParamVersionTime, ParamHashLink} {
+		if len(u.Query[name]) > 1 {
+			return fmt.Errorf("DID parameter %s must not be repeated", name)
+		}
+		if u.Query.Has(name) && u.Query.Get(name) == "" {
+			return fmt.Errorf("DID parameter %s must not be empty", name)
+		}
+	}
+
+	if u.Query.Has(ParamRelativeRef) {
+		if !u.Query.Has(ParamService) {
+			return fmt.Errorf("DID parameter relativeRef requires service")
+		}
+		ref, err := url.Parse(u.Query.Get(ParamRelativeRef))
+		if err != nil || ref.IsAbs() || ref.Host != "" {
+			return fmt.Errorf("DID parameter relativeRef must be a relative reference: %s", u.Query.Get(ParamRelativeRef))
+		}
+	}
+	if v := u.Query.Get(ParamVersionID); v != "" {
+		if id, err := strconv.Atoi(v); err != nil || id < 0 {
+			return fmt.Errorf("DID parameter versionId must be a ballot number: %s", v)
+		}
+	}
+	if v := u.Query.Get(ParamVersionTime); v != "" {
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("DID parameter versionTime must be an RFC3339 time: %s", v)
+		}
+	}
+	return nil
+}
+
+// ResolveOptions returns the resolution options selected by the versionId and versionTime parameters
+func (u *DIDURL) ResolveOptions() ResolveOptions {
+	var opts ResolveOptions
+	if v := u.Query.Get(ParamVersionID); v != "" {
+		id, _ := strconv.Atoi(v)
+		opts.VersionID = &id
+	}
+	if v := u.Query.Get(ParamVersionTime); v != "" {
+		opts.VersionTime, _ = time.Parse(time.RFC3339, v)
+	}
+	return opts
+}
+
+// IsBareDID reports whether u has no path, fragment or query
+func (u *DIDURL) IsBareDID() bool {
+	return u.Path == "" && u.Fragment == "" && len(u.Query) == 0
+}
+
+// String returns the DID URL, with query parameters in sorted order
+func (u *DIDURL) String() string {
+	s := u.DID + u.Path
+	if len(u.Query) > 0 {
+		s += "?" + u.Query.Encode()
+	}
+	if u.Fragment != "" {
+		s += "#" + u.Fragment
+	}
+	return s
+}
+
+// validMethodSpecificID reports whether id matches the DID Core method-specific-id rule
+func validMethodSpecificID(id string) bool {
+	if id == "" || strings.HasSuffix(id, ":") {
+		return false
+	}
+	return validChars(id, ":", false)
+}
+
+// validURLPart reports whether s consists of RFC 3986 pchars and the extra characters allowed
+func validURLPart(s, extra string) bool {
+	return validChars(s, ":@!$&'()*+,;="+extra, true)
+}
+
+// validChars reports whether s consists of letters, digits, ".", "-", "_", percent-encoded
+// octets and the extra characters, plus "~" when tilde is set (DID Core idchar excludes it)
+func validChars(s, extra string, tilde bool) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '.', c == '-', c == '_':
+		case c == '~' && tilde:
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return false
+			}
+			i += 2
+		case strings.IndexByte(extra, c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
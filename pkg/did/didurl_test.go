@@ -0,0 +1,103 @@
+package did
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDIDURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		did      string
+		path     string
+		fragment string
+		query    map[string]string
+		wantErr  bool
+	}{
+		{name: "bare DID", input: "did:char:abc", did: "did:char:abc"},
+		{name: "fragment", input: "did:char:abc#key-1", did: "did:char:abc", fragment: "key-1"},
+		{name: "path", input: "did:char:abc/a/b", did: "did:char:abc", path: "/a/b"},
+		{
+			name:  "service and relativeRef",
+			input: "did:char:abc?service=files&relativeRef=%2Fa.txt",
+			did:   "did:char:abc",
+			query: map[string]string{"service": "files", "relativeRef": "/a.txt"},
+		},
+		{
+			name:     "everything",
+			input:    "did:char:abc/p?versionId=3#key-1",
+			did:      "did:char:abc",
+			path:     "/p",
+			fragment: "key-1",
+			query:    map[string]string{"versionId": "3"},
+		},
+		{name: "percent-encoded fragment", input: "did:char:abc#key%201", did: "did:char:abc", fragment: "key%201"},
+		{name: "other method", input: "did:example:abc#key-1", wantErr: true},
+		{name: "invalid DID character", input: "did:char:ab$c#key-1", wantErr: true},
+		{name: "empty suffix", input: "did:char:#key-1", wantErr: true},
+		{name: "empty fragment", input: "did:char:abc#", wantErr: true},
+		{name: "empty query", input: "did:char:abc?", wantErr: true},
+		{name: "invalid fragment", input: "did:char:abc#key 1", wantErr: true},
+		{name: "bad percent-encoding", input: "did:char:abc#key%2", wantErr: true},
+		{name: "invalid path", input: "did:char:abc/a b", wantErr: true},
+		{name: "repeated service", input: "did:char:abc?service=a&service=b", wantErr: true},
+		{name: "empty service", input: "did:char:abc?service=", wantErr: true},
+		{name: "relativeRef without service", input: "did:char:abc?relativeRef=%2Fa", wantErr: true},
+		{name: "absolute relativeRef", input: "did:char:abc?service=a&relativeRef=https%3A%2F%2Fevil.example", wantErr: true},
+		{name: "network-path relativeRef", input: "did:char:abc?service=a&relativeRef=%2F%2Fevil.example%2Fx", wantErr: true},
+		{name: "negative versionId", input: "did:char:abc?versionId=-1", wantErr: true},
+		{name: "bad versionTime", input: "did:char:abc?versionTime=yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := ParseDIDURL(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", u)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if u.DID != tt.did || u.Path != tt.path || u.Fragment != tt.fragment {
+				t.Errorf("got DID %q path %q fragment %q", u.DID, u.Path, u.Fragment)
+			}
+			if len(u.Query) != len(tt.query) {
+				t.Errorf("query = %v, want %v", u.Query, tt.query)
+			}
+			for k, v := range tt.query {
+				if u.Query.Get(k) != v {
+					t.Errorf("query %s = %q, want %q", k, u.Query.Get(k), v)
+				}
+			}
+			if u.IsBareDID() != (tt.input == tt.did) {
+				t.Errorf("IsBareDID() = %v", u.IsBareDID())
+			}
+		})
+	}
+}
+
+func TestDIDURLResolveOptions(t *testing.T) {
+	u, err := ParseDIDURL("did:char:abc?versionId=7")
+	if err != nil {
+		t.Fatalf("ParseDIDURL failed: %v", err)
+	}
+	if opts := u.ResolveOptions(); opts.VersionID == nil || *opts.VersionID != 7 || !opts.VersionTime.IsZero() {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+
+	u, err = ParseDIDURL("did:char:abc?versionTime=2025-01-01T00:00:00Z#key-1")
+	if err != nil {
+		t.Fatalf("ParseDIDURL failed: %v", err)
+	}
+	want := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if opts := u.ResolveOptions(); opts.VersionID != nil || !opts.VersionTime.Equal(want) {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	if got := u.String(); got != "did:char:abc?versionTime=2025-01-01T00%3A00%3A00Z#key-1" {
+		t.Errorf("String() = %q", got)
+	}
+}
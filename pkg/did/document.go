@@ -17,8 +17,17 @@ type PublicKey struct {
 	Type         string    `json:"type"`
 	Controller   string    `json:"controller,omitempty"`
 	PublicKeyJwk *keys.JWK `json:"publicKeyJwk,omitempty"`
+	Purposes     []string  `json:"purposes,omitempty"` // Verification relationships, as in Sidetree
 }
 
+// Key purposes: the DID Core verification relationships a public key is listed under
+const (
+	PurposeAuthentication       = "authentication"
+	PurposeAssertionMethod      = "assertionMethod"
+	PurposeKeyAgreement         = "keyAgreement"
+	PurposeCapabilityDelegation = "capabilityDelegation"
+)
+
 // Service represents a service endpoint in a DID document
 type Service struct {
 	ID              string `json:"id"`
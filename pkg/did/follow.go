@@ -0,0 +1,100 @@
+package did
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// FollowOptions configures Processor.Follow
+type FollowOptions struct {
+	MinIdle time.Duration // Wait after catching up to the tip (default: 500ms)
+	MaxIdle time.Duration // Cap for the idle backoff (default: 5s)
+
+	// OnSync, if set, is called after every pass that processed at least one ballot
+	OnSync func(start int, result *SyncResult)
+}
+
+// NextUnsyncedBallot returns the ballot after last_synced_ballot, or 0 if nothing has been synced
+func (p *Processor) NextUnsyncedBallot() (int, error) {
+	value, err := p.store.GetSyncState(syncStateLastSynced)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sync state: %w", err)
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	lastSynced, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid last synced ballot in sync state: %w", err)
+	}
+	return lastSynced + 1, nil
+}
+
+// Follow tracks the ballot tip, processing ballots as they are decided, until ctx is cancelled
+//
+// It resumes after last_synced_ballot, so a restarted follower continues where it left off.
+// While no new ballots are decided, or a batch that cannot be fetched yet holds it up,
+// it polls with an exponential backoff between MinIdle and MaxIdle.
+// Sync failures are logged and retried after the same backoff. Cancellation
+// only takes effect between ballots, never part way through applying one.
+// Follow returns ctx.Err() once cancelled.
+func (p *Processor) Follow(ctx context.Context, opts FollowOptions) error {
+	minIdle := opts.MinIdle
+	if minIdle <= 0 {
+		minIdle = 500 * time.Millisecond
+	}
+	maxIdle := opts.MaxIdle
+	if maxIdle <= 0 {
+		maxIdle = 5 * time.Second
+	}
+	if maxIdle < minIdle {
+		maxIdle = minIdle
+	}
+
+	idle := minIdle
+	for {
+		start, err := p.NextUnsyncedBallot()
+		if err != nil {
+			return err
+		}
+
+		result, err := p.SyncFromBallot(ctx, start, 0)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("Sync from ballot %d failed, retrying in %v: %v", result.Next, idle, err)
+		}
+
+		if result.Ballots > 0 {
+			if opts.OnSync != nil {
+				opts.OnSync(start, result)
+			}
+			idle = minIdle
+		}
+
+		// Not at the tip yet (and no error): keep going without waiting
+		if err == nil && !result.AtTip && !result.Blocked {
+			continue
+		}
+
+		timer := time.NewTimer(idle)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if result.Ballots == 0 || err != nil {
+			idle *= 2
+			if idle > maxIdle {
+				idle = maxIdle
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package did
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+)
+
+// followInBackground runs Follow until the returned stop function is called
+func followInBackground(t *testing.T, p *Processor, opts FollowOptions) (stop func() error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Follow(ctx, opts) }()
+
+	return func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Follow did not stop after cancellation")
+			return nil
+		}
+	}
+}
+
+// waitFor polls cond until it holds or the deadline passes
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFollowAppliesNewBallots(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	replica := newTestStore(t, filepath.Join(t.TempDir(), "replica.db"))
+	follower := NewProcessor(replica, env.client, env.cfg.CHAR.AppPreimage)
+	stop := followInBackground(t, follower, FollowOptions{MinIdle: time.Millisecond, MaxIdle: 10 * time.Millisecond})
+
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	waitFor(t, "follower to apply the create", func() bool {
+		exists, _ := replica.DIDExists(created.DID)
+		return exists
+	})
+
+	if err := stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Follow returned %v, want context.Canceled", err)
+	}
+
+	next, err := follower.NextUnsyncedBallot()
+	if err != nil {
+		t.Fatalf("NextUnsyncedBallot failed: %v", err)
+	}
+	if next != created.BallotNumber+1 {
+		t.Errorf("next unsynced ballot = %d, want %d", next, created.BallotNumber+1)
+	}
+}
+
+func TestFollowResumesFromSyncState(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{})
+	for i := 0; i < 10; i++ {
+		env.srv.Advance()
+	}
+
+	if err := env.store.SetSyncState(syncStateLastSynced, "6"); err != nil {
+		t.Fatalf("SetSyncState failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var starts []int
+	processor := NewProcessor(env.store, env.client, env.cfg.CHAR.AppPreimage)
+	stop := followInBackground(t, processor, FollowOptions{
+		MinIdle: time.Millisecond,
+		OnSync: func(start int, result *SyncResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			starts = append(starts, start)
+		},
+	})
+
+	waitFor(t, "first sync pass", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(starts) > 0
+	})
+	stop()
+
+	if starts[0] != 7 {
+		t.Errorf("first pass started at ballot %d, want 7", starts[0])
+	}
+	next, _ := processor.NextUnsyncedBallot()
+	if next != 10 {
+		t.Errorf("next unsynced ballot = %d, want 10", next)
+	}
+}
+
+func TestFollowSurvivesRPCErrors(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{})
+	env.srv.Advance()
+
+	// Without client retries every injected fault reaches Follow
+	cfg := env.cfg.CHAR
+	cfg.MaxRetries = 0
+	env.srv.InjectHTTPError(3, 503)
+
+	processor := NewProcessor(env.store, char.NewClient(&cfg), env.cfg.CHAR.AppPreimage)
+	stop := followInBackground(t, processor, FollowOptions{MinIdle: time.Millisecond, MaxIdle: 2 * time.Millisecond})
+
+	waitFor(t, "ballot 0 to sync", func() bool {
+		next, _ := processor.NextUnsyncedBallot()
+		return next == 1
+	})
+	stop()
+}
@@ -0,0 +1,667 @@
+package did
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+	"github.com/yourusername/did-char/pkg/config"
+	"github.com/yourusername/did-char/pkg/encoding"
+	"github.com/yourusername/did-char/pkg/signing"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// testEnv wires a fake CHAR node, a fresh SQLite store and a config together
+type testEnv struct {
+	srv    *chartest.Server
+	cfg    *config.Config
+	store  *storage.Store
+	client *char.Client
+}
+
+func newTestEnv(t *testing.T, opts chartest.Options) *testEnv {
+	t.Helper()
+
+	srv := chartest.NewServer(opts)
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.CHAR = srv.CHARConfig()
+	cfg.DataDir.Path = dir
+	cfg.DataDir.KeysDir = filepath.Join(dir, "keys")
+	cfg.DataDir.DBPath = filepath.Join(dir, "did-char.db")
+	cfg.Database.Path = cfg.DataDir.DBPath
+
+	store := newTestStore(t, cfg.Database.Path)
+
+	return &testEnv{
+		srv:    srv,
+		cfg:    cfg,
+		store:  store,
+		client: char.NewClient(&cfg.CHAR),
+	}
+}
+
+func newTestStore(t *testing.T, path string) *storage.Store {
+	t.Helper()
+	store, err := storage.NewStore(path)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// encodeTestPayload encodes one operation as another writer would, in a canonical batch
+func encodeTestPayload(t *testing.T, opType encoding.OperationType, suffix string, op interface{}) string {
+	t.Helper()
+	encoded, err := encoding.NewOperation(opType, suffix, op)
+	if err != nil {
+		t.Fatalf("NewOperation failed: %v", err)
+	}
+	payload, err := encoding.EncodeCanonicalPayload([]encoding.Operation{encoded}, encoding.CompressionNone)
+	if err != nil {
+		t.Fatalf("EncodeCanonicalPayload failed: %v", err)
+	}
+	return payload
+}
+
+func loadDocument(t *testing.T, store *storage.Store, did string) (*storage.DIDRecord, *Document) {
+	t.Helper()
+	record, err := store.GetDID(did)
+	if err != nil {
+		t.Fatalf("GetDID failed: %v", err)
+	}
+	if record == nil {
+		t.Fatalf("DID %s not found", did)
+	}
+	var doc Document
+	if err := json.Unmarshal([]byte(record.Document), &doc); err != nil {
+		t.Fatalf("failed to parse document: %v", err)
+	}
+	return record, &doc
+}
+
+func TestIntegrationLifecycle(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	created, err := CreateDID(&CreateDIDRequest{
+		Services: []Service{{ID: "#domain", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	record, doc := loadDocument(t, env.store, created.DID)
+	if record.Status != "active" || record.CreatedAtBallot != created.BallotNumber {
+		t.Errorf("unexpected record after create: %+v", record)
+	}
+	if len(doc.Services) != 1 {
+		t.Errorf("expected 1 service, got %d", len(doc.Services))
+	}
+
+	err = UpdateDID(&UpdateDIDRequest{
+		DID:            created.DID,
+		AddServices:    []Service{{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: "https://hub.example.com"}},
+		RemoveServices: []string{"#domain"},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("UpdateDID failed: %v", err)
+	}
+
+	record, doc = loadDocument(t, env.store, created.DID)
+	if len(doc.Services) != 1 || doc.Services[0].ID != "#hub" {
+		t.Errorf("unexpected services after update: %+v", doc.Services)
+	}
+	if record.LastOperationBallot <= created.BallotNumber {
+		t.Errorf("last operation ballot not advanced: %d", record.LastOperationBallot)
+	}
+
+	if err := DeactivateDID(&DeactivateDIDRequest{DID: created.DID}, env.cfg, env.store, env.client); err != nil {
+		t.Fatalf("DeactivateDID failed: %v", err)
+	}
+
+	record, _ = loadDocument(t, env.store, created.DID)
+	if record.Status != "deactivated" {
+		t.Errorf("status = %s, want deactivated", record.Status)
+	}
+
+	ops, err := env.store.GetOperations(created.DID)
+	if err != nil {
+		t.Fatalf("GetOperations failed: %v", err)
+	}
+	if len(ops) != 3 {
+		t.Errorf("expected 3 operations, got %d", len(ops))
+	}
+}
+
+func TestIntegrationKeyAlgorithms(t *testing.T) {
+	tests := []struct {
+		algorithm signing.SignatureAlgorithm
+		crv       string
+		keyType   string
+	}{
+		{signing.AlgES256, "P-256", "JsonWebKey2020"},
+		{signing.AlgES256K, "secp256k1", "EcdsaSecp256k1VerificationKey2019"},
+		{signing.AlgEdDSA, "Ed25519", "Ed25519VerificationKey2020"},
+		{signing.AlgBLS, "BLS12-381-G1", "Bls12381G1Key2020"},
+		{signing.AlgSchnorr, "secp256k1", "SchnorrSecp256k1VerificationKey2019"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.algorithm), func(t *testing.T) {
+			env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+			created, err := CreateDID(&CreateDIDRequest{Algorithm: tt.algorithm}, env.cfg, env.store, env.client)
+			if err != nil {
+				t.Fatalf("CreateDID failed: %v", err)
+			}
+			_, doc := loadDocument(t, env.store, created.DID)
+			if len(doc.PublicKeys) != 1 || doc.PublicKeys[0].Type != tt.keyType || doc.PublicKeys[0].PublicKeyJwk.Crv != tt.crv {
+				t.Fatalf("unexpected public keys after create: %+v", doc.PublicKeys)
+			}
+
+			// Update and recover are signed with, and rotate to, keys of the same algorithm
+			err = UpdateDID(&UpdateDIDRequest{
+				DID:         created.DID,
+				AddServices: []Service{{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: "https://hub.example.com"}},
+			}, env.cfg, env.store, env.client)
+			if err != nil {
+				t.Fatalf("UpdateDID failed: %v", err)
+			}
+			recovered, err := RecoverDID(&RecoverDIDRequest{DID: created.DID}, env.cfg, env.store, env.client)
+			if err != nil {
+				t.Fatalf("RecoverDID failed: %v", err)
+			}
+			if recovered.KeyFile.UpdateKey.Crv != tt.crv || recovered.KeyFile.RecoveryKey.Crv != tt.crv {
+				t.Errorf("rotated keys changed curve: %s, %s", recovered.KeyFile.UpdateKey.Crv, recovered.KeyFile.RecoveryKey.Crv)
+			}
+
+			ops, err := env.store.GetOperations(created.DID)
+			if err != nil {
+				t.Fatalf("GetOperations failed: %v", err)
+			}
+			if len(ops) != 3 {
+				t.Errorf("expected 3 operations, got %d", len(ops))
+			}
+		})
+	}
+}
+
+func TestIntegrationSyncReplicatesState(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	err = UpdateDID(&UpdateDIDRequest{
+		DID:         created.DID,
+		AddServices: []Service{{ID: "#domain", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("UpdateDID failed: %v", err)
+	}
+
+	// Empty ballots in between must be skipped by a replica
+	env.srv.EmptyBallots(2)
+	env.srv.Advance()
+	env.srv.Advance()
+
+	replica := newTestStore(t, filepath.Join(t.TempDir(), "replica.db"))
+	processor := NewProcessor(replica, env.client, env.cfg.CHAR.AppPreimage)
+	if _, err := processor.SyncFromBallot(context.Background(), 0, env.srv.NextBallot()); err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+
+	original, _ := loadDocument(t, env.store, created.DID)
+	replicated, _ := loadDocument(t, replica, created.DID)
+	if original.Document != replicated.Document {
+		t.Errorf("replica document differs:\n%s\n%s", original.Document, replicated.Document)
+	}
+	if original.UpdateCommitment != replicated.UpdateCommitment {
+		t.Error("replica update commitment differs")
+	}
+}
+
+func TestIntegrationCreateLostBallotSavesNoKeyFile(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	// Another writer's vote lands first and wins the ballot
+	env.srv.InjectVote(env.cfg.CHAR.AppPreimage, "01020304", true)
+
+	_, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	var lost *char.BallotLostError
+	if !errors.As(err, &lost) {
+		t.Fatalf("expected BallotLostError, got %v", err)
+	}
+
+	entries, err := os.ReadDir(env.cfg.DataDir.KeysDir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("failed to read keys dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no key files, found %d", len(entries))
+	}
+
+	count, _ := env.store.GetDIDCount("")
+	if count != 0 {
+		t.Errorf("expected no DIDs stored, got %d", count)
+	}
+}
+
+func TestIntegrationProcessBallotOutcomes(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	env.srv.EmptyBallots(1)
+	env.srv.Advance()
+	env.srv.InjectVote(env.cfg.CHAR.AppPreimage, "ff0102030405060708", true)
+	env.srv.Advance()
+
+	replica := newTestStore(t, filepath.Join(t.TempDir(), "replica.db"))
+	processor := NewProcessor(replica, env.client, env.cfg.CHAR.AppPreimage)
+
+	tests := []struct {
+		name   string
+		ballot int
+		want   BallotOutcome
+	}{
+		{"create applied", created.BallotNumber, BallotApplied},
+		{"replayed create is idempotent", created.BallotNumber, BallotApplied},
+		{"empty ballot", created.BallotNumber + 1, BallotEmpty},
+		{"non-DID payload", created.BallotNumber + 2, BallotRejected},
+		{"undecided ballot", env.srv.NextBallot(), BallotUndecided},
+	}
+
+	for _, tt := range tests {
+		outcome, err := processor.ProcessBallot(context.Background(), tt.ballot)
+		if err != nil {
+			t.Fatalf("%s: ProcessBallot failed: %v", tt.name, err)
+		}
+		if outcome != tt.want {
+			t.Errorf("%s: outcome = %s, want %s", tt.name, outcome, tt.want)
+		}
+	}
+}
+
+func TestIntegrationSyncRejectsMalformedWrapper(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	// A vote whose slot wrapper declares an 8-byte length but carries only 4 bytes
+	env.srv.InjectVote(env.cfg.CHAR.AppPreimage, "0000ff01020304", false)
+	malformed := env.srv.NextBallot()
+	env.srv.Advance()
+
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	replica := newTestStore(t, filepath.Join(t.TempDir(), "replica.db"))
+	processor := NewProcessor(replica, env.client, env.cfg.CHAR.AppPreimage)
+
+	result, err := processor.SyncFromBallot(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if result.Rejected != 1 || result.Applied != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	rejected, err := replica.GetRejectedOperationsAtBallot(malformed)
+	if err != nil {
+		t.Fatalf("GetRejectedOperationsAtBallot failed: %v", err)
+	}
+	if len(rejected) != 1 || !strings.Contains(rejected[0].Reason, "malformed CHAR wrapper") {
+		t.Errorf("malformed ballot not recorded as rejected: %+v", rejected)
+	}
+	if exists, _ := replica.DIDExists(created.DID); !exists {
+		t.Error("operation decided after the malformed ballot was skipped")
+	}
+}
+
+func TestIntegrationSyncStopsAtTip(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	if _, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client); err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	replica := newTestStore(t, filepath.Join(t.TempDir(), "replica.db"))
+	processor := NewProcessor(replica, env.client, env.cfg.CHAR.AppPreimage)
+
+	// Ask for far more ballots than exist; sync must stop at the tip
+	tip := env.srv.NextBallot()
+	result, err := processor.SyncFromBallot(context.Background(), 0, 100)
+	if err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if !result.AtTip || result.Next != tip || result.Applied != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	lastSynced, err := syncStateInt(replica, syncStateLastSynced)
+	if err != nil {
+		t.Fatalf("failed to read sync state: %v", err)
+	}
+	if lastSynced != tip-1 {
+		t.Errorf("last synced = %d, want %d", lastSynced, tip-1)
+	}
+
+	// An operation decided on the former tip is picked up by the next sync
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	if created.BallotNumber != tip {
+		t.Fatalf("second create landed on ballot %d, want %d", created.BallotNumber, tip)
+	}
+
+	result, err = processor.SyncFromBallot(context.Background(), lastSynced+1, 0)
+	if err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if result.Applied != 1 {
+		t.Errorf("applied = %d, want 1", result.Applied)
+	}
+	if exists, _ := replica.DIDExists(created.DID); !exists {
+		t.Error("operation decided after the first sync was skipped")
+	}
+}
+
+func TestSyncFromBallotMaxBallots(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{})
+	for i := 0; i < 5; i++ {
+		env.srv.Advance()
+	}
+
+	processor := NewProcessor(env.store, env.client, env.cfg.CHAR.AppPreimage)
+	result, err := processor.SyncFromBallot(context.Background(), 0, 3)
+	if err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if result.AtTip || result.Ballots != 3 || result.Next != 3 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestSyncFromBallotPastCursorKeepsCursor(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{})
+	for i := 0; i < 5; i++ {
+		env.srv.Advance()
+	}
+
+	// Ballots 0 and 1 are never synced, so the cursor must not pass them
+	processor := NewProcessor(env.store, env.client, env.cfg.CHAR.AppPreimage)
+	if _, err := processor.SyncFromBallot(context.Background(), 2, 0); err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if next, _ := processor.NextUnsyncedBallot(); next != 0 {
+		t.Errorf("next unsynced ballot = %d, want 0", next)
+	}
+
+	if _, err := processor.SyncFromBallot(context.Background(), 0, 0); err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if next, _ := processor.NextUnsyncedBallot(); next != 5 {
+		t.Errorf("next unsynced ballot = %d, want 5", next)
+	}
+}
+
+func TestIntegrationBallotTimesBoundedOnlyWhenSeenUndecided(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	early, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	// The replica syncs the create after the fact, then sees the next ballot undecided
+	replica := newTestStore(t, filepath.Join(t.TempDir(), "replica.db"))
+	processor := NewProcessor(replica, env.client, env.cfg.CHAR.AppPreimage)
+	if _, err := processor.SyncFromBallot(context.Background(), 0, 0); err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	late, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	if _, err := processor.SyncFromBallot(context.Background(), late.BallotNumber, 0); err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+
+	if at, _ := replica.GetBallotTime(early.BallotNumber); at == nil || at.Bounded() {
+		t.Errorf("ballot synced after the fact has times %+v, want no lower bound", at)
+	}
+	if at, _ := replica.GetBallotTime(late.BallotNumber); at == nil || !at.Bounded() || at.DecidedAt.Before(at.DecidedAfter) {
+		t.Errorf("ballot seen undecided has times %+v, want both bounds", at)
+	}
+
+	// Metadata leaves out the time the replica cannot know
+	result, err := NewResolver(replica).ResolveResult(early.DID, ResolveOptions{})
+	if err != nil {
+		t.Fatalf("ResolveResult failed: %v", err)
+	}
+	if result.DIDDocumentMetadata.Created != "" {
+		t.Errorf("created = %s for a ballot synced after the fact", result.DIDDocumentMetadata.Created)
+	}
+}
+
+func TestIntegrationSyncReplayIsIdempotent(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	err = UpdateDID(&UpdateDIDRequest{
+		DID:         created.DID,
+		AddServices: []Service{{ID: "#domain", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("UpdateDID failed: %v", err)
+	}
+	before, _ := loadDocument(t, env.store, created.DID)
+
+	// The writer already applied both ballots; syncing over them must not fail or reapply
+	processor := NewProcessor(env.store, env.client, env.cfg.CHAR.AppPreimage)
+	result, err := processor.SyncFromBallot(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if result.Applied != 2 || !result.AtTip {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	after, _ := loadDocument(t, env.store, created.DID)
+	if before.Document != after.Document || before.UpdateCommitment != after.UpdateCommitment {
+		t.Error("replay changed DID state")
+	}
+	if count, _ := env.store.GetOperationCount(); count != 2 {
+		t.Errorf("operation count = %d, want 2", count)
+	}
+}
+
+func TestIntegrationRejectedOperationsAreRecorded(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	domain := env.cfg.CHAR.AppPreimage
+
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	suffix, _ := ParseDID(created.DID)
+
+	unknownType, err := encoding.EncodePayload(encoding.OperationType(0x7f), suffix, map[string]string{"type": "bogus"})
+	if err != nil {
+		t.Fatalf("EncodePayload failed: %v", err)
+	}
+	forgedUpdate, err := encoding.EncodePayload(encoding.OperationTypeUpdate, suffix, &UpdateOperation{
+		Type:        "update",
+		DID:         created.DID,
+		RevealValue: "not-the-reveal-value",
+	})
+	if err != nil {
+		t.Fatalf("EncodePayload failed: %v", err)
+	}
+
+	for _, payload := range []string{unknownType, "ff0102030405060708", forgedUpdate} {
+		env.srv.InjectVote(domain, payload, true)
+		env.srv.Advance()
+	}
+
+	// A valid operation after the invalid ones must still be applied
+	err = UpdateDID(&UpdateDIDRequest{
+		DID:         created.DID,
+		AddServices: []Service{{ID: "#domain", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("UpdateDID failed: %v", err)
+	}
+
+	replica := newTestStore(t, filepath.Join(t.TempDir(), "replica.db"))
+	processor := NewProcessor(replica, env.client, domain)
+	result, err := processor.SyncFromBallot(context.Background(), 0, 0)
+	if err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	if result.Applied != 2 || result.Rejected != 3 || !result.AtTip {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	rejected, err := replica.GetRejectedOperations("", 0)
+	if err != nil {
+		t.Fatalf("GetRejectedOperations failed: %v", err)
+	}
+	if len(rejected) != 3 {
+		t.Fatalf("expected 3 rejected operations, got %d", len(rejected))
+	}
+
+	// Newest first
+	wants := []struct {
+		ballot int
+		did    string
+		opType string
+		reason string
+	}{
+		{created.BallotNumber + 3, created.DID, OperationTypeUpdate, "reveal value does not match commitment"},
+		{created.BallotNumber + 2, "", "", "failed to decode payload"},
+		{created.BallotNumber + 1, created.DID, "0x7f", "unknown operation type 127"},
+	}
+	for i, want := range wants {
+		got := rejected[i]
+		if got.BallotNumber != want.ballot || got.DID != want.did || got.OperationType != want.opType {
+			t.Errorf("rejected[%d] = %+v, want ballot %d did %q type %q", i, got, want.ballot, want.did, want.opType)
+		}
+		if !strings.HasPrefix(got.Reason, want.reason) {
+			t.Errorf("rejected[%d] reason = %q, want prefix %q", i, got.Reason, want.reason)
+		}
+		if got.Payload == "" {
+			t.Errorf("rejected[%d] has no payload", i)
+		}
+	}
+
+	byDID, _ := replica.GetRejectedOperations(created.DID, 1)
+	if len(byDID) != 1 || byDID[0].BallotNumber != created.BallotNumber+3 {
+		t.Errorf("filtered rejections = %+v", byDID)
+	}
+
+	// Replaying the same ballots records nothing new
+	if _, err := processor.SyncFromBallot(context.Background(), 0, 0); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if count, _ := replica.GetRejectedOperationCount(); count != 3 {
+		t.Errorf("rejected count after replay = %d, want 3", count)
+	}
+
+	original, _ := loadDocument(t, env.store, created.DID)
+	replicated, _ := loadDocument(t, replica, created.DID)
+	if original.Document != replicated.Document {
+		t.Error("replica diverged after rejected operations")
+	}
+}
+
+func TestIntegrationCreateSuffixIsEnforced(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{})
+	domain := env.cfg.CHAR.AppPreimage
+
+	victimOp, victimDID := newTestCreateOperation(t)
+	victimSuffix, _ := ParseDID(victimDID)
+	attackerOp, attackerDID := newTestCreateOperation(t)
+	attackerSuffix, _ := ParseDID(attackerDID)
+
+	// Attacker's keys with a document claiming the victim's identifier
+	squat := *attackerOp
+	squatDoc := *attackerOp.InitialDocument
+	squatDoc.ID = victimDID
+	squat.InitialDocument = &squatDoc
+
+	tests := []struct {
+		name   string
+		suffix string
+		op     *CreateOperation
+		reason string
+	}{
+		{"squatted suffix", victimSuffix, &squat, "DID suffix does not match create operation"},
+		{"document ID mismatch", attackerSuffix, &squat, "initial document ID"},
+		{"missing document", attackerSuffix, &CreateOperation{Type: "create", UpdateCommitment: "x", RecoveryCommitment: "y"}, "create operation has no initial document"},
+		{"legitimate create after squat", victimSuffix, victimOp, ""},
+	}
+
+	firstBallot := env.srv.NextBallot()
+	for _, tt := range tests {
+		payload := encodeTestPayload(t, encoding.OperationTypeCreate, tt.suffix, tt.op)
+		env.srv.InjectVote(domain, payload, true)
+		env.srv.Advance()
+	}
+
+	processor := NewProcessor(env.store, env.client, domain)
+	if _, err := processor.SyncFromBallot(context.Background(), 0, 0); err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+
+	for i, tt := range tests {
+		ballot := firstBallot + i
+		rejected, _ := env.store.GetRejectedOperations("", 0)
+		var reason string
+		for _, r := range rejected {
+			if r.BallotNumber == ballot {
+				reason = r.Reason
+			}
+		}
+
+		if tt.reason == "" {
+			if reason != "" {
+				t.Errorf("%s: unexpectedly rejected: %s", tt.name, reason)
+			}
+			continue
+		}
+		if !strings.HasPrefix(reason, tt.reason) {
+			t.Errorf("%s: reason = %q, want prefix %q", tt.name, reason, tt.reason)
+		}
+	}
+
+	// The victim's DID belongs to the victim's keys, and the attacker's was never created
+	record, doc := loadDocument(t, env.store, victimDID)
+	if record.UpdateCommitment != victimOp.UpdateCommitment {
+		t.Error("victim DID has the wrong update commitment")
+	}
+	if doc.ID != victimDID {
+		t.Errorf("document ID = %s, want %s", doc.ID, victimDID)
+	}
+	if exists, _ := env.store.DIDExists(attackerDID); exists {
+		t.Error("attacker DID should not exist")
+	}
+}
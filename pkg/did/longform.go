@@ -0,0 +1,86 @@
+package did
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/did-char/pkg/canonical"
+	"github.com/yourusername/did-char/pkg/crypto"
+)
+
+// A long-form DID carries its own create operation, following Sidetree:
+//
+//	did:char:<suffix>:<base64url(initial state)>
+//
+// The initial state is the create operation exactly as it was hashed into the
+// suffix, so any resolver can verify it and resolve the DID before the create
+// operation is anchored on CHAR.
+
+// LongFormDID returns the long-form DID for a create operation built by NewCreateOperation
+func LongFormDID(op *CreateOperation) (string, error) {
+	if op.InitialDocument == nil || op.InitialDocument.ID == "" {
+		return "", fmt.Errorf("create operation has no DID yet")
+	}
+	did := op.InitialDocument.ID
+	initialState, err := createInitialState(op, did)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := canonical.Marshal(initialState)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal initial state: %w", err)
+	}
+	return did + ":" + crypto.Base64URLEncode(encoded), nil
+}
+
+// IsLongFormDID reports whether did has the long form did:char:<suffix>:<initial state>
+func IsLongFormDID(did string) bool {
+	return strings.HasPrefix(did, DIDPrefix) && strings.Contains(did[len(DIDPrefix):], ":")
+}
+
+// ParseLongFormDID verifies a long-form DID against its suffix
+//
+// It returns the short-form DID and the embedded create operation, with the
+// initial document's ID and key controllers set to the short-form DID as they
+// are once the operation is anchored.
+func ParseLongFormDID(did string) (string, *CreateOperation, error) {
+	if _, err := ParseDID(did); err != nil {
+		return "", nil, err
+	}
+	suffix, encoded, ok := strings.Cut(did[len(DIDPrefix):], ":")
+	if !ok || strings.Contains(encoded, ":") {
+		return "", nil, fmt.Errorf("invalid long-form DID: %s", did)
+	}
+
+	data, err := crypto.Base64URLDecode(encoded)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid long-form DID initial state: %w", err)
+	}
+	var op CreateOperation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return "", nil, fmt.Errorf("invalid long-form DID initial state: %w", err)
+	}
+	if op.Type != OperationTypeCreate || op.InitialDocument == nil || op.InitialDocument.ID != "" {
+		return "", nil, fmt.Errorf("long-form DID initial state is not a create operation")
+	}
+
+	// Only the JCS hash of the state as received is accepted: the embedded state is
+	// what the suffix commits to, and there is no anchored payload version to say otherwise
+	matches, computed, err := createSuffixMatches(data, "", suffix, canonical.JCS)
+	if err != nil {
+		return "", nil, err
+	}
+	if !matches {
+		return "", nil, fmt.Errorf("long-form DID initial state does not match suffix (computed %s)", computed)
+	}
+
+	shortDID := FormatDID(suffix)
+	op.InitialDocument.ID = shortDID
+	for i := range op.InitialDocument.PublicKeys {
+		if op.InitialDocument.PublicKeys[i].Controller == "" {
+			op.InitialDocument.PublicKeys[i].Controller = shortDID
+		}
+	}
+	return shortDID, &op, nil
+}
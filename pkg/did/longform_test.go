@@ -0,0 +1,180 @@
+package did
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/did-char/pkg/canonical"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+	"github.com/yourusername/did-char/pkg/crypto"
+	"github.com/yourusername/did-char/pkg/encoding"
+	"github.com/yourusername/did-char/pkg/keys"
+)
+
+// newLongFormCreate builds an unanchored create operation with one self-controlled key
+func newLongFormCreate(t *testing.T) (*CreateOperation, string) {
+	t.Helper()
+	pub, _, _ := ed25519.GenerateKey(nil)
+	doc := NewDocument("")
+	doc.AddPublicKey(PublicKey{ID: "#key-1", Type: "Ed25519VerificationKey2020", PublicKeyJwk: keys.Ed25519PublicKeyToJWK(pub, "key-1")})
+	doc.AddPublicKey(PublicKey{ID: "#key-2", Type: "Ed25519VerificationKey2020", Controller: "did:char:other", PublicKeyJwk: keys.Ed25519PublicKeyToJWK(pub, "key-2")})
+	doc.AddService(Service{ID: "#files", Type: "LinkedDomains", ServiceEndpoint: "https://files.example.com/"})
+
+	op, err := NewCreateOperation(doc, "update-commitment", "recovery-commitment")
+	if err != nil {
+		t.Fatalf("NewCreateOperation failed: %v", err)
+	}
+	longForm, err := LongFormDID(op)
+	if err != nil {
+		t.Fatalf("LongFormDID failed: %v", err)
+	}
+	return op, longForm
+}
+
+func TestParseLongFormDID(t *testing.T) {
+	op, longForm := newLongFormCreate(t)
+	shortDID := op.InitialDocument.ID
+
+	if !strings.HasPrefix(longForm, shortDID+":") || !IsLongFormDID(longForm) || IsLongFormDID(shortDID) {
+		t.Fatalf("unexpected long form %s for %s", longForm, shortDID)
+	}
+	gotShort, gotOp, err := ParseLongFormDID(longForm)
+	if err != nil {
+		t.Fatalf("ParseLongFormDID failed: %v", err)
+	}
+	want, _ := json.Marshal(op)
+	got, _ := json.Marshal(gotOp)
+	if gotShort != shortDID || string(got) != string(want) {
+		t.Errorf("round trip mismatch:\n got %s %s\nwant %s %s", gotShort, got, shortDID, want)
+	}
+
+	suffix, encoded, _ := strings.Cut(strings.TrimPrefix(longForm, DIDPrefix), ":")
+	state, _ := crypto.Base64URLDecode(encoded)
+	reencode := func(edit func(op *CreateOperation)) string {
+		var op CreateOperation
+		json.Unmarshal(state, &op)
+		edit(&op)
+		data, _ := json.Marshal(&op)
+		return DIDPrefix + suffix + ":" + crypto.Base64URLEncode(data)
+	}
+	_, legacySuffix, err := createSuffixMatches(state, "", suffix, canonical.Legacy)
+	if err != nil || legacySuffix == suffix {
+		t.Fatalf("legacy suffix %s should differ from %s: %v", legacySuffix, suffix, err)
+	}
+	otherOp, _ := newLongFormCreate(t)
+	otherSuffix, _ := ParseDID(otherOp.InitialDocument.ID)
+
+	tests := []struct {
+		name string
+		did  string
+	}{
+		{"short form", shortDID},
+		{"wrong suffix", DIDPrefix + otherSuffix + ":" + encoded},
+		{"legacy suffix", DIDPrefix + legacySuffix + ":" + encoded},
+		{"tampered document", reencode(func(op *CreateOperation) { op.InitialDocument.Services[0].ServiceEndpoint = "https://evil.example/" })},
+		{"tampered commitment", reencode(func(op *CreateOperation) { op.UpdateCommitment = "attacker" })},
+		{"document with ID", reencode(func(op *CreateOperation) { op.InitialDocument.ID = shortDID })},
+		{"not a create", reencode(func(op *CreateOperation) { op.Type = OperationTypeUpdate })},
+		{"not base64url", DIDPrefix + suffix + ":!!"},
+		{"not JSON", DIDPrefix + suffix + ":" + crypto.Base64URLEncode([]byte("nope"))},
+		{"extra segment", longForm + ":x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseLongFormDID(tt.did); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestIntegrationResolveLongForm(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	resolver := NewResolver(env.store)
+
+	op, longForm := newLongFormCreate(t)
+	shortDID := op.InitialDocument.ID
+
+	// Before anchoring only the long form resolves
+	res, err := resolver.ResolveResult(shortDID, ResolveOptions{})
+	if err != nil || res.DIDResolutionMetadata.Error != ResolutionErrorNotFound {
+		t.Fatalf("short form before anchoring: %+v, %v", res, err)
+	}
+	res, err = resolver.ResolveResult(longForm, ResolveOptions{})
+	if err != nil || res.DIDResolutionMetadata.Error != "" {
+		t.Fatalf("ResolveResult failed: %+v, %v", res, err)
+	}
+	meta := res.DIDDocumentMetadata
+	if meta.Method == nil || meta.Method.Published || meta.Method.CreatedAtBallot != nil || meta.CanonicalID != "" || len(meta.EquivalentID) != 1 || meta.EquivalentID[0] != shortDID {
+		t.Errorf("unexpected unpublished metadata: %+v %+v", meta, meta.Method)
+	}
+	doc := res.DIDDocument
+	if doc.ID != longForm || len(doc.VerificationMethod) != 2 || len(doc.Service) != 1 {
+		t.Fatalf("unexpected unpublished document: %+v", doc)
+	}
+	if vm := doc.VerificationMethod[0]; vm.ID != longForm+"#key-1" || vm.Controller != longForm {
+		t.Errorf("self-controlled key should use the long form: %+v", vm)
+	}
+	if vm := doc.VerificationMethod[1]; vm.Controller != "did:char:other" {
+		t.Errorf("foreign controller changed: %+v", vm)
+	}
+	versionID := 1
+	if res, _ := resolver.ResolveResult(longForm, ResolveOptions{VersionID: &versionID}); res.DIDResolutionMetadata.Error != ResolutionErrorNotFound {
+		t.Errorf("unpublished version should be notFound: %+v", res.DIDResolutionMetadata)
+	}
+	if deref, _ := resolver.Dereference(longForm + "#key-1"); deref.DereferencingMetadata.Error != "" {
+		t.Errorf("dereferencing unpublished key failed: %+v", deref.DereferencingMetadata)
+	}
+
+	tampered := strings.Replace(longForm, "files.example.com", "x", 1)
+	if res, _ := resolver.ResolveResult(tampered[:len(tampered)-2], ResolveOptions{}); res.DIDResolutionMetadata.Error != ResolutionErrorInvalidDID {
+		t.Errorf("tampered long form should be invalidDid: %+v", res.DIDResolutionMetadata)
+	}
+
+	// Once anchored, the long form resolves to the anchored state
+	suffix, _ := ParseDID(shortDID)
+	ballot, err := SubmitOperation(context.Background(), env.cfg, env.store, env.client, encoding.OperationTypeCreate, suffix, op)
+	if err != nil {
+		t.Fatalf("SubmitOperation failed: %v", err)
+	}
+	res, err = resolver.ResolveResult(longForm, ResolveOptions{})
+	if err != nil || res.DIDResolutionMetadata.Error != "" {
+		t.Fatalf("ResolveResult failed: %+v, %v", res, err)
+	}
+	meta = res.DIDDocumentMetadata
+	if !meta.Method.Published || meta.Method.CreatedAtBallot == nil || *meta.Method.CreatedAtBallot != ballot || meta.CanonicalID != shortDID || len(meta.EquivalentID) != 1 || meta.EquivalentID[0] != shortDID {
+		t.Errorf("unexpected published metadata: %+v %+v", meta, meta.Method)
+	}
+	if res.DIDDocument.ID != longForm || res.DIDDocument.VerificationMethod[0].Controller != longForm {
+		t.Errorf("published long form should keep its ID: %+v", res.DIDDocument)
+	}
+
+	short, err := resolver.ResolveResult(shortDID, ResolveOptions{})
+	if err != nil || short.DIDDocument.ID != shortDID || short.DIDDocumentMetadata.EquivalentID != nil || !short.DIDDocumentMetadata.Method.Published {
+		t.Errorf("unexpected short form result: %+v, %v", short, err)
+	}
+
+	plain, err := resolver.Resolve(longForm, ResolveOptions{VersionID: &ballot})
+	if err != nil || plain.DID != shortDID || plain.LongForm != longForm || !plain.Published {
+		t.Errorf("unexpected resolution: %+v, %v", plain, err)
+	}
+}
+
+func TestIntegrationCreateDIDLongForm(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	shortDID, op, err := ParseLongFormDID(created.LongFormDID)
+	if err != nil {
+		t.Fatalf("ParseLongFormDID failed: %v", err)
+	}
+	if shortDID != created.DID || op.UpdateCommitment != created.KeyFile.NextUpdateCommitment {
+		t.Errorf("long form %s does not match %s", created.LongFormDID, created.DID)
+	}
+}
@@ -0,0 +1,262 @@
+package did
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/yourusername/did-char/pkg/canonical"
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/config"
+	"github.com/yourusername/did-char/pkg/crypto"
+	"github.com/yourusername/did-char/pkg/encoding"
+	"github.com/yourusername/did-char/pkg/keys"
+	"github.com/yourusername/did-char/pkg/signing"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// PendingOperation is an update, recover or deactivate operation awaiting its signature
+//
+// The signature can be produced locally, as UpdateDID does with the key file, or by
+// a client that holds the key: SigningInput is what it signs and Complete takes the
+// resulting compact JWS back.
+type PendingOperation struct {
+	Type       string // OperationTypeUpdate, OperationTypeRecover or OperationTypeDeactivate
+	DID        string
+	SigningKey *keys.JWK // Public key the operation must be signed with
+	Payload    []byte    // Signed data JSON
+
+	op     interface{} // *UpdateOperation, *RecoverOperation or *DeactivateOperation
+	signed bool
+}
+
+// PrepareUpdate builds an update applying patches to an active DID
+//
+// updateKey is the public half of the key committed to by the DID's update
+// commitment; nextUpdateCommitment commits to the key for the following update.
+func PrepareUpdate(store *storage.Store, did string, updateKey *keys.JWK, nextUpdateCommitment string, patches []Patch) (*PendingOperation, error) {
+	updateKey = getPublicJWK(updateKey)
+	revealValue, err := activeReveal(store, did, updateKey, false)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := &Delta{
+		Patches:          patches,
+		UpdateCommitment: nextUpdateCommitment,
+	}
+	deltaHash, err := computeDeltaHash(delta, canonical.JCS)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(&UpdateSignedData{
+		UpdateKey: updateKey,
+		DeltaHash: deltaHash,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signed data: %w", err)
+	}
+
+	return &PendingOperation{
+		Type:       OperationTypeUpdate,
+		DID:        did,
+		SigningKey: updateKey,
+		Payload:    payload,
+		op: &UpdateOperation{
+			Type:        OperationTypeUpdate,
+			DID:         did,
+			RevealValue: revealValue,
+			Delta:       delta,
+		},
+	}, nil
+}
+
+// PrepareRecover builds a recover installing doc's public keys and services
+//
+// recoveryKey is the public half of the key committed to by the DID's recovery
+// commitment. The next commitments are for the keys of the following update and recover.
+func PrepareRecover(store *storage.Store, did string, recoveryKey *keys.JWK, doc *Document, nextUpdateCommitment, nextRecoveryCommitment string) (*PendingOperation, error) {
+	recoveryKey = getPublicJWK(recoveryKey)
+	revealValue, err := activeReveal(store, did, recoveryKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	patches := []Patch{}
+	if len(doc.PublicKeys) > 0 {
+		patches = append(patches, Patch{
+			Action:     PatchActionAddPublicKeys,
+			PublicKeys: doc.PublicKeys,
+		})
+	}
+	if len(doc.Services) > 0 {
+		patches = append(patches, Patch{
+			Action:   PatchActionAddServices,
+			Services: doc.Services,
+		})
+	}
+
+	delta := &RecoverDelta{
+		Patches:          patches,
+		UpdateCommitment: nextUpdateCommitment,
+	}
+	deltaHash, err := computeDeltaHash(delta, canonical.JCS)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(&RecoverSignedData{
+		RecoveryKey:        recoveryKey,
+		DeltaHash:          deltaHash,
+		RecoveryCommitment: nextRecoveryCommitment,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signed data: %w", err)
+	}
+
+	return &PendingOperation{
+		Type:       OperationTypeRecover,
+		DID:        did,
+		SigningKey: recoveryKey,
+		Payload:    payload,
+		op: &RecoverOperation{
+			Type:        OperationTypeRecover,
+			DID:         did,
+			RevealValue: revealValue,
+			Delta:       delta,
+		},
+	}, nil
+}
+
+// PrepareDeactivate builds a deactivate for an active DID
+//
+// recoveryKey is the public half of the key committed to by the DID's recovery commitment.
+func PrepareDeactivate(store *storage.Store, did string, recoveryKey *keys.JWK) (*PendingOperation, error) {
+	recoveryKey = getPublicJWK(recoveryKey)
+	revealValue, err := activeReveal(store, did, recoveryKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix, err := ParseDID(did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DID: %w", err)
+	}
+	payload, err := json.Marshal(&DeactivateSignedData{
+		RecoveryKey: recoveryKey,
+		DIDSuffix:   suffix,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signed data: %w", err)
+	}
+
+	return &PendingOperation{
+		Type:       OperationTypeDeactivate,
+		DID:        did,
+		SigningKey: recoveryKey,
+		Payload:    payload,
+		op: &DeactivateOperation{
+			Type:        OperationTypeDeactivate,
+			DID:         did,
+			RevealValue: revealValue,
+		},
+	}, nil
+}
+
+// activeReveal checks that did is active and that key opens its update
+// (or, with recovery set, recovery) commitment, returning the reveal value
+func activeReveal(store *storage.Store, did string, key *keys.JWK, recovery bool) (string, error) {
+	didRecord, err := store.GetDID(did)
+	if err != nil {
+		return "", fmt.Errorf("failed to load DID: %w", err)
+	}
+	if didRecord == nil {
+		return "", fmt.Errorf("DID not found: %s", did)
+	}
+	if didRecord.Status != "active" {
+		return "", fmt.Errorf("DID is not active: %s", didRecord.Status)
+	}
+
+	commitment, name := didRecord.UpdateCommitment, "commitment"
+	if recovery {
+		commitment, name = didRecord.RecoveryCommitment, "recovery commitment"
+	}
+
+	// Commitments made before JCS are over the legacy serialization of the key
+	for _, scheme := range []canonical.Scheme{canonical.JCS, canonical.Legacy} {
+		revealValue, err := computeReveal(key, scheme)
+		if err != nil {
+			return "", err
+		}
+		if VerifyReveal(revealValue, commitment) {
+			return revealValue, nil
+		}
+	}
+	return "", fmt.Errorf("reveal value does not match %s", name)
+}
+
+// SigningInput returns the JWS signing input, base64url(header) "." base64url(payload)
+//
+// Signing these bytes with SigningKey and appending "." and the base64url signature
+// gives the compact JWS that Complete expects.
+func (p *PendingOperation) SigningInput() (string, error) {
+	alg, err := p.Algorithm()
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(map[string]string{"alg": string(alg)})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JWS header: %w", err)
+	}
+	return crypto.Base64URLEncode(header) + "." + crypto.Base64URLEncode(p.Payload), nil
+}
+
+// Algorithm returns the JWS algorithm of the signing key
+func (p *PendingOperation) Algorithm() (signing.SignatureAlgorithm, error) {
+	return signing.DetectAlgorithm(keys.JWKToMap(p.SigningKey))
+}
+
+// Complete verifies a compact JWS over Payload by SigningKey and attaches it to the operation
+func (p *PendingOperation) Complete(jws string) error {
+	verifier, err := signing.NewVerifierFromJWK(keys.JWKToMap(p.SigningKey))
+	if err != nil {
+		return fmt.Errorf("failed to create verifier: %w", err)
+	}
+	if err := verifier.Verify(jws, p.Payload); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	switch op := p.op.(type) {
+	case *UpdateOperation:
+		op.SignedData = jws
+	case *RecoverOperation:
+		op.SignedData = jws
+	case *DeactivateOperation:
+		op.SignedData = jws
+	}
+	p.signed = true
+	return nil
+}
+
+// Submit anchors the signed operation on CHAR and applies it, returning its ballot
+func (p *PendingOperation) Submit(ctx context.Context, cfg *config.Config, store *storage.Store, charClient *char.Client) (int, error) {
+	return NewBatcher(cfg, store, charClient).SubmitPending(ctx, p)
+}
+
+// SubmitOperation anchors an operation on CHAR and applies it to the store
+//
+// The operation is voted onto the ballot currently open as a batch of one; once that
+// ballot is decided it is processed like any other, and its number is returned if
+// the operation was applied. Long-running writers share a Batcher instead.
+func SubmitOperation(
+	ctx context.Context,
+	cfg *config.Config,
+	store *storage.Store,
+	charClient *char.Client,
+	opType encoding.OperationType,
+	suffix string,
+	op interface{},
+) (int, error) {
+	return NewBatcher(cfg, store, charClient).SubmitOne(ctx, opType, suffix, op)
+}
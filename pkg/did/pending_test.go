@@ -0,0 +1,211 @@
+package did
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/did-char/pkg/canonical"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+	"github.com/yourusername/did-char/pkg/crypto"
+	"github.com/yourusername/did-char/pkg/encoding"
+	"github.com/yourusername/did-char/pkg/keys"
+)
+
+func TestIntegrationPendingOperationClientSigned(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	// A DID whose update key is held outside the key file
+	updateKey, _ := keys.GenerateEd25519Key()
+	updateJWK := keys.Ed25519PublicKeyToJWK(updateKey.Public().(ed25519.PublicKey), "update")
+	recoveryKey, _ := keys.GenerateEd25519Key()
+	recoveryJWK := keys.Ed25519PublicKeyToJWK(recoveryKey.Public().(ed25519.PublicKey), "recovery")
+
+	updateCommitment, _, _ := GenerateCommitmentFromJWK(updateJWK)
+	recoveryCommitment, _, _ := GenerateCommitmentFromJWK(recoveryJWK)
+	createOp, err := NewCreateOperation(NewDocument(""), updateCommitment, recoveryCommitment)
+	if err != nil {
+		t.Fatalf("NewCreateOperation failed: %v", err)
+	}
+	did := createOp.InitialDocument.ID
+	suffix, _ := ParseDID(did)
+	if _, err := SubmitOperation(context.Background(), env.cfg, env.store, env.client, encoding.OperationTypeCreate, suffix, createOp); err != nil {
+		t.Fatalf("SubmitOperation failed: %v", err)
+	}
+
+	patches := (&UpdateDIDRequest{
+		AddServices: []Service{{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: "https://hub.example.com"}},
+	}).Patches()
+	pending, err := PrepareUpdate(env.store, did, updateJWK, updateCommitment, patches)
+	if err != nil {
+		t.Fatalf("PrepareUpdate failed: %v", err)
+	}
+
+	if _, err := pending.Submit(context.Background(), env.cfg, env.store, env.client); err == nil {
+		t.Fatal("expected unsigned operation to be refused")
+	}
+
+	input, err := pending.SigningInput()
+	if err != nil {
+		t.Fatalf("SigningInput failed: %v", err)
+	}
+	header, _ := crypto.Base64URLDecode(strings.Split(input, ".")[0])
+	if string(header) != `{"alg":"EdDSA"}` {
+		t.Errorf("unexpected JWS header %s", header)
+	}
+
+	forged := input + "." + crypto.Base64URLEncode(ed25519.Sign(recoveryKey, []byte(input)))
+	if err := pending.Complete(forged); err == nil {
+		t.Fatal("expected signature by the wrong key to be rejected")
+	}
+	jws := input + "." + crypto.Base64URLEncode(ed25519.Sign(updateKey, []byte(input)))
+	if err := pending.Complete(jws); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	ballot, err := pending.Submit(context.Background(), env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	record, doc := loadDocument(t, env.store, did)
+	if record.LastOperationBallot != ballot || len(doc.Services) != 1 {
+		t.Errorf("update not applied: ballot %d, services %+v", record.LastOperationBallot, doc.Services)
+	}
+}
+
+func TestPrepareRejectsWrongKey(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	other, _ := keys.GenerateEd25519Key()
+	otherJWK := keys.Ed25519PublicKeyToJWK(other.Public().(ed25519.PublicKey), "other")
+
+	tests := []struct {
+		name    string
+		prepare func() error
+		want    string
+	}{
+		{"update", func() error {
+			_, err := PrepareUpdate(env.store, created.DID, otherJWK, "", nil)
+			return err
+		}, "reveal value does not match commitment"},
+		{"update with recovery key", func() error {
+			_, err := PrepareUpdate(env.store, created.DID, created.KeyFile.RecoveryKey, "", nil)
+			return err
+		}, "reveal value does not match commitment"},
+		{"recover", func() error {
+			_, err := PrepareRecover(env.store, created.DID, otherJWK, NewDocument(created.DID), "", "")
+			return err
+		}, "reveal value does not match recovery commitment"},
+		{"deactivate", func() error {
+			_, err := PrepareDeactivate(env.store, created.DID, created.KeyFile.UpdateKey)
+			return err
+		}, "reveal value does not match recovery commitment"},
+		{"unknown DID", func() error {
+			_, err := PrepareDeactivate(env.store, testRenderDID, otherJWK)
+			return err
+		}, "DID not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.prepare()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCreateOperation(t *testing.T) {
+	doc := NewDocument("")
+	doc.AddPublicKey(PublicKey{ID: "#key-1", Type: "JsonWebKey2020", PublicKeyJwk: &keys.JWK{Kty: "OKP", Crv: "Ed25519", X: "abc"}})
+	doc.AddPublicKey(PublicKey{ID: "#key-2", Type: "JsonWebKey2020", Controller: "did:char:other", PublicKeyJwk: &keys.JWK{Kty: "OKP", Crv: "Ed25519", X: "def"}})
+
+	op, err := NewCreateOperation(doc, "update", "recovery")
+	if err != nil {
+		t.Fatalf("NewCreateOperation failed: %v", err)
+	}
+	if doc.PublicKeys[0].Controller != doc.ID || doc.PublicKeys[1].Controller != "did:char:other" {
+		t.Errorf("unexpected controllers: %+v", doc.PublicKeys)
+	}
+
+	// The suffix must verify the way the processor checks it
+	suffix, _ := ParseDID(doc.ID)
+	computed, err := ComputeCreateSuffix(op, doc.ID)
+	if err != nil || computed != suffix {
+		t.Errorf("suffix %s does not verify (computed %s, %v)", suffix, computed, err)
+	}
+
+	if _, err := NewCreateOperation(doc, "update", "recovery"); err == nil {
+		t.Error("expected a document that already has an ID to be rejected")
+	}
+}
+
+func TestIntegrationUpdateLegacyDID(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	domain := env.cfg.CHAR.AppPreimage
+
+	// A DID created before JCS and multihashes: suffix and commitments are bare
+	// SHA-256 digests of encoding/json output
+	updateKey, _ := keys.GenerateEd25519Key()
+	updateJWK := keys.Ed25519PublicKeyToJWK(updateKey.Public().(ed25519.PublicKey), "update")
+	recoveryKey, _ := keys.GenerateEd25519Key()
+	recoveryJWK := keys.Ed25519PublicKeyToJWK(recoveryKey.Public().(ed25519.PublicKey), "recovery")
+
+	legacyCommitment := func(jwk *keys.JWK) string {
+		jwkBytes, _ := canonical.Legacy.Marshal(jwk)
+		if jcsBytes, _ := canonical.JCS.Marshal(jwk); string(jcsBytes) == string(jwkBytes) {
+			t.Fatal("legacy and JCS serializations should differ")
+		}
+		return crypto.HashToBase64URL(crypto.SHA256(jwkBytes))
+	}
+	createOp := &CreateOperation{
+		Type:               OperationTypeCreate,
+		InitialDocument:    NewDocument(""),
+		UpdateCommitment:   legacyCommitment(updateJWK),
+		RecoveryCommitment: legacyCommitment(recoveryJWK),
+	}
+	createBytes, _ := json.Marshal(createOp)
+	suffix := crypto.HashToBase64URL(createBytes)
+	did := FormatDID(suffix)
+	createOp.InitialDocument.ID = did
+
+	payload, err := encoding.EncodePayload(encoding.OperationTypeCreate, suffix, createOp)
+	if err != nil {
+		t.Fatalf("EncodePayload failed: %v", err)
+	}
+	env.srv.InjectVote(domain, payload, true)
+	env.srv.Advance()
+	if _, err := NewProcessor(env.store, env.client, domain).SyncFromBallot(context.Background(), 0, 0); err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+	loadDocument(t, env.store, did)
+
+	// Its keys still open the legacy commitments in a canonical payload
+	nextCommitment, _, _ := GenerateCommitmentFromJWK(updateJWK)
+	patches := (&UpdateDIDRequest{
+		AddServices: []Service{{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: "https://hub.example.com"}},
+	}).Patches()
+	pending, err := PrepareUpdate(env.store, did, updateJWK, nextCommitment, patches)
+	if err != nil {
+		t.Fatalf("PrepareUpdate failed: %v", err)
+	}
+	input, _ := pending.SigningInput()
+	if err := pending.Complete(input + "." + crypto.Base64URLEncode(ed25519.Sign(updateKey, []byte(input)))); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if _, err := pending.Submit(context.Background(), env.cfg, env.store, env.client); err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	record, doc := loadDocument(t, env.store, did)
+	if len(doc.Services) != 1 || record.UpdateCommitment != nextCommitment {
+		t.Errorf("update not applied: services %+v, update commitment %s", doc.Services, record.UpdateCommitment)
+	}
+}
@@ -1,11 +1,16 @@
 package did
 
 import (
-	"encoding/hex"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/yourusername/did-char/pkg/canonical"
+	"github.com/yourusername/did-char/pkg/cas"
 	"github.com/yourusername/did-char/pkg/char"
 	"github.com/yourusername/did-char/pkg/crypto"
 	"github.com/yourusername/did-char/pkg/encoding"
@@ -19,6 +24,38 @@ type Processor struct {
 	store      *storage.Store
 	charClient *char.Client
 	appDomain  string
+	content    cas.Fetcher     // Where anchored batches are fetched from (nil: nowhere)
+	limits     encoding.Limits // Maximum sizes accepted from payloads
+	undecided  *undecidedProbe // Shared by the copies made by withStore
+}
+
+// undecidedProbe remembers the last ballot seen undecided and when it was asked for
+//
+// Ballots are decided in order, so any ballot at or after it was decided after that
+// time. This bounds ballot times from below for ballots seen decided later.
+type undecidedProbe struct {
+	mu     sync.Mutex
+	ballot int
+	at     time.Time
+}
+
+// observe records that ballot was undecided when a request sent at at was answered
+func (u *undecidedProbe) observe(ballot int, at time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if ballot >= u.ballot {
+		u.ballot, u.at = ballot, at
+	}
+}
+
+// decidedAfter returns a time ballot was decided after, or zero if none is known
+func (u *undecidedProbe) decidedAfter(ballot int) time.Time {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.at.IsZero() || u.ballot > ballot {
+		return time.Time{}
+	}
+	return u.at
 }
 
 // NewProcessor creates a new decision roll processor
@@ -27,90 +64,490 @@ func NewProcessor(store *storage.Store, charClient *char.Client, appDomain strin
 		store:      store,
 		charClient: charClient,
 		appDomain:  appDomain,
+		limits:     encoding.DefaultLimits,
+		undecided:  &undecidedProbe{},
+	}
+}
+
+// SetContentFetcher sets where the operations of anchored batches are fetched from
+//
+// Without one, every anchored batch is recorded as unresolvable.
+func (p *Processor) SetContentFetcher(f cas.Fetcher) {
+	p.content = f
+}
+
+// SetLimits sets the maximum sizes accepted from payloads
+//
+// Payloads exceeding them are rejected. Every node following the same app domain
+// should use the same limits, or they may disagree about which operations apply.
+func (p *Processor) SetLimits(limits encoding.Limits) {
+	p.limits = limits
+}
+
+// withStore returns a copy of p that reads and writes through store
+func (p *Processor) withStore(store *storage.Store) *Processor {
+	return &Processor{store: store, charClient: p.charClient, appDomain: p.appDomain, content: p.content, limits: p.limits, undecided: p.undecided}
+}
+
+// BallotOutcome describes what ProcessBallot found on a ballot
+type BallotOutcome int
+
+const (
+	BallotUndecided    BallotOutcome = iota // Not decided yet; nothing may be recorded for it
+	BallotEmpty                             // Decided without a payload
+	BallotApplied                           // Decided with at least one DID operation that was applied
+	BallotRejected                          // Decided with a payload none of whose operations were applied
+	BallotUnresolvable                      // Decided with an anchored batch whose content could not be fetched yet
+)
+
+// String returns the outcome name
+func (o BallotOutcome) String() string {
+	switch o {
+	case BallotUndecided:
+		return "undecided"
+	case BallotEmpty:
+		return "empty"
+	case BallotApplied:
+		return "applied"
+	case BallotRejected:
+		return "rejected"
+	case BallotUnresolvable:
+		return "unresolvable"
+	default:
+		return fmt.Sprintf("BallotOutcome(%d)", int(o))
 	}
 }
 
 // ProcessBallot fetches and processes a single ballot
-func (p *Processor) ProcessBallot(ballotNumber int) error {
+//
+// All state changes for the ballot are committed in one transaction, and a ballot
+// whose operation is already recorded is not applied again.
+// The outcome is only meaningful when err is nil.
+func (p *Processor) ProcessBallot(ctx context.Context, ballotNumber int) (BallotOutcome, error) {
+	return p.processBallot(ctx, ballotNumber, false)
+}
+
+// processBallot fetches a ballot and applies it in a single transaction,
+// advancing last_synced_ballot in the same transaction when advanceCursor is set
+func (p *Processor) processBallot(ctx context.Context, ballotNumber int, advanceCursor bool) (BallotOutcome, error) {
 	// Query decision roll
-	roll, err := p.charClient.GetReferendumDecisionRoll(p.appDomain, ballotNumber, 1)
+	askedAt := time.Now()
+	roll, err := p.charClient.GetReferendumDecisionRoll(ctx, p.appDomain, ballotNumber, 1)
 	if err != nil {
-		return fmt.Errorf("failed to get decision roll: %w", err)
+		return BallotUndecided, fmt.Errorf("failed to get decision roll: %w", err)
 	}
 
 	// If ballot not found, it hasn't been decided yet
 	if !roll.Found {
+		p.undecided.observe(ballotNumber, askedAt)
+		return BallotUndecided, nil
+	}
+	observedAt := time.Now()
+	decidedAfter := p.undecided.decidedAfter(ballotNumber)
+
+	// Anchored content is fetched before the transaction, so the write lock is not held during I/O
+	var fetched *fetchedBatch
+	if payloadHex, ok, err := p.decidedPayload(roll); ok && err == nil {
+		fetched, err = p.fetchAnchored(ctx, payloadHex)
+		if err != nil {
+			return BallotUndecided, err
+		}
+	}
+
+	var outcome BallotOutcome
+	err = p.store.InTx(func(tx *storage.Store) error {
+		txp := p.withStore(tx)
+
+		var err error
+		outcome, err = txp.applyDecisionRoll(roll, ballotNumber, fetched)
+		if err != nil {
+			return err
+		}
+
+		// CHAR does not report when a ballot was decided; it lies between the last time
+		// this node saw it undecided, if it did, and the first time it saw it decided
+		if outcome != BallotEmpty {
+			if err := tx.RecordBallotTime(ballotNumber, decidedAfter, observedAt); err != nil {
+				return fmt.Errorf("failed to record ballot time: %w", err)
+			}
+		}
+
+		// A batch that cannot be fetched yet holds the cursor, so later ballots are never
+		// applied before it
+		if advanceCursor && outcome != BallotUnresolvable {
+			// The follower and a writer catching up to its own ballot may sync concurrently,
+			// and a sync may start anywhere; the cursor only moves forward one ballot at a
+			// time, so it never skips a ballot that was not applied
+			next, err := txp.NextUnsyncedBallot()
+			if err != nil {
+				return err
+			}
+			if ballotNumber == next {
+				if err := tx.SetSyncState(syncStateLastSynced, fmt.Sprintf("%d", ballotNumber)); err != nil {
+					return fmt.Errorf("failed to update sync state: %w", err)
+				}
+			}
+		}
 		return nil
+	})
+	if err != nil {
+		return BallotRejected, err
 	}
 
-	// Decode payload
+	return outcome, nil
+}
+
+// decidedPayload returns a decided ballot's payload with CHAR wrappers stripped,
+// or false if the ballot was decided empty
+//
+// A malformed wrapper or a payload over the size limits is returned as an error with
+// ok set, as the ballot is not empty; the payload is then the data as decided if the
+// wrapper could not be stripped.
+func (p *Processor) decidedPayload(roll *char.DecisionRollResponse) (string, bool, error) {
 	if roll.DecisionRoll == nil || roll.DecisionRoll.Data == "" {
+		return "", false, nil
+	}
+
+	// Skip empty/null ballots (length <= 8 hex chars = 4 bytes)
+	if len(roll.DecisionRoll.Data) <= 8 {
+		return "", false, nil
+	}
+
+	payloadHex, err := char.StripWrappers(roll.DecisionRoll.Data)
+	if err != nil {
+		return roll.DecisionRoll.Data, true, err
+	}
+	// Anchors are not decoded through the limits, so every payload is checked here
+	return payloadHex, true, p.limits.CheckPayload(payloadHex)
+}
+
+// applyDecisionRoll decodes a decided ballot and applies its operations to the store
+//
+// fetched holds the content of an anchor payload, if the ballot carries one.
+func (p *Processor) applyDecisionRoll(roll *char.DecisionRollResponse, ballotNumber int, fetched *fetchedBatch) (BallotOutcome, error) {
+	payloadHex, ok, payloadErr := p.decidedPayload(roll)
+	if !ok {
 		// Empty ballot, skip
-		return nil
+		return BallotEmpty, nil
 	}
 
-	payloadHex := roll.DecisionRoll.Data
+	// Replaying a ballot that was already applied or rejected is a no-op
+	applied, err := p.store.OperationExistsAtBallot(ballotNumber)
+	if err != nil {
+		return BallotRejected, fmt.Errorf("failed to check operation for ballot: %w", err)
+	}
+	if applied {
+		return BallotApplied, nil
+	}
+	rejected, err := p.store.RejectedOperationExistsAtBallot(ballotNumber)
+	if err != nil {
+		return BallotRejected, fmt.Errorf("failed to check rejected operation for ballot: %w", err)
+	}
+	if rejected {
+		return BallotRejected, nil
+	}
 
-	// Skip empty/null ballots (length <= 8 hex chars = 4 bytes)
-	if len(payloadHex) <= 8 {
-		return nil
+	if payloadErr != nil {
+		return p.recordRejection(&storage.RejectedOperationRecord{
+			BallotNumber: ballotNumber,
+			Payload:      payloadHex,
+		}, decodeRejection("payload", payloadErr))
 	}
 
-	payloadHex = stripWrappers(payloadHex)
+	if version, err := encoding.PayloadVersionOf(payloadHex); err == nil && version == encoding.PayloadVersionAnchor {
+		outcome, err := p.applyAnchor(payloadHex, ballotNumber, fetched)
+		if err != nil || outcome == BallotUnresolvable {
+			return outcome, err
+		}
+		// Forget earlier failed attempts to fetch the batch
+		if err := p.store.DeleteUnresolvableBatch(ballotNumber); err != nil {
+			return BallotRejected, fmt.Errorf("failed to delete unresolvable batch: %w", err)
+		}
+		return outcome, nil
+	}
 
-	version, opType, didSuffix, operationJSON, err := encoding.DecodePayload(payloadHex)
+	version, ops, err := p.limits.DecodeOperations(payloadHex)
 	if err != nil {
-		log.Printf("Failed to decode payload for ballot %d: %v", ballotNumber, err)
-		// Invalid or empty payload, skip this ballot
-		return nil
+		// Invalid payload (likely non-DID data)
+		return p.recordRejection(&storage.RejectedOperationRecord{
+			BallotNumber: ballotNumber,
+			Payload:      payloadHex,
+		}, decodeRejection("payload", err))
 	}
 
-	if version != encoding.PayloadVersion {
-		fmt.Printf("Unsupported payload version %d for ballot %d\n", version, ballotNumber)
-		// Unsupported version, skip this ballot (likely non-DID data)
-		return nil
+	return p.applyBatch(ops, encoding.SchemeOf(version), ballotNumber, payloadHex)
+}
+
+// applyBatch applies the operations decided on a ballot
+//
+// Operations are applied in payload order, their hashes checked under scheme. The
+// ballot counts as applied if any of its operations was; each rejected operation
+// is recorded with its reason.
+func (p *Processor) applyBatch(ops []encoding.Operation, scheme canonical.Scheme, ballotNumber int, payloadHex string) (BallotOutcome, error) {
+	outcome := BallotRejected
+	seen := make(map[string]bool)
+	for index, op := range ops {
+		did := FormatDID(op.DIDSuffix)
+		rejection := &storage.RejectedOperationRecord{
+			BallotNumber:   ballotNumber,
+			OperationIndex: index,
+			DID:            did,
+			OperationType:  operationTypeName(op.Type),
+			Payload:        payloadHex,
+		}
+
+		// A batch may change each DID at most once
+		if seen[op.DIDSuffix] {
+			if _, err := p.recordRejection(rejection, "duplicate operation for DID in batch"); err != nil {
+				return BallotRejected, err
+			}
+			continue
+		}
+		seen[op.DIDSuffix] = true
+
+		// Ballots are applied in order, but a sync --from an earlier ballot must not
+		// rewind a DID changed on a later one
+		record, err := p.store.GetDID(did)
+		if err != nil {
+			return BallotRejected, fmt.Errorf("failed to load DID: %w", err)
+		}
+		if record != nil && record.LastOperationBallot > ballotNumber {
+			if _, err := p.recordRejection(rejection, fmt.Sprintf("superseded by operation on ballot %d", record.LastOperationBallot)); err != nil {
+				return BallotRejected, err
+			}
+			continue
+		}
+
+		opOutcome, err := p.applyOperation(op, scheme, ballotNumber, index)
+		var rejectErr *rejectionError
+		if errors.As(err, &rejectErr) {
+			opOutcome, err = p.recordRejection(rejection, rejectErr.reason)
+		}
+		if err != nil {
+			return BallotRejected, err
+		}
+		if opOutcome == BallotApplied {
+			outcome = BallotApplied
+		}
+	}
+
+	return outcome, nil
+}
+
+// fetchedBatch is the result of fetching the content an anchor payload refers to
+type fetchedBatch struct {
+	data []byte // nil if the content could not be fetched
+	err  error  // Why the content could not be fetched
+}
+
+// fetchAnchored fetches the batch an anchor payload refers to, or returns nil for other payloads
+//
+// Failing to fetch is not an error: the batch is recorded as unresolvable when the
+// ballot is applied. Only cancellation of ctx is returned.
+func (p *Processor) fetchAnchored(ctx context.Context, payloadHex string) (*fetchedBatch, error) {
+	version, err := encoding.PayloadVersionOf(payloadHex)
+	if err != nil || version != encoding.PayloadVersionAnchor {
+		return nil, nil
+	}
+	anchor, err := encoding.DecodeAnchorPayload(payloadHex)
+	if err != nil {
+		// Rejected when the ballot is applied
+		return nil, nil
+	}
+	return p.fetchContent(ctx, anchor.ContentHash)
+}
+
+// fetchContent fetches content by hash from the processor's content fetcher
+func (p *Processor) fetchContent(ctx context.Context, hash string) (*fetchedBatch, error) {
+	if p.content == nil {
+		return &fetchedBatch{err: errors.New("no content store configured")}, nil
+	}
+
+	data, err := p.content.Get(ctx, hash)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return &fetchedBatch{data: data, err: err}, nil
+}
+
+// applyAnchor applies the batch an anchor payload refers to, or records it as unresolvable
+func (p *Processor) applyAnchor(payloadHex string, ballotNumber int, fetched *fetchedBatch) (BallotOutcome, error) {
+	anchor, err := encoding.DecodeAnchorPayload(payloadHex)
+	if err != nil {
+		return p.recordRejection(&storage.RejectedOperationRecord{
+			BallotNumber: ballotNumber,
+			Payload:      payloadHex,
+		}, fmt.Sprintf("failed to decode payload: %v", err))
+	}
+
+	if fetched == nil || fetched.data == nil {
+		reason := "content not fetched"
+		if fetched != nil && fetched.err != nil {
+			reason = fetched.err.Error()
+		}
+		return p.recordUnresolvable(&storage.UnresolvableBatchRecord{
+			BallotNumber:   ballotNumber,
+			ContentHash:    anchor.ContentHash,
+			OperationCount: anchor.OperationCount,
+			Payload:        payloadHex,
+			Reason:         reason,
+		})
+	}
+
+	// Content is immutable, so a batch that does not match its anchor never will
+	rejection := &storage.RejectedOperationRecord{
+		BallotNumber: ballotNumber,
+		Payload:      payloadHex,
+	}
+	if err := cas.Verify(anchor.ContentHash, fetched.data); err != nil {
+		return p.recordRejection(rejection, err.Error())
+	}
+	ops, err := p.limits.DecodeBatch(fetched.data)
+	if err != nil {
+		return p.recordRejection(rejection, decodeRejection("anchored batch", err))
+	}
+	if len(ops) != anchor.OperationCount {
+		return p.recordRejection(rejection, fmt.Sprintf("anchored batch has %d operations, anchor claims %d", len(ops), anchor.OperationCount))
+	}
+
+	return p.applyBatch(ops, encoding.SchemeOf(fetched.data[0]), ballotNumber, payloadHex)
+}
+
+// decodeRejection returns the rejection reason for a payload that failed to decode
+func decodeRejection(what string, err error) string {
+	var limitErr *encoding.LimitError
+	if errors.As(err, &limitErr) {
+		return fmt.Sprintf("%s exceeds size limits: %v", what, err)
 	}
+	return fmt.Sprintf("failed to decode %s: %v", what, err)
+}
+
+// recordUnresolvable stores an anchored batch whose content could not be fetched
+func (p *Processor) recordUnresolvable(record *storage.UnresolvableBatchRecord) (BallotOutcome, error) {
+	log.Printf("Batch on ballot %d is unresolvable: %s", record.BallotNumber, record.Reason)
+
+	if err := p.store.SaveUnresolvableBatch(record); err != nil {
+		return BallotRejected, fmt.Errorf("failed to save unresolvable batch: %w", err)
+	}
+	return BallotUnresolvable, nil
+}
 
-	did := FormatDID(didSuffix)
-	fmt.Printf("Processing DID %s operation type %d on ballot %d\n", did, opType, ballotNumber)
+// applyOperation applies one decoded operation of a ballot, checking its hashes under scheme
+func (p *Processor) applyOperation(op encoding.Operation, scheme canonical.Scheme, ballotNumber, index int) (BallotOutcome, error) {
+	did := FormatDID(op.DIDSuffix)
+	fmt.Printf("Processing DID %s operation type %d on ballot %d\n", did, op.Type, ballotNumber)
 
 	// Process based on operation type
+	switch op.Type {
+	case encoding.OperationTypeCreate:
+		return p.processCreate(did, op.Data, scheme, ballotNumber, index)
+	case encoding.OperationTypeUpdate:
+		return p.processUpdate(did, op.Data, scheme, ballotNumber, index)
+	case encoding.OperationTypeRecover:
+		return p.processRecover(did, op.Data, scheme, ballotNumber, index)
+	case encoding.OperationTypeDeactivate:
+		return p.processDeactivate(did, op.Data, scheme, ballotNumber, index)
+	default:
+		return BallotRejected, reject("unknown operation type %d", op.Type)
+	}
+}
+
+// rejectionError marks an operation that is invalid under the protocol rules.
+// Invalid operations are recorded and ignored; any other error aborts the ballot.
+type rejectionError struct {
+	reason string
+}
+
+func (e *rejectionError) Error() string {
+	return "operation rejected: " + e.reason
+}
+
+// reject returns a rejectionError with a formatted reason
+func reject(format string, args ...interface{}) error {
+	return &rejectionError{reason: fmt.Sprintf(format, args...)}
+}
+
+// recordRejection stores a rejected operation with its reason
+func (p *Processor) recordRejection(record *storage.RejectedOperationRecord, reason string) (BallotOutcome, error) {
+	log.Printf("Rejected operation %d on ballot %d: %s", record.OperationIndex, record.BallotNumber, reason)
+
+	record.Reason = reason
+	if err := p.store.SaveRejectedOperation(record); err != nil {
+		return BallotRejected, fmt.Errorf("failed to save rejected operation: %w", err)
+	}
+	return BallotRejected, nil
+}
+
+// saveVersion records the state an operation left a DID in, for historical resolution
+func (p *Processor) saveVersion(record *storage.DIDRecord, operationType string) error {
+	if err := p.store.SaveDocumentVersion(&storage.DocumentVersionRecord{
+		DID:           record.DID,
+		BallotNumber:  record.LastOperationBallot,
+		OperationType: operationType,
+		Status:        record.Status,
+		Document:      record.Document,
+	}); err != nil {
+		return fmt.Errorf("failed to save document version: %w", err)
+	}
+	return nil
+}
+
+// operationTypeName returns the stored name for a payload operation type
+func operationTypeName(opType encoding.OperationType) string {
 	switch opType {
 	case encoding.OperationTypeCreate:
-		return p.processCreate(did, operationJSON, ballotNumber)
+		return OperationTypeCreate
 	case encoding.OperationTypeUpdate:
-		return p.processUpdate(did, operationJSON, ballotNumber)
+		return OperationTypeUpdate
 	case encoding.OperationTypeRecover:
-		return p.processRecover(did, operationJSON, ballotNumber)
+		return OperationTypeRecover
 	case encoding.OperationTypeDeactivate:
-		return p.processDeactivate(did, operationJSON, ballotNumber)
+		return OperationTypeDeactivate
 	default:
-		fmt.Printf("Unknown operation type %d for ballot %d\n", opType, ballotNumber)
-		return fmt.Errorf("unknown operation type: %d", opType)
+		return fmt.Sprintf("0x%02x", byte(opType))
 	}
 }
 
 // processCreate handles CREATE operations
-func (p *Processor) processCreate(did string, operationJSON []byte, ballotNumber int) error {
+func (p *Processor) processCreate(did string, operationJSON []byte, scheme canonical.Scheme, ballotNumber, index int) (BallotOutcome, error) {
 	var op CreateOperation
 	if err := json.Unmarshal(operationJSON, &op); err != nil {
-		return fmt.Errorf("failed to unmarshal CREATE operation: %w", err)
+		return BallotRejected, reject("failed to unmarshal CREATE operation: %v", err)
+	}
+
+	// The DID must be self-certifying: its suffix is the hash of the create data
+	if op.InitialDocument == nil {
+		return BallotRejected, reject("create operation has no initial document")
+	}
+	if op.InitialDocument.ID != did {
+		return BallotRejected, reject("initial document ID %q does not match DID", op.InitialDocument.ID)
+	}
+	suffix, err := ParseDID(did)
+	if err != nil {
+		return BallotRejected, reject("invalid DID: %v", err)
+	}
+	matches, computed, err := createSuffixMatches(operationJSON, did, suffix, scheme)
+	if err != nil {
+		return BallotRejected, reject("failed to compute DID suffix: %v", err)
+	}
+	if !matches {
+		return BallotRejected, reject("DID suffix does not match create operation (computed %s)", computed)
 	}
 
 	// Check if DID already exists
 	exists, err := p.store.DIDExists(did)
 	if err != nil {
-		return fmt.Errorf("failed to check DID existence: %w", err)
+		return BallotRejected, fmt.Errorf("failed to check DID existence: %w", err)
 	}
 	if exists {
 		// DID already created, skip
-		return nil
+		return BallotRejected, reject("DID already exists")
 	}
 
 	// Save DID to database
 	docJSON, err := json.Marshal(op.InitialDocument)
 	if err != nil {
-		return fmt.Errorf("failed to marshal document: %w", err)
+		return BallotRejected, fmt.Errorf("failed to marshal document: %w", err)
 	}
 	didRecord := &storage.DIDRecord{
 		DID:                 did,
@@ -123,80 +560,118 @@ func (p *Processor) processCreate(did string, operationJSON []byte, ballotNumber
 	}
 
 	if err := p.store.SaveDID(didRecord); err != nil {
-		return fmt.Errorf("failed to save DID: %w", err)
+		return BallotRejected, fmt.Errorf("failed to save DID: %w", err)
+	}
+	if err := p.saveVersion(didRecord, "create"); err != nil {
+		return BallotRejected, err
 	}
 
 	// Save operation
 	opRecord := &storage.OperationRecord{
-		DID:           did,
-		BallotNumber:  ballotNumber,
-		OperationType: "create",
-		OperationData: string(operationJSON),
+		DID:            did,
+		BallotNumber:   ballotNumber,
+		OperationIndex: index,
+		OperationType:  "create",
+		OperationData:  string(operationJSON),
 	}
 
 	if err := p.store.SaveOperation(opRecord); err != nil {
-		return fmt.Errorf("failed to save operation: %w", err)
+		return BallotRejected, fmt.Errorf("failed to save operation: %w", err)
 	}
 
+	return BallotApplied, nil
+}
+
+// signedDeltaOperation is an update or recover operation as anchored, its delta
+// kept as the bytes whose hash was signed
+type signedDeltaOperation struct {
+	RevealValue string          `json:"revealValue"`
+	SignedData  string          `json:"signedData"`
+	Delta       json.RawMessage `json:"delta"`
+}
+
+// hasNoDelta reports whether the operation is missing its delta
+func (op *signedDeltaOperation) hasNoDelta() bool {
+	return len(op.Delta) == 0 || string(op.Delta) == "null"
+}
+
+// verifyDelta checks raw against the signed delta hash and only then parses it into delta
+func verifyDelta(raw json.RawMessage, signedHash string, scheme canonical.Scheme, delta interface{}) error {
+	// The hash is over the bytes themselves, canonicalized under scheme, so fields
+	// this node does not know about and the sender's member order and number
+	// formatting are covered exactly as the signer hashed them
+	deltaBytes, err := scheme.Transform(raw)
+	if err != nil {
+		return fmt.Errorf("invalid delta: %w", err)
+	}
+	if !crypto.HashMatches(signedHash, deltaBytes) {
+		return fmt.Errorf("delta hash mismatch: signed %s, actual %s", signedHash, crypto.HashToMultihash(deltaBytes))
+	}
+	if err := json.Unmarshal(raw, delta); err != nil {
+		return fmt.Errorf("failed to unmarshal delta: %w", err)
+	}
 	return nil
 }
 
 // processUpdate handles UPDATE operations with signature verification
-func (p *Processor) processUpdate(did string, operationJSON []byte, ballotNumber int) error {
-	var op UpdateOperation
+func (p *Processor) processUpdate(did string, operationJSON []byte, scheme canonical.Scheme, ballotNumber, index int) (BallotOutcome, error) {
+	var op signedDeltaOperation
 	if err := json.Unmarshal(operationJSON, &op); err != nil {
-		return fmt.Errorf("failed to unmarshal UPDATE operation: %w", err)
+		return BallotRejected, reject("failed to unmarshal UPDATE operation: %v", err)
 	}
 
 	// Load current DID state
 	didRecord, err := p.store.GetDID(did)
 	if err != nil {
-		return fmt.Errorf("failed to load DID: %w", err)
+		return BallotRejected, fmt.Errorf("failed to load DID: %w", err)
 	}
 	if didRecord == nil {
 		// DID doesn't exist, skip
-		return nil
+		return BallotRejected, reject("DID not found")
 	}
 	if didRecord.Status != "active" {
 		// DID is not active, skip
-		return nil
+		return BallotRejected, reject("DID is %s", didRecord.Status)
 	}
 
 	// Verify reveal matches commitment
 	if !VerifyReveal(op.RevealValue, didRecord.UpdateCommitment) {
-		return fmt.Errorf("reveal value does not match commitment")
+		return BallotRejected, reject("reveal value does not match commitment")
 	}
 
 	// Verify the JWS signature and extract signed data
 	signedData, err := verifyUpdateSignature(op.SignedData)
 	if err != nil {
-		return fmt.Errorf("signature verification failed: %w", err)
+		return BallotRejected, reject("signature verification failed: %v", err)
 	}
 
-	// Verify that the update key in signed data matches the reveal value
-	if err := VerifyKeyMatchesReveal(signedData.UpdateKey, op.RevealValue); err != nil {
-		return fmt.Errorf("update key does not match reveal: %w", err)
+	// Verify that the update key in signed data, as signed, matches the reveal value
+	updateKeyJSON, err := signedKeyJSON(op.SignedData, "updateKey")
+	if err != nil {
+		return BallotRejected, reject("%v", err)
+	}
+	if err := verifyKeyMatchesReveal(updateKeyJSON, op.RevealValue, scheme); err != nil {
+		return BallotRejected, reject("update key does not match reveal: %v", err)
 	}
 
-	// Verify delta hash matches the actual delta
-	deltaJSON, err := json.Marshal(op.Delta)
-	if err != nil {
-		return fmt.Errorf("failed to marshal delta: %w", err)
+	// Verify delta hash matches the delta as received, then parse it
+	if op.hasNoDelta() {
+		return BallotRejected, reject("update operation has no delta")
 	}
-	actualDeltaHash := crypto.HashToBase64URL(deltaJSON)
-	if actualDeltaHash != signedData.DeltaHash {
-		return fmt.Errorf("delta hash mismatch: signed %s, actual %s", signedData.DeltaHash, actualDeltaHash)
+	var delta Delta
+	if err := verifyDelta(op.Delta, signedData.DeltaHash, scheme, &delta); err != nil {
+		return BallotRejected, reject("%v", err)
 	}
 
 	// Parse current document
 	var currentDoc Document
 	if err := json.Unmarshal([]byte(didRecord.Document), &currentDoc); err != nil {
-		return fmt.Errorf("failed to parse DID document: %w", err)
+		return BallotRejected, fmt.Errorf("failed to parse DID document: %w", err)
 	}
 
 	// Apply patches
 	updatedDoc := currentDoc
-	for _, patch := range op.Delta.Patches {
+	for _, patch := range delta.Patches {
 		switch patch.Action {
 		case PatchActionAddPublicKeys:
 			for _, pk := range patch.PublicKeys {
@@ -220,79 +695,86 @@ func (p *Processor) processUpdate(did string, operationJSON []byte, ballotNumber
 	// Update database
 	docJSON, err := json.Marshal(updatedDoc)
 	if err != nil {
-		return fmt.Errorf("failed to marshal updated document: %w", err)
+		return BallotRejected, fmt.Errorf("failed to marshal updated document: %w", err)
 	}
 	didRecord.Document = string(docJSON)
-	didRecord.UpdateCommitment = op.Delta.UpdateCommitment
+	didRecord.UpdateCommitment = delta.UpdateCommitment
 	didRecord.LastOperationBallot = ballotNumber
 
 	if err := p.store.SaveDID(didRecord); err != nil {
-		return fmt.Errorf("failed to update DID: %w", err)
+		return BallotRejected, fmt.Errorf("failed to update DID: %w", err)
+	}
+	if err := p.saveVersion(didRecord, "update"); err != nil {
+		return BallotRejected, err
 	}
 
 	// Save operation
 	opRecord := &storage.OperationRecord{
-		DID:           did,
-		BallotNumber:  ballotNumber,
-		OperationType: "update",
-		OperationData: string(operationJSON),
+		DID:            did,
+		BallotNumber:   ballotNumber,
+		OperationIndex: index,
+		OperationType:  "update",
+		OperationData:  string(operationJSON),
 	}
 
 	if err := p.store.SaveOperation(opRecord); err != nil {
-		return fmt.Errorf("failed to save operation: %w", err)
+		return BallotRejected, fmt.Errorf("failed to save operation: %w", err)
 	}
 
-	return nil
+	return BallotApplied, nil
 }
 
 // processRecover handles RECOVER operations with signature verification
-func (p *Processor) processRecover(did string, operationJSON []byte, ballotNumber int) error {
-	var op RecoverOperation
+func (p *Processor) processRecover(did string, operationJSON []byte, scheme canonical.Scheme, ballotNumber, index int) (BallotOutcome, error) {
+	var op signedDeltaOperation
 	if err := json.Unmarshal(operationJSON, &op); err != nil {
-		return fmt.Errorf("failed to unmarshal RECOVER operation: %w", err)
+		return BallotRejected, reject("failed to unmarshal RECOVER operation: %v", err)
 	}
 
 	// Load current DID state
 	didRecord, err := p.store.GetDID(did)
 	if err != nil {
-		return fmt.Errorf("failed to load DID: %w", err)
+		return BallotRejected, fmt.Errorf("failed to load DID: %w", err)
 	}
 	if didRecord == nil {
-		return nil
+		return BallotRejected, reject("DID not found")
 	}
 	if didRecord.Status != "active" {
-		return nil
+		return BallotRejected, reject("DID is %s", didRecord.Status)
 	}
 
 	// Verify reveal matches recovery commitment
 	if !VerifyReveal(op.RevealValue, didRecord.RecoveryCommitment) {
-		return fmt.Errorf("reveal value does not match recovery commitment")
+		return BallotRejected, reject("reveal value does not match recovery commitment")
 	}
 
 	// Verify the JWS signature and extract signed data
 	signedData, err := verifyRecoverSignature(op.SignedData)
 	if err != nil {
-		return fmt.Errorf("signature verification failed: %w", err)
+		return BallotRejected, reject("signature verification failed: %v", err)
 	}
 
-	// Verify that the recovery key in signed data matches the reveal value
-	if err := VerifyKeyMatchesReveal(signedData.RecoveryKey, op.RevealValue); err != nil {
-		return fmt.Errorf("recovery key does not match reveal: %w", err)
+	// Verify that the recovery key in signed data, as signed, matches the reveal value
+	recoveryKeyJSON, err := signedKeyJSON(op.SignedData, "recoveryKey")
+	if err != nil {
+		return BallotRejected, reject("%v", err)
+	}
+	if err := verifyKeyMatchesReveal(recoveryKeyJSON, op.RevealValue, scheme); err != nil {
+		return BallotRejected, reject("recovery key does not match reveal: %v", err)
 	}
 
-	// Verify delta hash matches the actual delta
-	deltaJSON, err := json.Marshal(op.Delta)
-	if err != nil {
-		return fmt.Errorf("failed to marshal delta: %w", err)
+	// Verify delta hash matches the delta as received, then parse it
+	if op.hasNoDelta() {
+		return BallotRejected, reject("recover operation has no delta")
 	}
-	actualDeltaHash := crypto.HashToBase64URL(deltaJSON)
-	if actualDeltaHash != signedData.DeltaHash {
-		return fmt.Errorf("delta hash mismatch: signed %s, actual %s", signedData.DeltaHash, actualDeltaHash)
+	var delta RecoverDelta
+	if err := verifyDelta(op.Delta, signedData.DeltaHash, scheme, &delta); err != nil {
+		return BallotRejected, reject("%v", err)
 	}
 
 	// Build new document from patches
 	newDoc := NewDocument(did)
-	for _, patch := range op.Delta.Patches {
+	for _, patch := range delta.Patches {
 		switch patch.Action {
 		case PatchActionAddPublicKeys:
 			for _, pk := range patch.PublicKeys {
@@ -308,74 +790,82 @@ func (p *Processor) processRecover(did string, operationJSON []byte, ballotNumbe
 	// Update database
 	docJSON, err := json.Marshal(newDoc)
 	if err != nil {
-		return fmt.Errorf("failed to marshal new document: %w", err)
+		return BallotRejected, fmt.Errorf("failed to marshal new document: %w", err)
 	}
 	didRecord.Document = string(docJSON)
-	didRecord.UpdateCommitment = op.Delta.UpdateCommitment
+	didRecord.UpdateCommitment = delta.UpdateCommitment
 	didRecord.RecoveryCommitment = signedData.RecoveryCommitment
 	didRecord.LastOperationBallot = ballotNumber
 
 	if err := p.store.SaveDID(didRecord); err != nil {
-		return fmt.Errorf("failed to update DID: %w", err)
+		return BallotRejected, fmt.Errorf("failed to update DID: %w", err)
+	}
+	if err := p.saveVersion(didRecord, "recover"); err != nil {
+		return BallotRejected, err
 	}
 
 	// Save operation
 	opRecord := &storage.OperationRecord{
-		DID:           did,
-		BallotNumber:  ballotNumber,
-		OperationType: "recover",
-		OperationData: string(operationJSON),
+		DID:            did,
+		BallotNumber:   ballotNumber,
+		OperationIndex: index,
+		OperationType:  "recover",
+		OperationData:  string(operationJSON),
 	}
 
 	if err := p.store.SaveOperation(opRecord); err != nil {
-		return fmt.Errorf("failed to save operation: %w", err)
+		return BallotRejected, fmt.Errorf("failed to save operation: %w", err)
 	}
 
-	return nil
+	return BallotApplied, nil
 }
 
 // processDeactivate handles DEACTIVATE operations with signature verification
-func (p *Processor) processDeactivate(did string, operationJSON []byte, ballotNumber int) error {
+func (p *Processor) processDeactivate(did string, operationJSON []byte, scheme canonical.Scheme, ballotNumber, index int) (BallotOutcome, error) {
 	var op DeactivateOperation
 	if err := json.Unmarshal(operationJSON, &op); err != nil {
-		return fmt.Errorf("failed to unmarshal DEACTIVATE operation: %w", err)
+		return BallotRejected, reject("failed to unmarshal DEACTIVATE operation: %v", err)
 	}
 
 	// Load current DID state
 	didRecord, err := p.store.GetDID(did)
 	if err != nil {
-		return fmt.Errorf("failed to load DID: %w", err)
+		return BallotRejected, fmt.Errorf("failed to load DID: %w", err)
 	}
 	if didRecord == nil {
-		return nil
+		return BallotRejected, reject("DID not found")
 	}
 	if didRecord.Status != "active" {
-		return nil
+		return BallotRejected, reject("DID is %s", didRecord.Status)
 	}
 
 	// Verify reveal matches recovery commitment
 	if !VerifyReveal(op.RevealValue, didRecord.RecoveryCommitment) {
-		return fmt.Errorf("reveal value does not match recovery commitment")
+		return BallotRejected, reject("reveal value does not match recovery commitment")
 	}
 
 	// Verify the JWS signature and extract signed data
 	signedData, err := verifyDeactivateSignature(op.SignedData)
 	if err != nil {
-		return fmt.Errorf("signature verification failed: %w", err)
+		return BallotRejected, reject("signature verification failed: %v", err)
 	}
 
-	// Verify that the recovery key in signed data matches the reveal value
-	if err := VerifyKeyMatchesReveal(signedData.RecoveryKey, op.RevealValue); err != nil {
-		return fmt.Errorf("recovery key does not match reveal: %w", err)
+	// Verify that the recovery key in signed data, as signed, matches the reveal value
+	recoveryKeyJSON, err := signedKeyJSON(op.SignedData, "recoveryKey")
+	if err != nil {
+		return BallotRejected, reject("%v", err)
+	}
+	if err := verifyKeyMatchesReveal(recoveryKeyJSON, op.RevealValue, scheme); err != nil {
+		return BallotRejected, reject("recovery key does not match reveal: %v", err)
 	}
 
 	// Verify the DID suffix matches
 	suffix, err := ParseDID(did)
 	if err != nil {
-		return fmt.Errorf("failed to parse DID: %w", err)
+		return BallotRejected, reject("invalid DID: %v", err)
 	}
 	if signedData.DIDSuffix != did && signedData.DIDSuffix != suffix {
-		return fmt.Errorf("DID suffix mismatch in signed data")
+		return BallotRejected, reject("DID suffix mismatch in signed data")
 	}
 
 	// Deactivate
@@ -383,22 +873,26 @@ func (p *Processor) processDeactivate(did string, operationJSON []byte, ballotNu
 	didRecord.LastOperationBallot = ballotNumber
 
 	if err := p.store.SaveDID(didRecord); err != nil {
-		return fmt.Errorf("failed to update DID: %w", err)
+		return BallotRejected, fmt.Errorf("failed to update DID: %w", err)
+	}
+	if err := p.saveVersion(didRecord, "deactivate"); err != nil {
+		return BallotRejected, err
 	}
 
 	// Save operation
 	opRecord := &storage.OperationRecord{
-		DID:           did,
-		BallotNumber:  ballotNumber,
-		OperationType: "deactivate",
-		OperationData: string(operationJSON),
+		DID:            did,
+		BallotNumber:   ballotNumber,
+		OperationIndex: index,
+		OperationType:  "deactivate",
+		OperationData:  string(operationJSON),
 	}
 
 	if err := p.store.SaveOperation(opRecord); err != nil {
-		return fmt.Errorf("failed to save operation: %w", err)
+		return BallotRejected, fmt.Errorf("failed to save operation: %w", err)
 	}
 
-	return nil
+	return BallotApplied, nil
 }
 
 // verifyUpdateSignature verifies the JWS signature and extracts UpdateSignedData
@@ -491,6 +985,23 @@ func extractJWSPayload(jws string) ([]byte, error) {
 	return payload, nil
 }
 
+// signedKeyJSON returns the key member of a JWS's signed data as it was signed
+func signedKeyJSON(jws, member string) (json.RawMessage, error) {
+	payload, err := extractJWSPayload(jws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract JWS payload: %w", err)
+	}
+	var signedData map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &signedData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal signed data: %w", err)
+	}
+	key, ok := signedData[member]
+	if !ok || string(key) == "null" {
+		return nil, fmt.Errorf("signed data has no %s", member)
+	}
+	return key, nil
+}
+
 // splitJWS splits a JWS compact serialization into parts
 func splitJWS(jws string) []string {
 	var parts []string
@@ -516,104 +1027,93 @@ func base64URLDecode(s string) ([]byte, error) {
 	return crypto.Base64URLDecode(s)
 }
 
-// SyncFromBallot syncs all ballots starting from a given ballot number
-func (p *Processor) SyncFromBallot(startBallot int, maxBallots int) (int, error) {
-	processedCount := 0
-
-	for i := 0; i < maxBallots; i++ {
-		ballotNum := startBallot + i
+// SyncResult summarizes a SyncFromBallot run
+type SyncResult struct {
+	Ballots      int  // Decided ballots processed
+	Applied      int  // Ballots with at least one DID operation applied
+	Rejected     int  // Ballots whose payload had no operation applied
+	Unresolvable int  // Ballots whose anchored content could not be fetched yet (at most one)
+	Next         int  // First ballot not yet synced
+	AtTip        bool // Sync stopped because ballot Next is not decided yet
+	Blocked      bool // Sync stopped because ballot Next anchors a batch that could not be fetched yet
+}
 
-		err := p.ProcessBallot(ballotNum)
-		if err != nil {
-			return processedCount, fmt.Errorf("failed to process ballot %d: %w", ballotNum, err)
+// SyncFromBallot processes decided ballots starting from startBallot
+//
+// It stops at the first undecided ballot, so last_synced_ballot never moves past
+// the tip, or after maxBallots ballots when maxBallots is positive. It also stops at
+// a ballot anchoring a batch whose content cannot be fetched yet, which is tried
+// again by the next sync: applying later ballots first would make the result depend
+// on when the content arrived. Cancelling ctx
+// stops the sync between ballots. The result is returned even on error and covers
+// the ballots synced before the failure.
+func (p *Processor) SyncFromBallot(ctx context.Context, startBallot int, maxBallots int) (*SyncResult, error) {
+	result := &SyncResult{Next: startBallot}
+
+	for maxBallots <= 0 || result.Ballots < maxBallots {
+		if err := ctx.Err(); err != nil {
+			return result, err
 		}
 
-		// Update sync state
-		if err := p.store.SetSyncState("last_synced_ballot", fmt.Sprintf("%d", ballotNum)); err != nil {
-			return processedCount, fmt.Errorf("failed to update sync state: %w", err)
+		ballotNum := result.Next
+
+		// The ballot's state changes and the sync cursor are committed together
+		outcome, err := p.processBallot(ctx, ballotNum, true)
+		if err != nil {
+			return result, fmt.Errorf("failed to process ballot %d: %w", ballotNum, err)
 		}
 
-		processedCount++
-	}
+		if outcome == BallotUndecided {
+			result.AtTip = true
 
-	return processedCount, nil
-}
-
-// stripWrappers removes CHAR slot wrapper or referendum vote wrapper from payload hex
-func stripWrappers(hexData string) string {
-	data, err := hex.DecodeString(hexData)
-	if err != nil || len(data) < 3 {
-		return hexData
-	}
+			// Remember the tip so writers can start their search from it
+			if err := saveTipEstimate(p.store, char.TipEstimate{Ballot: ballotNum, ObservedAt: time.Now()}); err != nil {
+				return result, err
+			}
+			break
+		}
 
-	// Check if it starts with CHAR slot wrapper: 0000
-	if data[0] == 0x00 && data[1] == 0x00 {
-		// CHAR slot format: [0x00][0x00][CompactSize length][payload]
-		return stripSlotWrapper(hexData)
-	}
+		if outcome == BallotUnresolvable {
+			result.Unresolvable++
+			result.Blocked = true
+			break
+		}
 
-	// Check if it starts with referendum vote wrapper: 0x00 (leaf type)
-	if data[0] == 0x00 {
-		// Referendum vote format: [0x00][varint ballot][compact_size length][payload]
-		return stripReferendumVoteWrapper(hexData)
+		switch outcome {
+		case BallotApplied:
+			result.Applied++
+		case BallotRejected:
+			result.Rejected++
+		}
+		result.Ballots++
+		result.Next++
 	}
 
-	// No recognized wrapper, return as-is
-	return hexData
+	return result, nil
 }
 
-// stripSlotWrapper removes CHAR slot format wrapper
-func stripSlotWrapper(hexData string) string {
-	data, _ := hex.DecodeString(hexData)
-	if len(data) < 3 {
-		return hexData
-	}
-
-	// Parse CompactSize at position 2
-	if data[2] < 0xfd {
-		return hexData[6:] // Skip 0000 + 1 byte = 3 bytes = 6 hex chars
-	} else if data[2] == 0xfd {
-		return hexData[10:] // Skip 0000 + fd + 2 bytes = 5 bytes = 10 hex chars
-	} else if data[2] == 0xfe {
-		return hexData[14:] // Skip 0000 + fe + 4 bytes = 7 bytes = 14 hex chars
-	} else {
-		return hexData[22:] // Skip 0000 + ff + 8 bytes = 11 bytes = 22 hex chars
+// SyncThrough processes the ballots not synced yet up to and including ballot
+//
+// Writers apply their own ballot this way, after everything decided before it, so
+// it is applied against the same state as on every other node.
+func (p *Processor) SyncThrough(ctx context.Context, ballot int) error {
+	start, err := p.NextUnsyncedBallot()
+	if err != nil {
+		return err
 	}
-}
-
-// stripReferendumVoteWrapper removes referendum vote wrapper
-func stripReferendumVoteWrapper(hexData string) string {
-	data, _ := hex.DecodeString(hexData)
-	if len(data) < 3 {
-		return hexData
+	if start > ballot {
+		return nil
 	}
 
-	offset := 1 // Skip leaf type byte
-
-	// Skip Go varint ballot number (we need to read it to know its length)
-	for offset < len(data) {
-		if data[offset] < 0x80 {
-			offset++ // Last byte of varint
-			break
-		}
-		offset++ // Continue reading varint
+	result, err := p.SyncFromBallot(ctx, start, ballot-start+1)
+	if err != nil {
+		return err
 	}
-
-	if offset >= len(data) {
-		return hexData // Invalid
+	if result.Blocked {
+		return fmt.Errorf("ballot %d anchors a batch whose content is not available yet, so ballot %d cannot be applied", result.Next, ballot)
 	}
-
-	// Skip CompactSize payload length
-	if data[offset] < 0xfd {
-		offset += 1
-	} else if data[offset] == 0xfd {
-		offset += 3
-	} else if data[offset] == 0xfe {
-		offset += 5
-	} else {
-		offset += 9
+	if result.Next <= ballot {
+		return fmt.Errorf("sync stopped at ballot %d before reaching ballot %d", result.Next, ballot)
 	}
-
-	// Return payload after all wrappers
-	return hexData[offset*2:]
+	return nil
 }
@@ -1,16 +1,24 @@
 package did
 
 import (
+	"context"
+	"crypto/ed25519"
 	"encoding/json"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/yourusername/did-char/pkg/canonical"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+	"github.com/yourusername/did-char/pkg/crypto"
+	"github.com/yourusername/did-char/pkg/encoding"
 	"github.com/yourusername/did-char/pkg/keys"
 	"github.com/yourusername/did-char/pkg/signing"
 )
 
 func TestExtractJWSPayload(t *testing.T) {
 	// Create a valid JWS for testing
-	ecKey, _ := keys.GenerateSecp256k1Key()
+	ecKey, _ := keys.GenerateP256Key()
 	signer, _ := signing.NewES256Signer(ecKey)
 
 	payload := []byte(`{"test":"data"}`)
@@ -81,7 +89,7 @@ func TestSplitJWS(t *testing.T) {
 
 func TestVerifyUpdateSignature(t *testing.T) {
 	// Generate key and create signed data
-	ecKey, _ := keys.GenerateSecp256k1Key()
+	ecKey, _ := keys.GenerateP256Key()
 	jwk := keys.PrivateKeyToJWK(ecKey, "test")
 
 	signedData := UpdateSignedData{
@@ -116,7 +124,7 @@ func TestVerifyUpdateSignatureInvalidJWS(t *testing.T) {
 
 func TestVerifyRecoverSignature(t *testing.T) {
 	// Generate key and create signed data
-	ecKey, _ := keys.GenerateSecp256k1Key()
+	ecKey, _ := keys.GenerateP256Key()
 	jwk := keys.PrivateKeyToJWK(ecKey, "test")
 
 	signedData := RecoverSignedData{
@@ -145,7 +153,7 @@ func TestVerifyRecoverSignature(t *testing.T) {
 
 func TestVerifyDeactivateSignature(t *testing.T) {
 	// Generate key and create signed data
-	ecKey, _ := keys.GenerateSecp256k1Key()
+	ecKey, _ := keys.GenerateP256Key()
 	jwk := keys.PrivateKeyToJWK(ecKey, "test")
 
 	signedData := DeactivateSignedData{
@@ -170,8 +178,8 @@ func TestVerifyDeactivateSignature(t *testing.T) {
 
 func TestVerifySignatureWithWrongKey(t *testing.T) {
 	// Create signed data with one key, but include a different key in payload
-	signingKey, _ := keys.GenerateSecp256k1Key()
-	differentKey, _ := keys.GenerateSecp256k1Key()
+	signingKey, _ := keys.GenerateP256Key()
+	differentKey, _ := keys.GenerateP256Key()
 	differentJWK := keys.PrivateKeyToJWK(differentKey, "test")
 
 	// Include the different key in signed data (not the signing key)
@@ -199,7 +207,7 @@ func TestCreateVerifierFromJWK(t *testing.T) {
 		{
 			name: "EC P-256",
 			generateFn: func() (*keys.JWK, error) {
-				key, err := keys.GenerateSecp256k1Key()
+				key, err := keys.GenerateP256Key()
 				if err != nil {
 					return nil, err
 				}
@@ -237,73 +245,6 @@ func TestCreateVerifierFromJWK(t *testing.T) {
 	}
 }
 
-func TestStripWrappers(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "no wrapper - returns unchanged",
-			input:    "0101036162630a7b7d",
-			expected: "0101036162630a7b7d",
-		},
-		{
-			name:     "too short - returns unchanged",
-			input:    "00",
-			expected: "00",
-		},
-		{
-			name:     "invalid hex - returns unchanged",
-			input:    "not-hex!",
-			expected: "not-hex!",
-		},
-		{
-			name:     "CHAR slot wrapper short length",
-			input:    "000005" + "0101036162630a7b7d", // 0000 + length(5) + payload
-			expected: "0101036162630a7b7d",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := stripWrappers(tt.input)
-			if result != tt.expected {
-				t.Errorf("stripWrappers(%q) = %q, want %q", tt.input, result, tt.expected)
-			}
-		})
-	}
-}
-
-func TestStripSlotWrapper(t *testing.T) {
-	// Test with different CompactSize lengths
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "1-byte length",
-			input:    "000003616263", // 0000 + 03 + "abc"
-			expected: "616263",
-		},
-		{
-			name:     "too short",
-			input:    "0000",
-			expected: "0000",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := stripSlotWrapper(tt.input)
-			if result != tt.expected {
-				t.Errorf("stripSlotWrapper(%q) = %q, want %q", tt.input, result, tt.expected)
-			}
-		})
-	}
-}
-
 func TestBase64URLDecode(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -359,3 +300,92 @@ func TestVerifyUpdateSignatureWithEd25519(t *testing.T) {
 		t.Errorf("DeltaHash = %q, want %q", result.DeltaHash, "ed25519-delta-hash")
 	}
 }
+
+func TestIntegrationDeltaHashedAsReceived(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	domain := env.cfg.CHAR.AppPreimage
+
+	updateKey, _ := keys.GenerateEd25519Key()
+	updateJWK := keys.Ed25519PublicKeyToJWK(updateKey.Public().(ed25519.PublicKey), "update")
+	recoveryKey, _ := keys.GenerateEd25519Key()
+	recoveryJWK := keys.Ed25519PublicKeyToJWK(recoveryKey.Public().(ed25519.PublicKey), "recovery")
+	updateCommitment, revealValue, _ := GenerateCommitmentFromJWK(updateJWK)
+	recoveryCommitment, _, _ := GenerateCommitmentFromJWK(recoveryJWK)
+
+	createOp, err := NewCreateOperation(NewDocument(""), updateCommitment, recoveryCommitment)
+	if err != nil {
+		t.Fatalf("NewCreateOperation failed: %v", err)
+	}
+	did := createOp.InitialDocument.ID
+	suffix, _ := ParseDID(did)
+	if _, err := SubmitOperation(context.Background(), env.cfg, env.store, env.client, encoding.OperationTypeCreate, suffix, createOp); err != nil {
+		t.Fatalf("SubmitOperation failed: %v", err)
+	}
+
+	// A delta as another client might write it: members out of order, a field this
+	// node does not know, an exponent and characters encoding/json would escape
+	delta := `{"updateCommitment":"` + updateCommitment + `","patches":[{"services":[{"serviceEndpoint":"https://hub.example.com/?a=1&b=<2>","type":"IdentityHub","id":"#hub"}],"action":"add-services"}],"x-extension":1.0E0}`
+	canonicalDelta, err := canonical.Transform([]byte(delta))
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+	signedData, _ := json.Marshal(&UpdateSignedData{UpdateKey: updateJWK, DeltaHash: crypto.HashToBase64URL(canonicalDelta)})
+	signer, _ := signing.NewEdDSASigner(updateKey)
+	jws, err := signer.Sign(signedData)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	updateWithDelta := func(delta string) string {
+		data := `{"type":"update","didSuffix":"` + did + `","revealValue":"` + revealValue + `","signedData":"` + jws + `","delta":` + delta + `}`
+		payload, err := encoding.EncodeCanonicalPayload([]encoding.Operation{{Type: encoding.OperationTypeUpdate, DIDSuffix: suffix, Data: []byte(data)}}, encoding.CompressionNone)
+		if err != nil {
+			t.Fatalf("EncodeCanonicalPayload failed: %v", err)
+		}
+		return payload
+	}
+
+	tests := []struct {
+		name   string
+		delta  string
+		reason string
+	}{
+		{"tampered delta", strings.Replace(delta, "hub.example.com", "evil.example.com", 1), "delta hash mismatch"},
+		{"unsigned delta that does not parse", `{"patches":"none"}`, "delta hash mismatch"},
+		{"missing delta", `null`, "update operation has no delta"},
+		{"delta as signed", delta, ""},
+	}
+
+	firstBallot := env.srv.NextBallot()
+	for _, tt := range tests {
+		env.srv.InjectVote(domain, updateWithDelta(tt.delta), true)
+		env.srv.Advance()
+	}
+
+	replica := newTestStore(t, filepath.Join(t.TempDir(), "replica.db"))
+	if _, err := NewProcessor(replica, env.client, domain).SyncFromBallot(context.Background(), 0, 0); err != nil {
+		t.Fatalf("SyncFromBallot failed: %v", err)
+	}
+
+	for i, tt := range tests {
+		rejected, _ := replica.GetRejectedOperationsAtBallot(firstBallot + i)
+		var reason string
+		if len(rejected) > 0 {
+			reason = rejected[0].Reason
+		}
+		if tt.reason == "" {
+			if reason != "" {
+				t.Errorf("%s: unexpectedly rejected: %s", tt.name, reason)
+			}
+			continue
+		}
+		if !strings.HasPrefix(reason, tt.reason) {
+			t.Errorf("%s: reason = %q, want prefix %q", tt.name, reason, tt.reason)
+		}
+	}
+
+	_, doc := loadDocument(t, replica, did)
+	if len(doc.Services) != 1 || doc.Services[0].ServiceEndpoint != "https://hub.example.com/?a=1&b=<2>" {
+		t.Errorf("services = %+v", doc.Services)
+	}
+}
@@ -0,0 +1,138 @@
+package did
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/config"
+	"github.com/yourusername/did-char/pkg/keys"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// RecoverDIDRequest contains parameters for recovering a DID
+type RecoverDIDRequest struct {
+	DID         string
+	Document    *Document // Replacement public keys and services (nil: a single new key, like create)
+	KeyFilePath string    // Optional explicit key file path (default: derived from DID in keys dir)
+	Batcher     *Batcher  // Optional shared batcher to submit through (default: a batch of one)
+}
+
+// RecoverDIDResult contains the result of recovering a DID
+type RecoverDIDResult struct {
+	Document     *Document
+	KeyFile      *keys.KeyFile
+	BallotNumber int
+}
+
+// RecoverDID replaces a DID's document and rotates both its update and recovery keys
+//
+// The operation is signed with the key file's recovery key, so it works even if the
+// update key has been lost or compromised. New keys use the same algorithms as the old ones.
+func RecoverDID(
+	req *RecoverDIDRequest,
+	cfg *config.Config,
+	store *storage.Store,
+	charClient *char.Client,
+) (*RecoverDIDResult, error) {
+
+	// Load key file
+	path := keyFilePath(req.KeyFilePath, req.DID, cfg.DataDir.KeysDir)
+	keyFile, err := keys.LoadKeyFileFromPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load key file: %w", err)
+	}
+
+	// Get signer based on recovery key type
+	_, signer, err := GetSignerAndReveal(keyFile.RecoveryKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signer: %w", err)
+	}
+
+	// Rotate both keys (same algorithms as current)
+	newUpdateKey, newUpdateCommitment, err := generateNextKeyAndCommitment(keyFile.UpdateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate new update key: %w", err)
+	}
+	newRecoveryKey, newRecoveryCommitment, err := generateNextKeyAndCommitment(keyFile.RecoveryKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate new recovery key: %w", err)
+	}
+
+	// Build replacement document
+	newDoc, err := recoveryDocument(req.DID, req.Document, newUpdateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the operation against the current DID state
+	pending, err := PrepareRecover(store, req.DID, keyFile.RecoveryKey, newDoc, newUpdateCommitment, newRecoveryCommitment)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign the payload
+	signedData, err := signer.Sign(pending.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign recover data: %w", err)
+	}
+	if err := pending.Complete(signedData); err != nil {
+		return nil, err
+	}
+
+	ballotNumber, err := batcherFor(req.Batcher, cfg, store, charClient).SubmitPending(context.Background(), pending)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update key file with both new keys and commitments
+	keyFile.UpdateKey = newUpdateKey
+	keyFile.RecoveryKey = newRecoveryKey
+	keyFile.NextUpdateCommitment = newUpdateCommitment
+	keyFile.NextRecoveryCommitment = newRecoveryCommitment
+	keyFile.LastOperationBallot = ballotNumber
+
+	if err := keys.SaveKeyFileToPath(keyFile, path); err != nil {
+		return nil, fmt.Errorf("failed to update key file: %w", err)
+	}
+
+	return &RecoverDIDResult{
+		Document:     newDoc,
+		KeyFile:      keyFile,
+		BallotNumber: ballotNumber,
+	}, nil
+}
+
+// recoveryDocument builds the document a recover operation will install
+//
+// Only public keys and services carry over from a supplied document, with any private
+// key material stripped. Without a supplied document the new update key becomes #key-1,
+// as in CreateDID. Recover deltas carry no authentication, so none is set here either.
+func recoveryDocument(did string, supplied *Document, newUpdateKey *keys.JWK) (*Document, error) {
+	doc := NewDocument(did)
+
+	if supplied == nil {
+		pk, err := NewPublicKeyFromJWK("#key-1", newUpdateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build public key: %w", err)
+		}
+		doc.AddPublicKey(pk)
+		return doc, nil
+	}
+
+	for _, pk := range supplied.PublicKeys {
+		if pk.ID == "" || pk.PublicKeyJwk == nil {
+			return nil, fmt.Errorf("public key %q must have an id and publicKeyJwk", pk.ID)
+		}
+		pk.PublicKeyJwk = getPublicJWK(pk.PublicKeyJwk)
+		doc.AddPublicKey(pk)
+	}
+	for _, svc := range supplied.Services {
+		if svc.ID == "" || svc.Type == "" || svc.ServiceEndpoint == "" {
+			return nil, fmt.Errorf("service %q must have an id, type and serviceEndpoint", svc.ID)
+		}
+		doc.AddService(svc)
+	}
+
+	return doc, nil
+}
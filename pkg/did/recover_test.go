@@ -0,0 +1,142 @@
+package did
+
+import (
+	"testing"
+
+	"github.com/yourusername/did-char/pkg/char/chartest"
+	"github.com/yourusername/did-char/pkg/keys"
+)
+
+func TestIntegrationRecoverReplacesDocument(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	created, err := CreateDID(&CreateDIDRequest{
+		Services: []Service{{ID: "#domain", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	priv, err := keys.GenerateEd25519Key()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	// Private material in the supplied document must never reach CHAR
+	supplied := NewDocument("")
+	supplied.AddPublicKey(PublicKey{
+		ID:           "#signing",
+		Type:         "Ed25519VerificationKey2020",
+		PublicKeyJwk: keys.Ed25519PrivateKeyToJWK(priv, "signing"),
+	})
+	supplied.AddService(Service{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: "https://hub.example.com"})
+
+	recovered, err := RecoverDID(&RecoverDIDRequest{DID: created.DID, Document: supplied}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("RecoverDID failed: %v", err)
+	}
+
+	record, doc := loadDocument(t, env.store, created.DID)
+	if record.Status != "active" || record.LastOperationBallot != recovered.BallotNumber {
+		t.Errorf("unexpected record after recover: %+v", record)
+	}
+	if len(doc.PublicKeys) != 1 || doc.PublicKeys[0].ID != "#signing" {
+		t.Fatalf("unexpected public keys after recover: %+v", doc.PublicKeys)
+	}
+	if doc.PublicKeys[0].PublicKeyJwk.D != "" {
+		t.Error("private key material was published")
+	}
+	if len(doc.Services) != 1 || doc.Services[0].ID != "#hub" {
+		t.Errorf("unexpected services after recover: %+v", doc.Services)
+	}
+
+	// Both keys rotated and the new commitments match the stored state
+	keyFile, err := keys.LoadKeyFile(created.DID, env.cfg.DataDir.KeysDir)
+	if err != nil {
+		t.Fatalf("LoadKeyFile failed: %v", err)
+	}
+	if keyFile.UpdateKey.D == created.KeyFile.UpdateKey.D || keyFile.RecoveryKey.D == created.KeyFile.RecoveryKey.D {
+		t.Error("keys were not rotated")
+	}
+	if keyFile.NextUpdateCommitment != record.UpdateCommitment || keyFile.NextRecoveryCommitment != record.RecoveryCommitment {
+		t.Error("key file commitments do not match stored DID")
+	}
+	if keyFile.LastOperationBallot != recovered.BallotNumber {
+		t.Errorf("key file ballot = %d, want %d", keyFile.LastOperationBallot, recovered.BallotNumber)
+	}
+
+	// The rotated keys authorize later operations
+	err = UpdateDID(&UpdateDIDRequest{DID: created.DID, RemoveServices: []string{"#hub"}}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("UpdateDID after recover failed: %v", err)
+	}
+	if err := DeactivateDID(&DeactivateDIDRequest{DID: created.DID}, env.cfg, env.store, env.client); err != nil {
+		t.Fatalf("DeactivateDID after recover failed: %v", err)
+	}
+}
+
+func TestIntegrationRecoverGeneratesDocument(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	recovered, err := RecoverDID(&RecoverDIDRequest{DID: created.DID}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("RecoverDID failed: %v", err)
+	}
+
+	_, doc := loadDocument(t, env.store, created.DID)
+	if len(doc.PublicKeys) != 1 || doc.PublicKeys[0].ID != "#key-1" {
+		t.Fatalf("unexpected public keys after recover: %+v", doc.PublicKeys)
+	}
+	if doc.PublicKeys[0].PublicKeyJwk.X != recovered.KeyFile.UpdateKey.X {
+		t.Error("generated document should publish the new update key")
+	}
+	if len(doc.Services) != 0 {
+		t.Errorf("expected no services, got %+v", doc.Services)
+	}
+}
+
+func TestIntegrationRecoverRejectsInvalidRequests(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  *RecoverDIDRequest
+	}{
+		{
+			name: "key without JWK",
+			req: &RecoverDIDRequest{DID: created.DID, Document: &Document{
+				PublicKeys: []PublicKey{{ID: "#key-1", Type: "JsonWebKey2020"}},
+			}},
+		},
+		{
+			name: "service without endpoint",
+			req: &RecoverDIDRequest{DID: created.DID, Document: &Document{
+				Services: []Service{{ID: "#hub", Type: "IdentityHub"}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := RecoverDID(tt.req, env.cfg, env.store, env.client); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+
+	if err := DeactivateDID(&DeactivateDIDRequest{DID: created.DID}, env.cfg, env.store, env.client); err != nil {
+		t.Fatalf("DeactivateDID failed: %v", err)
+	}
+	if _, err := RecoverDID(&RecoverDIDRequest{DID: created.DID}, env.cfg, env.store, env.client); err == nil {
+		t.Fatal("expected recover of a deactivated DID to fail")
+	}
+}
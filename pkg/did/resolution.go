@@ -0,0 +1,216 @@
+package did
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// ContextDIDResolution is the JSON-LD context of a DID Resolution Result
+const ContextDIDResolution = "https://w3id.org/did-resolution/v1"
+
+// MediaTypeDIDLDJSON is the content type of rendered DID documents
+const MediaTypeDIDLDJSON = "application/did+ld+json"
+
+// DID Resolution and DID URL Dereferencing error codes
+const (
+	ResolutionErrorInvalidDID                 = "invalidDid"
+	ResolutionErrorInvalidDIDURL              = "invalidDidUrl"
+	ResolutionErrorNotFound                   = "notFound"
+	ResolutionErrorInvalidOptions             = "invalidOptions"
+	ResolutionErrorRepresentationNotSupported = "representationNotSupported"
+	ResolutionErrorInternal                   = "internalError"
+)
+
+// ResolutionResult is a W3C DID Resolution Result
+type ResolutionResult struct {
+	Context               string             `json:"@context"`
+	DIDDocument           *DIDDocument       `json:"didDocument"`
+	DIDResolutionMetadata ResolutionMetadata `json:"didResolutionMetadata"`
+	DIDDocumentMetadata   DocumentMetadata   `json:"didDocumentMetadata"`
+}
+
+// ResolutionMetadata describes the resolution process itself
+type ResolutionMetadata struct {
+	ContentType  string `json:"contentType,omitempty"`
+	Error        string `json:"error,omitempty"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+}
+
+// DocumentMetadata describes the resolved DID document
+//
+// Times are when this node first saw the ballots decided, and are omitted for
+// ballots it has no time for; the exact ballots are in Method.
+type DocumentMetadata struct {
+	Created       string          `json:"created,omitempty"`
+	Updated       string          `json:"updated,omitempty"`
+	Deactivated   bool            `json:"deactivated,omitempty"`
+	VersionID     string          `json:"versionId,omitempty"`
+	NextVersionID string          `json:"nextVersionId,omitempty"`
+	CanonicalID   string          `json:"canonicalId,omitempty"`
+	EquivalentID  []string        `json:"equivalentId,omitempty"`
+	Method        *MethodMetadata `json:"method,omitempty"`
+}
+
+// MethodMetadata is did:char specific document metadata
+type MethodMetadata struct {
+	Published          bool   `json:"published"`
+	CreatedAtBallot    *int   `json:"createdAtBallot,omitempty"`    // Published DIDs only
+	UpdatedAtBallot    *int   `json:"updatedAtBallot,omitempty"`    // Published DIDs only
+	UpdateCommitment   string `json:"updateCommitment,omitempty"`   // Latest version only
+	RecoveryCommitment string `json:"recoveryCommitment,omitempty"` // Latest version only
+}
+
+// NewErrorResult returns a resolution result reporting a resolution error
+func NewErrorResult(code, message string) *ResolutionResult {
+	return &ResolutionResult{
+		Context: ContextDIDResolution,
+		DIDResolutionMetadata: ResolutionMetadata{
+			Error:        code,
+			ErrorMessage: message,
+		},
+	}
+}
+
+// ResolveResult resolves did to a DID Resolution Result with a DID Core document
+//
+// Invalid DIDs, unknown DIDs or versions and invalid options are reported in
+// didResolutionMetadata. The returned error is reserved for internal failures.
+//
+// A long-form DID keeps its long form as the document ID and lists the short
+// form as equivalentId; once anchored, the short form is also its canonicalId.
+func (r *Resolver) ResolveResult(did string, opts ResolveOptions) (*ResolutionResult, error) {
+	if _, err := ParseDID(did); err != nil {
+		return NewErrorResult(ResolutionErrorInvalidDID, err.Error()), nil
+	}
+	if IsLongFormDID(did) {
+		if _, _, err := ParseLongFormDID(did); err != nil {
+			return NewErrorResult(ResolutionErrorInvalidDID, err.Error()), nil
+		}
+	}
+
+	var result *ResolutionResult
+	err := r.inSnapshot(func(sr *Resolver) error {
+		var err error
+		result, err = sr.resolveResult(did, opts)
+		return err
+	})
+	return result, err
+}
+
+// resolveResult implements ResolveResult against the resolver's store
+func (r *Resolver) resolveResult(did string, opts ResolveOptions) (*ResolutionResult, error) {
+	res, err := r.resolve(did, opts)
+	switch {
+	case errors.Is(err, ErrDIDNotFound), errors.Is(err, ErrVersionNotFound):
+		return NewErrorResult(ResolutionErrorNotFound, err.Error()), nil
+	case errors.Is(err, ErrInvalidOptions):
+		return NewErrorResult(ResolutionErrorInvalidOptions, err.Error()), nil
+	case err != nil:
+		return nil, err
+	}
+
+	var stored Document
+	if err := json.Unmarshal(res.Document, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse stored document: %w", err)
+	}
+	if res.LongForm != "" {
+		// Keys controlled by the DID itself name it the way it was resolved
+		for i := range stored.PublicKeys {
+			if stored.PublicKeys[i].Controller == res.DID {
+				stored.PublicKeys[i].Controller = res.LongForm
+			}
+		}
+	}
+	deactivated := res.Status == "deactivated"
+	doc, err := RenderDocument(did, &stored, deactivated)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.Published {
+		return &ResolutionResult{
+			Context:     ContextDIDResolution,
+			DIDDocument: doc,
+			DIDResolutionMetadata: ResolutionMetadata{
+				ContentType: MediaTypeDIDLDJSON,
+			},
+			DIDDocumentMetadata: DocumentMetadata{
+				EquivalentID: []string{res.DID},
+				Method:       &MethodMetadata{Published: false},
+			},
+		}, nil
+	}
+
+	meta := DocumentMetadata{
+		Deactivated: deactivated,
+		VersionID:   strconv.Itoa(res.VersionID),
+		CanonicalID: res.DID,
+		Method: &MethodMetadata{
+			Published:       true,
+			CreatedAtBallot: &res.CreatedAtBallot,
+			UpdatedAtBallot: &res.VersionID,
+		},
+	}
+	if res.LongForm != "" {
+		meta.EquivalentID = []string{res.DID}
+	}
+	if res.NextVersionID != nil {
+		meta.NextVersionID = strconv.Itoa(*res.NextVersionID)
+	} else {
+		record, err := r.store.GetDID(res.DID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load DID: %w", err)
+		}
+		if record != nil {
+			meta.Method.UpdateCommitment = record.UpdateCommitment
+			meta.Method.RecoveryCommitment = record.RecoveryCommitment
+		}
+	}
+
+	created, err := r.store.GetBallotTime(res.CreatedAtBallot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ballot time: %w", err)
+	}
+	if knownTime(created) {
+		meta.Created = formatDateTime(created.DecidedAt)
+	}
+	if res.VersionID != res.CreatedAtBallot {
+		updated, err := r.store.GetBallotTime(res.VersionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ballot time: %w", err)
+		}
+		if knownTime(updated) {
+			meta.Updated = formatDateTime(updated.DecidedAt)
+		}
+	}
+
+	return &ResolutionResult{
+		Context:     ContextDIDResolution,
+		DIDDocument: doc,
+		DIDResolutionMetadata: ResolutionMetadata{
+			ContentType: MediaTypeDIDLDJSON,
+		},
+		DIDDocumentMetadata: meta,
+	}, nil
+}
+
+// maxTimeUncertainty is how far a created or updated time may be from when its ballot was decided
+const maxTimeUncertainty = time.Minute
+
+// knownTime reports whether record tells when its ballot was decided closely enough to report
+//
+// Ballots this node synced after the fact, or saw decided only long after it last saw
+// them undecided, are left out rather than reported as when the node caught up.
+func knownTime(record *storage.BallotTimeRecord) bool {
+	return record != nil && record.Bounded() && record.Uncertainty() <= maxTimeUncertainty
+}
+
+// formatDateTime formats t as an XML Schema dateTime in UTC, as DID Core metadata requires
+func formatDateTime(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
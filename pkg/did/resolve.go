@@ -0,0 +1,223 @@
+package did
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// ErrDIDNotFound is returned when a DID has no applied operations
+var ErrDIDNotFound = errors.New("DID not found")
+
+// ErrVersionNotFound is returned when the requested version of a DID does not exist
+var ErrVersionNotFound = errors.New("DID version not found")
+
+// ErrInvalidOptions is returned for contradictory resolution options
+var ErrInvalidOptions = errors.New("invalid resolution options")
+
+// ResolveOptions selects which version of a DID to resolve; the zero value resolves the latest
+type ResolveOptions struct {
+	VersionID   *int      // Ballot of an operation on the DID; resolves the state that operation produced
+	VersionTime time.Time // Resolves the state as of this time, mapped to the last ballot seen decided by then
+}
+
+// Resolution is a DID document together with the metadata of its version
+type Resolution struct {
+	DID             string          // Always the short form
+	LongForm        string          // The long-form DID that was resolved, if any
+	Published       bool            // False for a long-form DID whose create operation is not anchored yet
+	Document        json.RawMessage // Exactly as stored when the version was produced
+	Status          string
+	CreatedAtBallot int
+	VersionID       int    // Ballot of the operation that produced this version
+	OperationType   string // Operation that produced this version
+	NextVersionID   *int   // Ballot of the following operation, if any
+}
+
+// Resolver resolves DIDs, optionally as of an earlier version, from the local store
+type Resolver struct {
+	store *storage.Store
+}
+
+// NewResolver creates a resolver over store
+func NewResolver(store *storage.Store) *Resolver {
+	return &Resolver{store: store}
+}
+
+// Resolve returns the requested version of a DID
+//
+// Versions are recorded as operations are applied. A database created before
+// versions were recorded only holds the state at that point, so older versions
+// of its DIDs cannot be resolved until the database is rebuilt from CHAR.
+// A long-form DID resolves to its anchored state once its create operation is
+// applied, and to the embedded initial document until then.
+// Resolve reads from a single snapshot, so it is safe alongside a running Processor.
+func (r *Resolver) Resolve(did string, opts ResolveOptions) (*Resolution, error) {
+	var res *Resolution
+	err := r.inSnapshot(func(sr *Resolver) error {
+		var err error
+		res, err = sr.resolve(did, opts)
+		return err
+	})
+	return res, err
+}
+
+// inSnapshot runs fn with a resolver bound to one read transaction
+func (r *Resolver) inSnapshot(fn func(sr *Resolver) error) error {
+	return r.store.InReadTx(func(tx *storage.Store) error {
+		return fn(&Resolver{store: tx})
+	})
+}
+
+// resolve implements Resolve against the resolver's store
+func (r *Resolver) resolve(did string, opts ResolveOptions) (*Resolution, error) {
+	if opts.VersionID != nil && !opts.VersionTime.IsZero() {
+		return nil, fmt.Errorf("%w: versionId and versionTime cannot be combined", ErrInvalidOptions)
+	}
+	if IsLongFormDID(did) {
+		return r.resolveLongForm(did, opts)
+	}
+
+	target := math.MaxInt
+	switch {
+	case opts.VersionID != nil:
+		target = *opts.VersionID
+	case !opts.VersionTime.IsZero():
+		ballot, err := r.ballotAt(opts.VersionTime)
+		if err != nil {
+			return nil, err
+		}
+		target = ballot
+	}
+
+	ops, err := r.store.GetOperations(did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load operations: %w", err)
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDIDNotFound, did)
+	}
+
+	// The operation that produced the requested version, and the one after it
+	current, next := -1, -1
+	for i, op := range ops {
+		if op.BallotNumber > target {
+			next = op.BallotNumber
+			break
+		}
+		current = i
+	}
+	if !opts.VersionTime.IsZero() && next >= 0 {
+		if err := r.checkDecidedAfter(did, next, opts.VersionTime); err != nil {
+			return nil, err
+		}
+	}
+	if current < 0 {
+		return nil, fmt.Errorf("%w: %s did not exist at ballot %d", ErrVersionNotFound, did, target)
+	}
+	if opts.VersionID != nil && ops[current].BallotNumber != target {
+		return nil, fmt.Errorf("%w: no operation on %s at ballot %d", ErrVersionNotFound, did, target)
+	}
+
+	versions, err := r.store.GetDocumentVersions(did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load document versions: %w", err)
+	}
+	var version *storage.DocumentVersionRecord
+	for _, v := range versions {
+		if v.BallotNumber == ops[current].BallotNumber {
+			version = v
+			break
+		}
+	}
+	if version == nil {
+		// Seeded databases only hold the state each DID was in when versions started being recorded
+		return nil, fmt.Errorf("%w: version %d of %s was applied before this database recorded versions; a database synced from ballot 0 can resolve it",
+			ErrVersionNotFound, ops[current].BallotNumber, did)
+	}
+
+	resolution := &Resolution{
+		DID:             did,
+		Published:       true,
+		Document:        json.RawMessage(version.Document),
+		Status:          version.Status,
+		CreatedAtBallot: ops[0].BallotNumber,
+		VersionID:       version.BallotNumber,
+		OperationType:   version.OperationType,
+	}
+	if next >= 0 {
+		resolution.NextVersionID = &next
+	}
+
+	return resolution, nil
+}
+
+// resolveLongForm resolves a long-form DID from the store once anchored, or from its initial state
+func (r *Resolver) resolveLongForm(did string, opts ResolveOptions) (*Resolution, error) {
+	shortDID, op, err := ParseLongFormDID(did)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := r.store.DIDExists(shortDID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check DID existence: %w", err)
+	}
+	if exists {
+		res, err := r.resolve(shortDID, opts)
+		if err != nil {
+			return nil, err
+		}
+		res.LongForm = did
+		return res, nil
+	}
+
+	if opts.VersionID != nil || !opts.VersionTime.IsZero() {
+		return nil, fmt.Errorf("%w: %s is not anchored yet", ErrVersionNotFound, shortDID)
+	}
+	doc, err := json.Marshal(op.InitialDocument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document: %w", err)
+	}
+	return &Resolution{
+		DID:           shortDID,
+		LongForm:      did,
+		Document:      doc,
+		Status:        "active",
+		OperationType: "create",
+	}, nil
+}
+
+// checkDecidedAfter fails unless ballot, which changed did, is known to be decided after t
+//
+// Recorded times are when this node first saw a ballot decided, so a ballot recorded
+// after t may still have been decided before it: when the node synced it late, or when
+// t falls between the last time it saw the ballot undecided and the first time decided.
+func (r *Resolver) checkDecidedAfter(did string, ballot int, t time.Time) error {
+	record, err := r.store.GetBallotTime(ballot)
+	if err != nil {
+		return fmt.Errorf("failed to load ballot time: %w", err)
+	}
+	if record == nil || !record.Bounded() {
+		return fmt.Errorf("%w: ballot %d changed %s and was synced by this node after it was decided, so it cannot tell whether that was before %s",
+			ErrVersionNotFound, ballot, did, t.UTC().Format(time.RFC3339Nano))
+	}
+	if record.DecidedAfter.Before(t) {
+		return fmt.Errorf("%w: ballot %d changed %s and was decided between %s and %s, so this node cannot tell whether that was before %s",
+			ErrVersionNotFound, ballot, did, record.DecidedAfter.Format(time.RFC3339Nano), record.DecidedAt.Format(time.RFC3339Nano), t.UTC().Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// ballotAt maps a time to the last ballot this node had seen decided by then
+func (r *Resolver) ballotAt(t time.Time) (int, error) {
+	ballot, err := r.store.GetLastBallotDecidedBy(t)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load ballot times: %w", err)
+	}
+	return ballot, nil
+}
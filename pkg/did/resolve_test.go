@@ -0,0 +1,341 @@
+package did
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/char/chartest"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+func TestIntegrationResolveHistoricalVersions(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	created, err := CreateDID(&CreateDIDRequest{
+		Services: []Service{{ID: "#domain", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	err = UpdateDID(&UpdateDIDRequest{
+		DID:         created.DID,
+		AddServices: []Service{{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: "https://hub.example.com"}},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("UpdateDID failed: %v", err)
+	}
+	if err := DeactivateDID(&DeactivateDIDRequest{DID: created.DID}, env.cfg, env.store, env.client); err != nil {
+		t.Fatalf("DeactivateDID failed: %v", err)
+	}
+
+	ops, err := env.store.GetOperations(created.DID)
+	if err != nil || len(ops) != 3 {
+		t.Fatalf("expected 3 operations, got %d (%v)", len(ops), err)
+	}
+	createBallot, updateBallot, deactivateBallot := ops[0].BallotNumber, ops[1].BallotNumber, ops[2].BallotNumber
+
+	resolver := NewResolver(env.store)
+	ballot := func(n int) *int { return &n }
+
+	tests := []struct {
+		name      string
+		opts      ResolveOptions
+		version   int
+		operation string
+		status    string
+		services  int
+		next      int // -1: no next version
+	}{
+		{"latest", ResolveOptions{}, deactivateBallot, "deactivate", "deactivated", 2, -1},
+		{"create", ResolveOptions{VersionID: ballot(createBallot)}, createBallot, "create", "active", 1, updateBallot},
+		{"update", ResolveOptions{VersionID: ballot(updateBallot)}, updateBallot, "update", "active", 2, deactivateBallot},
+		{"deactivate", ResolveOptions{VersionID: ballot(deactivateBallot)}, deactivateBallot, "deactivate", "deactivated", 2, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := resolver.Resolve(created.DID, tt.opts)
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			if res.VersionID != tt.version || res.OperationType != tt.operation || res.Status != tt.status {
+				t.Errorf("got version %d (%s, %s), want %d (%s, %s)",
+					res.VersionID, res.OperationType, res.Status, tt.version, tt.operation, tt.status)
+			}
+			if res.CreatedAtBallot != createBallot {
+				t.Errorf("created at ballot %d, want %d", res.CreatedAtBallot, createBallot)
+			}
+			if tt.next < 0 && res.NextVersionID != nil {
+				t.Errorf("unexpected next version %d", *res.NextVersionID)
+			}
+			if tt.next >= 0 && (res.NextVersionID == nil || *res.NextVersionID != tt.next) {
+				t.Errorf("next version = %v, want %d", res.NextVersionID, tt.next)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(res.Document, &doc); err != nil {
+				t.Fatalf("failed to parse document: %v", err)
+			}
+			if len(doc.Services) != tt.services {
+				t.Errorf("expected %d services, got %d", tt.services, len(doc.Services))
+			}
+		})
+	}
+}
+
+func TestIntegrationResolveVersionErrors(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	for i := 0; i < 3; i++ {
+		env.srv.Advance()
+	}
+
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+
+	// An operation applied before this database recorded versions
+	unrecorded := created.BallotNumber + 5
+	if err := env.store.SaveOperation(&storage.OperationRecord{DID: created.DID, BallotNumber: unrecorded, OperationType: OperationTypeUpdate, OperationData: "{}"}); err != nil {
+		t.Fatalf("SaveOperation failed: %v", err)
+	}
+
+	resolver := NewResolver(env.store)
+	before, between := created.BallotNumber-1, created.BallotNumber+1
+
+	tests := []struct {
+		name string
+		did  string
+		opts ResolveOptions
+		want error
+	}{
+		{"unknown DID", "did:char:unknown", ResolveOptions{}, ErrDIDNotFound},
+		{"before create", created.DID, ResolveOptions{VersionID: &before}, ErrVersionNotFound},
+		{"no operation at ballot", created.DID, ResolveOptions{VersionID: &between}, ErrVersionNotFound},
+		{"version not recorded", created.DID, ResolveOptions{VersionID: &unrecorded}, ErrVersionNotFound},
+		{"both options", created.DID, ResolveOptions{VersionID: &before, VersionTime: time.Now()}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolver.Resolve(tt.did, tt.opts)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestIntegrationResolveVersionTime(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	resolver := NewResolver(env.store)
+
+	if _, err := resolver.Resolve("did:char:unknown", ResolveOptions{VersionTime: time.Now()}); err == nil {
+		t.Fatal("expected error for an unknown DID")
+	}
+
+	// Pin the ballot times: the first times recorded for a ballot are kept. The create
+	// and the first update were seen undecided half a second before they were seen
+	// decided; the second update was only synced after the fact.
+	ref := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	decided := func(ballot int) time.Time { return ref.Add(time.Duration(ballot) * time.Minute) }
+	first := env.srv.NextBallot()
+	for ballot := first; ballot < first+3; ballot++ {
+		var after time.Time
+		if ballot < first+2 {
+			after = decided(ballot).Add(-500 * time.Millisecond)
+		}
+		if err := env.store.RecordBallotTime(ballot, after, decided(ballot)); err != nil {
+			t.Fatalf("RecordBallotTime failed: %v", err)
+		}
+	}
+
+	created, err := CreateDID(&CreateDIDRequest{}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	for _, endpoint := range []string{"https://hub.example.com", "https://hub2.example.com"} {
+		err = UpdateDID(&UpdateDIDRequest{
+			DID:         created.DID,
+			AddServices: []Service{{ID: "#hub", Type: "IdentityHub", ServiceEndpoint: endpoint}},
+		}, env.cfg, env.store, env.client)
+		if err != nil {
+			t.Fatalf("UpdateDID failed: %v", err)
+		}
+	}
+	updateBallot, lateBallot := created.BallotNumber+1, created.BallotNumber+2
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		version int    // -1: no version
+		reason  string // Expected in the error when there is no version
+	}{
+		{"before create", decided(created.BallotNumber).Add(-time.Second), -1, "did not exist"},
+		{"when created", decided(created.BallotNumber), created.BallotNumber, ""},
+		{"just before update", decided(updateBallot).Add(-time.Second), created.BallotNumber, ""},
+		{"while update was decided", decided(updateBallot).Add(-100 * time.Millisecond), -1, "was decided between"},
+		{"before late update", decided(lateBallot).Add(-time.Second), -1, "after it was decided"},
+		{"after late update", decided(lateBallot).Add(time.Hour), lateBallot, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := resolver.Resolve(created.DID, ResolveOptions{VersionTime: tt.at})
+			if tt.version < 0 {
+				if !errors.Is(err, ErrVersionNotFound) || !strings.Contains(err.Error(), tt.reason) {
+					t.Fatalf("expected ErrVersionNotFound (%s), got %v", tt.reason, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			if res.VersionID != tt.version {
+				t.Errorf("version = %d, want %d", res.VersionID, tt.version)
+			}
+		})
+	}
+}
+
+func TestIntegrationResolveResult(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+
+	created, err := CreateDID(&CreateDIDRequest{
+		Services: []Service{{ID: "#domain", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	err = UpdateDID(&UpdateDIDRequest{DID: created.DID, RemoveServices: []string{"#domain"}}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("UpdateDID failed: %v", err)
+	}
+	updateBallot := created.BallotNumber + 1
+
+	// The times this node first saw the ballots decided, having voted on them while open
+	createdAt, _ := env.store.GetBallotTime(created.BallotNumber)
+	updatedAt, _ := env.store.GetBallotTime(updateBallot)
+	if createdAt == nil || updatedAt == nil || !createdAt.Bounded() || !updatedAt.Bounded() {
+		t.Fatalf("ballot times not recorded exactly: %+v %+v", createdAt, updatedAt)
+	}
+
+	resolver := NewResolver(env.store)
+	record, _ := loadDocument(t, env.store, created.DID)
+
+	t.Run("latest", func(t *testing.T) {
+		res, err := resolver.ResolveResult(created.DID, ResolveOptions{})
+		if err != nil {
+			t.Fatalf("ResolveResult failed: %v", err)
+		}
+		if res.Context != ContextDIDResolution || res.DIDResolutionMetadata.ContentType != MediaTypeDIDLDJSON {
+			t.Errorf("unexpected result envelope: %+v", res)
+		}
+		if res.DIDDocument.ID != created.DID || len(res.DIDDocument.Service) != 0 {
+			t.Errorf("unexpected document: %+v", res.DIDDocument)
+		}
+		if len(res.DIDDocument.VerificationMethod) != 1 || res.DIDDocument.VerificationMethod[0].ID != created.DID+"#key-1" {
+			t.Errorf("unexpected verification methods: %+v", res.DIDDocument.VerificationMethod)
+		}
+
+		meta := res.DIDDocumentMetadata
+		want := DocumentMetadata{
+			Created:     formatDateTime(createdAt.DecidedAt),
+			Updated:     formatDateTime(updatedAt.DecidedAt),
+			VersionID:   strconv.Itoa(updateBallot),
+			CanonicalID: created.DID,
+		}
+		if meta.Created != want.Created || meta.Updated != want.Updated || meta.VersionID != want.VersionID ||
+			meta.NextVersionID != "" || meta.CanonicalID != want.CanonicalID || meta.Deactivated {
+			t.Errorf("metadata = %+v, want %+v", meta, want)
+		}
+		if meta.Method.CreatedAtBallot == nil || *meta.Method.CreatedAtBallot != created.BallotNumber || meta.Method.UpdateCommitment != record.UpdateCommitment {
+			t.Errorf("unexpected method metadata: %+v", meta.Method)
+		}
+	})
+
+	t.Run("historical", func(t *testing.T) {
+		res, err := resolver.ResolveResult(created.DID, ResolveOptions{VersionID: &created.BallotNumber})
+		if err != nil {
+			t.Fatalf("ResolveResult failed: %v", err)
+		}
+		meta := res.DIDDocumentMetadata
+		if meta.VersionID != strconv.Itoa(created.BallotNumber) || meta.NextVersionID != strconv.Itoa(updateBallot) || meta.Updated != "" {
+			t.Errorf("unexpected metadata: %+v", meta)
+		}
+		if meta.Method.UpdateCommitment != "" {
+			t.Error("historical versions should not report current commitments")
+		}
+		if len(res.DIDDocument.Service) != 1 || res.DIDDocument.Service[0].ID != created.DID+"#domain" {
+			t.Errorf("unexpected services: %+v", res.DIDDocument.Service)
+		}
+	})
+
+	t.Run("deactivated", func(t *testing.T) {
+		if err := DeactivateDID(&DeactivateDIDRequest{DID: created.DID}, env.cfg, env.store, env.client); err != nil {
+			t.Fatalf("DeactivateDID failed: %v", err)
+		}
+		res, err := resolver.ResolveResult(created.DID, ResolveOptions{})
+		if err != nil {
+			t.Fatalf("ResolveResult failed: %v", err)
+		}
+		if !res.DIDDocumentMetadata.Deactivated || len(res.DIDDocument.VerificationMethod) != 0 {
+			t.Errorf("unexpected deactivated result: %+v", res)
+		}
+	})
+}
+
+func TestResolveResultErrors(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{DecideOnSubmit: true})
+	resolver := NewResolver(env.store)
+	zero := 0
+
+	tests := []struct {
+		name string
+		did  string
+		opts ResolveOptions
+		code string
+	}{
+		{"invalid DID", "did:example:123", ResolveOptions{}, ResolutionErrorInvalidDID},
+		{"unknown DID", "did:char:unknown", ResolveOptions{}, ResolutionErrorNotFound},
+		{"invalid options", "did:char:unknown", ResolveOptions{VersionID: &zero, VersionTime: time.Now()}, ResolutionErrorInvalidOptions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := resolver.ResolveResult(tt.did, tt.opts)
+			if err != nil {
+				t.Fatalf("ResolveResult failed: %v", err)
+			}
+			if res.DIDResolutionMetadata.Error != tt.code || res.DIDDocument != nil {
+				t.Errorf("error = %q, want %q (document %+v)", res.DIDResolutionMetadata.Error, tt.code, res.DIDDocument)
+			}
+		})
+	}
+}
+
+func TestKnownTime(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		record *storage.BallotTimeRecord
+		want   bool
+	}{
+		{"not recorded", nil, false},
+		{"synced after the fact", &storage.BallotTimeRecord{DecidedAt: at}, false},
+		{"seen undecided shortly before", &storage.BallotTimeRecord{DecidedAfter: at.Add(-5 * time.Second), DecidedAt: at}, true},
+		{"seen undecided long before", &storage.BallotTimeRecord{DecidedAfter: at.Add(-time.Hour), DecidedAt: at}, false},
+	}
+	for _, tt := range tests {
+		if got := knownTime(tt.record); got != tt.want {
+			t.Errorf("%s: knownTime = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,26 +1,133 @@
 package did
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 
+	"github.com/yourusername/did-char/pkg/canonical"
 	"github.com/yourusername/did-char/pkg/crypto"
 )
 
 // DIDPrefix is the prefix for all did:char DIDs
 const DIDPrefix = "did:char:"
 
-// GenerateDIDSuffix generates a DID suffix from initial state
+// GenerateDIDSuffix generates a DID suffix from initial state, hashed over its JCS serialization
 func GenerateDIDSuffix(initialState interface{}) (string, error) {
-	// Canonicalize to JSON
-	canonical, err := json.Marshal(initialState)
+	return generateDIDSuffix(initialState, canonical.JCS)
+}
+
+// generateDIDSuffix generates a DID suffix from initial state serialized with scheme
+func generateDIDSuffix(initialState interface{}, scheme canonical.Scheme) (string, error) {
+	data, err := scheme.Marshal(initialState)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal initial state: %w", err)
 	}
 
-	// Hash and encode
-	suffix := crypto.HashToBase64URL(canonical)
-	return suffix, nil
+	// Hash and encode as a multihash
+	return crypto.HashToMultihash(data), nil
+}
+
+// ComputeCreateSuffix computes the DID suffix a create operation commits to
+//
+// The suffix is the hash of the create operation as it was before the DID was known,
+// so InitialDocument.ID and any public key controllers equal to did are cleared
+// before hashing. Pass an empty did for an operation that has no DID yet.
+func ComputeCreateSuffix(op *CreateOperation, did string) (string, error) {
+	return computeCreateSuffix(op, did, canonical.JCS)
+}
+
+// computeCreateSuffix computes the DID suffix a create operation commits to under scheme
+func computeCreateSuffix(op *CreateOperation, did string, scheme canonical.Scheme) (string, error) {
+	operationJSON, err := json.Marshal(op)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal create operation: %w", err)
+	}
+	_, computed, err := createSuffixMatches(operationJSON, did, "", scheme)
+	return computed, err
+}
+
+// createSuffixMatches reports whether suffix is the hash of the create operation
+// operationJSON, serialized with scheme, under the hash algorithm the suffix names.
+// computed is the suffix the operation hashes to under scheme.
+//
+// Under JCS the operation is hashed as received, so members this node does not
+// know about, such as extra JWK members, are covered as the writer hashed them.
+func createSuffixMatches(operationJSON []byte, did, suffix string, scheme canonical.Scheme) (matches bool, computed string, err error) {
+	data, err := createInitialStateJSON(operationJSON, did, scheme)
+	if err != nil {
+		return false, "", err
+	}
+	return crypto.HashMatches(suffix, data), crypto.HashToMultihash(data), nil
+}
+
+// createInitialStateJSON returns the create operation operationJSON as it was before
+// did was known, serialized with scheme
+func createInitialStateJSON(operationJSON []byte, did string, scheme canonical.Scheme) ([]byte, error) {
+	if scheme == canonical.Legacy {
+		// Legacy writers hashed the encoding/json output of the Go value
+		var op CreateOperation
+		if err := json.Unmarshal(operationJSON, &op); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal create operation: %w", err)
+		}
+		initialState, err := createInitialState(&op, did)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(initialState)
+	}
+
+	// Transform rejects duplicate members, which decoding below would silently merge
+	if _, err := canonical.Transform(operationJSON); err != nil {
+		return nil, fmt.Errorf("invalid create operation: %w", err)
+	}
+	var op map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(operationJSON))
+	dec.UseNumber()
+	if err := dec.Decode(&op); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal create operation: %w", err)
+	}
+
+	doc, ok := op["initialDocument"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("create operation has no initial document")
+	}
+	doc["id"] = ""
+	publicKeys, _ := doc["publicKey"].([]interface{})
+	for _, key := range publicKeys {
+		if pk, ok := key.(map[string]interface{}); ok && did != "" && pk["controller"] == did {
+			delete(pk, "controller")
+		}
+	}
+
+	data, err := json.Marshal(op)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal initial state: %w", err)
+	}
+	return canonical.Transform(data)
+}
+
+// createInitialState returns a copy of op as it was before did was known
+func createInitialState(op *CreateOperation, did string) (*CreateOperation, error) {
+	if op.InitialDocument == nil {
+		return nil, fmt.Errorf("create operation has no initial document")
+	}
+
+	doc := *op.InitialDocument
+	doc.ID = ""
+	if op.InitialDocument.PublicKeys != nil {
+		doc.PublicKeys = make([]PublicKey, len(op.InitialDocument.PublicKeys))
+		for i, pk := range op.InitialDocument.PublicKeys {
+			if did != "" && pk.Controller == did {
+				pk.Controller = ""
+			}
+			doc.PublicKeys[i] = pk
+		}
+	}
+
+	initialState := *op
+	initialState.InitialDocument = &doc
+	return &initialState, nil
 }
 
 // FormatDID formats a suffix as a full DID URI
@@ -29,9 +136,11 @@ func FormatDID(suffix string) string {
 }
 
 // ParseDID extracts the suffix from a DID URI
+//
+// Only a bare DID is accepted; use ParseDIDURL for DID URLs.
 func ParseDID(did string) (string, error) {
 	prefixLen := len(DIDPrefix)
-	if len(did) <= prefixLen || did[:prefixLen] != DIDPrefix {
+	if len(did) <= prefixLen || did[:prefixLen] != DIDPrefix || !validMethodSpecificID(did[prefixLen:]) {
 		return "", fmt.Errorf("invalid DID format: %s", did)
 	}
 	return did[prefixLen:], nil
@@ -1,8 +1,17 @@
 package did
 
 import (
+	"context"
+	"encoding/json"
 	"strings"
 	"testing"
+
+	"github.com/yourusername/did-char/pkg/canonical"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+	"github.com/yourusername/did-char/pkg/crypto"
+	"github.com/yourusername/did-char/pkg/encoding"
+	"github.com/yourusername/did-char/pkg/keys"
+	"github.com/yourusername/did-char/pkg/signing"
 )
 
 func TestGenerateDIDSuffix(t *testing.T) {
@@ -44,9 +53,9 @@ func TestGenerateDIDSuffix(t *testing.T) {
 				t.Error("suffix is empty")
 			}
 
-			// Should be 43 chars (SHA256 = 32 bytes = 43 base64url chars without padding)
-			if len(suffix) != 43 {
-				t.Errorf("suffix length = %d, want 43", len(suffix))
+			// Should be 46 chars (SHA-256 multihash = 34 bytes = 46 base64url chars without padding)
+			if len(suffix) != 46 {
+				t.Errorf("suffix length = %d, want 46", len(suffix))
 			}
 
 			// Should not contain padding
@@ -170,6 +179,21 @@ func TestParseDID(t *testing.T) {
 			did:     "did:char",
 			wantErr: true,
 		},
+		{
+			name:    "DID URL",
+			did:     "did:char:abc#key-1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid character",
+			did:     "did:char:abc]",
+			wantErr: true,
+		},
+		{
+			name:    "trailing colon",
+			did:     "did:char:abc:",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -237,3 +261,184 @@ func TestGenerateFormatRoundTrip(t *testing.T) {
 		t.Errorf("round-trip failed: suffix=%q, parsed=%q", suffix, parsedSuffix)
 	}
 }
+
+// newTestCreateOperation builds a create operation and its DID the way CreateDID does
+func newTestCreateOperation(t *testing.T) (*CreateOperation, string) {
+	t.Helper()
+
+	updateKey, err := generateKeyForAlgorithm(signing.AlgES256, "updateKey")
+	if err != nil {
+		t.Fatalf("failed to generate update key: %v", err)
+	}
+	updateCommitment, _, err := GenerateCommitmentFromJWK(updateKey)
+	if err != nil {
+		t.Fatalf("failed to generate commitment: %v", err)
+	}
+	recoveryKey, err := generateKeyForAlgorithm(signing.AlgES256, "recoveryKey")
+	if err != nil {
+		t.Fatalf("failed to generate recovery key: %v", err)
+	}
+	recoveryCommitment, _, err := GenerateCommitmentFromJWK(recoveryKey)
+	if err != nil {
+		t.Fatalf("failed to generate commitment: %v", err)
+	}
+
+	doc := NewDocument("")
+	doc.AddPublicKey(PublicKey{ID: "#key-1", Type: getVerificationKeyType(signing.AlgES256), PublicKeyJwk: getPublicJWK(updateKey)})
+	doc.AddAuthentication("#key-1")
+
+	op := &CreateOperation{
+		Type:               "create",
+		InitialDocument:    doc,
+		UpdateCommitment:   updateCommitment,
+		RecoveryCommitment: recoveryCommitment,
+	}
+	suffix, err := ComputeCreateSuffix(op, "")
+	if err != nil {
+		t.Fatalf("ComputeCreateSuffix failed: %v", err)
+	}
+
+	did := FormatDID(suffix)
+	doc.ID = did
+	doc.PublicKeys[0].Controller = did
+	return op, did
+}
+
+func TestComputeCreateSuffix(t *testing.T) {
+	op, did := newTestCreateOperation(t)
+	suffix, _ := ParseDID(did)
+
+	// The published form (ID and controller filled in) still hashes to the suffix
+	got, err := ComputeCreateSuffix(op, did)
+	if err != nil {
+		t.Fatalf("ComputeCreateSuffix failed: %v", err)
+	}
+	if got != suffix {
+		t.Errorf("suffix = %s, want %s", got, suffix)
+	}
+	if op.InitialDocument.ID != did || op.InitialDocument.PublicKeys[0].Controller != did {
+		t.Error("ComputeCreateSuffix modified the operation")
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(op *CreateOperation)
+	}{
+		{"different update commitment", func(op *CreateOperation) { op.UpdateCommitment = "other" }},
+		{"different recovery commitment", func(op *CreateOperation) { op.RecoveryCommitment = "other" }},
+		{"extra service", func(op *CreateOperation) {
+			op.InitialDocument.AddService(Service{ID: "#svc", Type: "LinkedDomains", ServiceEndpoint: "https://evil.example"})
+		}},
+		{"foreign controller", func(op *CreateOperation) { op.InitialDocument.PublicKeys[0].Controller = "did:char:someone-else" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutated := *op
+			docCopy := *op.InitialDocument
+			docCopy.PublicKeys = append([]PublicKey(nil), op.InitialDocument.PublicKeys...)
+			mutated.InitialDocument = &docCopy
+			tt.mutate(&mutated)
+
+			got, err := ComputeCreateSuffix(&mutated, did)
+			if err != nil {
+				t.Fatalf("ComputeCreateSuffix failed: %v", err)
+			}
+			if got == suffix {
+				t.Error("mutated operation hashed to the original suffix")
+			}
+		})
+	}
+
+	if _, err := ComputeCreateSuffix(&CreateOperation{Type: "create"}, ""); err == nil {
+		t.Error("expected error for missing initial document")
+	}
+}
+
+// TestComputeCreateSuffixVector checks a suffix against the hash, computed
+// independently, of the RFC 8785 serialization of the initial state
+func TestComputeCreateSuffixVector(t *testing.T) {
+	const commitment = "EiB3_pWSQS8HGyj94jfODqtYXzA0_CO9DpbbNIWmb75sbg"
+	op, err := NewCreateOperation(NewDocument(""), commitment, commitment)
+	if err != nil {
+		t.Fatalf("NewCreateOperation failed: %v", err)
+	}
+
+	// {"initialDocument":{"@context":["https://www.w3.org/ns/did/v1"],"id":""},"recoveryCommitment":"...","type":"create","updateCommitment":"..."}
+	want := "did:char:EiBFHF8jdUTDkOXuwQxJPSlCAUyevs6aUvDFs2XjyLMrrA"
+	if op.InitialDocument.ID != want {
+		t.Errorf("DID = %s, want %s", op.InitialDocument.ID, want)
+	}
+}
+
+// TestCreateSuffixCoversUnknownJWKMembers checks that a create operation is hashed as
+// received, so a writer that hashed a JWK member this node does not know about
+// computes the same suffix
+func TestCreateSuffixCoversUnknownJWKMembers(t *testing.T) {
+	env := newTestEnv(t, chartest.Options{})
+	domain := env.cfg.CHAR.AppPreimage
+
+	key, _ := keys.GenerateP256Key()
+	commitment, _, err := GenerateCommitment(key)
+	if err != nil {
+		t.Fatalf("GenerateCommitment failed: %v", err)
+	}
+	var jwk map[string]interface{}
+	jwkJSON, _ := json.Marshal(keys.PublicKeyToJWK(&key.PublicKey, ""))
+	if err := json.Unmarshal(jwkJSON, &jwk); err != nil {
+		t.Fatalf("failed to unmarshal JWK: %v", err)
+	}
+	jwk["use"] = "sig"
+
+	publicKey := map[string]interface{}{"id": "#key-1", "type": "JsonWebKey2020", "publicKeyJwk": jwk}
+	doc := map[string]interface{}{
+		"@context":       []string{ContextDIDCore},
+		"id":             "",
+		"publicKey":      []interface{}{publicKey},
+		"authentication": []string{"#key-1"},
+	}
+	op := map[string]interface{}{
+		"type":               "create",
+		"initialDocument":    doc,
+		"updateCommitment":   commitment,
+		"recoveryCommitment": commitment,
+	}
+	initialState, _ := json.Marshal(op)
+	canonicalState, err := canonical.Transform(initialState)
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+	suffix := crypto.HashToMultihash(canonicalState)
+	did := FormatDID(suffix)
+
+	doc["id"] = did
+	publicKey["controller"] = did
+	anchored, _ := json.Marshal(op)
+
+	// Through the Go structs the extra member is lost, and with it the suffix
+	var parsed CreateOperation
+	if err := json.Unmarshal(anchored, &parsed); err != nil {
+		t.Fatalf("failed to unmarshal create operation: %v", err)
+	}
+	if computed, _ := ComputeCreateSuffix(&parsed, did); computed == suffix {
+		t.Fatal("test operation does not depend on the extra JWK member")
+	}
+
+	payload, err := encoding.EncodeCanonicalPayload([]encoding.Operation{{Type: encoding.OperationTypeCreate, DIDSuffix: suffix, Data: anchored}}, encoding.CompressionNone)
+	if err != nil {
+		t.Fatalf("EncodeCanonicalPayload failed: %v", err)
+	}
+	ballot := env.srv.NextBallot()
+	env.srv.InjectVote(domain, payload, true)
+	env.srv.Advance()
+
+	processor := NewProcessor(env.store, env.client, domain)
+	if outcome, err := processor.ProcessBallot(context.Background(), ballot); err != nil || outcome != BallotApplied {
+		var reasons []string
+		rejected, _ := env.store.GetRejectedOperationsAtBallot(ballot)
+		for _, r := range rejected {
+			reasons = append(reasons, r.Reason)
+		}
+		t.Fatalf("ProcessBallot = %v, %v; rejected: %v", outcome, err, reasons)
+	}
+}
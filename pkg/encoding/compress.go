@@ -0,0 +1,202 @@
+package encoding
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression identifies how the operations of a compressed payload are compressed
+type Compression byte
+
+const (
+	CompressionNone    Compression = 0x00
+	CompressionDeflate Compression = 0x01 // RFC 1951 raw deflate
+	CompressionZstd    Compression = 0x02 // RFC 8878 Zstandard frame
+)
+
+// String returns the compression name as used in configuration
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionDeflate:
+		return "deflate"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("Compression(%d)", byte(c))
+	}
+}
+
+// ParseCompression parses a compression name; the empty string means none
+func ParseCompression(name string) (Compression, error) {
+	switch name {
+	case "", "none":
+		return CompressionNone, nil
+	case "deflate":
+		return CompressionDeflate, nil
+	case "zstd":
+		return CompressionZstd, nil
+	default:
+		return 0, fmt.Errorf("unknown compression %q (want none, deflate or zstd)", name)
+	}
+}
+
+// EncodeCompressedPayload encodes a batch of DID operations as a compressed payload (hex string)
+func EncodeCompressedPayload(ops []Operation, compression Compression) (string, error) {
+	data, err := EncodeCompressedBatch(ops, compression)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// EncodeCompressedBatch encodes a batch of DID operations in the compressed batch format
+//
+// Format: [version=0x04][compression][varint body length][body], where the body,
+// once decompressed, is a version 0x02 batch without its version byte. The body
+// length is that of the decompressed body, so decoders can refuse oversized
+// payloads before decompressing them.
+func EncodeCompressedBatch(ops []Operation, compression Compression) ([]byte, error) {
+	return encodeCompressedBatch(PayloadVersionCompressed, ops, compression)
+}
+
+// EncodeCanonicalPayload encodes a batch of DID operations hashed over RFC 8785 JSON (hex string)
+func EncodeCanonicalPayload(ops []Operation, compression Compression) (string, error) {
+	data, err := EncodeCanonicalBatch(ops, compression)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// EncodeCanonicalBatch encodes a batch of DID operations whose hashes are computed over RFC 8785 JSON
+//
+// The format is that of EncodeCompressedBatch with version 0x05; see SchemeOf.
+func EncodeCanonicalBatch(ops []Operation, compression Compression) ([]byte, error) {
+	return encodeCompressedBatch(PayloadVersionCanonical, ops, compression)
+}
+
+// encodeCompressedBatch encodes ops in the compressed batch format with the given version
+func encodeCompressedBatch(version byte, ops []Operation, compression Compression) ([]byte, error) {
+	batch, err := EncodeBatch(ops)
+	if err != nil {
+		return nil, err
+	}
+	body := batch[1:]
+
+	compressed, err := compress(body, compression)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(version)
+	buf.WriteByte(byte(compression))
+	if err := writeVarint(buf, uint64(len(body))); err != nil {
+		return nil, err
+	}
+	buf.Write(compressed)
+
+	return buf.Bytes(), nil
+}
+
+// decodeCompressedBatch reads the operations of a compressed payload following its version byte
+func (l Limits) decodeCompressedBatch(buf *bytes.Reader) ([]Operation, error) {
+	compressionByte, err := buf.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compression: %w", err)
+	}
+	compression := Compression(compressionByte)
+
+	bodyLen, err := readVarint(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body length: %w", err)
+	}
+	if err := l.checkPayloadSize(bodyLen); err != nil {
+		return nil, err
+	}
+
+	body, err := decompress(buf, compression, int64(bodyLen))
+	if err != nil {
+		return nil, err
+	}
+	return l.decodeBatch(bytes.NewReader(body))
+}
+
+// compress compresses data with the given compression
+func compress(data []byte, compression Compression) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+
+	case CompressionDeflate:
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.BestCompression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create deflate writer: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to deflate: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to deflate: %w", err)
+		}
+		return buf.Bytes(), nil
+
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+		}
+		defer enc.Close()
+		return enc.EncodeAll(data, nil), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", byte(compression))
+	}
+}
+
+// decompress reads exactly size decompressed bytes from r, failing if the stream
+// holds more or less, without ever buffering more than size bytes
+func decompress(r io.Reader, compression Compression, size int64) ([]byte, error) {
+	var body io.Reader
+	switch compression {
+	case CompressionNone:
+		body = r
+
+	case CompressionDeflate:
+		fr := flate.NewReader(r)
+		defer fr.Close()
+		body = fr
+
+	case CompressionZstd:
+		dec, err := zstd.NewReader(r,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderLowmem(true),
+			zstd.WithDecoderMaxMemory(uint64(size)+1),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+		}
+		defer dec.Close()
+		body = dec
+
+	default:
+		return nil, fmt.Errorf("unsupported compression %d", byte(compression))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, size+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s body: %w", compression, err)
+	}
+	if int64(len(data)) != size {
+		return nil, fmt.Errorf("%s body is not %d bytes as declared", compression, size)
+	}
+	return data, nil
+}
@@ -0,0 +1,123 @@
+package encoding
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestCompressedPayloadRoundTrip(t *testing.T) {
+	ops := []Operation{
+		{Type: OperationTypeCreate, DIDSuffix: "EiA", Data: []byte(`{"type":"create","delta":"` + strings.Repeat("abc", 500) + `"}`)},
+		{Type: OperationTypeDeactivate, DIDSuffix: "EiB", Data: []byte(`{"type":"deactivate"}`)},
+	}
+	plain, _ := EncodeBatchPayload(ops)
+
+	for _, compression := range []Compression{CompressionNone, CompressionDeflate, CompressionZstd} {
+		t.Run(compression.String(), func(t *testing.T) {
+			encoded, err := EncodeCompressedPayload(ops, compression)
+			if err != nil {
+				t.Fatalf("EncodeCompressedPayload failed: %v", err)
+			}
+			if compression != CompressionNone && len(encoded) >= len(plain) {
+				t.Errorf("compressed payload is %d hex chars, batch payload %d", len(encoded), len(plain))
+			}
+
+			version, decoded, err := DecodeOperations(encoded)
+			if err != nil {
+				t.Fatalf("DecodeOperations failed: %v", err)
+			}
+			if version != PayloadVersionCompressed {
+				t.Errorf("version = %d, want %d", version, PayloadVersionCompressed)
+			}
+			if len(decoded) != len(ops) {
+				t.Fatalf("decoded %d operations, want %d", len(decoded), len(ops))
+			}
+			for i, op := range decoded {
+				if op.Type != ops[i].Type || op.DIDSuffix != ops[i].DIDSuffix || string(op.Data) != string(ops[i].Data) {
+					t.Errorf("operation %d = %+v, want %+v", i, op, ops[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCompressedPayloadBodyLength(t *testing.T) {
+	ops := []Operation{{Type: OperationTypeCreate, DIDSuffix: "x", Data: []byte(strings.Repeat("{}", 1000))}}
+	batch, _ := EncodeBatch(ops)
+	body := batch[1:]
+
+	var deflated bytes.Buffer
+	w, _ := flate.NewWriter(&deflated, flate.BestCompression)
+	w.Write(body)
+	w.Close()
+
+	withLength := func(n int) string {
+		buf := new(bytes.Buffer)
+		buf.WriteByte(PayloadVersionCompressed)
+		buf.WriteByte(byte(CompressionDeflate))
+		writeVarint(buf, uint64(n))
+		buf.Write(deflated.Bytes())
+		return hex.EncodeToString(buf.Bytes())
+	}
+
+	if _, _, err := DecodeOperations(withLength(len(body))); err != nil {
+		t.Fatalf("DecodeOperations failed: %v", err)
+	}
+
+	// A body that inflates past its declared length is refused without being buffered
+	if _, _, err := DecodeOperations(withLength(len(body) - 1)); err == nil {
+		t.Error("expected body longer than declared to be refused")
+	}
+	if _, _, err := DecodeOperations(withLength(len(body) + 1)); err == nil {
+		t.Error("expected body shorter than declared to be refused")
+	}
+
+	limits := Limits{MaxPayloadSize: len(body) - 1}
+	_, _, err := limits.DecodeOperations(withLength(len(body)))
+	if _, ok := err.(*LimitError); !ok {
+		t.Errorf("err = %v, want *LimitError", err)
+	}
+}
+
+func TestCompressedPayloadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing compression", "04"},
+		{"unknown compression", "040905"},
+		{"missing body length", "0400"},
+		{"huge body length", "0400ffffffffffffffffff01"},
+		{"corrupt deflate", "040105ffffffffff"},
+		{"corrupt zstd", "040205ffffffffff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := DecodeOperations(tt.input); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	for _, name := range []string{"none", "deflate", "zstd"} {
+		c, err := ParseCompression(name)
+		if err != nil {
+			t.Fatalf("ParseCompression(%q) failed: %v", name, err)
+		}
+		if c.String() != name {
+			t.Errorf("ParseCompression(%q) = %s", name, c)
+		}
+	}
+	if c, err := ParseCompression(""); err != nil || c != CompressionNone {
+		t.Errorf("ParseCompression(\"\") = %s, %v", c, err)
+	}
+	if _, err := ParseCompression("gzip"); err == nil {
+		t.Error("expected error for unknown compression")
+	}
+}
@@ -0,0 +1,99 @@
+package encoding
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Limits bounds the sizes a decoder accepts from an untrusted payload
+//
+// Lengths are checked against the limits and against the bytes actually present
+// before anything is allocated, so a payload claiming a huge length costs nothing.
+// A zero field takes its value from DefaultLimits.
+type Limits struct {
+	MaxSuffixSize    int // Bytes in a DID suffix
+	MaxOperationSize int // Bytes of operation JSON
+	MaxPayloadSize   int // Bytes of a payload or batch, after decompression
+}
+
+// DefaultLimits are the limits used by the package-level decoding functions
+var DefaultLimits = Limits{
+	MaxSuffixSize:    256,
+	MaxOperationSize: 256 << 10,
+	MaxPayloadSize:   16 << 20,
+}
+
+// LimitError reports a length in a payload that exceeds a Limits maximum
+type LimitError struct {
+	What  string // What was too large, e.g. "operation 3 data"
+	Size  uint64 // Size claimed by the payload
+	Limit int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %d bytes exceeds limit of %d bytes", e.What, e.Size, e.Limit)
+}
+
+// withDefaults fills zero fields from DefaultLimits
+func (l Limits) withDefaults() Limits {
+	if l.MaxSuffixSize <= 0 {
+		l.MaxSuffixSize = DefaultLimits.MaxSuffixSize
+	}
+	if l.MaxOperationSize <= 0 {
+		l.MaxOperationSize = DefaultLimits.MaxOperationSize
+	}
+	if l.MaxPayloadSize <= 0 {
+		l.MaxPayloadSize = DefaultLimits.MaxPayloadSize
+	}
+	return l
+}
+
+// CheckOperation reports whether op fits within the limits, so writers can refuse
+// an operation every reader would reject
+func (l Limits) CheckOperation(op Operation) error {
+	l = l.withDefaults()
+	if len(op.DIDSuffix) > l.MaxSuffixSize {
+		return &LimitError{What: "DID suffix", Size: uint64(len(op.DIDSuffix)), Limit: l.MaxSuffixSize}
+	}
+	if len(op.Data) > l.MaxOperationSize {
+		return &LimitError{What: "operation data", Size: uint64(len(op.Data)), Limit: l.MaxOperationSize}
+	}
+	return nil
+}
+
+// CheckPayload checks the size of a hex payload before it is decoded
+//
+// The decoding functions check it themselves; payloads handled another way, such as
+// anchors, are checked with this.
+func (l Limits) CheckPayload(hexData string) error {
+	return l.withDefaults().checkPayloadSize(uint64(len(hexData) / 2))
+}
+
+// checkPayloadSize checks the size of a whole payload or batch
+func (l Limits) checkPayloadSize(size uint64) error {
+	if size > uint64(l.MaxPayloadSize) {
+		return &LimitError{What: "payload", Size: size, Limit: l.MaxPayloadSize}
+	}
+	return nil
+}
+
+// readBytes reads a length-prefixed field of at most limit bytes
+func readBytes(buf *bytes.Reader, what string, limit int) ([]byte, error) {
+	n, err := readVarint(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s length: %w", what, err)
+	}
+	if n > uint64(limit) {
+		return nil, &LimitError{What: what, Size: n, Limit: limit}
+	}
+	if n > uint64(buf.Len()) {
+		return nil, fmt.Errorf("failed to read %s: %w", what, io.ErrUnexpectedEOF)
+	}
+
+	field := make([]byte, n)
+	if _, err := io.ReadFull(buf, field); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	return field, nil
+}
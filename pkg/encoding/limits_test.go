@@ -0,0 +1,60 @@
+package encoding
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimitsRefuseOversizedFields(t *testing.T) {
+	limits := Limits{MaxSuffixSize: 8, MaxOperationSize: 16, MaxPayloadSize: 256}
+
+	single, _ := EncodePayload(OperationTypeCreate, strings.Repeat("s", 9), map[string]string{})
+	batch, _ := EncodeBatchPayload([]Operation{
+		{Type: OperationTypeCreate, DIDSuffix: "a", Data: []byte("{}")},
+		{Type: OperationTypeUpdate, DIDSuffix: "b", Data: []byte(`{"delta":"` + strings.Repeat("d", 16) + `"}`)},
+	})
+	large, _ := EncodeBatchPayload([]Operation{{Type: OperationTypeCreate, DIDSuffix: "a", Data: []byte(strings.Repeat("x", 300))}})
+
+	tests := []struct {
+		name    string
+		payload string
+		what    string
+	}{
+		{"suffix", single, "suffix"},
+		{"operation data", batch, "operation 1 data"},
+		{"payload", large, "payload"},
+		// Lengths far beyond the payload are refused before allocating
+		{"claimed JSON length", "01010161ffffffffffffffff7f", "JSON"},
+		{"claimed suffix length", "0201ffffffffffffffff7f", "operation 0 suffix"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := limits.DecodeOperations(tt.payload)
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("err = %v, want *LimitError", err)
+			}
+			if limitErr.What != tt.what {
+				t.Errorf("What = %q, want %q", limitErr.What, tt.what)
+			}
+		})
+	}
+}
+
+func TestLimitsDefaults(t *testing.T) {
+	// Zero fields fall back to DefaultLimits
+	ops := []Operation{{Type: OperationTypeCreate, DIDSuffix: "a", Data: []byte(strings.Repeat("x", DefaultLimits.MaxOperationSize+1))}}
+	payload, _ := EncodeBatchPayload(ops)
+
+	if _, _, err := (Limits{}).DecodeOperations(payload); err == nil {
+		t.Error("expected default operation size limit to apply")
+	}
+	if err := (Limits{}).CheckOperation(ops[0]); err == nil {
+		t.Error("expected CheckOperation to refuse an oversized operation")
+	}
+	if err := (Limits{}).CheckOperation(Operation{DIDSuffix: "a", Data: []byte("{}")}); err != nil {
+		t.Errorf("CheckOperation failed: %v", err)
+	}
+}
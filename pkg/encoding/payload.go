@@ -7,9 +7,30 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+
+	"github.com/yourusername/did-char/pkg/canonical"
+)
+
+// Payload versions
+const (
+	PayloadVersion       byte = 0x01 // A single operation
+	PayloadVersionBatch  byte = 0x02 // A batch of operations
+	PayloadVersionAnchor byte = 0x03 // The content hash of a batch held in a content store
+
+	PayloadVersionCompressed byte = 0x04 // A batch of operations, optionally compressed
+	PayloadVersionCanonical  byte = 0x05 // As PayloadVersionCompressed, with hashes over RFC 8785 JSON
 )
 
-const PayloadVersion byte = 0x01
+// SchemeOf returns how operation JSON is serialized for hashing in payloads of the given version
+//
+// Operations in payloads before PayloadVersionCanonical were hashed over the
+// encoding/json output of the decoded operation, and are still checked that way.
+func SchemeOf(version byte) canonical.Scheme {
+	if version >= PayloadVersionCanonical {
+		return canonical.JCS
+	}
+	return canonical.Legacy
+}
 
 // OperationType represents the type of DID operation
 type OperationType byte
@@ -56,8 +77,18 @@ func EncodePayload(opType OperationType, didSuffix string, operationData interfa
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
-// DecodePayload decodes a binary payload into operation components
+// DecodePayload decodes a binary payload into operation components, within DefaultLimits
 func DecodePayload(hexData string) (version byte, opType OperationType, didSuffix string, operationJSON []byte, err error) {
+	return DefaultLimits.DecodePayload(hexData)
+}
+
+// DecodePayload decodes a binary payload into operation components, within the limits
+func (l Limits) DecodePayload(hexData string) (version byte, opType OperationType, didSuffix string, operationJSON []byte, err error) {
+	l = l.withDefaults()
+	if err := l.checkPayloadSize(uint64(len(hexData) / 2)); err != nil {
+		return 0, 0, "", nil, err
+	}
+
 	data, err := hex.DecodeString(hexData)
 	if err != nil {
 		return 0, 0, "", nil, fmt.Errorf("invalid hex: %w", err)
@@ -79,29 +110,288 @@ func DecodePayload(hexData string) (version byte, opType OperationType, didSuffi
 	opType = OperationType(opTypeByte)
 
 	// DID suffix
-	suffixLen, err := readVarint(buf)
+	suffixBytes, err := readBytes(buf, "suffix", l.MaxSuffixSize)
 	if err != nil {
-		return 0, 0, "", nil, fmt.Errorf("failed to read suffix length: %w", err)
-	}
-	suffixBytes := make([]byte, suffixLen)
-	if _, err := io.ReadFull(buf, suffixBytes); err != nil {
-		return 0, 0, "", nil, fmt.Errorf("failed to read suffix: %w", err)
+		return 0, 0, "", nil, err
 	}
 	didSuffix = string(suffixBytes)
 
 	// Operation JSON
-	jsonLen, err := readVarint(buf)
+	operationJSON, err = readBytes(buf, "JSON", l.MaxOperationSize)
 	if err != nil {
-		return 0, 0, "", nil, fmt.Errorf("failed to read JSON length: %w", err)
-	}
-	operationJSON = make([]byte, jsonLen)
-	if _, err := io.ReadFull(buf, operationJSON); err != nil {
-		return 0, 0, "", nil, fmt.Errorf("failed to read JSON: %w", err)
+		return 0, 0, "", nil, err
 	}
 
 	return version, opType, didSuffix, operationJSON, nil
 }
 
+// Operation is one DID operation carried in a payload
+type Operation struct {
+	Type      OperationType
+	DIDSuffix string
+	Data      []byte // Operation JSON
+}
+
+// NewOperation builds an Operation, marshaling operationData to JSON
+func NewOperation(opType OperationType, didSuffix string, operationData interface{}) (Operation, error) {
+	jsonBytes, err := json.Marshal(operationData)
+	if err != nil {
+		return Operation{}, fmt.Errorf("failed to marshal operation data: %w", err)
+	}
+	return Operation{Type: opType, DIDSuffix: didSuffix, Data: jsonBytes}, nil
+}
+
+// EncodeBatchPayload encodes several DID operations into one binary payload (hex string)
+//
+// Format: [version=0x02][varint count], then for each operation in order:
+// [varint suffix length][suffix][operation type][varint data length][data].
+// Operations are applied in the order they are encoded.
+func EncodeBatchPayload(ops []Operation) (string, error) {
+	data, err := EncodeBatch(ops)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// EncodeBatch encodes several DID operations into the binary batch format
+//
+// The result is the batch payload as bytes; it is also the content an anchor payload refers to.
+func EncodeBatch(ops []Operation) ([]byte, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("batch has no operations")
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(PayloadVersionBatch)
+
+	if err := writeVarint(buf, uint64(len(ops))); err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		suffixBytes := []byte(op.DIDSuffix)
+		if err := writeVarint(buf, uint64(len(suffixBytes))); err != nil {
+			return nil, err
+		}
+		buf.Write(suffixBytes)
+
+		buf.WriteByte(byte(op.Type))
+
+		if err := writeVarint(buf, uint64(len(op.Data))); err != nil {
+			return nil, err
+		}
+		buf.Write(op.Data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DecodeBatch decodes a batch encoded by EncodeBatch, EncodeCompressedBatch or EncodeCanonicalBatch,
+// within DefaultLimits
+func DecodeBatch(data []byte) ([]Operation, error) {
+	return DefaultLimits.DecodeBatch(data)
+}
+
+// DecodeBatch decodes a batch encoded by EncodeBatch, EncodeCompressedBatch or EncodeCanonicalBatch,
+// within the limits
+func (l Limits) DecodeBatch(data []byte) ([]Operation, error) {
+	l = l.withDefaults()
+	if err := l.checkPayloadSize(uint64(len(data))); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("failed to read version: %w", io.EOF)
+	}
+
+	switch data[0] {
+	case PayloadVersionBatch:
+		return l.decodeBatch(bytes.NewReader(data[1:]))
+	case PayloadVersionCompressed, PayloadVersionCanonical:
+		return l.decodeCompressedBatch(bytes.NewReader(data[1:]))
+	default:
+		return nil, fmt.Errorf("unsupported batch version %d", data[0])
+	}
+}
+
+// PayloadVersionOf returns the version byte of a payload
+func PayloadVersionOf(hexData string) (byte, error) {
+	if len(hexData) < 2 {
+		return 0, fmt.Errorf("failed to read version: %w", io.EOF)
+	}
+	version, err := hex.DecodeString(hexData[:2])
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex: %w", err)
+	}
+	return version[0], nil
+}
+
+// DecodeOperations decodes a single-operation or batch payload into its operations, in order,
+// within DefaultLimits
+//
+// Anchor payloads carry no operations; decode them with DecodeAnchorPayload.
+func DecodeOperations(hexData string) (version byte, ops []Operation, err error) {
+	return DefaultLimits.DecodeOperations(hexData)
+}
+
+// DecodeOperations decodes a single-operation or batch payload into its operations, in order,
+// within the limits
+func (l Limits) DecodeOperations(hexData string) (version byte, ops []Operation, err error) {
+	l = l.withDefaults()
+	if err := l.checkPayloadSize(uint64(len(hexData) / 2)); err != nil {
+		return 0, nil, err
+	}
+
+	data, err := hex.DecodeString(hexData)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid hex: %w", err)
+	}
+	if len(data) == 0 {
+		return 0, nil, fmt.Errorf("failed to read version: %w", io.EOF)
+	}
+
+	version = data[0]
+	switch version {
+	case PayloadVersion:
+		_, opType, didSuffix, operationJSON, err := l.DecodePayload(hexData)
+		if err != nil {
+			return version, nil, err
+		}
+		return version, []Operation{{Type: opType, DIDSuffix: didSuffix, Data: operationJSON}}, nil
+
+	case PayloadVersionBatch, PayloadVersionCompressed, PayloadVersionCanonical:
+		ops, err := l.DecodeBatch(data)
+		if err != nil {
+			return version, nil, err
+		}
+		return version, ops, nil
+
+	default:
+		return version, nil, fmt.Errorf("unsupported payload version %d", version)
+	}
+}
+
+// maxAnchorOperations bounds the operation count an anchor may claim, keeping it within an int
+const maxAnchorOperations = 1 << 24
+
+// Anchor is the content of an anchor payload: a batch stored outside the vote
+type Anchor struct {
+	ContentHash    string // Hash of the batch bytes in the content store
+	OperationCount int    // Number of operations in the batch
+}
+
+// EncodeAnchorPayload encodes an anchor into a binary payload (hex string)
+//
+// Format: [version=0x03][varint operation count][varint hash length][content hash].
+func EncodeAnchorPayload(anchor Anchor) (string, error) {
+	if anchor.OperationCount <= 0 {
+		return "", fmt.Errorf("anchor has no operations")
+	}
+	if anchor.ContentHash == "" {
+		return "", fmt.Errorf("anchor has no content hash")
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(PayloadVersionAnchor)
+
+	if err := writeVarint(buf, uint64(anchor.OperationCount)); err != nil {
+		return "", err
+	}
+	hashBytes := []byte(anchor.ContentHash)
+	if err := writeVarint(buf, uint64(len(hashBytes))); err != nil {
+		return "", err
+	}
+	buf.Write(hashBytes)
+
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeAnchorPayload decodes an anchor payload
+func DecodeAnchorPayload(hexData string) (Anchor, error) {
+	data, err := hex.DecodeString(hexData)
+	if err != nil {
+		return Anchor{}, fmt.Errorf("invalid hex: %w", err)
+	}
+
+	buf := bytes.NewReader(data)
+	version, err := buf.ReadByte()
+	if err != nil {
+		return Anchor{}, fmt.Errorf("failed to read version: %w", err)
+	}
+	if version != PayloadVersionAnchor {
+		return Anchor{}, fmt.Errorf("not an anchor payload: version %d", version)
+	}
+
+	count, err := readVarint(buf)
+	if err != nil {
+		return Anchor{}, fmt.Errorf("failed to read operation count: %w", err)
+	}
+	if count == 0 || count > maxAnchorOperations {
+		return Anchor{}, fmt.Errorf("invalid operation count %d", count)
+	}
+
+	hashLen, err := readVarint(buf)
+	if err != nil {
+		return Anchor{}, fmt.Errorf("failed to read hash length: %w", err)
+	}
+	if hashLen == 0 || hashLen > uint64(buf.Len()) {
+		return Anchor{}, fmt.Errorf("invalid content hash length %d", hashLen)
+	}
+	hashBytes := make([]byte, hashLen)
+	if _, err := io.ReadFull(buf, hashBytes); err != nil {
+		return Anchor{}, fmt.Errorf("failed to read content hash: %w", err)
+	}
+	if buf.Len() != 0 {
+		return Anchor{}, fmt.Errorf("%d trailing bytes after content hash", buf.Len())
+	}
+
+	return Anchor{ContentHash: string(hashBytes), OperationCount: int(count)}, nil
+}
+
+// decodeBatch reads the operations of a batch payload following its version byte
+func (l Limits) decodeBatch(buf *bytes.Reader) ([]Operation, error) {
+	count, err := readVarint(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operation count: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("batch has no operations")
+	}
+	// Every operation takes at least three bytes
+	if count > uint64(buf.Len())/3 {
+		return nil, fmt.Errorf("batch claims %d operations in %d bytes", count, buf.Len())
+	}
+
+	ops := make([]Operation, 0, count)
+	for i := uint64(0); i < count; i++ {
+		suffixBytes, err := readBytes(buf, fmt.Sprintf("operation %d suffix", i), l.MaxSuffixSize)
+		if err != nil {
+			return nil, err
+		}
+
+		opTypeByte, err := buf.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: failed to read operation type: %w", i, err)
+		}
+
+		opData, err := readBytes(buf, fmt.Sprintf("operation %d data", i), l.MaxOperationSize)
+		if err != nil {
+			return nil, err
+		}
+
+		ops = append(ops, Operation{
+			Type:      OperationType(opTypeByte),
+			DIDSuffix: string(suffixBytes),
+			Data:      opData,
+		})
+	}
+
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after %d operations", buf.Len(), count)
+	}
+
+	return ops, nil
+}
+
 // writeVarint writes a varint to the buffer
 func writeVarint(buf *bytes.Buffer, n uint64) error {
 	tmp := make([]byte, binary.MaxVarintLen64)
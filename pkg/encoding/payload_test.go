@@ -7,6 +7,9 @@ import (
 	"testing"
 )
 
+// wideLimits admits the long suffixes used to exercise varint lengths
+var wideLimits = Limits{MaxSuffixSize: 1 << 20}
+
 func TestEncodeDecodeRoundTrip(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -66,7 +69,7 @@ func TestEncodeDecodeRoundTrip(t *testing.T) {
 			}
 
 			// Decode
-			version, opType, didSuffix, opJSON, err := DecodePayload(encoded)
+			version, opType, didSuffix, opJSON, err := wideLimits.DecodePayload(encoded)
 			if err != nil {
 				t.Fatalf("DecodePayload failed: %v", err)
 			}
@@ -198,7 +201,7 @@ func TestVarintEdgeCases(t *testing.T) {
 				t.Fatalf("EncodePayload failed: %v", err)
 			}
 
-			_, _, decodedSuffix, _, err := DecodePayload(encoded)
+			_, _, decodedSuffix, _, err := wideLimits.DecodePayload(encoded)
 			if err != nil {
 				t.Fatalf("DecodePayload failed: %v", err)
 			}
@@ -324,3 +327,134 @@ func TestEncodePayloadDeterminism(t *testing.T) {
 		t.Error("EncodePayload is not deterministic")
 	}
 }
+
+func TestBatchPayloadRoundTrip(t *testing.T) {
+	ops := []Operation{
+		{Type: OperationTypeCreate, DIDSuffix: "EiDfirst", Data: []byte(`{"type":"create"}`)},
+		{Type: OperationTypeUpdate, DIDSuffix: "EiDsecond", Data: []byte(`{"type":"update","patches":[]}`)},
+		{Type: OperationTypeDeactivate, DIDSuffix: "", Data: []byte(`{}`)},
+	}
+
+	encoded, err := EncodeBatchPayload(ops)
+	if err != nil {
+		t.Fatalf("EncodeBatchPayload failed: %v", err)
+	}
+
+	version, decoded, err := DecodeOperations(encoded)
+	if err != nil {
+		t.Fatalf("DecodeOperations failed: %v", err)
+	}
+	if version != PayloadVersionBatch {
+		t.Errorf("version = %d, want %d", version, PayloadVersionBatch)
+	}
+	if len(decoded) != len(ops) {
+		t.Fatalf("decoded %d operations, want %d", len(decoded), len(ops))
+	}
+	for i, op := range decoded {
+		if op.Type != ops[i].Type || op.DIDSuffix != ops[i].DIDSuffix || string(op.Data) != string(ops[i].Data) {
+			t.Errorf("operation %d = %+v, want %+v", i, op, ops[i])
+		}
+	}
+}
+
+func TestDecodeOperationsSinglePayload(t *testing.T) {
+	encoded, err := EncodePayload(OperationTypeRecover, "abc", map[string]string{"k": "v"})
+	if err != nil {
+		t.Fatalf("EncodePayload failed: %v", err)
+	}
+
+	version, ops, err := DecodeOperations(encoded)
+	if err != nil {
+		t.Fatalf("DecodeOperations failed: %v", err)
+	}
+	if version != PayloadVersion || len(ops) != 1 {
+		t.Fatalf("version %d with %d operations, want version 1 with 1", version, len(ops))
+	}
+	if ops[0].Type != OperationTypeRecover || ops[0].DIDSuffix != "abc" || string(ops[0].Data) != `{"k":"v"}` {
+		t.Errorf("unexpected operation: %+v", ops[0])
+	}
+}
+
+func TestDecodeOperationsErrors(t *testing.T) {
+	valid, _ := EncodeBatchPayload([]Operation{{Type: OperationTypeCreate, DIDSuffix: "x", Data: []byte("{}")}})
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"invalid hex", "zz"},
+		{"unknown version", "03010178027b7d"},
+		{"no operations", "0200"},
+		{"missing count", "02"},
+		{"truncated suffix", "02010578"},
+		{"missing type", "02010178"},
+		{"truncated data", "0201017801057b"},
+		{"count exceeds operations", "0202" + valid[4:]},
+		{"trailing bytes", valid + "00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := DecodeOperations(tt.input); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	if _, err := EncodeBatchPayload(nil); err == nil {
+		t.Error("expected error encoding an empty batch")
+	}
+}
+
+func TestAnchorPayloadRoundTrip(t *testing.T) {
+	anchor := Anchor{ContentHash: "n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgg", OperationCount: 300}
+
+	encoded, err := EncodeAnchorPayload(anchor)
+	if err != nil {
+		t.Fatalf("EncodeAnchorPayload failed: %v", err)
+	}
+	if version, _ := PayloadVersionOf(encoded); version != PayloadVersionAnchor {
+		t.Errorf("version = %d, want %d", version, PayloadVersionAnchor)
+	}
+
+	decoded, err := DecodeAnchorPayload(encoded)
+	if err != nil {
+		t.Fatalf("DecodeAnchorPayload failed: %v", err)
+	}
+	if decoded != anchor {
+		t.Errorf("decoded = %+v, want %+v", decoded, anchor)
+	}
+
+	// Anchors carry no operations of their own
+	if _, _, err := DecodeOperations(encoded); err == nil {
+		t.Error("expected DecodeOperations to refuse an anchor payload")
+	}
+}
+
+func TestDecodeAnchorPayloadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"batch version", "0201017801027b7d"},
+		{"no operations", "03000178"},
+		{"missing hash", "0301"},
+		{"empty hash", "030100"},
+		{"truncated hash", "03010578"},
+		{"trailing bytes", "0301017800"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeAnchorPayload(tt.input); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	if _, err := EncodeAnchorPayload(Anchor{ContentHash: "x"}); err == nil {
+		t.Error("expected error encoding an anchor without operations")
+	}
+}
@@ -12,23 +12,23 @@ import (
 	"github.com/yourusername/did-char/pkg/crypto"
 )
 
-// JWK represents a JSON Web Key supporting EC (P-256), OKP (Ed25519, BLS12-381)
+// JWK represents a JSON Web Key supporting EC (P-256, secp256k1), OKP (Ed25519, BLS12-381, x-only secp256k1)
 type JWK struct {
 	ID  string `json:"id,omitempty"`
-	Kty string `json:"kty"`           // "EC" for ECDSA, "OKP" for Ed25519/BLS
-	Crv string `json:"crv"`           // "P-256", "Ed25519", or "BLS12-381-G1"
-	Alg string `json:"alg,omitempty"` // "ES256", "EdDSA", or "BLS"
+	Kty string `json:"kty"`           // "EC" for ECDSA, "OKP" for Ed25519/BLS/Schnorr
+	Crv string `json:"crv"`           // "P-256", "secp256k1", "Ed25519", or "BLS12-381-G1"
+	Alg string `json:"alg,omitempty"` // "ES256", "ES256K", "EdDSA", "BLS", or "BIP340"
 	X   string `json:"x"`
-	Y   string `json:"y,omitempty"`   // Not used for Ed25519/BLS
+	Y   string `json:"y,omitempty"`   // Not used for Ed25519/BLS/Schnorr
 	D   string `json:"d,omitempty"`   // Private key (omit for public)
 }
 
-// GenerateSecp256k1Key generates a new secp256k1 key pair
-func GenerateSecp256k1Key() (*ecdsa.PrivateKey, error) {
+// GenerateP256Key generates a new P-256 key pair
+func GenerateP256Key() (*ecdsa.PrivateKey, error) {
 	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 }
 
-// PrivateKeyToJWK converts an ECDSA private key to JWK
+// PrivateKeyToJWK converts an ECDSA P-256 private key to JWK
 func PrivateKeyToJWK(key *ecdsa.PrivateKey, keyID string) *JWK {
 	return &JWK{
 		ID:  keyID,
@@ -40,7 +40,7 @@ func PrivateKeyToJWK(key *ecdsa.PrivateKey, keyID string) *JWK {
 	}
 }
 
-// PublicKeyToJWK converts an ECDSA public key to JWK
+// PublicKeyToJWK converts an ECDSA P-256 public key to JWK
 func PublicKeyToJWK(key *ecdsa.PublicKey, keyID string) *JWK {
 	return &JWK{
 		ID:  keyID,
@@ -51,7 +51,7 @@ func PublicKeyToJWK(key *ecdsa.PublicKey, keyID string) *JWK {
 	}
 }
 
-// JWKToPrivateKey converts a JWK to an ECDSA private key
+// JWKToPrivateKey converts a P-256 JWK to an ECDSA private key
 func JWKToPrivateKey(jwk *JWK) (*ecdsa.PrivateKey, error) {
 	if jwk.D == "" {
 		return nil, fmt.Errorf("JWK does not contain private key (d)")
@@ -87,7 +87,7 @@ func JWKToPrivateKey(jwk *JWK) (*ecdsa.PrivateKey, error) {
 	}, nil
 }
 
-// JWKToPublicKey converts a JWK to an ECDSA public key
+// JWKToPublicKey converts a P-256 JWK to an ECDSA public key
 func JWKToPublicKey(jwk *JWK) (*ecdsa.PublicKey, error) {
 	xBytes, err := crypto.Base64URLDecode(jwk.X)
 	if err != nil {
@@ -9,7 +9,7 @@ import (
 
 func TestEC256KeyRoundTrip(t *testing.T) {
 	// Generate key
-	privateKey, err := GenerateSecp256k1Key()
+	privateKey, err := GenerateP256Key()
 	if err != nil {
 		t.Fatalf("failed to generate key: %v", err)
 	}
@@ -40,7 +40,7 @@ func TestEC256KeyRoundTrip(t *testing.T) {
 
 func TestEC256PublicKeyRoundTrip(t *testing.T) {
 	// Generate key
-	privateKey, err := GenerateSecp256k1Key()
+	privateKey, err := GenerateP256Key()
 	if err != nil {
 		t.Fatalf("failed to generate key: %v", err)
 	}
@@ -63,6 +63,94 @@ func TestEC256PublicKeyRoundTrip(t *testing.T) {
 	}
 }
 
+func TestSecp256k1KeyRoundTrip(t *testing.T) {
+	// Generate key
+	privateKey, err := GenerateSecp256k1Key()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// Convert to JWK
+	jwk := Secp256k1PrivateKeyToJWK(privateKey, "k1-key-1")
+	if jwk.Kty != "EC" {
+		t.Errorf("wrong kty: %s", jwk.Kty)
+	}
+	if jwk.Crv != "secp256k1" {
+		t.Errorf("wrong crv: %s", jwk.Crv)
+	}
+	if jwk.Alg != "ES256K" {
+		t.Errorf("wrong alg: %s", jwk.Alg)
+	}
+
+	// Convert back
+	recoveredKey, err := JWKToSecp256k1PrivateKey(jwk)
+	if err != nil {
+		t.Fatalf("failed to convert JWK to key: %v", err)
+	}
+	if !privateKey.Key.Equals(&recoveredKey.Key) {
+		t.Error("recovered key does not match original")
+	}
+
+	// Public key round trip
+	pubJWK := Secp256k1PublicKeyToJWK(privateKey.PubKey(), "k1-pub-key-1")
+	if pubJWK.D != "" {
+		t.Error("public JWK should not contain D")
+	}
+	recoveredPubKey, err := JWKToSecp256k1PublicKey(pubJWK)
+	if err != nil {
+		t.Fatalf("failed to convert JWK to public key: %v", err)
+	}
+	if !privateKey.PubKey().IsEqual(recoveredPubKey) {
+		t.Error("recovered public key does not match original")
+	}
+
+	// A point off the curve is refused
+	pubJWK.Y = pubJWK.X
+	if _, err := JWKToSecp256k1PublicKey(pubJWK); err == nil {
+		t.Error("expected error for a point that is not on the curve")
+	}
+}
+
+func TestSchnorrKeyRoundTrip(t *testing.T) {
+	// Generate key
+	privateKey, err := GenerateSecp256k1Key()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// Convert to JWK
+	jwk := SchnorrPrivateKeyToJWK(privateKey, "schnorr-key-1")
+	if jwk.Kty != "OKP" || jwk.Crv != "secp256k1" || jwk.Alg != "BIP340" {
+		t.Errorf("wrong key type: kty=%s, crv=%s, alg=%s", jwk.Kty, jwk.Crv, jwk.Alg)
+	}
+	if jwk.Y != "" {
+		t.Error("Schnorr JWK should be x-only")
+	}
+
+	// Convert back
+	recoveredKey, err := JWKToSchnorrPrivateKey(jwk)
+	if err != nil {
+		t.Fatalf("failed to convert JWK to key: %v", err)
+	}
+	if !privateKey.Key.Equals(&recoveredKey.Key) {
+		t.Error("recovered key does not match original")
+	}
+
+	// The public key is the point with this x and even y
+	recoveredPubKey, err := JWKToSchnorrPublicKey(SchnorrPublicKeyToJWK(privateKey.PubKey(), ""))
+	if err != nil {
+		t.Fatalf("failed to convert JWK to public key: %v", err)
+	}
+	if recoveredPubKey.X().Cmp(privateKey.PubKey().X()) != 0 || recoveredPubKey.Y().Bit(0) != 0 {
+		t.Error("recovered public key should have the original x and even y")
+	}
+
+	// An ES256K JWK is not a Schnorr key
+	if _, err := JWKToSchnorrPrivateKey(Secp256k1PrivateKeyToJWK(privateKey, "")); err == nil {
+		t.Error("expected error for EC secp256k1 JWK as Schnorr")
+	}
+}
+
 func TestEd25519KeyRoundTrip(t *testing.T) {
 	// Generate key
 	privateKey, err := GenerateEd25519Key()
@@ -241,13 +329,13 @@ func TestJWKToMapRoundTrip(t *testing.T) {
 	}
 }
 
-func TestGenerateSecp256k1Key(t *testing.T) {
-	key1, err := GenerateSecp256k1Key()
+func TestGenerateP256Key(t *testing.T) {
+	key1, err := GenerateP256Key()
 	if err != nil {
 		t.Fatalf("failed to generate first key: %v", err)
 	}
 
-	key2, err := GenerateSecp256k1Key()
+	key2, err := GenerateP256Key()
 	if err != nil {
 		t.Fatalf("failed to generate second key: %v", err)
 	}
@@ -297,7 +385,7 @@ func TestGenerateBLSKey(t *testing.T) {
 
 func TestJWKMarshalUnmarshal(t *testing.T) {
 	// Generate an EC key
-	privateKey, _ := GenerateSecp256k1Key()
+	privateKey, _ := GenerateP256Key()
 	jwk := PrivateKeyToJWK(privateKey, "marshal-test")
 
 	// Marshal
@@ -352,13 +440,20 @@ func TestInvalidJWKConversions(t *testing.T) {
 	if err == nil {
 		t.Error("expected error for invalid BLS JWK")
 	}
+
+	// Test P-256 JWK as secp256k1
+	p256 := &JWK{Kty: "EC", Crv: "P-256", X: "test", Y: "test", D: "test"}
+	_, err = JWKToSecp256k1PrivateKey(p256)
+	if err == nil {
+		t.Error("expected error for P-256 JWK as secp256k1")
+	}
 }
 
 func TestSignWithConvertedKeys(t *testing.T) {
 	// This tests the full flow: generate key, convert to JWK, convert back, sign
 
 	// EC key
-	ecKey, _ := GenerateSecp256k1Key()
+	ecKey, _ := GenerateP256Key()
 	ecJWK := PrivateKeyToJWK(ecKey, "ec-test")
 	recoveredEC, _ := JWKToPrivateKey(ecJWK)
 
@@ -36,8 +36,11 @@ func GetKeyFilePath(did string, keysDir string) string {
 
 // SaveKeyFile saves a key file to disk
 func SaveKeyFile(keyFile *KeyFile, keysDir string) error {
-	path := GetKeyFilePath(keyFile.DID, keysDir)
+	return SaveKeyFileToPath(keyFile, GetKeyFilePath(keyFile.DID, keysDir))
+}
 
+// SaveKeyFileToPath saves a key file to an explicit path
+func SaveKeyFileToPath(keyFile *KeyFile, path string) error {
 	data, err := json.MarshalIndent(keyFile, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal key file: %w", err)
@@ -61,8 +64,11 @@ func SaveKeyFile(keyFile *KeyFile, keysDir string) error {
 
 // LoadKeyFile loads a key file from disk
 func LoadKeyFile(did string, keysDir string) (*KeyFile, error) {
-	path := GetKeyFilePath(did, keysDir)
+	return LoadKeyFileFromPath(GetKeyFilePath(did, keysDir))
+}
 
+// LoadKeyFileFromPath loads a key file from an explicit path
+func LoadKeyFileFromPath(path string) (*KeyFile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
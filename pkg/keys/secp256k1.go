@@ -0,0 +1,151 @@
+package keys
+
+import (
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/yourusername/did-char/pkg/crypto"
+)
+
+// secp256k1 keys are EC JWKs with crv "secp256k1" (RFC 8812), signed with ES256K.
+// Coordinates and the private scalar are always 32 bytes.
+
+// GenerateSecp256k1Key generates a new secp256k1 key pair
+func GenerateSecp256k1Key() (*secp256k1.PrivateKey, error) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secp256k1 key: %w", err)
+	}
+	return key, nil
+}
+
+// Secp256k1PrivateKeyToJWK converts a secp256k1 private key to JWK
+func Secp256k1PrivateKeyToJWK(key *secp256k1.PrivateKey, keyID string) *JWK {
+	jwk := Secp256k1PublicKeyToJWK(key.PubKey(), keyID)
+	jwk.D = crypto.Base64URLEncode(key.Serialize())
+	return jwk
+}
+
+// Secp256k1PublicKeyToJWK converts a secp256k1 public key to JWK
+func Secp256k1PublicKeyToJWK(key *secp256k1.PublicKey, keyID string) *JWK {
+	// Uncompressed form is 0x04 || x || y
+	point := key.SerializeUncompressed()
+	return &JWK{
+		ID:  keyID,
+		Kty: "EC",
+		Crv: "secp256k1",
+		Alg: "ES256K",
+		X:   crypto.Base64URLEncode(point[1:33]),
+		Y:   crypto.Base64URLEncode(point[33:65]),
+	}
+}
+
+// JWKToSecp256k1PrivateKey converts a JWK to a secp256k1 private key
+func JWKToSecp256k1PrivateKey(jwk *JWK) (*secp256k1.PrivateKey, error) {
+	if jwk.Kty != "EC" || jwk.Crv != "secp256k1" {
+		return nil, fmt.Errorf("JWK is not a secp256k1 key: kty=%s, crv=%s", jwk.Kty, jwk.Crv)
+	}
+	if jwk.D == "" {
+		return nil, fmt.Errorf("JWK does not contain private key (d)")
+	}
+
+	return decodeSecp256k1PrivateKey(jwk.D)
+}
+
+// JWKToSecp256k1PublicKey converts a JWK to a secp256k1 public key, checking it is on the curve
+func JWKToSecp256k1PublicKey(jwk *JWK) (*secp256k1.PublicKey, error) {
+	if jwk.Kty != "EC" || jwk.Crv != "secp256k1" {
+		return nil, fmt.Errorf("JWK is not a secp256k1 key: kty=%s, crv=%s", jwk.Kty, jwk.Crv)
+	}
+
+	xBytes, err := crypto.Base64URLDecode(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode X: %w", err)
+	}
+	yBytes, err := crypto.Base64URLDecode(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Y: %w", err)
+	}
+	if len(xBytes) != 32 || len(yBytes) != 32 {
+		return nil, fmt.Errorf("invalid secp256k1 coordinate size: x=%d, y=%d", len(xBytes), len(yBytes))
+	}
+
+	point := append(append([]byte{0x04}, xBytes...), yBytes...)
+	publicKey, err := secp256k1.ParsePubKey(point)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secp256k1 public key: %w", err)
+	}
+	return publicKey, nil
+}
+
+// BIP-340 Schnorr keys are OKP JWKs with crv "secp256k1" and only x: the public key
+// is x-only, standing for the point with even y. The private scalar is stored as is.
+
+// SchnorrPrivateKeyToJWK converts a secp256k1 private key to a BIP-340 Schnorr JWK
+func SchnorrPrivateKeyToJWK(key *secp256k1.PrivateKey, keyID string) *JWK {
+	jwk := SchnorrPublicKeyToJWK(key.PubKey(), keyID)
+	jwk.D = crypto.Base64URLEncode(key.Serialize())
+	return jwk
+}
+
+// SchnorrPublicKeyToJWK converts a secp256k1 public key to an x-only BIP-340 Schnorr JWK
+func SchnorrPublicKeyToJWK(key *secp256k1.PublicKey, keyID string) *JWK {
+	// Compressed form is a parity byte followed by x
+	return &JWK{
+		ID:  keyID,
+		Kty: "OKP",
+		Crv: "secp256k1",
+		Alg: "BIP340",
+		X:   crypto.Base64URLEncode(key.SerializeCompressed()[1:]),
+	}
+}
+
+// JWKToSchnorrPrivateKey converts a BIP-340 Schnorr JWK to a secp256k1 private key
+func JWKToSchnorrPrivateKey(jwk *JWK) (*secp256k1.PrivateKey, error) {
+	if jwk.Kty != "OKP" || jwk.Crv != "secp256k1" {
+		return nil, fmt.Errorf("JWK is not a Schnorr secp256k1 key: kty=%s, crv=%s", jwk.Kty, jwk.Crv)
+	}
+	if jwk.D == "" {
+		return nil, fmt.Errorf("JWK does not contain private key (d)")
+	}
+
+	return decodeSecp256k1PrivateKey(jwk.D)
+}
+
+// JWKToSchnorrPublicKey converts an x-only BIP-340 Schnorr JWK to the secp256k1 public key with even y
+func JWKToSchnorrPublicKey(jwk *JWK) (*secp256k1.PublicKey, error) {
+	if jwk.Kty != "OKP" || jwk.Crv != "secp256k1" {
+		return nil, fmt.Errorf("JWK is not a Schnorr secp256k1 key: kty=%s, crv=%s", jwk.Kty, jwk.Crv)
+	}
+
+	xBytes, err := crypto.Base64URLDecode(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode X: %w", err)
+	}
+	if len(xBytes) != 32 {
+		return nil, fmt.Errorf("invalid x-only public key size: %d", len(xBytes))
+	}
+
+	publicKey, err := secp256k1.ParsePubKey(append([]byte{secp256k1.PubKeyFormatCompressedEven}, xBytes...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid secp256k1 public key: %w", err)
+	}
+	return publicKey, nil
+}
+
+// decodeSecp256k1PrivateKey decodes a JWK d value, which must be a 32-byte scalar in [1, n)
+func decodeSecp256k1PrivateKey(encoded string) (*secp256k1.PrivateKey, error) {
+	dBytes, err := crypto.Base64URLDecode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode D: %w", err)
+	}
+	if len(dBytes) != 32 {
+		return nil, fmt.Errorf("invalid secp256k1 private key size: %d", len(dBytes))
+	}
+
+	var d secp256k1.ModNScalar
+	if overflow := d.SetByteSlice(dBytes); overflow || d.IsZero() {
+		return nil, fmt.Errorf("invalid secp256k1 private key")
+	}
+	return secp256k1.NewPrivateKey(&d), nil
+}
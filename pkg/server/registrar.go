@@ -0,0 +1,839 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/cas"
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/config"
+	"github.com/yourusername/did-char/pkg/did"
+	"github.com/yourusername/did-char/pkg/encoding"
+	"github.com/yourusername/did-char/pkg/keys"
+	"github.com/yourusername/did-char/pkg/signing"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// Registration states, as defined by the DIF Universal Registrar
+const (
+	StateFinished = "finished"
+	StateFailed   = "failed"
+	StateAction   = "action"
+	StateWait     = "wait"
+)
+
+// ActionSignPayload asks the client to sign the payloads in signingRequest
+const ActionSignPayload = "signPayload"
+
+// Universal Registrar didDocumentOperation values accepted by update
+const (
+	DocumentOperationSet    = "setDidDocument"
+	DocumentOperationAdd    = "addToDidDocument"
+	DocumentOperationRemove = "removeFromDidDocument"
+)
+
+// Job timing: how often clients should poll a waiting job, and how long a job is
+// kept after its last change, whatever its state
+const (
+	jobWaitTime  = 2 * time.Second
+	jobRetention = time.Hour
+)
+
+// maxOpenJobs bounds the jobs not yet finished or failed; new jobs are refused beyond it
+const maxOpenJobs = 1000
+
+// RegistrarRequest is the body of a create, update, recover or deactivate request
+//
+// A request carrying only a jobId polls that job; in client-managed secret mode it
+// also carries the signingResponse for a job in the action state.
+type RegistrarRequest struct {
+	JobID                string           `json:"jobId,omitempty"`
+	DID                  string           `json:"did,omitempty"`
+	Options              RegistrarOptions `json:"options"`
+	Secret               RegistrarSecret  `json:"secret"`
+	DIDDocumentOperation []string         `json:"didDocumentOperation,omitempty"`
+	DIDDocument          json.RawMessage  `json:"didDocument,omitempty"` // A document, or for update one per didDocumentOperation
+}
+
+// RegistrarOptions selects the secret mode and, in client-managed mode, carries public keys
+//
+// Keys are public JWKs. UpdateKey and RecoveryKey open the DID's current commitments
+// (or, for create, are committed to); the Next keys are committed to by the operation.
+type RegistrarOptions struct {
+	ClientSecretMode bool                       `json:"clientSecretMode,omitempty"`
+	Algorithm        signing.SignatureAlgorithm `json:"algorithm,omitempty"` // Internal mode create: ES256, ES256K, EdDSA, BLS or BIP340
+	UpdateKey        *keys.JWK                  `json:"updateKey,omitempty"`
+	RecoveryKey      *keys.JWK                  `json:"recoveryKey,omitempty"`
+	NextUpdateKey    *keys.JWK                  `json:"nextUpdateKey,omitempty"`
+	NextRecoveryKey  *keys.JWK                  `json:"nextRecoveryKey,omitempty"`
+}
+
+// RegistrarSecret carries client-managed signatures
+type RegistrarSecret struct {
+	SigningResponse map[string]SigningResponse `json:"signingResponse,omitempty"`
+}
+
+// SigningRequest asks the client to sign SerializedPayload, the JWS signing input
+type SigningRequest struct {
+	Alg               signing.SignatureAlgorithm `json:"alg"`
+	Purpose           string                     `json:"purpose"`
+	PublicKeyJwk      *keys.JWK                  `json:"publicKeyJwk"`
+	SerializedPayload string                     `json:"serializedPayload"`
+}
+
+// SigningResponse answers a SigningRequest with the compact JWS, or just its signature part
+type SigningResponse struct {
+	JWS       string `json:"jws,omitempty"`
+	Signature string `json:"signature,omitempty"` // base64url
+}
+
+// RegistrarState is the response to every registrar request
+type RegistrarState struct {
+	JobID                   string                `json:"jobId,omitempty"`
+	DIDState                DIDState              `json:"didState"`
+	DIDRegistrationMetadata RegistrationMetadata  `json:"didRegistrationMetadata"`
+	DIDDocumentMetadata     *did.DocumentMetadata `json:"didDocumentMetadata,omitempty"`
+}
+
+// DIDState describes where a registration job stands
+type DIDState struct {
+	State          string                    `json:"state"`
+	DID            string                    `json:"did,omitempty"`
+	DIDDocument    *did.DIDDocument          `json:"didDocument,omitempty"`
+	Action         string                    `json:"action,omitempty"`
+	SigningRequest map[string]SigningRequest `json:"signingRequest,omitempty"`
+	Wait           string                    `json:"wait,omitempty"`
+	WaitTime       int                       `json:"waitTime,omitempty"` // Suggested milliseconds before polling again
+	Reason         string                    `json:"reason,omitempty"`
+}
+
+// RegistrationMetadata is did:char specific registration metadata
+type RegistrationMetadata struct {
+	Operation    string `json:"operation,omitempty"`
+	BallotNumber *int   `json:"ballotNumber,omitempty"`
+}
+
+// Registrar creates, updates, recovers and deactivates DIDs for HTTP clients
+//
+// In internal secret mode keys live in the configured keys directory, as for the
+// CLI. In client-managed secret mode the client supplies public keys, the registrar
+// returns the signing input and the client posts the JWS back. Jobs are kept in
+// memory, so they do not survive a restart.
+//
+// With a configured token every request must carry it as a bearer token. Without
+// one only client-managed secret mode is served, since internal secret mode would
+// let anyone change the DIDs whose keys are in the keys directory.
+type Registrar struct {
+	cfg        *config.Config
+	store      *storage.Store
+	charClient *char.Client
+	resolver   *did.Resolver
+	batcher    *did.Batcher // Shared by all jobs, so operations submitted together share a ballot
+
+	mu       sync.Mutex
+	jobs     map[string]*job
+	didLocks map[string]*didLock // Serialize internal secret mode jobs on one DID
+}
+
+// didLock serializes the jobs on one DID; it is dropped once no job holds or awaits it
+type didLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// job is a registration in progress
+type job struct {
+	id        string
+	operation string
+	updated   time.Time
+	state     RegistrarState
+	status    int                   // HTTP status of the current state
+	pending   *did.PendingOperation // Awaiting the client's signature
+	requestID string                // Key of the outstanding signing request
+}
+
+// NewRegistrar creates a registrar writing through store and charClient
+func NewRegistrar(cfg *config.Config, store *storage.Store, charClient *char.Client) *Registrar {
+	return &Registrar{
+		cfg:        cfg,
+		store:      store,
+		charClient: charClient,
+		resolver:   did.NewResolver(store),
+		batcher:    did.NewBatcher(cfg, store, charClient),
+		jobs:       make(map[string]*job),
+		didLocks:   make(map[string]*didLock),
+	}
+}
+
+// SetContentFetcher sets where batches anchored by other writers are fetched from
+// while the registrar catches up to the ballots it submitted to
+func (reg *Registrar) SetContentFetcher(f cas.Fetcher) {
+	reg.batcher.SetContentFetcher(f)
+}
+
+// register adds the registrar's routes to mux
+func (reg *Registrar) register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /1.0/create", reg.handle(did.OperationTypeCreate, reg.startCreate))
+	mux.HandleFunc("POST /1.0/update", reg.handle(did.OperationTypeUpdate, reg.startUpdate))
+	mux.HandleFunc("POST /1.0/recover", reg.handle(did.OperationTypeRecover, reg.startRecover))
+	mux.HandleFunc("POST /1.0/deactivate", reg.handle(did.OperationTypeDeactivate, reg.startDeactivate))
+}
+
+// authorized reports whether r carries the configured bearer token, if there is one
+func (reg *Registrar) authorized(r *http.Request) bool {
+	if reg.cfg.Registrar.Token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(reg.cfg.Registrar.Token)) == 1
+}
+
+// requestError is a problem with the request itself, reported with HTTP 400
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+func (e *requestError) Unwrap() error { return e.err }
+
+// badRequest returns a requestError with a formatted message
+func badRequest(format string, args ...interface{}) error {
+	return &requestError{fmt.Errorf(format, args...)}
+}
+
+// handle decodes a registrar request and either continues its job or starts a new one
+func (reg *Registrar) handle(operation string, start func(*job, *RegistrarRequest) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !reg.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="did-char registrar"`)
+			writeState(w, http.StatusUnauthorized, failedState("", "missing or invalid bearer token"))
+			return
+		}
+
+		var req RegistrarRequest
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err := dec.Decode(&req); err != nil {
+			writeState(w, http.StatusBadRequest, failedState("", fmt.Sprintf("invalid request body: %v", err)))
+			return
+		}
+
+		if req.JobID != "" {
+			reg.continueJob(w, operation, &req)
+			return
+		}
+
+		if !req.Options.ClientSecretMode && reg.cfg.Registrar.Token == "" {
+			writeState(w, http.StatusForbidden, failedState("", "internal secret mode requires a registrar token; use clientSecretMode"))
+			return
+		}
+
+		j, err := reg.newJob(operation)
+		if err != nil {
+			writeState(w, http.StatusServiceUnavailable, failedState("", err.Error()))
+			return
+		}
+		if err := start(j, &req); err != nil {
+			status := http.StatusInternalServerError
+			var reqErr *requestError
+			if errors.As(err, &reqErr) {
+				status = http.StatusBadRequest
+			}
+			reg.finish(j, status, failedState(j.id, err.Error()))
+		}
+		reg.respond(w, j)
+	}
+}
+
+// continueJob reports a job's state, first applying a signing response if it carries one
+func (reg *Registrar) continueJob(w http.ResponseWriter, operation string, req *RegistrarRequest) {
+	reg.mu.Lock()
+	j := reg.jobs[req.JobID]
+	reg.mu.Unlock()
+	if j == nil || j.operation != operation {
+		writeState(w, http.StatusNotFound, failedState(req.JobID, "unknown jobId"))
+		return
+	}
+
+	if len(req.Secret.SigningResponse) > 0 {
+		if err := reg.sign(j, req.Secret.SigningResponse); err != nil {
+			// The job stays in the action state so the client can try again
+			writeState(w, http.StatusBadRequest, failedState(j.id, err.Error()))
+			return
+		}
+	}
+	reg.respond(w, j)
+}
+
+// newJob creates and records a job with a random ID
+//
+// Jobs unchanged for jobRetention are dropped first: a client that never signs or
+// polls again does not hold its job forever. It fails if maxOpenJobs are still open.
+func (reg *Registrar) newJob(operation string) (*job, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(fmt.Sprintf("failed to generate job ID: %v", err))
+	}
+
+	j := &job{
+		id:        hex.EncodeToString(id[:]),
+		operation: operation,
+		updated:   time.Now(),
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	open := 0
+	for id, old := range reg.jobs {
+		if time.Since(old.updated) > jobRetention {
+			delete(reg.jobs, id)
+			continue
+		}
+		if state := old.state.DIDState.State; state != StateFinished && state != StateFailed {
+			open++
+		}
+	}
+	if open >= maxOpenJobs {
+		return nil, fmt.Errorf("too many registrations in progress; try again later")
+	}
+	reg.jobs[j.id] = j
+	return j, nil
+}
+
+// respond writes a job's current state
+func (reg *Registrar) respond(w http.ResponseWriter, j *job) {
+	reg.mu.Lock()
+	state, status := j.state, j.status
+	reg.mu.Unlock()
+	writeState(w, status, &state)
+}
+
+// finish records a job's new state
+func (reg *Registrar) finish(j *job, status int, state *RegistrarState) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	j.state = *state
+	j.status = status
+	j.updated = time.Now()
+}
+
+// failedState returns a failed registration state
+func failedState(jobID, reason string) *RegistrarState {
+	return &RegistrarState{
+		JobID:    jobID,
+		DIDState: DIDState{State: StateFailed, Reason: reason},
+	}
+}
+
+// submit runs fn in the background, moving j to wait until it returns the DID and ballot
+func (reg *Registrar) submit(j *job, didStr string, fn func(ctx context.Context) (string, int, error)) {
+	reg.finish(j, http.StatusAccepted, &RegistrarState{
+		JobID: j.id,
+		DIDState: DIDState{
+			State:    StateWait,
+			DID:      didStr,
+			Wait:     "waiting for the operation to be decided on CHAR",
+			WaitTime: int(jobWaitTime / time.Millisecond),
+		},
+		DIDRegistrationMetadata: RegistrationMetadata{Operation: j.operation},
+	})
+
+	go func() {
+		didStr, ballot, err := fn(context.Background())
+		if err != nil {
+			log.Printf("Registrar job %s (%s) failed: %v", j.id, j.operation, err)
+			reg.finish(j, http.StatusInternalServerError, failedState(j.id, err.Error()))
+			return
+		}
+		reg.finish(j, http.StatusOK, reg.finishedState(j, didStr, ballot))
+	}()
+}
+
+// lockDID locks a DID against other internal secret mode jobs and returns the unlock function
+//
+// Those jobs read the DID's key file, submit and then rewrite the key file, so two at
+// once would both sign with the same key.
+func (reg *Registrar) lockDID(didStr string) func() {
+	reg.mu.Lock()
+	l := reg.didLocks[didStr]
+	if l == nil {
+		l = &didLock{}
+		reg.didLocks[didStr] = l
+	}
+	l.refs++
+	reg.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		reg.mu.Lock()
+		defer reg.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(reg.didLocks, didStr)
+		}
+	}
+}
+
+// finishedState describes a job whose operation was applied on ballot
+func (reg *Registrar) finishedState(j *job, didStr string, ballot int) *RegistrarState {
+	state := &RegistrarState{
+		JobID: j.id,
+		DIDState: DIDState{
+			State: StateFinished,
+			DID:   didStr,
+		},
+		DIDRegistrationMetadata: RegistrationMetadata{Operation: j.operation, BallotNumber: &ballot},
+	}
+
+	result, err := reg.resolver.ResolveResult(didStr, did.ResolveOptions{})
+	if err != nil || result.DIDResolutionMetadata.Error != "" {
+		log.Printf("Registrar job %s: failed to resolve %s after ballot %d: %v", j.id, didStr, ballot, err)
+		return state
+	}
+	state.DIDState.DIDDocument = result.DIDDocument
+	state.DIDDocumentMetadata = &result.DIDDocumentMetadata
+	return state
+}
+
+// awaitSignature moves j to the action state, asking the client to sign pending
+func (reg *Registrar) awaitSignature(j *job, pending *did.PendingOperation) error {
+	input, err := pending.SigningInput()
+	if err != nil {
+		return badRequest("%v", err)
+	}
+	alg, err := pending.Algorithm()
+	if err != nil {
+		return badRequest("%v", err)
+	}
+
+	requestID := "signingRequest-" + pending.Type
+	reg.mu.Lock()
+	j.pending = pending
+	j.requestID = requestID
+	reg.mu.Unlock()
+
+	reg.finish(j, http.StatusAccepted, &RegistrarState{
+		JobID: j.id,
+		DIDState: DIDState{
+			State:  StateAction,
+			DID:    pending.DID,
+			Action: ActionSignPayload,
+			SigningRequest: map[string]SigningRequest{
+				requestID: {
+					Alg:               alg,
+					Purpose:           pending.Type,
+					PublicKeyJwk:      pending.SigningKey,
+					SerializedPayload: input,
+				},
+			},
+		},
+		DIDRegistrationMetadata: RegistrationMetadata{Operation: j.operation},
+	})
+	return nil
+}
+
+// sign completes a job in the action state with the client's signing response and submits it
+//
+// The pending operation is taken from the job while it is checked, so of two signing
+// responses arriving together only one can submit; it is put back if the check fails.
+func (reg *Registrar) sign(j *job, responses map[string]SigningResponse) error {
+	reg.mu.Lock()
+	pending, requestID := j.pending, j.requestID
+	j.pending = nil
+	reg.mu.Unlock()
+	if pending == nil {
+		return fmt.Errorf("job is not waiting for a signature")
+	}
+
+	if err := completePending(pending, requestID, responses); err != nil {
+		reg.mu.Lock()
+		j.pending = pending
+		reg.mu.Unlock()
+		return err
+	}
+
+	reg.submit(j, pending.DID, func(ctx context.Context) (string, int, error) {
+		ballot, err := reg.batcher.SubmitPending(ctx, pending)
+		return pending.DID, ballot, err
+	})
+	return nil
+}
+
+// completePending signs pending with the response to requestID, a JWS or a bare signature
+func completePending(pending *did.PendingOperation, requestID string, responses map[string]SigningResponse) error {
+	resp, ok := responses[requestID]
+	if !ok {
+		return fmt.Errorf("missing signingResponse for %s", requestID)
+	}
+	jws := resp.JWS
+	if jws == "" && resp.Signature != "" {
+		input, err := pending.SigningInput()
+		if err != nil {
+			return err
+		}
+		jws = input + "." + resp.Signature
+	}
+	if jws == "" {
+		return fmt.Errorf("signingResponse %s has no jws or signature", requestID)
+	}
+	return pending.Complete(jws)
+}
+
+// startCreate starts a create job
+func (reg *Registrar) startCreate(j *job, req *RegistrarRequest) error {
+	var input *did.DIDDocument
+	if len(req.DIDDocument) > 0 {
+		if err := json.Unmarshal(req.DIDDocument, &input); err != nil {
+			return badRequest("invalid didDocument: %v", err)
+		}
+	}
+
+	if !req.Options.ClientSecretMode {
+		createReq := &did.CreateDIDRequest{Algorithm: req.Options.Algorithm, Batcher: reg.batcher}
+		if input != nil {
+			if len(input.VerificationMethod) > 0 {
+				return badRequest("keys are generated in internal secret mode; use clientSecretMode to supply verification methods")
+			}
+			createReq.Services = documentServices(input)
+		}
+		reg.submit(j, "", func(ctx context.Context) (string, int, error) {
+			result, err := did.CreateDID(createReq, reg.cfg, reg.store, reg.charClient)
+			if err != nil {
+				return "", 0, err
+			}
+			return result.DID, result.BallotNumber, nil
+		})
+		return nil
+	}
+
+	// Client-managed: the operation is unsigned, so it can be submitted right away
+	updateCommitment, err := commitmentFor("updateKey", req.Options.UpdateKey)
+	if err != nil {
+		return err
+	}
+	recoveryCommitment, err := commitmentFor("recoveryKey", req.Options.RecoveryKey)
+	if err != nil {
+		return err
+	}
+	if input == nil {
+		return badRequest("didDocument is required in client-managed secret mode")
+	}
+	doc, err := documentFromInput(input)
+	if err != nil {
+		return err
+	}
+	doc.ID = ""
+
+	createOp, err := did.NewCreateOperation(doc, updateCommitment, recoveryCommitment)
+	if err != nil {
+		return badRequest("%v", err)
+	}
+	didStr := doc.ID
+	suffix, err := did.ParseDID(didStr)
+	if err != nil {
+		return err
+	}
+	reg.submit(j, didStr, func(ctx context.Context) (string, int, error) {
+		ballot, err := reg.batcher.SubmitOne(ctx, encoding.OperationTypeCreate, suffix, createOp)
+		return didStr, ballot, err
+	})
+	return nil
+}
+
+// startUpdate starts an update job
+func (reg *Registrar) startUpdate(j *job, req *RegistrarRequest) error {
+	if _, err := did.ParseDID(req.DID); err != nil {
+		return badRequest("%v", err)
+	}
+	updateReq, err := reg.updateRequest(req)
+	if err != nil {
+		return err
+	}
+
+	if !req.Options.ClientSecretMode {
+		updateReq.Batcher = reg.batcher
+		reg.submit(j, req.DID, func(ctx context.Context) (string, int, error) {
+			defer reg.lockDID(req.DID)()
+			if err := did.UpdateDID(updateReq, reg.cfg, reg.store, reg.charClient); err != nil {
+				return "", 0, err
+			}
+			ballot, err := lastBallot(reg.store, req.DID)
+			return req.DID, ballot, err
+		})
+		return nil
+	}
+
+	if req.Options.UpdateKey == nil {
+		return badRequest("options.updateKey is required in client-managed secret mode")
+	}
+	nextCommitment, err := commitmentFor("nextUpdateKey", req.Options.NextUpdateKey)
+	if err != nil {
+		return err
+	}
+	pending, err := did.PrepareUpdate(reg.store, req.DID, req.Options.UpdateKey, nextCommitment, updateReq.Patches())
+	if err != nil {
+		return badRequest("%v", err)
+	}
+	return reg.awaitSignature(j, pending)
+}
+
+// startRecover starts a recover job
+func (reg *Registrar) startRecover(j *job, req *RegistrarRequest) error {
+	if _, err := did.ParseDID(req.DID); err != nil {
+		return badRequest("%v", err)
+	}
+
+	var doc *did.Document
+	if len(req.DIDDocument) > 0 {
+		var input did.DIDDocument
+		if err := json.Unmarshal(req.DIDDocument, &input); err != nil {
+			return badRequest("invalid didDocument: %v", err)
+		}
+		var err error
+		if doc, err = documentFromInput(&input); err != nil {
+			return err
+		}
+	}
+
+	if !req.Options.ClientSecretMode {
+		recoverReq := &did.RecoverDIDRequest{DID: req.DID, Document: doc, Batcher: reg.batcher}
+		reg.submit(j, req.DID, func(ctx context.Context) (string, int, error) {
+			defer reg.lockDID(req.DID)()
+			result, err := did.RecoverDID(recoverReq, reg.cfg, reg.store, reg.charClient)
+			if err != nil {
+				return "", 0, err
+			}
+			return req.DID, result.BallotNumber, nil
+		})
+		return nil
+	}
+
+	if req.Options.RecoveryKey == nil {
+		return badRequest("options.recoveryKey is required in client-managed secret mode")
+	}
+	if doc == nil {
+		return badRequest("didDocument is required in client-managed secret mode")
+	}
+	nextUpdate, err := commitmentFor("nextUpdateKey", req.Options.NextUpdateKey)
+	if err != nil {
+		return err
+	}
+	nextRecovery, err := commitmentFor("nextRecoveryKey", req.Options.NextRecoveryKey)
+	if err != nil {
+		return err
+	}
+	pending, err := did.PrepareRecover(reg.store, req.DID, req.Options.RecoveryKey, doc, nextUpdate, nextRecovery)
+	if err != nil {
+		return badRequest("%v", err)
+	}
+	return reg.awaitSignature(j, pending)
+}
+
+// startDeactivate starts a deactivate job
+func (reg *Registrar) startDeactivate(j *job, req *RegistrarRequest) error {
+	if _, err := did.ParseDID(req.DID); err != nil {
+		return badRequest("%v", err)
+	}
+
+	if !req.Options.ClientSecretMode {
+		reg.submit(j, req.DID, func(ctx context.Context) (string, int, error) {
+			defer reg.lockDID(req.DID)()
+			deactivateReq := &did.DeactivateDIDRequest{DID: req.DID, Batcher: reg.batcher}
+			if err := did.DeactivateDID(deactivateReq, reg.cfg, reg.store, reg.charClient); err != nil {
+				return "", 0, err
+			}
+			ballot, err := lastBallot(reg.store, req.DID)
+			return req.DID, ballot, err
+		})
+		return nil
+	}
+
+	if req.Options.RecoveryKey == nil {
+		return badRequest("options.recoveryKey is required in client-managed secret mode")
+	}
+	pending, err := did.PrepareDeactivate(reg.store, req.DID, req.Options.RecoveryKey)
+	if err != nil {
+		return badRequest("%v", err)
+	}
+	return reg.awaitSignature(j, pending)
+}
+
+// updateRequest translates didDocumentOperation/didDocument pairs into an update request
+//
+// setDidDocument replaces every key and service; authentication cannot be changed
+// by an update. removeFromDidDocument only needs the IDs of the entries to remove.
+func (reg *Registrar) updateRequest(req *RegistrarRequest) (*did.UpdateDIDRequest, error) {
+	operations := req.DIDDocumentOperation
+	if len(operations) == 0 {
+		operations = []string{DocumentOperationSet}
+	}
+
+	var inputs []did.DIDDocument
+	if len(req.DIDDocument) > 0 && strings.HasPrefix(strings.TrimSpace(string(req.DIDDocument)), "[") {
+		if err := json.Unmarshal(req.DIDDocument, &inputs); err != nil {
+			return nil, badRequest("invalid didDocument: %v", err)
+		}
+	} else if len(req.DIDDocument) > 0 {
+		var input did.DIDDocument
+		if err := json.Unmarshal(req.DIDDocument, &input); err != nil {
+			return nil, badRequest("invalid didDocument: %v", err)
+		}
+		inputs = []did.DIDDocument{input}
+	}
+	if len(inputs) != len(operations) {
+		return nil, badRequest("expected %d didDocument entries for didDocumentOperation, got %d", len(operations), len(inputs))
+	}
+
+	updateReq := &did.UpdateDIDRequest{DID: req.DID}
+	for i, operation := range operations {
+		input := &inputs[i]
+		switch operation {
+		case DocumentOperationSet:
+			current, err := reg.currentDocument(req.DID)
+			if err != nil {
+				return nil, err
+			}
+			for _, pk := range current.PublicKeys {
+				updateReq.RemovePublicKeys = append(updateReq.RemovePublicKeys, pk.ID)
+			}
+			for _, svc := range current.Services {
+				updateReq.RemoveServices = append(updateReq.RemoveServices, svc.ID)
+			}
+			fallthrough
+		case DocumentOperationAdd:
+			doc, err := documentFromInput(input)
+			if err != nil {
+				return nil, err
+			}
+			updateReq.AddPublicKeys = append(updateReq.AddPublicKeys, doc.PublicKeys...)
+			updateReq.AddServices = append(updateReq.AddServices, doc.Services...)
+		case DocumentOperationRemove:
+			for _, vm := range input.VerificationMethod {
+				updateReq.RemovePublicKeys = append(updateReq.RemovePublicKeys, relativeID(vm.ID))
+			}
+			for _, svc := range input.Service {
+				updateReq.RemoveServices = append(updateReq.RemoveServices, relativeID(svc.ID))
+			}
+		default:
+			return nil, badRequest("unsupported didDocumentOperation: %s", operation)
+		}
+	}
+
+	return updateReq, nil
+}
+
+// currentDocument loads the stored document of an active DID
+func (reg *Registrar) currentDocument(didStr string) (*did.Document, error) {
+	record, err := reg.store.GetDID(didStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load DID: %w", err)
+	}
+	if record == nil {
+		return nil, badRequest("DID not found: %s", didStr)
+	}
+	var doc did.Document
+	if err := json.Unmarshal([]byte(record.Document), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse DID document: %w", err)
+	}
+	return &doc, nil
+}
+
+// lastBallot returns the ballot of the most recent operation applied to a DID
+func lastBallot(store *storage.Store, didStr string) (int, error) {
+	record, err := store.GetDID(didStr)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load DID: %w", err)
+	}
+	if record == nil {
+		return 0, fmt.Errorf("DID not found: %s", didStr)
+	}
+	return record.LastOperationBallot, nil
+}
+
+// commitmentFor returns the commitment to a client-supplied public key
+func commitmentFor(name string, jwk *keys.JWK) (string, error) {
+	if jwk == nil {
+		return "", badRequest("options.%s is required in client-managed secret mode", name)
+	}
+	if jwk.D != "" {
+		return "", badRequest("options.%s must be a public key", name)
+	}
+	if _, err := signing.DetectAlgorithm(keys.JWKToMap(jwk)); err != nil {
+		return "", badRequest("options.%s: %v", name, err)
+	}
+	commitment, _, err := did.GenerateCommitmentFromJWK(jwk)
+	if err != nil {
+		return "", err
+	}
+	return commitment, nil
+}
+
+// documentFromInput converts a DID Core document from a request to the stored layout
+//
+// Verification methods must carry a publicKeyJwk; their type is derived from the key.
+func documentFromInput(input *did.DIDDocument) (*did.Document, error) {
+	doc := did.NewDocument(input.ID)
+
+	for _, vm := range input.VerificationMethod {
+		if vm.ID == "" || vm.PublicKeyJwk == nil {
+			return nil, badRequest("verification method %q must have an id and publicKeyJwk", vm.ID)
+		}
+		if vm.PublicKeyJwk.D != "" {
+			return nil, badRequest("verification method %q contains a private key", vm.ID)
+		}
+		pk, err := did.NewPublicKeyFromJWK(relativeID(vm.ID), vm.PublicKeyJwk)
+		if err != nil {
+			return nil, badRequest("verification method %q: %v", vm.ID, err)
+		}
+		if vm.Controller != "" && vm.Controller != input.ID {
+			pk.Controller = vm.Controller
+		}
+		doc.AddPublicKey(pk)
+	}
+	for _, ref := range input.Authentication {
+		doc.AddAuthentication(relativeID(ref))
+	}
+	for _, svc := range input.Service {
+		if svc.ID == "" || svc.Type == "" || svc.ServiceEndpoint == "" {
+			return nil, badRequest("service %q must have an id, type and serviceEndpoint", svc.ID)
+		}
+		doc.AddService(did.Service{ID: relativeID(svc.ID), Type: svc.Type, ServiceEndpoint: svc.ServiceEndpoint})
+	}
+
+	return doc, nil
+}
+
+// documentServices returns the services of a request document in the stored layout
+func documentServices(input *did.DIDDocument) []did.Service {
+	var services []did.Service
+	for _, svc := range input.Service {
+		services = append(services, did.Service{ID: relativeID(svc.ID), Type: svc.Type, ServiceEndpoint: svc.ServiceEndpoint})
+	}
+	return services
+}
+
+// relativeID turns a DID URL or bare fragment into the "#fragment" form stored documents use
+func relativeID(id string) string {
+	if i := strings.Index(id, "#"); i >= 0 {
+		return id[i:]
+	}
+	if id == "" {
+		return ""
+	}
+	return "#" + id
+}
+
+// writeState writes a registrar response
+func writeState(w http.ResponseWriter, status int, state *RegistrarState) {
+	writeJSON(w, status, "application/json", state)
+}
@@ -0,0 +1,477 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/crypto"
+	"github.com/yourusername/did-char/pkg/did"
+	"github.com/yourusername/did-char/pkg/keys"
+	"github.com/yourusername/did-char/pkg/signing"
+)
+
+// post sends a registrar request and decodes the response state
+func (e *testEnv) post(t *testing.T, path string, body interface{}) (int, *RegistrarState) {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+	resp := e.postRaw(t, path, e.cfg.Registrar.Token, bytes.NewReader(data))
+	defer resp.Body.Close()
+
+	var state RegistrarState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp.StatusCode, &state
+}
+
+// postRaw sends body to path with token as the bearer token, if there is one
+func (e *testEnv) postRaw(t *testing.T, path, token string, body io.Reader) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, e.http.URL+path, body)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", path, err)
+	}
+	return resp
+}
+
+// await polls a job until it leaves the wait state
+func (e *testEnv) await(t *testing.T, path string, state *RegistrarState) *RegistrarState {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for state.DIDState.State == StateWait {
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still waiting", state.JobID)
+		}
+		time.Sleep(10 * time.Millisecond)
+		_, state = e.post(t, path, &RegistrarRequest{JobID: state.JobID})
+	}
+	return state
+}
+
+// mustFinish polls a job and fails the test unless it finishes
+func (e *testEnv) mustFinish(t *testing.T, path string, state *RegistrarState) *RegistrarState {
+	t.Helper()
+	state = e.await(t, path, state)
+	if state.DIDState.State != StateFinished {
+		t.Fatalf("job %s: state %s (%s)", state.JobID, state.DIDState.State, state.DIDState.Reason)
+	}
+	return state
+}
+
+func TestRegistrarInternalSecretMode(t *testing.T) {
+	env := newTestEnv(t)
+
+	status, state := env.post(t, "/1.0/create", map[string]interface{}{
+		"didDocument": map[string]interface{}{
+			"service": []map[string]string{{"id": "#domain", "type": "LinkedDomains", "serviceEndpoint": "https://example.com"}},
+		},
+	})
+	if status != http.StatusAccepted || state.DIDState.State != StateWait || state.JobID == "" {
+		t.Fatalf("create: status %d, state %+v", status, state)
+	}
+	state = env.mustFinish(t, "/1.0/create", state)
+	didStr := state.DIDState.DID
+	if state.DIDRegistrationMetadata.BallotNumber == nil || state.DIDDocumentMetadata == nil {
+		t.Errorf("finished create is missing metadata: %+v", state)
+	}
+	if doc := state.DIDState.DIDDocument; doc == nil || doc.ID != didStr || len(doc.Service) != 1 || len(doc.VerificationMethod) != 1 {
+		t.Fatalf("unexpected created document: %+v", doc)
+	}
+	if !keys.KeyFileExists(didStr, env.cfg.DataDir.KeysDir) {
+		t.Error("internal secret mode should keep keys in the keystore")
+	}
+
+	_, state = env.post(t, "/1.0/update", map[string]interface{}{
+		"did":                  didStr,
+		"didDocumentOperation": []string{DocumentOperationAdd},
+		"didDocument": []map[string]interface{}{{
+			"service": []map[string]string{{"id": didStr + "#hub", "type": "IdentityHub", "serviceEndpoint": "https://hub.example.com"}},
+		}},
+	})
+	state = env.mustFinish(t, "/1.0/update", state)
+	if len(state.DIDState.DIDDocument.Service) != 2 {
+		t.Errorf("expected 2 services after update, got %+v", state.DIDState.DIDDocument.Service)
+	}
+
+	_, state = env.post(t, "/1.0/update", map[string]interface{}{
+		"did":                  didStr,
+		"didDocumentOperation": []string{DocumentOperationRemove},
+		"didDocument":          []map[string]interface{}{{"service": []map[string]string{{"id": "#domain"}}}},
+	})
+	state = env.mustFinish(t, "/1.0/update", state)
+	if svc := state.DIDState.DIDDocument.Service; len(svc) != 1 || svc[0].ID != didStr+"#hub" {
+		t.Errorf("unexpected services after removal: %+v", svc)
+	}
+
+	_, state = env.post(t, "/1.0/deactivate", map[string]interface{}{"did": didStr})
+	state = env.mustFinish(t, "/1.0/deactivate", state)
+	if state.DIDDocumentMetadata == nil || !state.DIDDocumentMetadata.Deactivated {
+		t.Errorf("deactivate should report deactivated metadata: %+v", state.DIDDocumentMetadata)
+	}
+}
+
+func TestRegistrarClientManagedSecretMode(t *testing.T) {
+	env := newTestEnv(t)
+
+	// The client keeps every private key: Ed25519 for updates, P-256 for recovery
+	updateKey, _ := keys.GenerateEd25519Key()
+	nextUpdateKey, _ := keys.GenerateEd25519Key()
+	recoveryKey, _ := keys.GenerateP256Key()
+	nextRecoveryKey, _ := keys.GenerateP256Key()
+	signingKey, _ := keys.GenerateEd25519Key()
+
+	updateJWK := keys.Ed25519PublicKeyToJWK(updateKey.Public().(ed25519.PublicKey), "update")
+	nextUpdateJWK := keys.Ed25519PublicKeyToJWK(nextUpdateKey.Public().(ed25519.PublicKey), "update")
+	recoveryJWK := keys.PublicKeyToJWK(&recoveryKey.PublicKey, "recovery")
+	nextRecoveryJWK := keys.PublicKeyToJWK(&nextRecoveryKey.PublicKey, "recovery")
+
+	_, state := env.post(t, "/1.0/create", map[string]interface{}{
+		"options": RegistrarOptions{ClientSecretMode: true, UpdateKey: updateJWK, RecoveryKey: recoveryJWK},
+		"didDocument": map[string]interface{}{
+			"verificationMethod": []map[string]interface{}{{
+				"id":           "#sig",
+				"type":         "JsonWebKey2020",
+				"publicKeyJwk": keys.Ed25519PublicKeyToJWK(signingKey.Public().(ed25519.PublicKey), "sig"),
+			}},
+			"authentication": []string{"#sig"},
+		},
+	})
+	if state.DIDState.DID == "" {
+		t.Fatalf("client-managed create should report the DID while waiting: %+v", state)
+	}
+	state = env.mustFinish(t, "/1.0/create", state)
+	didStr := state.DIDState.DID
+	if doc := state.DIDState.DIDDocument; len(doc.Authentication) != 1 || doc.Authentication[0] != didStr+"#sig" {
+		t.Errorf("unexpected created document: %+v", doc)
+	}
+	if keys.KeyFileExists(didStr, env.cfg.DataDir.KeysDir) {
+		t.Error("client-managed mode must not write a key file")
+	}
+
+	// Update: sign the returned signing input and send back only the signature
+	status, state := env.post(t, "/1.0/update", map[string]interface{}{
+		"did":                  didStr,
+		"options":              RegistrarOptions{ClientSecretMode: true, UpdateKey: updateJWK, NextUpdateKey: nextUpdateJWK},
+		"didDocumentOperation": []string{DocumentOperationAdd},
+		"didDocument":          []map[string]interface{}{{"service": []map[string]string{{"id": "#hub", "type": "IdentityHub", "serviceEndpoint": "https://hub.example.com"}}}},
+	})
+	if status != http.StatusAccepted || state.DIDState.State != StateAction || state.DIDState.Action != ActionSignPayload {
+		t.Fatalf("update: status %d, state %+v", status, state.DIDState)
+	}
+	req, ok := state.DIDState.SigningRequest["signingRequest-update"]
+	if !ok || req.Alg != signing.AlgEdDSA || req.PublicKeyJwk.X != updateJWK.X {
+		t.Fatalf("unexpected signing request: %+v", state.DIDState.SigningRequest)
+	}
+	signature := crypto.Base64URLEncode(ed25519.Sign(updateKey, []byte(req.SerializedPayload)))
+	_, state = env.post(t, "/1.0/update", map[string]interface{}{
+		"jobId":  state.JobID,
+		"secret": RegistrarSecret{SigningResponse: map[string]SigningResponse{"signingRequest-update": {Signature: signature}}},
+	})
+	state = env.mustFinish(t, "/1.0/update", state)
+	if len(state.DIDState.DIDDocument.Service) != 1 {
+		t.Errorf("expected the added service, got %+v", state.DIDState.DIDDocument.Service)
+	}
+
+	// Recover: sign the signed data with an ordinary JWS signer and send the JWS
+	_, state = env.post(t, "/1.0/recover", map[string]interface{}{
+		"did": didStr,
+		"options": RegistrarOptions{
+			ClientSecretMode: true,
+			RecoveryKey:      recoveryJWK,
+			NextUpdateKey:    updateJWK,
+			NextRecoveryKey:  nextRecoveryJWK,
+		},
+		"didDocument": map[string]interface{}{
+			"service": []map[string]string{{"id": "#new", "type": "LinkedDomains", "serviceEndpoint": "https://new.example.com"}},
+		},
+	})
+	if state.DIDState.State != StateAction {
+		t.Fatalf("recover: unexpected state %+v", state.DIDState)
+	}
+	recoverReq := state.DIDState.SigningRequest["signingRequest-recover"]
+	state = env.sendJWS(t, "/1.0/recover", state.JobID, "signingRequest-recover", signJWS(t, recoveryKey, recoverReq.SerializedPayload))
+	state = env.mustFinish(t, "/1.0/recover", state)
+	if doc := state.DIDState.DIDDocument; len(doc.Service) != 1 || doc.Service[0].ID != didStr+"#new" || len(doc.VerificationMethod) != 0 {
+		t.Errorf("unexpected recovered document: %+v", doc)
+	}
+
+	// Deactivate with the rotated recovery key
+	_, state = env.post(t, "/1.0/deactivate", map[string]interface{}{
+		"did":     didStr,
+		"options": RegistrarOptions{ClientSecretMode: true, RecoveryKey: nextRecoveryJWK},
+	})
+	deactivateReq := state.DIDState.SigningRequest["signingRequest-deactivate"]
+	state = env.sendJWS(t, "/1.0/deactivate", state.JobID, "signingRequest-deactivate", signJWS(t, nextRecoveryKey, deactivateReq.SerializedPayload))
+	state = env.mustFinish(t, "/1.0/deactivate", state)
+	if !state.DIDDocumentMetadata.Deactivated {
+		t.Error("expected deactivated metadata")
+	}
+}
+
+// sendJWS answers a signing request with a compact JWS
+func (e *testEnv) sendJWS(t *testing.T, path, jobID, requestID, jws string) *RegistrarState {
+	t.Helper()
+	status, state := e.post(t, path, &RegistrarRequest{
+		JobID:  jobID,
+		Secret: RegistrarSecret{SigningResponse: map[string]SigningResponse{requestID: {JWS: jws}}},
+	})
+	if status != http.StatusAccepted {
+		t.Fatalf("signing response rejected: status %d, %s", status, state.DIDState.Reason)
+	}
+	return state
+}
+
+// signJWS signs the payload of a signing input with an ES256 signer, as a client library would
+func signJWS(t *testing.T, key interface{}, signingInput string) string {
+	t.Helper()
+	parts := strings.Split(signingInput, ".")
+	if len(parts) != 2 {
+		t.Fatalf("malformed signing input: %q", signingInput)
+	}
+	payload, err := crypto.Base64URLDecode(parts[1])
+	if err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	signer, err := signing.NewES256Signer(key)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return jws
+}
+
+func TestRegistrarErrors(t *testing.T) {
+	env := newTestEnv(t)
+	didStr := env.createDID(t)
+
+	updateKey, _ := keys.GenerateEd25519Key()
+	pubJWK := keys.Ed25519PublicKeyToJWK(updateKey.Public().(ed25519.PublicKey), "update")
+	privJWK := keys.Ed25519PrivateKeyToJWK(updateKey, "update")
+
+	tests := []struct {
+		name   string
+		path   string
+		body   interface{}
+		status int
+	}{
+		{"unknown job", "/1.0/create", map[string]string{"jobId": "nope"}, http.StatusNotFound},
+		{"client create without keys", "/1.0/create", map[string]interface{}{
+			"options": map[string]bool{"clientSecretMode": true}, "didDocument": map[string]string{},
+		}, http.StatusBadRequest},
+		{"client create with private key", "/1.0/create", map[string]interface{}{
+			"options":     RegistrarOptions{ClientSecretMode: true, UpdateKey: privJWK, RecoveryKey: pubJWK},
+			"didDocument": map[string]string{},
+		}, http.StatusBadRequest},
+		{"internal create with keys", "/1.0/create", map[string]interface{}{
+			"didDocument": map[string]interface{}{"verificationMethod": []map[string]interface{}{{"id": "#k", "publicKeyJwk": pubJWK}}},
+		}, http.StatusBadRequest},
+		{"invalid did", "/1.0/update", map[string]interface{}{"did": "did:example:123", "didDocument": map[string]string{}}, http.StatusBadRequest},
+		{"unknown document operation", "/1.0/update", map[string]interface{}{
+			"did": didStr, "didDocumentOperation": []string{"mergeDidDocument"}, "didDocument": []map[string]string{{}},
+		}, http.StatusBadRequest},
+		{"mismatched documents", "/1.0/update", map[string]interface{}{
+			"did": didStr, "didDocumentOperation": []string{DocumentOperationAdd, DocumentOperationRemove}, "didDocument": []map[string]string{{}},
+		}, http.StatusBadRequest},
+		{"wrong update key", "/1.0/update", map[string]interface{}{
+			"did":         didStr,
+			"options":     RegistrarOptions{ClientSecretMode: true, UpdateKey: pubJWK, NextUpdateKey: pubJWK},
+			"didDocument": map[string]string{},
+		}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, state := env.post(t, tt.path, tt.body)
+			if status != tt.status || state.DIDState.State != StateFailed || state.DIDState.Reason == "" {
+				t.Errorf("status %d, state %+v; want %d failed", status, state.DIDState, tt.status)
+			}
+		})
+	}
+
+	t.Run("malformed body", func(t *testing.T) {
+		resp := env.postRaw(t, "/1.0/create", env.cfg.Registrar.Token, strings.NewReader("{"))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", resp.StatusCode)
+		}
+	})
+}
+
+func TestRegistrarAuthentication(t *testing.T) {
+	env := newTestEnv(t)
+	body := `{"didDocument":{}}`
+
+	for _, token := range []string{"", "wrong-token"} {
+		resp := env.postRaw(t, "/1.0/create", token, strings.NewReader(body))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, resp.StatusCode)
+		}
+		if resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("token %q: missing WWW-Authenticate header", token)
+		}
+	}
+
+	// Without a token only client-managed secret mode is served
+	env.cfg.Registrar.Token = ""
+	status, state := env.post(t, "/1.0/create", map[string]interface{}{"didDocument": map[string]interface{}{}})
+	if status != http.StatusForbidden || state.DIDState.State != StateFailed {
+		t.Errorf("internal secret mode without a token: status %d, state %+v; want 403 failed", status, state.DIDState)
+	}
+
+	updateKey, _ := keys.GenerateEd25519Key()
+	recoveryKey, _ := keys.GenerateEd25519Key()
+	_, state = env.post(t, "/1.0/create", map[string]interface{}{
+		"options": RegistrarOptions{
+			ClientSecretMode: true,
+			UpdateKey:        keys.Ed25519PublicKeyToJWK(updateKey.Public().(ed25519.PublicKey), "update"),
+			RecoveryKey:      keys.Ed25519PublicKeyToJWK(recoveryKey.Public().(ed25519.PublicKey), "recovery"),
+		},
+		"didDocument": map[string]interface{}{},
+	})
+	if state = env.mustFinish(t, "/1.0/create", state); state.DIDState.DID == "" {
+		t.Errorf("client-managed create without a token should succeed: %+v", state.DIDState)
+	}
+}
+
+func TestRegistrarRejectsBadSignature(t *testing.T) {
+	env := newTestEnv(t)
+
+	updateKey, _ := keys.GenerateEd25519Key()
+	recoveryKey, _ := keys.GenerateEd25519Key()
+	updateJWK := keys.Ed25519PublicKeyToJWK(updateKey.Public().(ed25519.PublicKey), "update")
+	recoveryJWK := keys.Ed25519PublicKeyToJWK(recoveryKey.Public().(ed25519.PublicKey), "recovery")
+
+	_, state := env.post(t, "/1.0/create", map[string]interface{}{
+		"options":     RegistrarOptions{ClientSecretMode: true, UpdateKey: updateJWK, RecoveryKey: recoveryJWK},
+		"didDocument": map[string]string{},
+	})
+	didStr := env.mustFinish(t, "/1.0/create", state).DIDState.DID
+
+	_, state = env.post(t, "/1.0/deactivate", map[string]interface{}{
+		"did":     didStr,
+		"options": RegistrarOptions{ClientSecretMode: true, RecoveryKey: recoveryJWK},
+	})
+	jobID := state.JobID
+	input := state.DIDState.SigningRequest["signingRequest-deactivate"].SerializedPayload
+
+	// Signed by the update key instead of the recovery key
+	wrong := crypto.Base64URLEncode(ed25519.Sign(updateKey, []byte(input)))
+	status, failed := env.post(t, "/1.0/deactivate", &RegistrarRequest{
+		JobID:  jobID,
+		Secret: RegistrarSecret{SigningResponse: map[string]SigningResponse{"signingRequest-deactivate": {Signature: wrong}}},
+	})
+	if status != http.StatusBadRequest || !strings.Contains(failed.DIDState.Reason, "invalid signature") {
+		t.Fatalf("status %d, state %+v", status, failed.DIDState)
+	}
+
+	// The job still waits for a valid signature
+	_, state = env.post(t, "/1.0/deactivate", &RegistrarRequest{JobID: jobID})
+	if state.DIDState.State != StateAction {
+		t.Fatalf("job should stay in action state, got %s", state.DIDState.State)
+	}
+	// Of two valid signing responses sent together, only one submits the operation
+	right := crypto.Base64URLEncode(ed25519.Sign(recoveryKey, []byte(input)))
+	statuses := make([]int, 2)
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i], _ = env.post(t, "/1.0/deactivate", &RegistrarRequest{
+				JobID:  jobID,
+				Secret: RegistrarSecret{SigningResponse: map[string]SigningResponse{"signingRequest-deactivate": {Signature: right}}},
+			})
+		}()
+	}
+	wg.Wait()
+	if (statuses[0] == http.StatusAccepted) == (statuses[1] == http.StatusAccepted) {
+		t.Fatalf("want exactly one signing response accepted, got statuses %v", statuses)
+	}
+	_, state = env.post(t, "/1.0/deactivate", &RegistrarRequest{JobID: jobID})
+	env.mustFinish(t, "/1.0/deactivate", state)
+
+	record, err := env.store.GetDID(didStr)
+	if err != nil || record.Status != "deactivated" {
+		t.Errorf("DID not deactivated: %+v (%v)", record, err)
+	}
+}
+
+func TestRegistrarJobLimits(t *testing.T) {
+	env := newTestEnv(t)
+	reg := NewRegistrar(env.cfg, env.store, env.client)
+
+	// Jobs nobody finishes count against the limit until they expire
+	var first *job
+	for i := 0; i < maxOpenJobs; i++ {
+		j, err := reg.newJob(did.OperationTypeUpdate)
+		if err != nil {
+			t.Fatalf("newJob %d failed: %v", i, err)
+		}
+		if first == nil {
+			first = j
+		}
+	}
+	if _, err := reg.newJob(did.OperationTypeUpdate); err == nil {
+		t.Fatal("expected an error beyond the open job limit")
+	}
+	first.updated = time.Now().Add(-2 * jobRetention)
+	if _, err := reg.newJob(did.OperationTypeUpdate); err != nil {
+		t.Fatalf("newJob after expiry failed: %v", err)
+	}
+	if _, ok := reg.jobs[first.id]; ok {
+		t.Error("expired job in the action state was kept")
+	}
+
+	// A DID's lock is dropped once its last job releases it
+	unlock := reg.lockDID("did:char:a")
+	done := make(chan struct{})
+	go func() {
+		reg.lockDID("did:char:a")()
+		close(done)
+	}()
+	unlock()
+	<-done
+	if len(reg.didLocks) != 0 {
+		t.Errorf("%d DID locks left after every job released them", len(reg.didLocks))
+	}
+}
+
+func TestRelativeID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"did:char:abc#key-1", "#key-1"},
+		{"#key-1", "#key-1"},
+		{"key-1", "#key-1"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := relativeID(tt.id); got != tt.want {
+			t.Errorf("relativeID(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Package server exposes did:char over HTTP following the DIF Universal Resolver and Registrar contracts
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/did-char/pkg/cas"
+	"github.com/yourusername/did-char/pkg/did"
+)
+
+// Media types a resolution or dereferencing can be returned in
+const (
+	MediaTypeDIDResolution    = "application/did-resolution"
+	MediaTypeDIDDereferencing = "application/did-url-dereferencing"
+	MediaTypeLDJSON           = "application/ld+json"
+	ProfileDIDResolution      = "https://w3id.org/did-resolution"
+	mediaTypeLDJSONResolution = MediaTypeLDJSON + `;profile="` + ProfileDIDResolution + `"`
+)
+
+// Server serves DID resolution requests from a local did:char store
+type Server struct {
+	resolver *did.Resolver
+	mux      *http.ServeMux
+}
+
+// New creates a server that resolves DIDs with resolver
+//
+// With a non-nil registrar the server also accepts Universal Registrar requests
+// under /1.0/create, /1.0/update, /1.0/recover and /1.0/deactivate.
+func New(resolver *did.Resolver, registrar *Registrar) *Server {
+	s := &Server{
+		resolver: resolver,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /1.0/identifiers/{did}", s.handleResolve)
+	if registrar != nil {
+		registrar.register(s.mux)
+	}
+	return s
+}
+
+// ServeContent also serves anchored batches from content under /cas/<hash>
+//
+// Other nodes can then use this server as their HTTP content store.
+func (s *Server) ServeContent(content cas.Fetcher) {
+	s.mux.Handle("/cas/", http.StripPrefix("/cas", cas.NewHandler(content)))
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleResolve implements GET /1.0/identifiers/{did}
+//
+// The response is the DID document (application/did+ld+json) or the full
+// DID Resolution Result, chosen from the Accept header. Errors are always
+// returned as a resolution result. DID URLs are handed to handleDereference.
+func (s *Server) handleResolve(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		writeResult(w, http.StatusNotAcceptable, MediaTypeDIDResolution, did.NewErrorResult(
+			did.ResolutionErrorRepresentationNotSupported,
+			"supported representations: "+did.MediaTypeDIDLDJSON+", "+MediaTypeDIDResolution,
+		))
+		return
+	}
+	if didURL, ok := requestDIDURL(r); ok {
+		s.handleDereference(w, r, contentType, didURL)
+		return
+	}
+	resultType := contentType
+	if resultType == did.MediaTypeDIDLDJSON {
+		resultType = MediaTypeDIDResolution
+	}
+
+	opts, err := resolveOptions(r)
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, resultType, did.NewErrorResult(did.ResolutionErrorInvalidOptions, err.Error()))
+		return
+	}
+
+	didStr := r.PathValue("did")
+	result, err := s.resolver.ResolveResult(didStr, opts)
+	if err != nil {
+		log.Printf("Failed to resolve %s: %v", didStr, err)
+		writeResult(w, http.StatusInternalServerError, resultType, did.NewErrorResult(did.ResolutionErrorInternal, "internal error"))
+		return
+	}
+
+	if code := result.DIDResolutionMetadata.Error; code != "" {
+		writeResult(w, errorStatus(code), resultType, result)
+		return
+	}
+
+	status := http.StatusOK
+	if result.DIDDocumentMetadata.Deactivated {
+		status = http.StatusGone
+	}
+	if contentType == did.MediaTypeDIDLDJSON {
+		writeJSON(w, status, contentType, result.DIDDocument)
+		return
+	}
+	writeResult(w, status, contentType, result)
+}
+
+// requestDIDURL returns the DID URL a request asks to dereference, if it is not a bare DID
+//
+// The DID URL is normally percent-encoded into the path as a whole. A DID URL
+// query may instead arrive as the request query, which is then merged into it;
+// versionId and versionTime alone keep the request a resolution.
+func requestDIDURL(r *http.Request) (string, bool) {
+	raw := r.PathValue("did")
+	query := r.URL.Query()
+	if !strings.ContainsAny(raw, "/?#") && !query.Has(did.ParamService) && !query.Has(did.ParamRelativeRef) {
+		return "", false
+	}
+
+	base, fragment, hasFragment := strings.Cut(raw, "#")
+	if !strings.Contains(base, "?") && r.URL.RawQuery != "" {
+		base += "?" + r.URL.RawQuery
+	}
+	if hasFragment {
+		base += "#" + fragment
+	}
+	return base, true
+}
+
+// handleDereference dereferences a DID URL for GET /1.0/identifiers/{did}
+//
+// When the client accepts the bare content, a selected verification method or
+// service is returned as application/did+ld+json and a service endpoint URL as
+// a 303 redirect. Otherwise, and for errors, the response is the DID URL
+// Dereferencing Result.
+func (s *Server) handleDereference(w http.ResponseWriter, r *http.Request, contentType, didURL string) {
+	result, err := s.resolver.Dereference(didURL)
+	if err != nil {
+		log.Printf("Failed to dereference %s: %v", didURL, err)
+		result = did.NewDereferencingErrorResult(did.ResolutionErrorInternal, "internal error")
+	}
+
+	if code := result.DereferencingMetadata.Error; code != "" {
+		writeJSON(w, errorStatus(code), MediaTypeDIDDereferencing, result)
+		return
+	}
+
+	status := http.StatusOK
+	if _, ok := result.ContentStream.(*did.DIDDocument); ok && result.ContentMetadata.Deactivated {
+		status = http.StatusGone
+	}
+	if contentType != did.MediaTypeDIDLDJSON {
+		writeJSON(w, status, MediaTypeDIDDereferencing, result)
+		return
+	}
+	if endpoint, ok := result.ContentStream.(string); ok {
+		http.Redirect(w, r, endpoint, http.StatusSeeOther)
+		return
+	}
+	writeJSON(w, status, did.MediaTypeDIDLDJSON, result.ContentStream)
+}
+
+// resolveOptions reads the versionId and versionTime query parameters
+func resolveOptions(r *http.Request) (did.ResolveOptions, error) {
+	var opts did.ResolveOptions
+	query := r.URL.Query()
+
+	if v := query.Get("versionId"); v != "" {
+		ballot, err := strconv.Atoi(v)
+		if err != nil || ballot < 0 {
+			return opts, errors.New("versionId must be a ballot number")
+		}
+		opts.VersionID = &ballot
+	}
+	if v := query.Get("versionTime"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, errors.New("versionTime must be an RFC 3339 date-time")
+		}
+		opts.VersionTime = t
+	}
+
+	return opts, nil
+}
+
+// negotiate picks the response media type for an Accept header
+//
+// A missing or wildcard Accept gets the resolution result. Among the supported
+// types, the one with the highest quality value wins.
+func negotiate(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return MediaTypeDIDResolution, true
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		var candidate string
+		switch mediaType {
+		case did.MediaTypeDIDLDJSON:
+			candidate = did.MediaTypeDIDLDJSON
+		case MediaTypeDIDResolution, MediaTypeDIDDereferencing, "*/*", "application/*", "application/json":
+			candidate = MediaTypeDIDResolution
+		case MediaTypeLDJSON:
+			if params["profile"] == ProfileDIDResolution {
+				candidate = mediaTypeLDJSONResolution
+			}
+		}
+		if candidate != "" && q > bestQ {
+			best, bestQ = candidate, q
+		}
+	}
+
+	return best, best != ""
+}
+
+// errorStatus maps a DID Resolution error code to an HTTP status
+func errorStatus(code string) int {
+	switch code {
+	case did.ResolutionErrorInvalidDID, did.ResolutionErrorInvalidDIDURL, did.ResolutionErrorInvalidOptions:
+		return http.StatusBadRequest
+	case did.ResolutionErrorNotFound:
+		return http.StatusNotFound
+	case did.ResolutionErrorRepresentationNotSupported:
+		return http.StatusNotAcceptable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeResult writes a resolution result
+func writeResult(w http.ResponseWriter, status int, contentType string, result *did.ResolutionResult) {
+	writeJSON(w, status, contentType, result)
+}
+
+// writeJSON writes v as an indented JSON response
+func writeJSON(w http.ResponseWriter, status int, contentType string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
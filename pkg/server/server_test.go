@@ -0,0 +1,396 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/yourusername/did-char/pkg/char"
+	"github.com/yourusername/did-char/pkg/char/chartest"
+	"github.com/yourusername/did-char/pkg/config"
+	"github.com/yourusername/did-char/pkg/did"
+	"github.com/yourusername/did-char/pkg/storage"
+)
+
+// testEnv is a resolver and registrar server backed by a fresh store and a fake CHAR node
+type testEnv struct {
+	cfg    *config.Config
+	store  *storage.Store
+	client *char.Client
+	http   *httptest.Server
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	srv := chartest.NewServer(chartest.Options{DecideOnSubmit: true})
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.CHAR = srv.CHARConfig()
+	cfg.DataDir.Path = dir
+	cfg.DataDir.KeysDir = filepath.Join(dir, "keys")
+	cfg.DataDir.DBPath = filepath.Join(dir, "did-char.db")
+	cfg.Database.Path = cfg.DataDir.DBPath
+	cfg.Registrar.Token = "test-token"
+
+	store, err := storage.NewStore(cfg.Database.Path)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	client := char.NewClient(&cfg.CHAR)
+	httpSrv := httptest.NewServer(New(did.NewResolver(store), NewRegistrar(cfg, store, client)))
+	t.Cleanup(httpSrv.Close)
+
+	return &testEnv{cfg: cfg, store: store, client: client, http: httpSrv}
+}
+
+func (e *testEnv) createDID(t *testing.T) string {
+	t.Helper()
+	created, err := did.CreateDID(&did.CreateDIDRequest{}, e.cfg, e.store, e.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	return created.DID
+}
+
+// get requests path with an Accept header and decodes the JSON body into out
+func (e *testEnv) get(t *testing.T, path, accept string, out interface{}) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, e.http.URL+path, nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return resp
+}
+
+func identifierPath(didStr string) string {
+	return "/1.0/identifiers/" + url.PathEscape(didStr)
+}
+
+func TestResolveRepresentations(t *testing.T) {
+	env := newTestEnv(t)
+	didStr := env.createDID(t)
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		document    bool // body is the bare DID document
+	}{
+		{"no accept", "", MediaTypeDIDResolution, false},
+		{"wildcard", "*/*", MediaTypeDIDResolution, false},
+		{"resolution", MediaTypeDIDResolution, MediaTypeDIDResolution, false},
+		{"ld+json profile", `application/ld+json;profile="https://w3id.org/did-resolution"`, mediaTypeLDJSONResolution, false},
+		{"document", did.MediaTypeDIDLDJSON, did.MediaTypeDIDLDJSON, true},
+		{"quality", did.MediaTypeDIDLDJSON + ";q=0.5, " + MediaTypeDIDResolution, MediaTypeDIDResolution, false},
+		{"quality reversed", did.MediaTypeDIDLDJSON + ", " + MediaTypeDIDResolution + ";q=0.5", did.MediaTypeDIDLDJSON, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]json.RawMessage
+			resp := env.get(t, identifierPath(didStr), tt.accept, &body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+			if got := resp.Header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+
+			var id string
+			if tt.document {
+				json.Unmarshal(body["id"], &id)
+			} else {
+				var doc did.DIDDocument
+				if err := json.Unmarshal(body["didDocument"], &doc); err != nil {
+					t.Fatalf("missing didDocument: %v", err)
+				}
+				id = doc.ID
+			}
+			if id != didStr {
+				t.Errorf("document id = %q, want %q", id, didStr)
+			}
+		})
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	env := newTestEnv(t)
+	didStr := env.createDID(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		status int
+		code   string
+	}{
+		{"invalid did", identifierPath("did:example:123"), "", http.StatusBadRequest, did.ResolutionErrorInvalidDID},
+		{"not found", identifierPath("did:char:EiDahaOGH-liLLdDtTxEAdc8i-cfCz-WUcQdRJheMVNn3A"), "", http.StatusNotFound, did.ResolutionErrorNotFound},
+		{"unknown version", identifierPath(didStr) + "?versionId=99999", "", http.StatusNotFound, did.ResolutionErrorNotFound},
+		{"bad versionId", identifierPath(didStr) + "?versionId=abc", "", http.StatusBadRequest, did.ResolutionErrorInvalidOptions},
+		{"bad versionTime", identifierPath(didStr) + "?versionTime=yesterday", "", http.StatusBadRequest, did.ResolutionErrorInvalidOptions},
+		{"combined versions", identifierPath(didStr) + "?versionId=0&versionTime=2026-01-01T00:00:00Z", "", http.StatusBadRequest, did.ResolutionErrorInvalidOptions},
+		{"unsupported representation", identifierPath(didStr), "text/html", http.StatusNotAcceptable, did.ResolutionErrorRepresentationNotSupported},
+		{"error as document request", identifierPath("did:example:123"), did.MediaTypeDIDLDJSON, http.StatusBadRequest, did.ResolutionErrorInvalidDID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result did.ResolutionResult
+			resp := env.get(t, tt.path, tt.accept, &result)
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if result.DIDResolutionMetadata.Error != tt.code {
+				t.Errorf("error = %q, want %q", result.DIDResolutionMetadata.Error, tt.code)
+			}
+			if result.DIDDocument != nil {
+				t.Errorf("error result carries a document: %+v", result.DIDDocument)
+			}
+		})
+	}
+
+	resp := env.get(t, "/1.0/identifiers/"+url.PathEscape(didStr), "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("escaped DID status = %d", resp.StatusCode)
+	}
+	req, _ := http.NewRequest(http.MethodPost, env.http.URL+identifierPath(didStr), nil)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST should not be allowed: %v %v", resp, err)
+	}
+}
+
+func TestResolveVersionsAndDeactivation(t *testing.T) {
+	env := newTestEnv(t)
+	didStr := env.createDID(t)
+	if err := did.DeactivateDID(&did.DeactivateDIDRequest{DID: didStr}, env.cfg, env.store, env.client); err != nil {
+		t.Fatalf("DeactivateDID failed: %v", err)
+	}
+	ops, err := env.store.GetOperations(didStr)
+	if err != nil || len(ops) != 2 {
+		t.Fatalf("expected 2 operations, got %d (%v)", len(ops), err)
+	}
+
+	var latest did.ResolutionResult
+	resp := env.get(t, identifierPath(didStr), "", &latest)
+	if resp.StatusCode != http.StatusGone {
+		t.Errorf("deactivated status = %d, want 410", resp.StatusCode)
+	}
+	if !latest.DIDDocumentMetadata.Deactivated || latest.DIDDocument == nil {
+		t.Errorf("deactivated result should carry metadata and document: %+v", latest)
+	}
+
+	var doc did.DIDDocument
+	resp = env.get(t, identifierPath(didStr), did.MediaTypeDIDLDJSON, &doc)
+	if resp.StatusCode != http.StatusGone || doc.ID != didStr {
+		t.Errorf("deactivated document: status %d, id %q", resp.StatusCode, doc.ID)
+	}
+
+	var created did.ResolutionResult
+	resp = env.get(t, identifierPath(didStr)+"?versionId="+strconv.Itoa(ops[0].BallotNumber), "", &created)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("historical status = %d, want 200", resp.StatusCode)
+	}
+	meta := created.DIDDocumentMetadata
+	if meta.Deactivated || meta.VersionID != strconv.Itoa(ops[0].BallotNumber) || meta.NextVersionID != strconv.Itoa(ops[1].BallotNumber) {
+		t.Errorf("unexpected historical metadata: %+v", meta)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", MediaTypeDIDResolution, true},
+		{"application/json", MediaTypeDIDResolution, true},
+		{"application/*;q=0.1, application/did+ld+json;q=0.9", did.MediaTypeDIDLDJSON, true},
+		{"application/ld+json", "", false},
+		{"text/html, image/png", "", false},
+		{"application/did+ld+json;q=0", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := negotiate(tt.accept)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("negotiate(%q) = %q, %v, want %q, %v", tt.accept, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// Resolving must keep working while another connection writes new operations
+func TestResolveDuringWrites(t *testing.T) {
+	env := newTestEnv(t)
+	first := env.createDID(t)
+
+	done := make(chan struct{})
+	writeErr := make(chan error, 1)
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			err := did.UpdateDID(&did.UpdateDIDRequest{
+				DID:         first,
+				AddServices: []did.Service{{ID: fmt.Sprintf("#svc-%d", i), Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}},
+			}, env.cfg, env.store, env.client)
+			if err != nil {
+				writeErr <- err
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				resp, err := http.Get(env.http.URL + identifierPath(first))
+				if err != nil {
+					errs <- err
+					return
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					errs <- fmt.Errorf("status %d", resp.StatusCode)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	select {
+	case err := <-writeErr:
+		t.Fatalf("UpdateDID failed: %v", err)
+	default:
+	}
+	for err := range errs {
+		t.Errorf("concurrent resolve failed: %v", err)
+	}
+
+	var result did.ResolutionResult
+	env.get(t, identifierPath(first), "", &result)
+	if len(result.DIDDocument.Service) != 5 {
+		t.Errorf("expected 5 services after writes, got %d", len(result.DIDDocument.Service))
+	}
+}
+
+func TestDereference(t *testing.T) {
+	env := newTestEnv(t)
+	created, err := did.CreateDID(&did.CreateDIDRequest{
+		Services: []did.Service{{ID: "#files", Type: "LinkedDomains", ServiceEndpoint: "https://files.example.com/store/"}},
+	}, env.cfg, env.store, env.client)
+	if err != nil {
+		t.Fatalf("CreateDID failed: %v", err)
+	}
+	didStr := created.DID
+
+	tests := []struct {
+		name        string
+		path        string
+		accept      string
+		status      int
+		contentType string
+		location    string
+		id          string // id of the returned content, or the error code
+	}{
+		{"method result", identifierPath(didStr + "#key-1"), "", http.StatusOK, MediaTypeDIDDereferencing, "", didStr + "#key-1"},
+		{"method content", identifierPath(didStr + "#key-1"), did.MediaTypeDIDLDJSON, http.StatusOK, did.MediaTypeDIDLDJSON, "", didStr + "#key-1"},
+		{"service content", identifierPath(didStr + "#files"), did.MediaTypeDIDLDJSON, http.StatusOK, did.MediaTypeDIDLDJSON, "", didStr + "#files"},
+		{"endpoint redirect", identifierPath(didStr + "?service=files&relativeRef=%2Fa.txt"), did.MediaTypeDIDLDJSON, http.StatusSeeOther, "", "https://files.example.com/a.txt", ""},
+		{"endpoint from request query", identifierPath(didStr) + "?service=files&relativeRef=b.txt", did.MediaTypeDIDLDJSON, http.StatusSeeOther, "", "https://files.example.com/store/b.txt", ""},
+		{"endpoint result", identifierPath(didStr + "?service=files"), MediaTypeDIDDereferencing, http.StatusOK, MediaTypeDIDDereferencing, "", ""},
+		{"unknown fragment", identifierPath(didStr + "#nope"), did.MediaTypeDIDLDJSON, http.StatusNotFound, MediaTypeDIDDereferencing, "", did.ResolutionErrorNotFound},
+		{"invalid DID URL", identifierPath(didStr + "?relativeRef=%2Fa"), "", http.StatusBadRequest, MediaTypeDIDDereferencing, "", did.ResolutionErrorInvalidDIDURL},
+	}
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, env.http.URL+tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp, err := noRedirect.Do(req)
+			if err != nil {
+				t.Fatalf("GET %s failed: %v", tt.path, err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.location != "" {
+				if got := resp.Header.Get("Location"); got != tt.location {
+					t.Errorf("Location = %q, want %q", got, tt.location)
+				}
+				return
+			}
+			if got := resp.Header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+
+			var body struct {
+				ID                    string                 `json:"id"`
+				ContentStream         json.RawMessage        `json:"contentStream"`
+				DereferencingMetadata did.ResolutionMetadata `json:"dereferencingMetadata"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			switch {
+			case body.DereferencingMetadata.Error != "":
+				if body.DereferencingMetadata.Error != tt.id {
+					t.Errorf("error = %q, want %q", body.DereferencingMetadata.Error, tt.id)
+				}
+			case tt.contentType == did.MediaTypeDIDLDJSON:
+				if body.ID != tt.id {
+					t.Errorf("id = %q, want %q", body.ID, tt.id)
+				}
+			case tt.id == "":
+				if string(body.ContentStream) != `"https://files.example.com/store/"` {
+					t.Errorf("contentStream = %s", body.ContentStream)
+				}
+			default:
+				var content struct{ ID string }
+				json.Unmarshal(body.ContentStream, &content)
+				if content.ID != tt.id {
+					t.Errorf("contentStream id = %q, want %q", content.ID, tt.id)
+				}
+			}
+		})
+	}
+}
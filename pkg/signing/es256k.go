@@ -0,0 +1,184 @@
+package signing
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// ES256K is ECDSA over secp256k1 with SHA-256 (RFC 8812). The signature is R || S,
+// 32 bytes each, over the JWS signing input. Signing is deterministic (RFC 6979)
+// and always produces the low S value.
+
+// ES256KSigner implements Signer for ECDSA secp256k1
+type ES256KSigner struct {
+	privateKey *secp256k1.PrivateKey
+}
+
+// NewES256KSigner creates a new ES256K signer from a secp256k1 private key
+func NewES256KSigner(key interface{}) (*ES256KSigner, error) {
+	privateKey, ok := key.(*secp256k1.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected *secp256k1.PrivateKey, got %T", key)
+	}
+
+	return &ES256KSigner{
+		privateKey: privateKey,
+	}, nil
+}
+
+// Sign creates a JWS compact serialization for the given payload
+func (s *ES256KSigner) Sign(payload []byte) (string, error) {
+	headerJSON, err := json.Marshal(map[string]interface{}{
+		"alg": string(AlgES256K),
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal header: %w", err)
+	}
+
+	signingInput := base64URLEncode(headerJSON) + "." + base64URLEncode(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+	sig := ecdsa.Sign(s.privateKey, hash[:])
+
+	r, sv := sig.R(), sig.S()
+	rBytes, sBytes := r.Bytes(), sv.Bytes()
+	signature := append(rBytes[:], sBytes[:]...)
+
+	return signingInput + "." + base64URLEncode(signature), nil
+}
+
+// Algorithm returns the signature algorithm
+func (s *ES256KSigner) Algorithm() SignatureAlgorithm {
+	return AlgES256K
+}
+
+// PublicKeyJWK returns the public key as a JWK map
+func (s *ES256KSigner) PublicKeyJWK() map[string]interface{} {
+	point := s.privateKey.PubKey().SerializeUncompressed()
+	return map[string]interface{}{
+		"kty": "EC",
+		"crv": "secp256k1",
+		"x":   base64URLEncode(point[1:33]),
+		"y":   base64URLEncode(point[33:65]),
+	}
+}
+
+// ES256KVerifier implements Verifier for ECDSA secp256k1
+type ES256KVerifier struct {
+	publicKey *secp256k1.PublicKey
+}
+
+// NewES256KVerifier creates a new ES256K verifier from a secp256k1 public key
+func NewES256KVerifier(key interface{}) (*ES256KVerifier, error) {
+	publicKey, ok := key.(*secp256k1.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expected *secp256k1.PublicKey, got %T", key)
+	}
+
+	return &ES256KVerifier{
+		publicKey: publicKey,
+	}, nil
+}
+
+// NewES256KVerifierFromJWK creates an ES256K verifier from a JWK map
+func NewES256KVerifierFromJWK(jwk map[string]interface{}) (*ES256KVerifier, error) {
+	kty, _ := jwk["kty"].(string)
+	crv, _ := jwk["crv"].(string)
+
+	if kty != "EC" || crv != "secp256k1" {
+		return nil, fmt.Errorf("invalid key type for ES256K: kty=%s, crv=%s", kty, crv)
+	}
+
+	xStr, _ := jwk["x"].(string)
+	yStr, _ := jwk["y"].(string)
+
+	xBytes, err := base64URLDecode(xStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode x: %w", err)
+	}
+
+	yBytes, err := base64URLDecode(yStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode y: %w", err)
+	}
+
+	if len(xBytes) != 32 || len(yBytes) != 32 {
+		return nil, fmt.Errorf("invalid secp256k1 coordinate size: x=%d, y=%d", len(xBytes), len(yBytes))
+	}
+
+	// ParsePubKey rejects points that are not on the curve
+	publicKey, err := secp256k1.ParsePubKey(append(append([]byte{0x04}, xBytes...), yBytes...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid secp256k1 public key: %w", err)
+	}
+
+	return &ES256KVerifier{
+		publicKey: publicKey,
+	}, nil
+}
+
+// Verify verifies a JWS compact serialization
+func (v *ES256KVerifier) Verify(compact string, expectedPayload []byte) error {
+	parts := strings.Split(compact, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("invalid JWS format: expected 3 parts, got %d", len(parts))
+	}
+
+	headerJSON, err := base64URLDecode(parts[0])
+	if err != nil {
+		return fmt.Errorf("failed to decode header: %w", err)
+	}
+
+	var header map[string]interface{}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return fmt.Errorf("failed to parse header: %w", err)
+	}
+
+	alg, _ := header["alg"].(string)
+	if alg != string(AlgES256K) {
+		return fmt.Errorf("invalid algorithm in header: %s", alg)
+	}
+
+	payload, err := base64URLDecode(parts[1])
+	if err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	signature, err := base64URLDecode(parts[2])
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if len(signature) != 64 {
+		return fmt.Errorf("invalid ES256K signature size: %d", len(signature))
+	}
+
+	var r, s secp256k1.ModNScalar
+	if overflow := r.SetByteSlice(signature[:32]); overflow || r.IsZero() {
+		return fmt.Errorf("invalid ES256K signature")
+	}
+	if overflow := s.SetByteSlice(signature[32:]); overflow || s.IsZero() {
+		return fmt.Errorf("invalid ES256K signature")
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.NewSignature(&r, &s).Verify(hash[:], v.publicKey) {
+		return fmt.Errorf("signature verification failed")
+	}
+
+	// Optionally verify payload matches expected
+	if expectedPayload != nil && string(payload) != string(expectedPayload) {
+		return fmt.Errorf("payload mismatch")
+	}
+
+	return nil
+}
+
+// Algorithm returns the signature algorithm
+func (v *ES256KVerifier) Algorithm() SignatureAlgorithm {
+	return AlgES256K
+}
@@ -0,0 +1,283 @@
+package signing
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Schnorr signatures follow BIP-340: x-only secp256k1 public keys (the point with
+// even y), 64-byte signatures R.x || s and tagged SHA-256 hashes. The message signed
+// is the SHA-256 of the JWS signing input. Signing uses an all-zero aux_rand, which
+// BIP-340 permits, so the same key and payload always give the same signature.
+//
+// There is no registered JOSE algorithm for BIP-340, so the JWS header says "BIP340".
+
+// SchnorrSigner implements Signer for BIP-340 Schnorr over secp256k1
+type SchnorrSigner struct {
+	privateKey *secp256k1.PrivateKey
+}
+
+// NewSchnorrSigner creates a new Schnorr signer from a secp256k1 private key
+func NewSchnorrSigner(key interface{}) (*SchnorrSigner, error) {
+	privateKey, ok := key.(*secp256k1.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected *secp256k1.PrivateKey, got %T", key)
+	}
+
+	return &SchnorrSigner{
+		privateKey: privateKey,
+	}, nil
+}
+
+// Sign creates a JWS compact serialization for the given payload
+func (s *SchnorrSigner) Sign(payload []byte) (string, error) {
+	headerJSON, err := json.Marshal(map[string]interface{}{
+		"alg": string(AlgSchnorr),
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal header: %w", err)
+	}
+
+	signingInput := base64URLEncode(headerJSON) + "." + base64URLEncode(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	signature, err := signBIP340(s.privateKey, hash[:], make([]byte, 32))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64URLEncode(signature), nil
+}
+
+// Algorithm returns the signature algorithm
+func (s *SchnorrSigner) Algorithm() SignatureAlgorithm {
+	return AlgSchnorr
+}
+
+// PublicKeyJWK returns the x-only public key as a JWK map
+func (s *SchnorrSigner) PublicKeyJWK() map[string]interface{} {
+	return map[string]interface{}{
+		"kty": "OKP",
+		"crv": "secp256k1",
+		"x":   base64URLEncode(s.privateKey.PubKey().SerializeCompressed()[1:]),
+	}
+}
+
+// SchnorrVerifier implements Verifier for BIP-340 Schnorr over secp256k1
+type SchnorrVerifier struct {
+	publicKey []byte // x-only, 32 bytes
+}
+
+// NewSchnorrVerifier creates a new Schnorr verifier from a secp256k1 public key
+// Only the x coordinate is used, as BIP-340 requires
+func NewSchnorrVerifier(key interface{}) (*SchnorrVerifier, error) {
+	publicKey, ok := key.(*secp256k1.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expected *secp256k1.PublicKey, got %T", key)
+	}
+
+	return &SchnorrVerifier{
+		publicKey: publicKey.SerializeCompressed()[1:],
+	}, nil
+}
+
+// NewSchnorrVerifierFromJWK creates a Schnorr verifier from a JWK map
+func NewSchnorrVerifierFromJWK(jwk map[string]interface{}) (*SchnorrVerifier, error) {
+	kty, _ := jwk["kty"].(string)
+	crv, _ := jwk["crv"].(string)
+
+	if kty != "OKP" || crv != "secp256k1" {
+		return nil, fmt.Errorf("invalid key type for Schnorr: kty=%s, crv=%s", kty, crv)
+	}
+
+	xStr, _ := jwk["x"].(string)
+	xBytes, err := base64URLDecode(xStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode x: %w", err)
+	}
+
+	if _, err := liftX(xBytes); err != nil {
+		return nil, err
+	}
+
+	return &SchnorrVerifier{
+		publicKey: xBytes,
+	}, nil
+}
+
+// Verify verifies a JWS compact serialization
+func (v *SchnorrVerifier) Verify(compact string, expectedPayload []byte) error {
+	parts := strings.Split(compact, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("invalid JWS format: expected 3 parts, got %d", len(parts))
+	}
+
+	headerJSON, err := base64URLDecode(parts[0])
+	if err != nil {
+		return fmt.Errorf("failed to decode header: %w", err)
+	}
+
+	var header map[string]interface{}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return fmt.Errorf("failed to parse header: %w", err)
+	}
+
+	alg, _ := header["alg"].(string)
+	if alg != string(AlgSchnorr) {
+		return fmt.Errorf("invalid algorithm in header: %s", alg)
+	}
+
+	payload, err := base64URLDecode(parts[1])
+	if err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	signature, err := base64URLDecode(parts[2])
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := verifyBIP340(v.publicKey, hash[:], signature); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	// Optionally verify payload matches expected
+	if expectedPayload != nil && string(payload) != string(expectedPayload) {
+		return fmt.Errorf("payload mismatch")
+	}
+
+	return nil
+}
+
+// Algorithm returns the signature algorithm
+func (v *SchnorrVerifier) Algorithm() SignatureAlgorithm {
+	return AlgSchnorr
+}
+
+// taggedHash computes the BIP-340 tagged hash SHA256(SHA256(tag) || SHA256(tag) || data...)
+func taggedHash(tag string, data ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// liftX returns the point with x coordinate x and even y
+func liftX(x []byte) (*secp256k1.PublicKey, error) {
+	if len(x) != 32 {
+		return nil, fmt.Errorf("invalid x-only public key size: %d", len(x))
+	}
+	// ParsePubKey rejects x >= p and x with no point on the curve
+	publicKey, err := secp256k1.ParsePubKey(append([]byte{secp256k1.PubKeyFormatCompressedEven}, x...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid x-only public key: %w", err)
+	}
+	return publicKey, nil
+}
+
+// signBIP340 signs msg with the BIP-340 signing algorithm and auxiliary data aux
+func signBIP340(privateKey *secp256k1.PrivateKey, msg, aux []byte) ([]byte, error) {
+	if len(aux) != 32 {
+		return nil, fmt.Errorf("invalid aux size: %d", len(aux))
+	}
+
+	// d is negated if needed so that d*G has even y
+	var d secp256k1.ModNScalar
+	d.Set(&privateKey.Key)
+	if d.IsZero() {
+		return nil, fmt.Errorf("invalid secp256k1 private key")
+	}
+	point := privateKey.PubKey().SerializeCompressed()
+	if point[0] == secp256k1.PubKeyFormatCompressedOdd {
+		d.Negate()
+	}
+	px := point[1:]
+
+	// t = bytes(d) xor hash_aux(aux); k' = hash_nonce(t || P.x || msg) mod n
+	dBytes := d.Bytes()
+	t := taggedHash("BIP0340/aux", aux)
+	for i := range t {
+		t[i] ^= dBytes[i]
+	}
+	var k secp256k1.ModNScalar
+	k.SetByteSlice(taggedHash("BIP0340/nonce", t, px, msg))
+	if k.IsZero() {
+		return nil, fmt.Errorf("invalid nonce")
+	}
+
+	// R = k'*G, with k negated if needed so that R has even y
+	var r secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&k, &r)
+	r.ToAffine()
+	if r.Y.IsOdd() {
+		k.Negate()
+	}
+	rx := r.X.Bytes()
+
+	// e = hash_challenge(R.x || P.x || msg) mod n; s = k + e*d
+	var e secp256k1.ModNScalar
+	e.SetByteSlice(taggedHash("BIP0340/challenge", rx[:], px, msg))
+	s := new(secp256k1.ModNScalar).Mul2(&e, &d).Add(&k)
+	sBytes := s.Bytes()
+
+	signature := append(rx[:], sBytes[:]...)
+
+	// BIP-340 recommends checking the signature before releasing it
+	if err := verifyBIP340(px, msg, signature); err != nil {
+		return nil, fmt.Errorf("produced an invalid signature: %w", err)
+	}
+	return signature, nil
+}
+
+// verifyBIP340 checks a 64-byte BIP-340 signature of msg under the x-only public key
+func verifyBIP340(publicKey, msg, signature []byte) error {
+	pub, err := liftX(publicKey)
+	if err != nil {
+		return err
+	}
+	if len(signature) != 64 {
+		return fmt.Errorf("invalid Schnorr signature size: %d", len(signature))
+	}
+
+	var r secp256k1.FieldVal
+	if overflow := r.SetByteSlice(signature[:32]); overflow {
+		return fmt.Errorf("signature r is not a field element")
+	}
+	var s secp256k1.ModNScalar
+	if overflow := s.SetByteSlice(signature[32:]); overflow {
+		return fmt.Errorf("signature s is not less than the curve order")
+	}
+
+	// R = s*G - e*P
+	var e secp256k1.ModNScalar
+	e.SetByteSlice(taggedHash("BIP0340/challenge", signature[:32], publicKey, msg))
+	e.Negate()
+
+	var p, sG, eP, point secp256k1.JacobianPoint
+	pub.AsJacobian(&p)
+	secp256k1.ScalarBaseMultNonConst(&s, &sG)
+	secp256k1.ScalarMultNonConst(&e, &p, &eP)
+	secp256k1.AddNonConst(&sG, &eP, &point)
+
+	if (point.X.IsZero() && point.Y.IsZero()) || point.Z.IsZero() {
+		return fmt.Errorf("signature R is the point at infinity")
+	}
+	point.ToAffine()
+	if point.Y.IsOdd() {
+		return fmt.Errorf("signature R has odd y")
+	}
+	if !point.X.Equals(&r) {
+		return fmt.Errorf("signature R does not match")
+	}
+	return nil
+}
@@ -10,10 +10,14 @@ type SignatureAlgorithm string
 const (
 	// AlgES256 is ECDSA using P-256 curve and SHA-256
 	AlgES256 SignatureAlgorithm = "ES256"
+	// AlgES256K is ECDSA using secp256k1 curve and SHA-256
+	AlgES256K SignatureAlgorithm = "ES256K"
 	// AlgEdDSA is EdDSA using Ed25519 curve
 	AlgEdDSA SignatureAlgorithm = "EdDSA"
 	// AlgBLS is BLS12-381 signature scheme
 	AlgBLS SignatureAlgorithm = "BLS"
+	// AlgSchnorr is BIP-340 Schnorr using x-only secp256k1 keys
+	AlgSchnorr SignatureAlgorithm = "BIP340"
 )
 
 // Signer creates JWS signatures
@@ -39,10 +43,14 @@ func NewSigner(alg SignatureAlgorithm, privateKey interface{}) (Signer, error) {
 	switch alg {
 	case AlgES256:
 		return NewES256Signer(privateKey)
+	case AlgES256K:
+		return NewES256KSigner(privateKey)
 	case AlgEdDSA:
 		return NewEdDSASigner(privateKey)
 	case AlgBLS:
 		return NewBLSSigner(privateKey)
+	case AlgSchnorr:
+		return NewSchnorrSigner(privateKey)
 	default:
 		return nil, fmt.Errorf("unsupported algorithm: %s", alg)
 	}
@@ -53,10 +61,14 @@ func NewVerifier(alg SignatureAlgorithm, publicKey interface{}) (Verifier, error
 	switch alg {
 	case AlgES256:
 		return NewES256Verifier(publicKey)
+	case AlgES256K:
+		return NewES256KVerifier(publicKey)
 	case AlgEdDSA:
 		return NewEdDSAVerifier(publicKey)
 	case AlgBLS:
 		return NewBLSVerifier(publicKey)
+	case AlgSchnorr:
+		return NewSchnorrVerifier(publicKey)
 	default:
 		return nil, fmt.Errorf("unsupported algorithm: %s", alg)
 	}
@@ -70,10 +82,14 @@ func NewVerifierFromJWK(jwk map[string]interface{}) (Verifier, error) {
 	switch {
 	case kty == "EC" && crv == "P-256":
 		return NewES256VerifierFromJWK(jwk)
+	case kty == "EC" && crv == "secp256k1":
+		return NewES256KVerifierFromJWK(jwk)
 	case kty == "OKP" && crv == "Ed25519":
 		return NewEdDSAVerifierFromJWK(jwk)
 	case kty == "OKP" && crv == "BLS12-381-G1":
 		return NewBLSVerifierFromJWK(jwk)
+	case kty == "OKP" && crv == "secp256k1":
+		return NewSchnorrVerifierFromJWK(jwk)
 	default:
 		return nil, fmt.Errorf("unsupported key type: kty=%s, crv=%s", kty, crv)
 	}
@@ -87,10 +103,14 @@ func DetectAlgorithm(jwk map[string]interface{}) (SignatureAlgorithm, error) {
 	switch {
 	case kty == "EC" && crv == "P-256":
 		return AlgES256, nil
+	case kty == "EC" && crv == "secp256k1":
+		return AlgES256K, nil
 	case kty == "OKP" && crv == "Ed25519":
 		return AlgEdDSA, nil
 	case kty == "OKP" && crv == "BLS12-381-G1":
 		return AlgBLS, nil
+	case kty == "OKP" && crv == "secp256k1":
+		return AlgSchnorr, nil
 	default:
 		return "", fmt.Errorf("unsupported key type: kty=%s, crv=%s", kty, crv)
 	}
@@ -1,11 +1,16 @@
 package signing
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"encoding/hex"
+	"strings"
 	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 )
 
 func TestES256SignAndVerify(t *testing.T) {
@@ -81,6 +86,377 @@ func TestES256VerifierFromJWK(t *testing.T) {
 	}
 }
 
+func TestES256KSignAndVerify(t *testing.T) {
+	// Generate key
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// Create signer
+	signer, err := NewES256KSigner(privateKey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	// Sign
+	payload := []byte(`{"test":"data","deltaHash":"jkl012"}`)
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	// RFC 6979 signing is deterministic
+	again, _ := signer.Sign(payload)
+	if again != jws {
+		t.Error("expected the same signature for the same payload")
+	}
+
+	// Create verifier
+	verifier, err := NewES256KVerifier(privateKey.PubKey())
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+
+	// Verify
+	err = verifier.Verify(jws, payload)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+
+	// Verify with wrong payload should fail
+	err = verifier.Verify(jws, []byte(`{"wrong":"payload"}`))
+	if err == nil {
+		t.Fatal("expected verification to fail with wrong payload")
+	}
+
+	// A P-256 signature must not verify as ES256K
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p256Signer, _ := NewES256Signer(p256Key)
+	p256JWS, _ := p256Signer.Sign(payload)
+	if err := verifier.Verify(p256JWS, payload); err == nil {
+		t.Fatal("expected ES256 signature to be rejected")
+	}
+}
+
+func TestES256KVerifierFromJWK(t *testing.T) {
+	// Generate key
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// Create signer
+	signer, err := NewES256KSigner(privateKey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	// Sign
+	payload := []byte(`{"test":"data"}`)
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	// Create verifier from JWK map
+	jwkMap := signer.PublicKeyJWK()
+	verifier, err := NewES256KVerifierFromJWK(jwkMap)
+	if err != nil {
+		t.Fatalf("failed to create verifier from JWK: %v", err)
+	}
+
+	// Verify
+	err = verifier.Verify(jws, payload)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+
+	// A point that is not on the curve is refused
+	offCurve := map[string]interface{}{
+		"kty": "EC",
+		"crv": "secp256k1",
+		"x":   jwkMap["x"],
+		"y":   base64URLEncode(make([]byte, 32)),
+	}
+	if _, err := NewES256KVerifierFromJWK(offCurve); err == nil {
+		t.Fatal("expected off-curve key to be rejected")
+	}
+
+	// A P-256 JWK is not an ES256K key
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p256Signer, _ := NewES256Signer(p256Key)
+	if _, err := NewES256KVerifierFromJWK(p256Signer.PublicKeyJWK()); err == nil || !strings.Contains(err.Error(), "P-256") {
+		t.Fatalf("expected P-256 JWK to be rejected, got %v", err)
+	}
+}
+
+func TestSchnorrSignAndVerify(t *testing.T) {
+	// Generate key
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// Create signer
+	signer, err := NewSchnorrSigner(privateKey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	// Sign
+	payload := []byte(`{"test":"data","deltaHash":"mno345"}`)
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	// Signing is deterministic
+	again, _ := signer.Sign(payload)
+	if again != jws {
+		t.Error("expected the same signature for the same payload")
+	}
+
+	// Create verifier
+	verifier, err := NewSchnorrVerifier(privateKey.PubKey())
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+
+	// Verify
+	err = verifier.Verify(jws, payload)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+
+	// Verify with wrong payload should fail
+	err = verifier.Verify(jws, []byte(`{"wrong":"payload"}`))
+	if err == nil {
+		t.Fatal("expected verification to fail with wrong payload")
+	}
+
+	// An ES256K signature by the same key must not verify as Schnorr
+	ecdsaSigner, _ := NewES256KSigner(privateKey)
+	ecdsaJWS, _ := ecdsaSigner.Sign(payload)
+	if err := verifier.Verify(ecdsaJWS, payload); err == nil {
+		t.Fatal("expected ES256K signature to be rejected")
+	}
+}
+
+func TestSchnorrVerifierFromJWK(t *testing.T) {
+	// Generate key
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	// Create signer
+	signer, err := NewSchnorrSigner(privateKey)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	// Sign
+	payload := []byte(`{"test":"data"}`)
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	// Create verifier from JWK map
+	jwkMap := signer.PublicKeyJWK()
+	if _, ok := jwkMap["y"]; ok {
+		t.Error("Schnorr JWK should be x-only")
+	}
+	verifier, err := NewSchnorrVerifierFromJWK(jwkMap)
+	if err != nil {
+		t.Fatalf("failed to create verifier from JWK: %v", err)
+	}
+
+	// Verify
+	err = verifier.Verify(jws, payload)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+
+	// An x coordinate with no point on the curve is refused (BIP-340 test vector 5)
+	offCurve, _ := hex.DecodeString("EEFDEA4CDB677750A420FEE807EACF21EB9898AE79B9768766E4FAA04A2D4A34")
+	if _, err := NewSchnorrVerifierFromJWK(map[string]interface{}{"kty": "OKP", "crv": "secp256k1", "x": base64URLEncode(offCurve)}); err == nil {
+		t.Fatal("expected off-curve key to be rejected")
+	}
+}
+
+// TestBIP340Vectors runs the test vectors published with BIP-340
+func TestBIP340Vectors(t *testing.T) {
+	tests := []struct {
+		index   int
+		secKey  string
+		pubKey  string
+		auxRand string
+		msg     string
+		sig     string
+		valid   bool
+		comment string
+	}{
+		{
+			index:   0,
+			secKey:  "0000000000000000000000000000000000000000000000000000000000000003",
+			pubKey:  "F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+			auxRand: "0000000000000000000000000000000000000000000000000000000000000000",
+			msg:     "0000000000000000000000000000000000000000000000000000000000000000",
+			sig:     "E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0",
+			valid:   true,
+		},
+		{
+			index:   1,
+			secKey:  "B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF",
+			pubKey:  "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			auxRand: "0000000000000000000000000000000000000000000000000000000000000001",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+			valid:   true,
+		},
+		{
+			index:   2,
+			secKey:  "C90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B14E5C9",
+			pubKey:  "DD308AFEC5777E13121FA72B9CC1B7CC0139715309B086C960E18FD969774EB8",
+			auxRand: "C87AA53824B4D7AE2EB035A2B5BBBCCC080E76CDC6D1692C4B0B62D798E6D906",
+			msg:     "7E2D58D8B3BCDF1ABADEC7829054F90DDA9805AAB56C77333024B9D0A508B75C",
+			sig:     "5831AAEED7B44BB74E5EAB94BA9D4294C49BCF2A60728D8B4C200F50DD313C1BAB745879A5AD954A72C45A91C3A51D3C7ADEA98D82F8481E0E1E03674A6F3FB7",
+			valid:   true,
+		},
+		{
+			index:   3,
+			secKey:  "0B432B2677937381AEF05BB02A66ECD012773062CF3FA2549E44F58ED2401710",
+			pubKey:  "25D1DFF95105F5253C4022F628A996AD3A0D95FBF21D468A1B33F8C160D8F517",
+			auxRand: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF",
+			msg:     "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF",
+			sig:     "7EB0509757E246F19449885651611CB965ECC1A187DD51B64FDA1EDC9637D5EC97582B9CB13DB3933705B32BA982AF5AF25FD78881EBB32771FC5922EFC66EA3",
+			valid:   true,
+		},
+		{
+			index:  4,
+			pubKey: "D69C3509BB99E412E68B0FE8544E72837DFA30746D8BE2AA65975F29D22DC7B9",
+			msg:    "4DF3C3F68FCC83B27E9D42C90431A72499F17875C81A599B566C9889B9696703",
+			sig:    "00000000000000000000003B78CE563F89A0ED9414F5AA28AD0D96D6795F9C6376AFB1548AF603B3EB45C9F8207DEE1060CB71C04E80F593060B07D28308D7F4",
+			valid:  true,
+		},
+		{
+			index:   5,
+			pubKey:  "EEFDEA4CDB677750A420FEE807EACF21EB9898AE79B9768766E4FAA04A2D4A34",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E17776969E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B",
+			valid:   false,
+			comment: "public key not on the curve",
+		},
+		{
+			index:   6,
+			pubKey:  "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "FFF97BD5755EEEA420453A14355235D382F6472F8568A18B2F057A14602975563CC27944640AC607CD107AE10923D9EF7A73C643E166BE5EBEAFA34B1AC553E2",
+			valid:   false,
+			comment: "has_even_y(R) is false",
+		},
+		{
+			index:   7,
+			pubKey:  "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "1FA62E331EDBC21C394792D2AB1100A7B432B013DF3F6FF4F99FCB33E0E1515F28890B3EDB6E7189B630448B515CE4F8622A954CFE545735AAEA5134FCCDB2BD",
+			valid:   false,
+			comment: "negated message",
+		},
+		{
+			index:   8,
+			pubKey:  "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E177769961764B3AA9B2FFCB6EF947B6887A226E8D7C93E00C5ED0C1834FF0D0C2E6DA6",
+			valid:   false,
+			comment: "negated s value",
+		},
+		{
+			index:   9,
+			pubKey:  "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "0000000000000000000000000000000000000000000000000000000000000000123DDA8328AF9C23A94C1FEECFD123BA4FB73476F0D594DCB65C6425BD186051",
+			valid:   false,
+			comment: "sG - eP is infinite",
+		},
+		{
+			index:   10,
+			pubKey:  "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "00000000000000000000000000000000000000000000000000000000000000017615FBAF5AE28864013C099742DEADB4DBA87F11AC6754F93780D5A1837CF197",
+			valid:   false,
+			comment: "sG - eP is infinite",
+		},
+		{
+			index:   11,
+			pubKey:  "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "4A298DACAE57395A15D0795DDBFD1DCB564DA82B0F269BC70A74F8220429BA1D69E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B",
+			valid:   false,
+			comment: "sig[0:32] is not an X coordinate on the curve",
+		},
+		{
+			index:   12,
+			pubKey:  "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F69E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B",
+			valid:   false,
+			comment: "sig[0:32] is equal to field size",
+		},
+		{
+			index:   13,
+			pubKey:  "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E177769FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141",
+			valid:   false,
+			comment: "sig[32:64] is equal to curve order",
+		},
+		{
+			index:   14,
+			pubKey:  "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC30",
+			msg:     "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			sig:     "6CFF5C3BA86C69EA4B7376F31A9BCB4F74C1976089B2D9963DA2E5543E17776969E89B4C5564D00349106B8497785DD7D1D713A8AE82B32FA79D5F7FC407D39B",
+			valid:   false,
+			comment: "public key is not a valid X coordinate because it exceeds the field size",
+		},
+	}
+
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatalf("bad hex %s: %v", s, err)
+		}
+		return b
+	}
+
+	for _, tt := range tests {
+		pubKey, msg, sig := decode(tt.pubKey), decode(tt.msg), decode(tt.sig)
+
+		if tt.secKey != "" {
+			privateKey := secp256k1.PrivKeyFromBytes(decode(tt.secKey))
+			if got := privateKey.PubKey().SerializeCompressed()[1:]; !bytes.Equal(got, pubKey) {
+				t.Errorf("vector %d: public key = %X", tt.index, got)
+			}
+			got, err := signBIP340(privateKey, msg, decode(tt.auxRand))
+			if err != nil {
+				t.Errorf("vector %d: sign failed: %v", tt.index, err)
+			} else if !bytes.Equal(got, sig) {
+				t.Errorf("vector %d: signature = %X", tt.index, got)
+			}
+		}
+
+		err := verifyBIP340(pubKey, msg, sig)
+		if tt.valid && err != nil {
+			t.Errorf("vector %d: expected valid signature: %v", tt.index, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("vector %d: expected invalid signature (%s)", tt.index, tt.comment)
+		}
+	}
+}
+
 func TestEdDSASignAndVerify(t *testing.T) {
 	// Generate key
 	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
@@ -243,6 +619,14 @@ func TestNewVerifierFromJWK(t *testing.T) {
 			},
 			wantAlg: AlgES256,
 		},
+		{
+			name: "ES256K",
+			setup: func() (Signer, error) {
+				key, _ := secp256k1.GeneratePrivateKey()
+				return NewES256KSigner(key)
+			},
+			wantAlg: AlgES256K,
+		},
 		{
 			name: "EdDSA",
 			setup: func() (Signer, error) {
@@ -259,6 +643,14 @@ func TestNewVerifierFromJWK(t *testing.T) {
 			},
 			wantAlg: AlgBLS,
 		},
+		{
+			name: "BIP340",
+			setup: func() (Signer, error) {
+				key, _ := secp256k1.GeneratePrivateKey()
+				return NewSchnorrSigner(key)
+			},
+			wantAlg: AlgSchnorr,
+		},
 	}
 
 	for _, tt := range tests {
@@ -307,6 +699,11 @@ func TestDetectAlgorithm(t *testing.T) {
 			jwk:     map[string]interface{}{"kty": "EC", "crv": "P-256", "x": "test", "y": "test"},
 			wantAlg: AlgES256,
 		},
+		{
+			name:    "ES256K",
+			jwk:     map[string]interface{}{"kty": "EC", "crv": "secp256k1", "x": "test", "y": "test"},
+			wantAlg: AlgES256K,
+		},
 		{
 			name:    "EdDSA",
 			jwk:     map[string]interface{}{"kty": "OKP", "crv": "Ed25519", "x": "test"},
@@ -317,6 +714,11 @@ func TestDetectAlgorithm(t *testing.T) {
 			jwk:     map[string]interface{}{"kty": "OKP", "crv": "BLS12-381-G1", "x": "test"},
 			wantAlg: AlgBLS,
 		},
+		{
+			name:    "BIP340",
+			jwk:     map[string]interface{}{"kty": "OKP", "crv": "secp256k1", "x": "test"},
+			wantAlg: AlgSchnorr,
+		},
 		{
 			name:    "unsupported",
 			jwk:     map[string]interface{}{"kty": "RSA"},
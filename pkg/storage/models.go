@@ -20,17 +20,18 @@ type DIDRecord struct {
 
 // OperationRecord represents an operation in the database
 type OperationRecord struct {
-	ID            int
-	DID           string
-	BallotNumber  int
-	OperationType string
-	OperationData string
-	CreatedAt     time.Time
+	ID             int
+	DID            string
+	BallotNumber   int
+	OperationIndex int // Position of the operation in its ballot's payload
+	OperationType  string
+	OperationData  string
+	CreatedAt      time.Time
 }
 
 // SaveDID saves or updates a DID record
 func (s *Store) SaveDID(record *DIDRecord) error {
-	_, err := s.db.Exec(`
+	_, err := s.q.Exec(`
 		INSERT INTO dids (
 			did, status, document, update_commitment, recovery_commitment,
 			created_at_ballot, last_operation_ballot, updated_at
@@ -50,7 +51,7 @@ func (s *Store) SaveDID(record *DIDRecord) error {
 // GetDID retrieves a DID record by DID
 func (s *Store) GetDID(did string) (*DIDRecord, error) {
 	record := &DIDRecord{}
-	err := s.db.QueryRow(`
+	err := s.q.QueryRow(`
 		SELECT did, status, document, update_commitment, recovery_commitment,
 			   created_at_ballot, last_operation_ballot, created_at, updated_at
 		FROM dids WHERE did = ?
@@ -67,29 +68,47 @@ func (s *Store) GetDID(did string) (*DIDRecord, error) {
 
 // SaveOperation saves an operation record
 func (s *Store) SaveOperation(record *OperationRecord) error {
-	_, err := s.db.Exec(`
-		INSERT INTO operations (did, ballot_number, operation_type, operation_data)
-		VALUES (?, ?, ?, ?)
-	`, record.DID, record.BallotNumber, record.OperationType, record.OperationData)
+	_, err := s.q.Exec(`
+		INSERT INTO operations (did, ballot_number, operation_index, operation_type, operation_data)
+		VALUES (?, ?, ?, ?, ?)
+	`, record.DID, record.BallotNumber, record.OperationIndex, record.OperationType, record.OperationData)
 	return err
 }
 
 // GetOperations retrieves all operations for a DID
 func (s *Store) GetOperations(did string) ([]*OperationRecord, error) {
-	rows, err := s.db.Query(`
-		SELECT id, did, ballot_number, operation_type, operation_data, created_at
+	rows, err := s.q.Query(`
+		SELECT id, did, ballot_number, operation_index, operation_type, operation_data, created_at
 		FROM operations WHERE did = ?
-		ORDER BY ballot_number ASC
+		ORDER BY ballot_number ASC, operation_index ASC
 	`, did)
 	if err != nil {
 		return nil, err
 	}
+	return scanOperations(rows)
+}
+
+// GetOperationsAtBallot retrieves the operations applied from a ballot, in payload order
+func (s *Store) GetOperationsAtBallot(ballotNumber int) ([]*OperationRecord, error) {
+	rows, err := s.q.Query(`
+		SELECT id, did, ballot_number, operation_index, operation_type, operation_data, created_at
+		FROM operations WHERE ballot_number = ?
+		ORDER BY operation_index ASC
+	`, ballotNumber)
+	if err != nil {
+		return nil, err
+	}
+	return scanOperations(rows)
+}
+
+// scanOperations reads operation rows and closes them
+func scanOperations(rows *sql.Rows) ([]*OperationRecord, error) {
 	defer rows.Close()
 
 	var ops []*OperationRecord
 	for rows.Next() {
 		op := &OperationRecord{}
-		if err := rows.Scan(&op.ID, &op.DID, &op.BallotNumber, &op.OperationType, &op.OperationData, &op.CreatedAt); err != nil {
+		if err := rows.Scan(&op.ID, &op.DID, &op.BallotNumber, &op.OperationIndex, &op.OperationType, &op.OperationData, &op.CreatedAt); err != nil {
 			return nil, err
 		}
 		ops = append(ops, op)
@@ -100,13 +119,13 @@ func (s *Store) GetOperations(did string) ([]*OperationRecord, error) {
 // GetLastBallotNumber gets the highest ballot number processed
 func (s *Store) GetLastBallotNumber() (int, error) {
 	var ballot int
-	err := s.db.QueryRow("SELECT COALESCE(MAX(ballot_number), -1) FROM operations").Scan(&ballot)
+	err := s.q.QueryRow("SELECT COALESCE(MAX(ballot_number), -1) FROM operations").Scan(&ballot)
 	return ballot, err
 }
 
 // GetAllDIDs retrieves all DIDs
 func (s *Store) GetAllDIDs() ([]*DIDRecord, error) {
-	rows, err := s.db.Query(`
+	rows, err := s.q.Query(`
 		SELECT did, status, document, update_commitment, recovery_commitment,
 			   created_at_ballot, last_operation_ballot, created_at, updated_at
 		FROM dids
@@ -137,9 +156,9 @@ func (s *Store) GetDIDCount(status string) (int, error) {
 	var count int
 	var err error
 	if status == "" {
-		err = s.db.QueryRow("SELECT COUNT(*) FROM dids").Scan(&count)
+		err = s.q.QueryRow("SELECT COUNT(*) FROM dids").Scan(&count)
 	} else {
-		err = s.db.QueryRow("SELECT COUNT(*) FROM dids WHERE status = ?", status).Scan(&count)
+		err = s.q.QueryRow("SELECT COUNT(*) FROM dids WHERE status = ?", status).Scan(&count)
 	}
 	return count, err
 }
@@ -147,37 +166,294 @@ func (s *Store) GetDIDCount(status string) (int, error) {
 // GetOperationCount returns the total number of operations
 func (s *Store) GetOperationCount() (int, error) {
 	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM operations").Scan(&count)
+	err := s.q.QueryRow("SELECT COUNT(*) FROM operations").Scan(&count)
 	return count, err
 }
 
 // DIDExists checks if a DID exists
 func (s *Store) DIDExists(did string) (bool, error) {
 	var exists bool
-	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM dids WHERE did = ?)", did).Scan(&exists)
+	err := s.q.QueryRow("SELECT EXISTS(SELECT 1 FROM dids WHERE did = ?)", did).Scan(&exists)
+	return exists, err
+}
+
+// OperationExistsAtBallot checks if any operation has been recorded for a ballot
+func (s *Store) OperationExistsAtBallot(ballotNumber int) (bool, error) {
+	var exists bool
+	err := s.q.QueryRow("SELECT EXISTS(SELECT 1 FROM operations WHERE ballot_number = ?)", ballotNumber).Scan(&exists)
 	return exists, err
 }
 
 // GetRecentOperations gets the N most recent operations
 func (s *Store) GetRecentOperations(limit int) ([]*OperationRecord, error) {
-	rows, err := s.db.Query(`
-		SELECT id, did, ballot_number, operation_type, operation_data, created_at
+	rows, err := s.q.Query(`
+		SELECT id, did, ballot_number, operation_index, operation_type, operation_data, created_at
 		FROM operations
-		ORDER BY ballot_number DESC
+		ORDER BY ballot_number DESC, operation_index DESC
 		LIMIT ?
 	`, limit)
 	if err != nil {
 		return nil, err
 	}
+	return scanOperations(rows)
+}
+
+// DocumentVersionRecord is the state of a DID right after an operation was applied
+type DocumentVersionRecord struct {
+	DID           string
+	BallotNumber  int // Ballot of the operation that produced this version
+	OperationType string
+	Status        string
+	Document      string
+	CreatedAt     time.Time
+}
+
+// SaveDocumentVersion records the state produced by an operation
+func (s *Store) SaveDocumentVersion(record *DocumentVersionRecord) error {
+	_, err := s.q.Exec(`
+		INSERT INTO document_versions (did, ballot_number, operation_type, status, document)
+		VALUES (?, ?, ?, ?, ?)
+	`, record.DID, record.BallotNumber, record.OperationType, record.Status, record.Document)
+	return err
+}
+
+// GetDocumentVersions retrieves all recorded versions of a DID, oldest first
+func (s *Store) GetDocumentVersions(did string) ([]*DocumentVersionRecord, error) {
+	rows, err := s.q.Query(`
+		SELECT did, ballot_number, operation_type, status, document, created_at
+		FROM document_versions WHERE did = ?
+		ORDER BY ballot_number ASC
+	`, did)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	var ops []*OperationRecord
+	var versions []*DocumentVersionRecord
 	for rows.Next() {
-		op := &OperationRecord{}
-		if err := rows.Scan(&op.ID, &op.DID, &op.BallotNumber, &op.OperationType, &op.OperationData, &op.CreatedAt); err != nil {
+		v := &DocumentVersionRecord{}
+		if err := rows.Scan(&v.DID, &v.BallotNumber, &v.OperationType, &v.Status, &v.Document, &v.CreatedAt); err != nil {
 			return nil, err
 		}
-		ops = append(ops, op)
+		versions = append(versions, v)
 	}
-	return ops, rows.Err()
+	return versions, rows.Err()
+}
+
+// RejectedOperationRecord represents a decided operation that failed validation and was ignored
+type RejectedOperationRecord struct {
+	ID             int
+	BallotNumber   int
+	OperationIndex int    // Position of the operation in its ballot's payload (0 if the payload could not be decoded)
+	DID            string // Empty if the payload could not be decoded
+	OperationType  string // Empty if the payload could not be decoded
+	Payload        string // Payload hex as decided, with CHAR wrappers stripped unless they were malformed
+	Reason         string
+	CreatedAt      time.Time
+}
+
+// SaveRejectedOperation records a rejected operation. Recording the same operation again is a no-op.
+func (s *Store) SaveRejectedOperation(record *RejectedOperationRecord) error {
+	_, err := s.q.Exec(`
+		INSERT INTO rejected_operations (ballot_number, operation_index, did, operation_type, payload, reason)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(ballot_number, operation_index) DO NOTHING
+	`, record.BallotNumber, record.OperationIndex, record.DID, record.OperationType, record.Payload, record.Reason)
+	return err
+}
+
+// RejectedOperationExistsAtBallot checks if any rejected operation has been recorded for a ballot
+func (s *Store) RejectedOperationExistsAtBallot(ballotNumber int) (bool, error) {
+	var exists bool
+	err := s.q.QueryRow("SELECT EXISTS(SELECT 1 FROM rejected_operations WHERE ballot_number = ?)", ballotNumber).Scan(&exists)
+	return exists, err
+}
+
+// GetRejectedOperations retrieves rejected operations, newest first
+// An empty did returns rejections for all DIDs; a limit of 0 or less returns all of them.
+func (s *Store) GetRejectedOperations(did string, limit int) ([]*RejectedOperationRecord, error) {
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+
+	query := `
+		SELECT id, ballot_number, operation_index, did, operation_type, payload, reason, created_at
+		FROM rejected_operations`
+	args := []interface{}{}
+	if did != "" {
+		query += " WHERE did = ?"
+		args = append(args, did)
+	}
+	query += " ORDER BY ballot_number DESC, operation_index DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRejectedOperations(rows)
+}
+
+// GetRejectedOperationsAtBallot retrieves the operations rejected from a ballot, in payload order
+func (s *Store) GetRejectedOperationsAtBallot(ballotNumber int) ([]*RejectedOperationRecord, error) {
+	rows, err := s.q.Query(`
+		SELECT id, ballot_number, operation_index, did, operation_type, payload, reason, created_at
+		FROM rejected_operations WHERE ballot_number = ?
+		ORDER BY operation_index ASC
+	`, ballotNumber)
+	if err != nil {
+		return nil, err
+	}
+	return scanRejectedOperations(rows)
+}
+
+// scanRejectedOperations reads rejected operation rows and closes them
+func scanRejectedOperations(rows *sql.Rows) ([]*RejectedOperationRecord, error) {
+	defer rows.Close()
+
+	var records []*RejectedOperationRecord
+	for rows.Next() {
+		r := &RejectedOperationRecord{}
+		if err := rows.Scan(&r.ID, &r.BallotNumber, &r.OperationIndex, &r.DID, &r.OperationType, &r.Payload, &r.Reason, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// GetRejectedOperationCount returns the total number of rejected operations
+func (s *Store) GetRejectedOperationCount() (int, error) {
+	var count int
+	err := s.q.QueryRow("SELECT COUNT(*) FROM rejected_operations").Scan(&count)
+	return count, err
+}
+
+// UnresolvableBatchRecord is a decided anchor whose operations could not be fetched yet
+type UnresolvableBatchRecord struct {
+	BallotNumber   int
+	ContentHash    string
+	OperationCount int
+	Payload        string // Anchor payload hex as decided, with CHAR wrappers stripped
+	Reason         string // Why the content could not be fetched on the last attempt
+	Attempts       int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// SaveUnresolvableBatch records a failed attempt to fetch a batch, counting repeated attempts
+func (s *Store) SaveUnresolvableBatch(record *UnresolvableBatchRecord) error {
+	_, err := s.q.Exec(`
+		INSERT INTO unresolvable_batches (ballot_number, content_hash, operation_count, payload, reason)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(ballot_number) DO UPDATE SET
+			reason = excluded.reason,
+			attempts = attempts + 1,
+			updated_at = CURRENT_TIMESTAMP
+	`, record.BallotNumber, record.ContentHash, record.OperationCount, record.Payload, record.Reason)
+	return err
+}
+
+// DeleteUnresolvableBatch forgets a batch once it has been resolved
+func (s *Store) DeleteUnresolvableBatch(ballotNumber int) error {
+	_, err := s.q.Exec("DELETE FROM unresolvable_batches WHERE ballot_number = ?", ballotNumber)
+	return err
+}
+
+// UnresolvableBatchExistsAtBallot checks if a ballot's batch is waiting for its content
+func (s *Store) UnresolvableBatchExistsAtBallot(ballotNumber int) (bool, error) {
+	var exists bool
+	err := s.q.QueryRow("SELECT EXISTS(SELECT 1 FROM unresolvable_batches WHERE ballot_number = ?)", ballotNumber).Scan(&exists)
+	return exists, err
+}
+
+// GetUnresolvableBatches retrieves batches waiting for their content, oldest ballot first
+func (s *Store) GetUnresolvableBatches() ([]*UnresolvableBatchRecord, error) {
+	rows, err := s.q.Query(`
+		SELECT ballot_number, content_hash, operation_count, payload, reason, attempts, created_at, updated_at
+		FROM unresolvable_batches
+		ORDER BY ballot_number ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*UnresolvableBatchRecord
+	for rows.Next() {
+		r := &UnresolvableBatchRecord{}
+		if err := rows.Scan(&r.BallotNumber, &r.ContentHash, &r.OperationCount, &r.Payload, &r.Reason, &r.Attempts, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// BallotTimeRecord bounds when a ballot was decided, as far as this node has seen
+//
+// The ballot was decided after DecidedAfter and at or before DecidedAt. DecidedAfter
+// is zero when the node did not see the ballot undecided, such as when it synced the
+// ballot after the fact; DecidedAt is then only when the node caught up.
+type BallotTimeRecord struct {
+	BallotNumber int
+	DecidedAt    time.Time
+	DecidedAfter time.Time
+}
+
+// Bounded reports whether the ballot was seen undecided, so its decision time is known
+// to within DecidedAfter and DecidedAt
+func (r *BallotTimeRecord) Bounded() bool {
+	return !r.DecidedAfter.IsZero()
+}
+
+// Uncertainty returns how far DecidedAt may be from when the ballot was decided
+func (r *BallotTimeRecord) Uncertainty() time.Duration {
+	return r.DecidedAt.Sub(r.DecidedAfter)
+}
+
+// RecordBallotTime records when a ballot was first seen decided and, if known, when
+// it or an earlier ballot was last seen undecided (zero if not known)
+// Only the first times recorded for a ballot are kept, so they never move.
+func (s *Store) RecordBallotTime(ballotNumber int, decidedAfter, decidedAt time.Time) error {
+	var after sql.NullInt64
+	if !decidedAfter.IsZero() {
+		after = sql.NullInt64{Int64: decidedAfter.UnixMilli(), Valid: true}
+	}
+	_, err := s.q.Exec(`
+		INSERT INTO ballot_times (ballot_number, decided_at, decided_after)
+		VALUES (?, ?, ?)
+		ON CONFLICT(ballot_number) DO NOTHING
+	`, ballotNumber, decidedAt.UnixMilli(), after)
+	return err
+}
+
+// GetBallotTime retrieves the recorded times of a ballot, or nil if they were not recorded
+func (s *Store) GetBallotTime(ballotNumber int) (*BallotTimeRecord, error) {
+	var at int64
+	var after sql.NullInt64
+	err := s.q.QueryRow("SELECT decided_at, decided_after FROM ballot_times WHERE ballot_number = ?", ballotNumber).Scan(&at, &after)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := &BallotTimeRecord{BallotNumber: ballotNumber, DecidedAt: time.UnixMilli(at).UTC()}
+	if after.Valid {
+		record.DecidedAfter = time.UnixMilli(after.Int64).UTC()
+	}
+	return record, nil
+}
+
+// GetLastBallotDecidedBy retrieves the last recorded ballot first seen decided at or before t,
+// or -1 if there is none
+func (s *Store) GetLastBallotDecidedBy(t time.Time) (int, error) {
+	var ballot sql.NullInt64
+	err := s.q.QueryRow("SELECT MAX(ballot_number) FROM ballot_times WHERE decided_at <= ?", t.UnixMilli()).Scan(&ballot)
+	if err != nil || !ballot.Valid {
+		return -1, err
+	}
+	return int(ballot.Int64), nil
 }
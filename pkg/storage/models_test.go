@@ -0,0 +1,392 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRejectedOperations(t *testing.T) {
+	store := newTestStore(t)
+
+	records := []*RejectedOperationRecord{
+		{BallotNumber: 3, DID: "did:char:a", OperationType: "update", Payload: "01", Reason: "bad reveal"},
+		{BallotNumber: 7, Payload: "ff", Reason: "failed to decode payload"},
+		{BallotNumber: 9, DID: "did:char:a", OperationType: "deactivate", Payload: "02", Reason: "bad signature"},
+	}
+	for _, r := range records {
+		if err := store.SaveRejectedOperation(r); err != nil {
+			t.Fatalf("SaveRejectedOperation failed: %v", err)
+		}
+	}
+
+	// Recording a ballot twice keeps the first record
+	if err := store.SaveRejectedOperation(&RejectedOperationRecord{BallotNumber: 3, Payload: "00", Reason: "other"}); err != nil {
+		t.Fatalf("SaveRejectedOperation failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		did     string
+		limit   int
+		ballots []int
+	}{
+		{"all", "", 0, []int{9, 7, 3}},
+		{"limited", "", 2, []int{9, 7}},
+		{"by DID", "did:char:a", 0, []int{9, 3}},
+		{"unknown DID", "did:char:b", 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.GetRejectedOperations(tt.did, tt.limit)
+			if err != nil {
+				t.Fatalf("GetRejectedOperations failed: %v", err)
+			}
+			if len(got) != len(tt.ballots) {
+				t.Fatalf("got %d records, want %d", len(got), len(tt.ballots))
+			}
+			for i, r := range got {
+				if r.BallotNumber != tt.ballots[i] {
+					t.Errorf("record %d ballot = %d, want %d", i, r.BallotNumber, tt.ballots[i])
+				}
+			}
+		})
+	}
+
+	first, _ := store.GetRejectedOperations("did:char:a", 0)
+	if first[1].Reason != "bad reveal" {
+		t.Errorf("duplicate record overwrote reason: %q", first[1].Reason)
+	}
+
+	if exists, _ := store.RejectedOperationExistsAtBallot(7); !exists {
+		t.Error("ballot 7 should be recorded as rejected")
+	}
+	if count, _ := store.GetRejectedOperationCount(); count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+}
+
+func TestDocumentVersions(t *testing.T) {
+	store := newTestStore(t)
+
+	for _, v := range []*DocumentVersionRecord{
+		{DID: "did:char:a", BallotNumber: 5, OperationType: "update", Status: "active", Document: `{"v":2}`},
+		{DID: "did:char:a", BallotNumber: 1, OperationType: "create", Status: "active", Document: `{"v":1}`},
+		{DID: "did:char:b", BallotNumber: 2, OperationType: "create", Status: "active", Document: `{}`},
+	} {
+		if err := store.SaveDocumentVersion(v); err != nil {
+			t.Fatalf("SaveDocumentVersion failed: %v", err)
+		}
+	}
+
+	// A DID has at most one version per ballot
+	if err := store.SaveDocumentVersion(&DocumentVersionRecord{DID: "did:char:a", BallotNumber: 5, OperationType: "update", Status: "active", Document: `{}`}); err == nil {
+		t.Error("expected duplicate version to fail")
+	}
+
+	versions, err := store.GetDocumentVersions("did:char:a")
+	if err != nil {
+		t.Fatalf("GetDocumentVersions failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0].BallotNumber != 1 || versions[1].Document != `{"v":2}` {
+		t.Errorf("unexpected versions: %+v %+v", versions[0], versions[1])
+	}
+}
+
+func TestMigrationSeedsDocumentVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	// State written without versions, as by releases that did not record them.
+	// did:char:b was created in the batch that deactivated did:char:a.
+	record := testDIDRecord("did:char:a", 1)
+	record.LastOperationBallot = 4
+	record.Status = "deactivated"
+	if err := store.SaveDID(record); err != nil {
+		t.Fatalf("SaveDID failed: %v", err)
+	}
+	if err := store.SaveDID(testDIDRecord("did:char:b", 4)); err != nil {
+		t.Fatalf("SaveDID failed: %v", err)
+	}
+	for _, op := range []*OperationRecord{
+		{DID: "did:char:a", BallotNumber: 1, OperationType: "create"},
+		{DID: "did:char:b", BallotNumber: 4, OperationType: "create"},
+		{DID: "did:char:a", BallotNumber: 4, OperationIndex: 1, OperationType: "deactivate"},
+	} {
+		op.OperationData = "{}"
+		if err := store.SaveOperation(op); err != nil {
+			t.Fatalf("SaveOperation failed: %v", err)
+		}
+	}
+	store.Close()
+	setUserVersion(t, path, 0)
+
+	store, err = NewStore(path)
+	if err != nil {
+		t.Fatalf("reopening store failed: %v", err)
+	}
+
+	versions, err := store.GetDocumentVersions("did:char:a")
+	if err != nil {
+		t.Fatalf("GetDocumentVersions failed: %v", err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("expected 1 seeded version, got %d", len(versions))
+	}
+	if v := versions[0]; v.BallotNumber != 4 || v.OperationType != "deactivate" || v.Status != "deactivated" {
+		t.Errorf("unexpected seeded version: %+v", v)
+	}
+	versions, _ = store.GetDocumentVersions("did:char:b")
+	if len(versions) != 1 || versions[0].OperationType != "create" {
+		t.Errorf("unexpected seeded versions for did:char:b: %+v", versions)
+	}
+	if at, _ := store.GetBallotTime(4); at == nil || at.Bounded() {
+		t.Errorf("ballot time %+v not seeded from operations, without a lower bound", at)
+	}
+
+	// The seeding runs once; later state is never backfilled
+	if err := store.SaveDID(testDIDRecord("did:char:c", 5)); err != nil {
+		t.Fatalf("SaveDID failed: %v", err)
+	}
+	store.Close()
+
+	store, err = NewStore(path)
+	if err != nil {
+		t.Fatalf("reopening store failed: %v", err)
+	}
+	defer store.Close()
+
+	if versions, _ := store.GetDocumentVersions("did:char:c"); len(versions) != 0 {
+		t.Errorf("versions seeded again on reopen: %+v", versions)
+	}
+}
+
+// setUserVersion sets the schema version of the database at path
+func setUserVersion(t *testing.T, path string, version int) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		t.Fatalf("failed to set schema version: %v", err)
+	}
+}
+
+func TestOperationsKeyedByBallotAndIndex(t *testing.T) {
+	store := newTestStore(t)
+
+	for i, did := range []string{"did:char:a", "did:char:b"} {
+		if err := store.SaveOperation(&OperationRecord{DID: did, BallotNumber: 7, OperationIndex: i, OperationType: "create", OperationData: "{}"}); err != nil {
+			t.Fatalf("SaveOperation %d failed: %v", i, err)
+		}
+	}
+	if err := store.SaveOperation(&OperationRecord{DID: "did:char:c", BallotNumber: 7, OperationIndex: 1, OperationType: "create", OperationData: "{}"}); err == nil {
+		t.Error("expected duplicate (ballot, index) to fail")
+	}
+
+	ops, err := store.GetOperationsAtBallot(7)
+	if err != nil {
+		t.Fatalf("GetOperationsAtBallot failed: %v", err)
+	}
+	if len(ops) != 2 || ops[0].DID != "did:char:a" || ops[1].OperationIndex != 1 {
+		t.Errorf("unexpected operations: %+v", ops)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := store.SaveRejectedOperation(&RejectedOperationRecord{BallotNumber: 8, OperationIndex: i, Payload: "02", Reason: "bad"}); err != nil {
+			t.Fatalf("SaveRejectedOperation %d failed: %v", i, err)
+		}
+	}
+	rejected, err := store.GetRejectedOperationsAtBallot(8)
+	if err != nil {
+		t.Fatalf("GetRejectedOperationsAtBallot failed: %v", err)
+	}
+	if len(rejected) != 2 || rejected[1].OperationIndex != 1 {
+		t.Errorf("unexpected rejected operations: %+v", rejected)
+	}
+}
+
+func TestMigrationAddsDecidedAfter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+
+	// ballot_times as created before lower bounds were recorded
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE ballot_times (ballot_number INTEGER PRIMARY KEY, decided_at INTEGER NOT NULL);
+		INSERT INTO ballot_times (ballot_number, decided_at) VALUES (3, 1000);
+	`); err != nil {
+		t.Fatalf("failed to create legacy table: %v", err)
+	}
+	db.Close()
+
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+
+	if at, _ := store.GetBallotTime(3); at == nil || at.DecidedAt.UnixMilli() != 1000 || at.Bounded() {
+		t.Errorf("migrated ballot times = %+v", at)
+	}
+	if err := store.RecordBallotTime(4, time.UnixMilli(1500), time.UnixMilli(2000)); err != nil {
+		t.Fatalf("RecordBallotTime failed: %v", err)
+	}
+	if at, _ := store.GetBallotTime(4); at == nil || at.DecidedAfter.UnixMilli() != 1500 {
+		t.Errorf("ballot times recorded after migration = %+v", at)
+	}
+}
+
+func TestMigrationAddsOperationIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+
+	// Tables as created by releases with one operation per ballot
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE operations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			did TEXT NOT NULL,
+			ballot_number INTEGER NOT NULL,
+			operation_type TEXT NOT NULL,
+			operation_data TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(ballot_number)
+		);
+		CREATE TABLE rejected_operations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ballot_number INTEGER NOT NULL,
+			did TEXT NOT NULL DEFAULT '',
+			operation_type TEXT NOT NULL DEFAULT '',
+			payload TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(ballot_number)
+		);
+		INSERT INTO operations (did, ballot_number, operation_type, operation_data) VALUES ('did:char:a', 3, 'create', '{}');
+		INSERT INTO rejected_operations (ballot_number, payload, reason) VALUES (4, 'ff', 'failed to decode payload');
+	`); err != nil {
+		t.Fatalf("failed to create legacy tables: %v", err)
+	}
+	db.Close()
+
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer store.Close()
+
+	ops, err := store.GetOperations("did:char:a")
+	if err != nil {
+		t.Fatalf("GetOperations failed: %v", err)
+	}
+	if len(ops) != 1 || ops[0].BallotNumber != 3 || ops[0].OperationIndex != 0 {
+		t.Fatalf("unexpected migrated operations: %+v", ops)
+	}
+	if err := store.SaveOperation(&OperationRecord{DID: "did:char:b", BallotNumber: 3, OperationIndex: 1, OperationType: "create", OperationData: "{}"}); err != nil {
+		t.Errorf("second operation on a migrated ballot failed: %v", err)
+	}
+
+	if exists, _ := store.RejectedOperationExistsAtBallot(4); !exists {
+		t.Error("migrated rejection missing")
+	}
+	if err := store.SaveRejectedOperation(&RejectedOperationRecord{BallotNumber: 4, OperationIndex: 1, Payload: "ff", Reason: "other"}); err != nil {
+		t.Errorf("second rejection on a migrated ballot failed: %v", err)
+	}
+	if count, _ := store.GetRejectedOperationCount(); count != 2 {
+		t.Errorf("rejected count = %d, want 2", count)
+	}
+}
+
+func TestUnresolvableBatches(t *testing.T) {
+	store := newTestStore(t)
+
+	for _, r := range []*UnresolvableBatchRecord{
+		{BallotNumber: 9, ContentHash: "h9", OperationCount: 2, Payload: "03", Reason: "content not found"},
+		{BallotNumber: 4, ContentHash: "h4", OperationCount: 1, Payload: "03", Reason: "content not found"},
+		{BallotNumber: 9, ContentHash: "h9", OperationCount: 2, Payload: "03", Reason: "connection refused"},
+	} {
+		if err := store.SaveUnresolvableBatch(r); err != nil {
+			t.Fatalf("SaveUnresolvableBatch failed: %v", err)
+		}
+	}
+
+	records, err := store.GetUnresolvableBatches()
+	if err != nil {
+		t.Fatalf("GetUnresolvableBatches failed: %v", err)
+	}
+	if len(records) != 2 || records[0].BallotNumber != 4 || records[1].BallotNumber != 9 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	if r := records[1]; r.Attempts != 2 || r.Reason != "connection refused" || r.OperationCount != 2 {
+		t.Errorf("retried record = %+v", r)
+	}
+
+	if err := store.DeleteUnresolvableBatch(9); err != nil {
+		t.Fatalf("DeleteUnresolvableBatch failed: %v", err)
+	}
+	if exists, _ := store.UnresolvableBatchExistsAtBallot(9); exists {
+		t.Error("deleted batch still exists")
+	}
+	if exists, _ := store.UnresolvableBatchExistsAtBallot(4); !exists {
+		t.Error("remaining batch missing")
+	}
+}
+
+func TestBallotTimes(t *testing.T) {
+	store := newTestStore(t)
+
+	ref := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if ballot, err := store.GetLastBallotDecidedBy(ref); err != nil || ballot != -1 {
+		t.Fatalf("GetLastBallotDecidedBy on empty store = %d, %v; want -1", ballot, err)
+	}
+
+	// Ballot 3 was seen undecided a second before it was seen decided; ballot 7 never was
+	if err := store.RecordBallotTime(3, ref.Add(-time.Second), ref); err != nil {
+		t.Fatalf("RecordBallotTime failed: %v", err)
+	}
+	if err := store.RecordBallotTime(7, time.Time{}, ref.Add(time.Minute)); err != nil {
+		t.Fatalf("RecordBallotTime failed: %v", err)
+	}
+	// A later sighting does not move the recorded times
+	if err := store.RecordBallotTime(3, time.Time{}, ref.Add(time.Hour)); err != nil {
+		t.Fatalf("RecordBallotTime failed: %v", err)
+	}
+
+	if at, _ := store.GetBallotTime(3); at == nil || !at.DecidedAt.Equal(ref) || !at.DecidedAfter.Equal(ref.Add(-time.Second)) || !at.Bounded() {
+		t.Errorf("ballot 3 times = %+v, want decided between %v and %v", at, ref.Add(-time.Second), ref)
+	}
+	if at, _ := store.GetBallotTime(7); at == nil || at.Bounded() {
+		t.Errorf("ballot 7 times = %+v, want no lower bound", at)
+	}
+	if at, _ := store.GetBallotTime(5); at != nil {
+		t.Errorf("unrecorded ballot times = %+v, want nil", at)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want int
+	}{
+		{ref.Add(-time.Millisecond), -1},
+		{ref, 3},
+		{ref.Add(59 * time.Second), 3},
+		{ref.Add(time.Hour), 7},
+	}
+	for _, tt := range tests {
+		if got, err := store.GetLastBallotDecidedBy(tt.at); err != nil || got != tt.want {
+			t.Errorf("GetLastBallotDecidedBy(%v) = %d, %v; want %d", tt.at, got, err, tt.want)
+		}
+	}
+}
@@ -3,39 +3,77 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // Store manages the SQLite database
 type Store struct {
-	db *sql.DB
+	db     *sql.DB
+	readDB *sql.DB // Read-only handle for InReadTx snapshots
+	q      querier // db, or tx inside InTx/InReadTx
+	tx     *sql.Tx // set when the store is bound to a transaction
 }
 
 // NewStore creates a new storage instance
 func NewStore(dbPath string) (*Store, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	// Wait for locks instead of failing with SQLITE_BUSY, and take the write lock
+	// when a transaction begins so concurrent units of work cannot deadlock.
+	// WAL lets readers keep going while a writer commits.
+	db, err := openDB(dbPath, "_busy_timeout=5000&_txlock=immediate&_journal_mode=WAL")
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
-
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, err
 	}
 
-	store := &Store{db: db}
+	store := &Store{db: db, q: db}
 
 	// Run migrations
 	if err := store.migrate(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	// Readers use deferred transactions, which never take the write lock
+	readDB, err := openDB(dbPath, "_busy_timeout=5000&_txlock=deferred&_query_only=true")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	store.readDB = readDB
+
 	return store, nil
 }
 
+// openDB opens and pings a SQLite database with extra DSN parameters
+func openDB(dbPath, params string) (*sql.DB, error) {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite3", dbPath+sep+params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return db, nil
+}
+
 // Close closes the database connection
 func (s *Store) Close() error {
+	if s.tx != nil {
+		return fmt.Errorf("cannot close a store bound to a transaction")
+	}
+	if err := s.readDB.Close(); err != nil {
+		s.db.Close()
+		return err
+	}
 	return s.db.Close()
 }
 
@@ -54,19 +92,41 @@ func (s *Store) migrate() error {
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
 
-	CREATE TABLE IF NOT EXISTS operations (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
+	CREATE TABLE IF NOT EXISTS operations (` + operationsColumns + `);
+
+	CREATE INDEX IF NOT EXISTS idx_operations_ballot ON operations(ballot_number);
+	CREATE INDEX IF NOT EXISTS idx_operations_did ON operations(did);
+
+	CREATE TABLE IF NOT EXISTS document_versions (
 		did TEXT NOT NULL,
 		ballot_number INTEGER NOT NULL,
-		operation_type TEXT NOT NULL CHECK(operation_type IN ('create', 'update', 'recover', 'deactivate')),
-		operation_data TEXT NOT NULL,
+		operation_type TEXT NOT NULL,
+		status TEXT NOT NULL,
+		document TEXT NOT NULL,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (did) REFERENCES dids(did),
-		UNIQUE(ballot_number)
+		PRIMARY KEY (did, ballot_number)
 	);
 
-	CREATE INDEX IF NOT EXISTS idx_operations_ballot ON operations(ballot_number);
-	CREATE INDEX IF NOT EXISTS idx_operations_did ON operations(did);
+	CREATE TABLE IF NOT EXISTS rejected_operations (` + rejectedOperationsColumns + `);
+
+	CREATE INDEX IF NOT EXISTS idx_rejected_operations_did ON rejected_operations(did);
+
+	CREATE TABLE IF NOT EXISTS unresolvable_batches (
+		ballot_number INTEGER PRIMARY KEY,
+		content_hash TEXT NOT NULL,
+		operation_count INTEGER NOT NULL,
+		payload TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 1,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS ballot_times (
+		ballot_number INTEGER PRIMARY KEY,
+		decided_at INTEGER NOT NULL, -- Unix milliseconds when the ballot was first seen decided
+		decided_after INTEGER        -- Unix milliseconds when it or an earlier ballot was last seen undecided; NULL if unknown
+	);
 
 	CREATE TABLE IF NOT EXISTS sync_state (
 		key TEXT PRIMARY KEY,
@@ -75,13 +135,158 @@ func (s *Store) migrate() error {
 	);
 	`
 
-	_, err := s.db.Exec(schema)
-	return err
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Tables created when a ballot held a single operation are keyed by ballot alone
+	if err := s.rebuildWithOperationIndex("operations", operationsColumns,
+		"id, did, ballot_number, operation_type, operation_data, created_at",
+		"CREATE INDEX IF NOT EXISTS idx_operations_ballot ON operations(ballot_number)",
+		"CREATE INDEX IF NOT EXISTS idx_operations_did ON operations(did)",
+	); err != nil {
+		return err
+	}
+	if err := s.rebuildWithOperationIndex("rejected_operations", rejectedOperationsColumns,
+		"id, ballot_number, did, operation_type, payload, reason, created_at",
+		"CREATE INDEX IF NOT EXISTS idx_rejected_operations_did ON rejected_operations(did)",
+	); err != nil {
+		return err
+	}
+	if err := s.addColumnIfMissing("ballot_times", "decided_after", "INTEGER"); err != nil {
+		return err
+	}
+
+	return s.runDataMigrations()
+}
+
+// dataMigrations rewrite existing rows and must run once each, in order. SQLite's
+// user_version records how many have run.
+var dataMigrations = []string{
+	// 1: Databases created before versions were recorded start with the current state only
+	`INSERT OR IGNORE INTO document_versions (did, ballot_number, operation_type, status, document)
+		SELECT d.did, d.last_operation_ballot, COALESCE(o.operation_type, 'create'), d.status, d.document
+		FROM dids d LEFT JOIN operations o ON o.ballot_number = d.last_operation_ballot AND o.did = d.did`,
+
+	// 2: Ballots applied before their times were recorded were first seen when their operations were saved
+	`INSERT OR IGNORE INTO ballot_times (ballot_number, decided_at)
+		SELECT ballot_number, CAST(strftime('%s', MIN(created_at)) AS INTEGER) * 1000
+		FROM operations GROUP BY ballot_number`,
+}
+
+// runDataMigrations applies the data migrations the database has not run yet
+func (s *Store) runDataMigrations() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version >= len(dataMigrations) {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin data migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := version; i < len(dataMigrations); i++ {
+		if _, err := tx.Exec(dataMigrations[i]); err != nil {
+			return fmt.Errorf("failed to run data migration %d: %w", i+1, err)
+		}
+	}
+	// PRAGMA takes no bound parameters
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(dataMigrations))); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Column definitions of the tables keyed by (ballot_number, operation_index)
+const (
+	operationsColumns = `
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		did TEXT NOT NULL,
+		ballot_number INTEGER NOT NULL,
+		operation_index INTEGER NOT NULL DEFAULT 0,
+		operation_type TEXT NOT NULL CHECK(operation_type IN ('create', 'update', 'recover', 'deactivate')),
+		operation_data TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (did) REFERENCES dids(did),
+		UNIQUE(ballot_number, operation_index)
+	`
+
+	rejectedOperationsColumns = `
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ballot_number INTEGER NOT NULL,
+		operation_index INTEGER NOT NULL DEFAULT 0,
+		did TEXT NOT NULL DEFAULT '',
+		operation_type TEXT NOT NULL DEFAULT '',
+		payload TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(ballot_number, operation_index)
+	`
+)
+
+// rebuildWithOperationIndex recreates a table that has no operation_index column
+//
+// SQLite cannot change a table's constraints in place, so the rows are copied into
+// a table with the current columns (operation_index 0) and the old table is dropped.
+func (s *Store) rebuildWithOperationIndex(table, columns, copied string, indexes ...string) error {
+	var hasIndex bool
+	if err := s.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM pragma_table_info(?) WHERE name = 'operation_index')", table,
+	).Scan(&hasIndex); err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	if hasIndex {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration of %s: %w", table, err)
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE %s_new (%s)", table, columns),
+		fmt.Sprintf("INSERT INTO %s_new (%s) SELECT %s FROM %s", table, copied, copied, table),
+		fmt.Sprintf("DROP TABLE %s", table),
+		fmt.Sprintf("ALTER TABLE %s_new RENAME TO %s", table, table),
+	}
+	for _, stmt := range append(stmts, indexes...) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", table, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// addColumnIfMissing adds a nullable column to a table created before the column existed
+func (s *Store) addColumnIfMissing(table, column, definition string) error {
+	var exists bool
+	if err := s.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM pragma_table_info(?) WHERE name = ?)", table, column,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	if exists {
+		return nil
+	}
+
+	if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
+	return nil
 }
 
 // SetSyncState sets a sync state value
 func (s *Store) SetSyncState(key, value string) error {
-	_, err := s.db.Exec(`
+	_, err := s.q.Exec(`
 		INSERT INTO sync_state (key, value, updated_at)
 		VALUES (?, ?, CURRENT_TIMESTAMP)
 		ON CONFLICT(key) DO UPDATE SET
@@ -94,7 +299,7 @@ func (s *Store) SetSyncState(key, value string) error {
 // GetSyncState gets a sync state value
 func (s *Store) GetSyncState(key string) (string, error) {
 	var value string
-	err := s.db.QueryRow("SELECT value FROM sync_state WHERE key = ?", key).Scan(&value)
+	err := s.q.QueryRow("SELECT value FROM sync_state WHERE key = ?", key).Scan(&value)
 	if err == sql.ErrNoRows {
 		return "", nil
 	}
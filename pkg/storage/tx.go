@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// querier is the subset of *sql.DB and *sql.Tx used by Store methods
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// InTx runs fn as a single unit of work
+//
+// The Store passed to fn runs every read and write in one SQLite transaction,
+// which is committed if fn returns nil and rolled back otherwise. Calling InTx
+// on a Store that is already inside a transaction runs fn in that transaction.
+func (s *Store) InTx(fn func(tx *Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	sqlTx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	txStore := &Store{db: s.db, readDB: s.readDB, q: sqlTx, tx: sqlTx}
+	if err := fn(txStore); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// InReadTx runs fn against a consistent snapshot of the database
+//
+// The snapshot is taken without the write lock, so readers do not wait for or
+// block a concurrent InTx. The Store passed to fn is read-only. Calling InReadTx
+// on a Store that is already inside a transaction runs fn in that transaction.
+func (s *Store) InReadTx(fn func(tx *Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	sqlTx, err := s.readDB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin read transaction: %w", err)
+	}
+	defer sqlTx.Rollback()
+
+	return fn(&Store{db: s.db, readDB: s.readDB, q: sqlTx, tx: sqlTx})
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func testDIDRecord(did string, ballot int) *DIDRecord {
+	return &DIDRecord{
+		DID:                 did,
+		Status:              "active",
+		Document:            "{}",
+		CreatedAtBallot:     ballot,
+		LastOperationBallot: ballot,
+	}
+}
+
+func TestInTxCommit(t *testing.T) {
+	store := newTestStore(t)
+
+	err := store.InTx(func(tx *Store) error {
+		if err := tx.SaveDID(testDIDRecord("did:char:a", 1)); err != nil {
+			return err
+		}
+		if err := tx.SaveOperation(&OperationRecord{DID: "did:char:a", BallotNumber: 1, OperationType: "create", OperationData: "{}"}); err != nil {
+			return err
+		}
+		return tx.SetSyncState("last_synced_ballot", "1")
+	})
+	if err != nil {
+		t.Fatalf("InTx failed: %v", err)
+	}
+
+	if exists, _ := store.DIDExists("did:char:a"); !exists {
+		t.Error("DID not committed")
+	}
+	if exists, _ := store.OperationExistsAtBallot(1); !exists {
+		t.Error("operation not committed")
+	}
+	if value, _ := store.GetSyncState("last_synced_ballot"); value != "1" {
+		t.Errorf("sync state = %q, want 1", value)
+	}
+}
+
+func TestInTxRollback(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.SaveOperation(&OperationRecord{DID: "did:char:x", BallotNumber: 5, OperationType: "create", OperationData: "{}"}); err != nil {
+		t.Fatalf("SaveOperation failed: %v", err)
+	}
+
+	// The second insert violates UNIQUE(ballot_number); the DID and cursor must roll back with it
+	err := store.InTx(func(tx *Store) error {
+		if err := tx.SaveDID(testDIDRecord("did:char:b", 5)); err != nil {
+			return err
+		}
+		if err := tx.SetSyncState("last_synced_ballot", "5"); err != nil {
+			return err
+		}
+		return tx.SaveOperation(&OperationRecord{DID: "did:char:b", BallotNumber: 5, OperationType: "create", OperationData: "{}"})
+	})
+	if err == nil {
+		t.Fatal("expected InTx to fail")
+	}
+
+	if exists, _ := store.DIDExists("did:char:b"); exists {
+		t.Error("DID write was not rolled back")
+	}
+	if value, _ := store.GetSyncState("last_synced_ballot"); value != "" {
+		t.Errorf("sync state = %q, want unset", value)
+	}
+}
+
+func TestInTxNested(t *testing.T) {
+	store := newTestStore(t)
+	errBoom := errors.New("boom")
+
+	err := store.InTx(func(tx *Store) error {
+		if err := tx.InTx(func(inner *Store) error {
+			return inner.SetSyncState("k", "v")
+		}); err != nil {
+			return err
+		}
+		return errBoom
+	})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("InTx returned %v, want errBoom", err)
+	}
+
+	if value, _ := store.GetSyncState("k"); value != "" {
+		t.Errorf("nested write survived outer rollback: %q", value)
+	}
+}
+
+func TestCloseInsideTx(t *testing.T) {
+	store := newTestStore(t)
+	err := store.InTx(func(tx *Store) error {
+		return tx.Close()
+	})
+	if err == nil {
+		t.Error("expected Close inside a transaction to fail")
+	}
+}
+
+func TestInReadTxSnapshot(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.SaveDID(testDIDRecord("did:char:a", 1)); err != nil {
+		t.Fatalf("SaveDID failed: %v", err)
+	}
+
+	err := store.InReadTx(func(tx *Store) error {
+		before, err := tx.GetDIDCount("")
+		if err != nil {
+			return err
+		}
+
+		// A writer commits while the snapshot is open, without waiting for it
+		if err := store.InTx(func(w *Store) error {
+			return w.SaveDID(testDIDRecord("did:char:b", 2))
+		}); err != nil {
+			t.Fatalf("write during read transaction failed: %v", err)
+		}
+
+		after, err := tx.GetDIDCount("")
+		if err != nil {
+			return err
+		}
+		if before != 1 || after != 1 {
+			t.Errorf("snapshot changed: %d then %d DIDs", before, after)
+		}
+
+		// Nested calls reuse the snapshot, and it is read-only
+		return tx.InReadTx(func(nested *Store) error {
+			if err := nested.SaveDID(testDIDRecord("did:char:c", 3)); err == nil {
+				t.Error("expected write in read transaction to fail")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("InReadTx failed: %v", err)
+	}
+
+	if count, _ := store.GetDIDCount(""); count != 2 {
+		t.Errorf("expected 2 DIDs after snapshot closed, got %d", count)
+	}
+}